@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DriveHandler struct {
+	driveUseCase domain.DriveUseCase
+}
+
+func NewDriveHandler(driveUseCase domain.DriveUseCase) *DriveHandler {
+	return &DriveHandler{
+		driveUseCase: driveUseCase,
+	}
+}
+
+// ImportDrive handles POST /api/v1/notebooks/:id/drive/import
+func (h *DriveHandler) ImportDrive(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	job, err := h.driveUseCase.ImportDrive(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	respondJobAccepted(c, job)
+}
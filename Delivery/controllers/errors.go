@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondValidationError writes a 400 response listing the field errors if err
+// is a *domain.ValidationError, and reports whether it did.
+func respondValidationError(c *gin.Context, err error) bool {
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "validation failed",
+		"fields": validationErr.Fields,
+	})
+	return true
+}
+
+// respondListError writes errors of paginated list endpoints: bad cursors and
+// parameters are 400, anything else 500.
+func respondListError(c *gin.Context, err error) {
+	if errors.Is(err, domain.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if respondValidationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
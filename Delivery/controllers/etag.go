@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag formats a document version as an entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// notModified sets the ETag header for a document and, when the request's
+// If-None-Match already names that version, answers 304. It reports whether
+// the response has been written.
+func notModified(c *gin.Context, version int64) bool {
+	return notModifiedTag(c, etag(version))
+}
+
+// notModifiedTag is notModified for entity tags that are not versions
+func notModifiedTag(c *gin.Context, tag string) bool {
+	c.Header("ETag", tag)
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == tag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion reads the version a write is conditioned on. Writes without
+// If-Match are rejected with 428 and tags that cannot name a version with
+// 412. "If-Match: *" yields nil, which accepts any version.
+func ifMatchVersion(c *gin.Context) (*int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the notebook's ETag is required"})
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || !strings.HasPrefix(header, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current version"})
+		return nil, false
+	}
+	return &version, true
+}
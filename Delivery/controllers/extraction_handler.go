@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExtractionHandler struct {
+	extractionUseCase domain.ExtractionUseCase
+}
+
+func NewExtractionHandler(extractionUseCase domain.ExtractionUseCase) *ExtractionHandler {
+	return &ExtractionHandler{
+		extractionUseCase: extractionUseCase,
+	}
+}
+
+// GetSourceText handles GET /api/v1/notebooks/:id/sources/:source_id/text
+func (h *ExtractionHandler) GetSourceText(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	text, err := h.extractionUseCase.GetSourceText(userID.(string), c.Param("id"), c.Param("source_id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, text)
+}
+
+// RetryExtraction handles POST /api/v1/notebooks/:id/sources/:source_id/extract
+func (h *ExtractionHandler) RetryExtraction(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	source, err := h.extractionUseCase.RetryExtraction(userID.(string), c.Param("id"), c.Param("source_id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, source)
+}
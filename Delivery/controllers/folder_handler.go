@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FolderHandler struct {
+	folderUseCase domain.FolderUseCase
+}
+
+func NewFolderHandler(folderUseCase domain.FolderUseCase) *FolderHandler {
+	return &FolderHandler{
+		folderUseCase: folderUseCase,
+	}
+}
+
+// CreateFolder handles POST /api/v1/folders
+func (h *FolderHandler) CreateFolder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Name     string  `json:"name" binding:"required"`
+		ParentID *string `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderUseCase.CreateFolder(userID.(string), request.Name, request.ParentID)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// ListFolders handles GET /api/v1/folders
+func (h *FolderHandler) ListFolders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	folders, err := h.folderUseCase.ListFolders(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folders)
+}
+
+// RenameFolder handles PUT /api/v1/folders/:id
+func (h *FolderHandler) RenameFolder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderUseCase.RenameFolder(userID.(string), c.Param("id"), request.Name)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// MoveFolder handles POST /api/v1/folders/:id/move
+func (h *FolderHandler) MoveFolder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	// A null or missing parent_id moves the folder to the top level
+	var request struct {
+		ParentID *string `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderUseCase.MoveFolder(userID.(string), c.Param("id"), request.ParentID)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder handles DELETE /api/v1/folders/:id
+func (h *FolderHandler) DeleteFolder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := h.folderUseCase.DeleteFolder(userID.(string), c.Param("id")); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
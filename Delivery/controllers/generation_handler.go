@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenerationHandler struct {
+	generationUseCase domain.GenerationUseCase
+}
+
+func NewGenerationHandler(generationUseCase domain.GenerationUseCase) *GenerationHandler {
+	return &GenerationHandler{
+		generationUseCase: generationUseCase,
+	}
+}
+
+// GenerateSnapnotes handles POST /api/v1/notebooks/:id/snapnotes/generate
+func (h *GenerationHandler) GenerateSnapnotes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	job, err := h.generationUseCase.GenerateSnapnotes(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	respondJobAccepted(c, job)
+}
+
+// GeneratePrepPilot handles POST /api/v1/notebooks/:id/prep-pilot/generate
+func (h *GenerationHandler) GeneratePrepPilot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	job, err := h.generationUseCase.GeneratePrepPilot(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	respondJobAccepted(c, job)
+}
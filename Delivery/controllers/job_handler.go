@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobUseCase domain.JobUseCase
+}
+
+func NewJobHandler(jobUseCase domain.JobUseCase) *JobHandler {
+	return &JobHandler{
+		jobUseCase: jobUseCase,
+	}
+}
+
+// GetJob handles GET /api/v1/jobs/:id
+func (h *JobHandler) GetJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	job, err := h.jobUseCase.GetJob(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob handles POST /api/v1/jobs/:id/retry
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	job, err := h.jobUseCase.RetryJob(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	respondJobAccepted(c, job)
+}
+
+// ListNotebookJobs handles GET /api/v1/notebooks/:id/jobs
+func (h *JobHandler) ListNotebookJobs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request domain.JobListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, err := h.jobUseCase.ListNotebookJobs(userID.(string), c.Param("id"), request)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// respondJobAccepted answers a request whose work was queued with the job
+// and where to follow it
+func respondJobAccepted(c *gin.Context, job *domain.Job) {
+	c.Header("Location", "/api/v1/jobs/"+job.ID.Hex())
+	c.JSON(http.StatusAccepted, job)
+}
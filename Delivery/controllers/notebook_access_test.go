@@ -0,0 +1,302 @@
+package controllers_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cognivia-api/Delivery/controllers"
+	"cognivia-api/Delivery/routers"
+	domain "cognivia-api/Domain"
+	usecase "cognivia-api/Usecase"
+	"cognivia-api/infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The use cases only get the repositories their access checks read. A request
+// that gets past the checks of its route reaches a nil repository and fails
+// with 500 instead of the expected 403 or 404.
+
+type notebookRepository struct {
+	domain.NotebookRepository
+	notebook domain.Notebook
+}
+
+func (r *notebookRepository) GetByID(id primitive.ObjectID) (*domain.Notebook, error) {
+	if id != r.notebook.ID {
+		return nil, nil
+	}
+	notebook := r.notebook
+	return &notebook, nil
+}
+
+type shareRepository struct {
+	domain.NotebookShareRepository
+	mu     sync.Mutex
+	shares map[primitive.ObjectID]domain.NotebookShare
+}
+
+func (r *shareRepository) GetByID(id primitive.ObjectID) (*domain.NotebookShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	share, ok := r.shares[id]
+	if !ok {
+		return nil, nil
+	}
+	return &share, nil
+}
+
+func (r *shareRepository) FindByNotebookAndUser(notebookID, userID primitive.ObjectID) (*domain.NotebookShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, share := range r.shares {
+		if share.NotebookID == notebookID && share.UserID != nil && *share.UserID == userID {
+			return &share, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *shareRepository) FindByNotebookAndEmail(notebookID primitive.ObjectID, email string) (*domain.NotebookShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, share := range r.shares {
+		if share.NotebookID == notebookID && share.Email == email {
+			return &share, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *shareRepository) Delete(id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.shares, id)
+	return nil
+}
+
+type userRepository struct {
+	domain.UserRepository
+	users map[string]*domain.User
+}
+
+func (r *userRepository) FindByID(id string) (*domain.User, error) {
+	return r.users[id], nil
+}
+
+type accessFixture struct {
+	router   *gin.Engine
+	notebook primitive.ObjectID
+	shares   *shareRepository
+
+	owner, viewer, revoked, stranger primitive.ObjectID
+	viewerShare, revokedShare        primitive.ObjectID
+}
+
+func newAccessFixture(t *testing.T) *accessFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	f := &accessFixture{
+		notebook: primitive.NewObjectID(),
+		owner:    primitive.NewObjectID(),
+		viewer:   primitive.NewObjectID(),
+		revoked:  primitive.NewObjectID(),
+		stranger: primitive.NewObjectID(),
+	}
+	users := &userRepository{users: map[string]*domain.User{}}
+	for _, id := range []primitive.ObjectID{f.owner, f.viewer, f.revoked, f.stranger} {
+		users.users[id.Hex()] = &domain.User{ID: id, Email: id.Hex() + "@example.com", EmailVerified: true}
+	}
+	notebookRepo := &notebookRepository{notebook: domain.Notebook{
+		ID: f.notebook, UserID: f.owner, Name: "Biology", Version: 3,
+		GoogleDriveLink: stringPointer("https://drive.google.com/file/d/1AbCdEfGhIjKlMnOp/view"),
+	}}
+	f.viewerShare, f.revokedShare = primitive.NewObjectID(), primitive.NewObjectID()
+	f.shares = &shareRepository{shares: map[primitive.ObjectID]domain.NotebookShare{
+		f.viewerShare: {
+			ID: f.viewerShare, NotebookID: f.notebook, OwnerID: f.owner, UserID: &f.viewer,
+			Email: f.viewer.Hex() + "@example.com", Role: domain.ShareRoleViewer,
+		},
+		f.revokedShare: {
+			ID: f.revokedShare, NotebookID: f.notebook, OwnerID: f.owner, UserID: &f.revoked,
+			Email: f.revoked.Hex() + "@example.com", Role: domain.ShareRoleEditor,
+		},
+	}}
+
+	notebooks := usecase.NewNotebookUseCase(notebookRepo, nil, nil, f.shares, nil, nil, nil, users, nil)
+	jobs := usecase.NewJobUseCase(nil, notebooks)
+	sources := usecase.NewSourceUseCase(nil, nil, notebookRepo, notebooks, nil, jobs)
+
+	authMiddleware := func(c *gin.Context) {
+		c.Set("auth_method", infrastructure.AuthMethodJWT)
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+	}
+	f.router = routers.SetupRouter(
+		authMiddleware, nil, nil,
+		controllers.NewNotebookHandler(notebooks),
+		controllers.NewShareLinkHandler(usecase.NewShareLinkUseCase(notebookRepo, nil, nil)),
+		nil, nil,
+		controllers.NewTrashHandler(usecase.NewTrashUseCase(notebookRepo, nil, nil, nil, jobs)),
+		controllers.NewRevisionHandler(usecase.NewRevisionUseCase(nil, notebooks, nil, nil)),
+		controllers.NewNotebookCopyHandler(usecase.NewNotebookCopyUseCase(notebookRepo, nil, nil, notebooks, nil, nil)),
+		controllers.NewSourceHandler(sources),
+		controllers.NewExtractionHandler(usecase.NewExtractionUseCase(nil, nil, notebookRepo, notebooks, nil, nil, jobs)),
+		controllers.NewDriveHandler(usecase.NewDriveUseCase(notebookRepo, nil, notebooks, sources, nil, jobs)),
+		controllers.NewGenerationHandler(usecase.NewGenerationUseCase(nil, notebooks, nil, nil, nil, jobs)),
+		controllers.NewJobHandler(jobs),
+		nil, nil,
+	)
+
+	// The owner revokes the editor's share through the API
+	if code := f.do(f.owner, http.MethodDelete, fmt.Sprintf("/api/v1/notebooks/%s/shares/%s", f.notebook.Hex(), f.revokedShare.Hex())); code != http.StatusOK && code != http.StatusNoContent {
+		t.Fatalf("revoking the share: got %d", code)
+	}
+	return f
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+// do sends a request with a body that passes the handler's own checks, so
+// the response shows what the use case decided
+func (f *accessFixture) do(user primitive.ObjectID, method, path string) int {
+	var body bytes.Buffer
+	contentType := "application/json"
+	if method == http.MethodPost && strings.HasSuffix(path, "/sources") {
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "notes.txt")
+		part.Write([]byte("Cells are the basic unit of life."))
+		form.Close()
+		contentType = form.FormDataContentType()
+	} else {
+		body.WriteString(`{"name":"Renamed","email":"someone@example.com","role":"editor"}`)
+	}
+
+	request := httptest.NewRequest(method, path, &body)
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("If-Match", "*")
+	request.Header.Set("X-Test-User", user.Hex())
+	response := httptest.NewRecorder()
+	f.router.ServeHTTP(response, request)
+	return response.Code
+}
+
+type notebookRoute struct {
+	method, template, path string
+}
+
+func (r notebookRoute) String() string {
+	return r.method + " " + r.template
+}
+
+// notebookRoutes returns the routes of a single notebook with their
+// parameters filled in
+func (f *accessFixture) notebookRoutes() []notebookRoute {
+	const prefix = "/api/v1/notebooks/:id"
+	routes := []notebookRoute{}
+	for _, route := range f.router.Routes() {
+		if route.Path != prefix && !strings.HasPrefix(route.Path, prefix+"/") {
+			continue
+		}
+		path := strings.Replace(route.Path, ":id", f.notebook.Hex(), 1)
+		path = strings.Replace(path, ":share_id", f.viewerShare.Hex(), 1)
+		for _, param := range []string{":source_id", ":revision_id", ":link_id"} {
+			path = strings.Replace(path, param, primitive.NewObjectID().Hex(), 1)
+		}
+		if strings.HasSuffix(path, "/diff") {
+			path += "?from=" + primitive.NewObjectID().Hex() + "&to=" + primitive.NewObjectID().Hex()
+		}
+		routes = append(routes, notebookRoute{method: route.Method, template: route.Path, path: path})
+	}
+	return routes
+}
+
+func TestNotebookRoutesHideNotebookFromOutsiders(t *testing.T) {
+	f := newAccessFixture(t)
+	routes := f.notebookRoutes()
+	if len(routes) < 30 {
+		t.Fatalf("found only %d notebook routes", len(routes))
+	}
+
+	for _, route := range routes {
+		for name, user := range map[string]primitive.ObjectID{"non-owner": f.stranger, "revoked sharee": f.revoked} {
+			if code := f.do(user, route.method, route.path); code != http.StatusNotFound {
+				t.Errorf("%s by %s: got %d, want 404", route, name, code)
+			}
+		}
+	}
+}
+
+// viewerStatus is what a viewer gets from routes other than refused
+// changes (403) and allowed reads. Trash routes and share links only resolve
+// the owner's notebooks.
+var viewerStatus = map[string]int{
+	"POST /api/v1/notebooks/:id/duplicate":          http.StatusOK,
+	"POST /api/v1/notebooks/:id/restore":            http.StatusNotFound,
+	"DELETE /api/v1/notebooks/:id/permanent":        http.StatusNotFound,
+	"GET /api/v1/notebooks/:id/shares":              http.StatusForbidden,
+	"GET /api/v1/notebooks/:id/links":               http.StatusNotFound,
+	"POST /api/v1/notebooks/:id/links":              http.StatusNotFound,
+	"DELETE /api/v1/notebooks/:id/links/:link_id":   http.StatusNotFound,
+	"DELETE /api/v1/notebooks/:id/shares/:share_id": http.StatusOK,
+}
+
+func TestNotebookRoutesRefuseViewerChanges(t *testing.T) {
+	f := newAccessFixture(t)
+
+	for _, route := range f.notebookRoutes() {
+		want, ok := viewerStatus[route.String()]
+		switch {
+		case !ok && route.method == http.MethodGet:
+			continue
+		case !ok:
+			want = http.StatusForbidden
+		case want == http.StatusOK:
+			// Allowed, covered by the use case tests
+			continue
+		}
+		if code := f.do(f.viewer, route.method, route.path); code != want {
+			t.Errorf("%s by viewer: got %d, want %d", route, code, want)
+		}
+	}
+}
+
+func TestViewerCanLeaveButNotRevokeOthers(t *testing.T) {
+	f := newAccessFixture(t)
+	other := primitive.NewObjectID()
+	f.shares.shares[other] = domain.NotebookShare{ID: other, NotebookID: f.notebook, UserID: &f.stranger, Role: domain.ShareRoleViewer}
+
+	path := fmt.Sprintf("/api/v1/notebooks/%s/shares/", f.notebook.Hex())
+	if code := f.do(f.viewer, http.MethodDelete, path+other.Hex()); code != http.StatusForbidden {
+		t.Errorf("viewer revoked another share: got %d", code)
+	}
+	if code := f.do(f.viewer, http.MethodDelete, path+f.viewerShare.Hex()); code != http.StatusOK && code != http.StatusNoContent {
+		t.Fatalf("viewer could not leave: got %d", code)
+	}
+	if code := f.do(f.viewer, http.MethodGet, fmt.Sprintf("/api/v1/notebooks/%s", f.notebook.Hex())); code != http.StatusNotFound {
+		t.Errorf("viewer kept access after leaving: got %d", code)
+	}
+}
+
+func TestViewerUpdateWithStaleETagIsForbidden(t *testing.T) {
+	f := newAccessFixture(t)
+
+	request := httptest.NewRequest(http.MethodPut, "/api/v1/notebooks/"+f.notebook.Hex(), strings.NewReader(`{"name":"Renamed"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("If-Match", `"1"`)
+	request.Header.Set("X-Test-User", f.viewer.Hex())
+	response := httptest.NewRecorder()
+	f.router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("got %d, want 403", response.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotebookCopyHandler struct {
+	notebookCopyUseCase domain.NotebookCopyUseCase
+}
+
+func NewNotebookCopyHandler(notebookCopyUseCase domain.NotebookCopyUseCase) *NotebookCopyHandler {
+	return &NotebookCopyHandler{
+		notebookCopyUseCase: notebookCopyUseCase,
+	}
+}
+
+// DuplicateNotebook handles POST /api/v1/notebooks/:id/duplicate
+func (h *NotebookCopyHandler) DuplicateNotebook(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	// The body is optional
+	var request domain.DuplicateRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notebook, err := h.notebookCopyUseCase.DuplicateNotebook(userID.(string), c.Param("id"), request)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", etag(notebook.Version))
+	c.JSON(http.StatusCreated, notebook)
+}
+
+// GetOriginStatus handles GET /api/v1/notebooks/:id/origin
+func (h *NotebookCopyHandler) GetOriginStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	status, err := h.notebookCopyUseCase.GetOriginStatus(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SyncFromOrigin handles POST /api/v1/notebooks/:id/sync
+func (h *NotebookCopyHandler) SyncFromOrigin(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	notebook, err := h.notebookCopyUseCase.SyncFromOrigin(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", etag(notebook.Version))
+	c.JSON(http.StatusOK, notebook)
+}
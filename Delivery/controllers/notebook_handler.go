@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	domain "cognivia-api/Domain"
@@ -26,20 +27,21 @@ func (h *NotebookHandler) CreateNotebook(c *gin.Context) {
 		return
 	}
 
-	var notebook domain.Notebook
-	if err := c.ShouldBindJSON(&notebook); err != nil {
+	var request domain.CreateNotebookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Pass userID to the use case
-	if err := h.notebookUseCase.CreateNotebook(userID.(string), &notebook); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.notebookUseCase.CreateNotebook(userID.(string), &request); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
 		return
 	}
 
 	// Return the created notebook details
-	c.JSON(http.StatusCreated, notebook)
+	c.Header("ETag", etag(request.Version))
+	c.JSON(http.StatusCreated, request)
 }
 
 func (h *NotebookHandler) GetNotebook(c *gin.Context) {
@@ -54,7 +56,7 @@ func (h *NotebookHandler) GetNotebook(c *gin.Context) {
 
 	notebook, err := h.notebookUseCase.GetNotebookByID(userID.(string), notebookID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondNotebookError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -62,6 +64,9 @@ func (h *NotebookHandler) GetNotebook(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found"})
 		return
 	}
+	if notModified(c, notebook.Version) {
+		return
+	}
 
 	c.JSON(http.StatusOK, notebook)
 }
@@ -74,16 +79,46 @@ func (h *NotebookHandler) GetNotebooksByUserID(c *gin.Context) {
 		return
 	}
 
-	notebooks, err := h.notebookUseCase.GetNotebooksByUserID(userID.(string))
+	var request domain.NotebookListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notebooks, err := h.notebookUseCase.ListNotebooks(userID.(string), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondNotebookError(c, err, http.StatusInternalServerError)
 		return
 	}
 
 	c.JSON(http.StatusOK, notebooks)
 }
 
+// GetTags returns the distinct tags used on the caller's notebooks
+func (h *NotebookHandler) GetTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	tags, err := h.notebookUseCase.GetTags(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
 func (h *NotebookHandler) UpdateNotebook(c *gin.Context) {
+	// Extract user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
 	notebookID := c.Param("id")
 
 	updateReq := domain.UpdateRequest{}
@@ -93,11 +128,18 @@ func (h *NotebookHandler) UpdateNotebook(c *gin.Context) {
 		return
 	}
 
-	if err := h.notebookUseCase.UpdateNotebook(notebookID, updateReq); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
+	notebook, err := h.notebookUseCase.UpdateNotebook(userID.(string), notebookID, updateReq, expectedVersion)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", etag(notebook.Version))
 	c.JSON(http.StatusOK, gin.H{"message": "Notebook updated successfully"})
 }
 
@@ -111,8 +153,13 @@ func (h *NotebookHandler) DeleteNotebook(c *gin.Context) {
 
 	notebookID := c.Param("id")
 
-	if err := h.notebookUseCase.DeleteNotebook(userID.(string), notebookID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.notebookUseCase.DeleteNotebook(userID.(string), notebookID, expectedVersion); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -135,7 +182,10 @@ func (h *NotebookHandler) GetSnapnotes(c *gin.Context) {
 
 	snapnotes, err := h.notebookUseCase.GetSnapnotes(userID.(string), notebookID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondNotebookError(c, err, http.StatusNotFound)
+		return
+	}
+	if snapnotes != nil && notModified(c, snapnotes.Version) {
 		return
 	}
 
@@ -158,11 +208,141 @@ func (h *NotebookHandler) GetPrepPilot(c *gin.Context) {
 
 	prepPilot, err := h.notebookUseCase.GetPrepPilot(userID.(string), notebookID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondNotebookError(c, err, http.StatusNotFound)
+		return
+	}
+	if prepPilot != nil && notModified(c, prepPilot.Version) {
 		return
 	}
 
 	c.JSON(http.StatusOK, prepPilot)
 }
 
+// GetSharedNotebooks returns the notebooks other users shared with the caller
+func (h *NotebookHandler) GetSharedNotebooks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	notebooks, err := h.notebookUseCase.GetSharedNotebooks(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notebooks)
+}
+
+// ShareNotebook handles POST /api/v1/notebooks/:id/shares
+func (h *NotebookHandler) ShareNotebook(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := h.notebookUseCase.ShareNotebook(userID.(string), c.Param("id"), request.Email, request.Role)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// ListShares handles GET /api/v1/notebooks/:id/shares
+func (h *NotebookHandler) ListShares(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	shares, err := h.notebookUseCase.ListShares(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// UpdateShare handles PUT /api/v1/notebooks/:id/shares/:share_id
+func (h *NotebookHandler) UpdateShare(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := h.notebookUseCase.UpdateShare(userID.(string), c.Param("id"), c.Param("share_id"), request.Role)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// RevokeShare handles DELETE /api/v1/notebooks/:id/shares/:share_id
+func (h *NotebookHandler) RevokeShare(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := h.notebookUseCase.RevokeShare(userID.(string), c.Param("id"), c.Param("share_id")); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share removed successfully"})
+}
+
+// respondNotebookError maps notebook access errors to status codes. Other
+// errors are returned with the fallback status.
+func respondNotebookError(c *gin.Context, err error, fallback int) {
+	switch {
+	case errors.Is(err, domain.ErrNotebookNotFound), errors.Is(err, domain.ErrShareNotFound),
+		errors.Is(err, domain.ErrShareLinkInvalid), errors.Is(err, domain.ErrFolderNotFound),
+		errors.Is(err, domain.ErrRevisionNotFound), errors.Is(err, domain.ErrOriginNotFound),
+		errors.Is(err, domain.ErrSourceNotFound), errors.Is(err, domain.ErrSourceTextNotFound),
+		errors.Is(err, domain.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrContentGeneration):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		if respondValidationError(c, err) {
+			return
+		}
+		c.JSON(fallback, gin.H{"error": err.Error()})
+	}
+}
+
 // Add other handler methods as needed
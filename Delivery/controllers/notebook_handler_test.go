@@ -0,0 +1,67 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cognivia-api/Delivery/controllers"
+	domain "cognivia-api/Domain"
+	usecase "cognivia-api/Usecase"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type folderRepository struct {
+	domain.FolderRepository
+	folders map[primitive.ObjectID]*domain.Folder
+}
+
+func (r *folderRepository) GetByID(id primitive.ObjectID) (*domain.Folder, error) {
+	return r.folders[id], nil
+}
+
+func TestCreateNotebookRejectsInvalidFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user, other := primitive.NewObjectID(), primitive.NewObjectID()
+	othersFolder := primitive.NewObjectID()
+	folders := &folderRepository{folders: map[primitive.ObjectID]*domain.Folder{
+		othersFolder: {ID: othersFolder, UserID: other, Name: "Chemistry"},
+	}}
+
+	var tooManyTags []string
+	for i := range 21 {
+		tooManyTags = append(tooManyTags, fmt.Sprintf("topic %d", i))
+	}
+
+	// Requests that pass the checks reach the nil unit of work and fail
+	handler := controllers.NewNotebookHandler(usecase.NewNotebookUseCase(nil, nil, nil, nil, folders, nil, nil, nil, nil))
+	router := gin.New()
+	router.POST("/notebooks", func(c *gin.Context) { c.Set("user_id", user.Hex()) }, handler.CreateNotebook)
+
+	for _, tc := range []struct {
+		name, body, field, code string
+	}{
+		{"unknown folder", `{"name":"Biology","folder_id":"` + primitive.NewObjectID().Hex() + `"}`, "folder_id", "not_found"},
+		{"folder of another user", `{"name":"Biology","folder_id":"` + othersFolder.Hex() + `"}`, "folder_id", "not_found"},
+		{"long tag", `{"name":"Biology","tags":["` + strings.Repeat("x", 33) + `"]}`, "tags", "too_long"},
+		{"too many tags", `{"name":"Biology","tags":["` + strings.Join(tooManyTags, `","`) + `"]}`, "tags", "too_many"},
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/notebooks", strings.NewReader(tc.body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		var body struct {
+			Fields []domain.FieldError `json:"fields"`
+		}
+		json.Unmarshal(response.Body.Bytes(), &body)
+		if response.Code != http.StatusBadRequest || len(body.Fields) != 1 || body.Fields[0].Field != tc.field || body.Fields[0].Code != tc.code {
+			t.Errorf("%s: got %d %s", tc.name, response.Code, response.Body)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oidcFlowCookie = "oidc_flow"
+
+type OIDCHandler struct {
+	oidcUseCase domain.OIDCUseCase
+	userUseCase domain.UserUseCase
+}
+
+func NewOIDCHandler(oidcUseCase domain.OIDCUseCase, userUseCase domain.UserUseCase) *OIDCHandler {
+	return &OIDCHandler{
+		oidcUseCase: oidcUseCase,
+		userUseCase: userUseCase,
+	}
+}
+
+// ListProviders handles GET /api/v1/auth/oidc/providers
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oidcUseCase.Providers()})
+}
+
+// Login handles GET /api/v1/auth/oidc/:provider/login
+func (h *OIDCHandler) Login(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, flowToken, err := h.oidcUseCase.BeginLogin(c.Request.Context(), provider)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting %s sign-in: %v", provider, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to contact sign-in provider"})
+		return
+	}
+
+	// Lax so the cookie survives the top-level redirect back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, flowToken, 600, "/api/v1/auth/oidc", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback handles GET /api/v1/auth/oidc/:provider/callback
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was cancelled or denied: " + providerError})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	flowToken, err := c.Cookie(oidcFlowCookie)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-in session expired, please start again"})
+		return
+	}
+	// The flow token is single use
+	c.SetCookie(oidcFlowCookie, "", -1, "/api/v1/auth/oidc", "", c.Request.TLS != nil, true)
+
+	user, err := h.oidcUseCase.CompleteLogin(c.Request.Context(), provider, state, code, flowToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sign-in state, please start again"})
+		default:
+			log.Printf("Error completing %s sign-in: %v", provider, err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if err := h.userUseCase.CheckLoginAllowed(user); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Social sign-in replaces the password step only
+	if user.TwoFactor.Enabled {
+		challenge, err := h.userUseCase.GenerateTwoFactorChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	token, err := h.userUseCase.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
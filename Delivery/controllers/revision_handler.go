@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RevisionHandler struct {
+	revisionUseCase domain.RevisionUseCase
+}
+
+func NewRevisionHandler(revisionUseCase domain.RevisionUseCase) *RevisionHandler {
+	return &RevisionHandler{
+		revisionUseCase: revisionUseCase,
+	}
+}
+
+// ListRevisions handles GET /api/v1/notebooks/:id/revisions
+func (h *RevisionHandler) ListRevisions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request domain.RevisionListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revisions, err := h.revisionUseCase.ListRevisions(userID.(string), c.Param("id"), request)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision handles GET /api/v1/notebooks/:id/revisions/:revision_id
+func (h *RevisionHandler) GetRevision(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	revision, err := h.revisionUseCase.GetRevision(userID.(string), c.Param("id"), c.Param("revision_id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffRevisions handles GET /api/v1/notebooks/:id/revisions/diff?from=&to=
+func (h *RevisionHandler) DiffRevisions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to revision IDs are required"})
+		return
+	}
+
+	diff, err := h.revisionUseCase.DiffRevisions(userID.(string), c.Param("id"), from, to)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreRevision handles POST /api/v1/notebooks/:id/revisions/:revision_id/restore
+func (h *RevisionHandler) RestoreRevision(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	revision, err := h.revisionUseCase.RestoreRevision(userID.(string), c.Param("id"), c.Param("revision_id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
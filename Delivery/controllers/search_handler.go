@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchUseCase domain.SearchUseCase
+}
+
+func NewSearchHandler(searchUseCase domain.SearchUseCase) *SearchHandler {
+	return &SearchHandler{
+		searchUseCase: searchUseCase,
+	}
+}
+
+// Search handles GET /api/v1/search?q=...&kind=...&limit=...
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Query string   `form:"q"`
+		Kinds []string `form:"kind"`
+		Limit int      `form:"limit"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hits, err := h.searchUseCase.Search(userID.(string), request.Query, request.Kinds, request.Limit)
+	if err != nil {
+		if respondValidationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": hits})
+}
+
+// Reindex handles POST /api/v1/search/reindex
+func (h *SearchHandler) Reindex(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	count, err := h.searchUseCase.ReindexUser(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Notebooks reindexed",
+		"notebooks": count,
+	})
+}
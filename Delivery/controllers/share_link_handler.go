@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareLinkHandler struct {
+	shareLinkUseCase domain.ShareLinkUseCase
+}
+
+func NewShareLinkHandler(shareLinkUseCase domain.ShareLinkUseCase) *ShareLinkHandler {
+	return &ShareLinkHandler{
+		shareLinkUseCase: shareLinkUseCase,
+	}
+}
+
+// CreateShareLink handles POST /api/v1/notebooks/:id/links
+func (h *ShareLinkHandler) CreateShareLink(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	// The body is optional, links without expires_at never expire
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	link, token, err := h.shareLinkUseCase.CreateShareLink(userID.(string), c.Param("id"), request.ExpiresAt)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Share link created. Store the token now, it will not be shown again",
+		"token":   token,
+		"link":    link,
+	})
+}
+
+// ListShareLinks handles GET /api/v1/notebooks/:id/links
+func (h *ShareLinkHandler) ListShareLinks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	links, err := h.shareLinkUseCase.ListShareLinks(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// RevokeShareLink handles DELETE /api/v1/notebooks/:id/links/:link_id
+func (h *ShareLinkHandler) RevokeShareLink(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := h.shareLinkUseCase.RevokeShareLink(userID.(string), c.Param("id"), c.Param("link_id")); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// GetPublicNotebook handles GET /api/v1/public/notebooks/:token
+func (h *ShareLinkHandler) GetPublicNotebook(c *gin.Context) {
+	notebook, err := h.shareLinkUseCase.GetPublicNotebook(c.Param("token"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, notebook)
+}
+
+// GetPublicSnapnotes handles GET /api/v1/public/notebooks/:token/snapnotes
+func (h *ShareLinkHandler) GetPublicSnapnotes(c *gin.Context) {
+	snapnotes, err := h.shareLinkUseCase.GetPublicSnapnotes(c.Param("token"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapnotes)
+}
+
+// GetPracticePrepPilot handles GET /api/v1/public/notebooks/:token/prep-pilot
+func (h *ShareLinkHandler) GetPracticePrepPilot(c *gin.Context) {
+	practice, err := h.shareLinkUseCase.GetPracticePrepPilot(c.Param("token"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, practice)
+}
+
+// SubmitPracticeQuiz handles POST /api/v1/public/notebooks/:token/prep-pilot/submit
+func (h *ShareLinkHandler) SubmitPracticeQuiz(c *gin.Context) {
+	var request struct {
+		Answers []domain.PracticeAnswer `json:"answers" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.shareLinkUseCase.SubmitPracticeQuiz(c.Param("token"), request.Answers)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
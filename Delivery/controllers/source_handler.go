@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead allows for the form boundaries and part headers around
+// the uploaded file
+const multipartOverhead = 1 << 20
+
+type SourceHandler struct {
+	sourceUseCase domain.SourceUseCase
+}
+
+func NewSourceHandler(sourceUseCase domain.SourceUseCase) *SourceHandler {
+	return &SourceHandler{
+		sourceUseCase: sourceUseCase,
+	}
+}
+
+// UploadSource handles POST /api/v1/notebooks/:id/sources with a multipart
+// form carrying the file in the "file" field
+func (h *SourceHandler) UploadSource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.sourceUseCase.MaxSourceSize()+multipartOverhead)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a file is required in the \"file\" form field"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	source, err := h.sourceUseCase.UploadSource(userID.(string), c.Param("id"), domain.SourceUpload{
+		FileName: fileHeader.Filename,
+		Content:  file,
+	})
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+// ListSources handles GET /api/v1/notebooks/:id/sources
+func (h *SourceHandler) ListSources(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	sources, err := h.sourceUseCase.ListSources(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+// DownloadSource handles GET /api/v1/notebooks/:id/sources/:source_id/content
+func (h *SourceHandler) DownloadSource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	source, content, err := h.sourceUseCase.OpenSource(userID.(string), c.Param("id"), c.Param("source_id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// The content never changes, so its checksum is a strong ETag
+	if notModifiedTag(c, `"`+source.Checksum+`"`) {
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, source.Size, source.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": source.FileName}),
+	})
+}
+
+// DeleteSource handles DELETE /api/v1/notebooks/:id/sources/:source_id
+func (h *SourceHandler) DeleteSource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := h.sourceUseCase.DeleteSource(userID.(string), c.Param("id"), c.Param("source_id")); err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Source deleted successfully"})
+}
@@ -107,9 +107,15 @@ func (h *TestResultHandler) GetUserTestResults(c *gin.Context) {
 		return
 	}
 
-	testResults, err := h.testResultUseCase.GetUserTestResults(userID.(string))
+	var request domain.TestResultListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	testResults, err := h.testResultUseCase.GetUserTestResults(userID.(string), request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondListError(c, err)
 		return
 	}
 
@@ -131,9 +137,15 @@ func (h *TestResultHandler) GetNotebookTestResults(c *gin.Context) {
 		return
 	}
 
-	testResults, err := h.testResultUseCase.GetNotebookTestResults(userID.(string), notebookID)
+	var request domain.TestResultListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	testResults, err := h.testResultUseCase.GetNotebookTestResults(userID.(string), notebookID, request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondListError(c, err)
 		return
 	}
 
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"net/http"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	trashUseCase domain.TrashUseCase
+}
+
+func NewTrashHandler(trashUseCase domain.TrashUseCase) *TrashHandler {
+	return &TrashHandler{
+		trashUseCase: trashUseCase,
+	}
+}
+
+// ListTrash handles GET /api/v1/notebooks/trash
+func (h *TrashHandler) ListTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request domain.ListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notebooks, err := h.trashUseCase.ListTrash(userID.(string), request)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notebooks)
+}
+
+// RestoreNotebook handles POST /api/v1/notebooks/:id/restore
+func (h *TrashHandler) RestoreNotebook(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	notebook, err := h.trashUseCase.RestoreNotebook(userID.(string), c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("ETag", etag(notebook.Version))
+	c.JSON(http.StatusOK, notebook)
+}
+
+// DeleteNotebookPermanently handles DELETE /api/v1/notebooks/:id/permanent
+func (h *TrashHandler) DeleteNotebookPermanently(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.trashUseCase.DeleteNotebookPermanently(userID.(string), c.Param("id"), expectedVersion)
+	if err != nil {
+		respondNotebookError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	respondJobAccepted(c, job)
+}
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	domain "cognivia-api/Domain"
 
@@ -11,12 +14,14 @@ import (
 )
 
 type UserHandler struct {
-	userUseCase domain.UserUseCase
+	userUseCase   domain.UserUseCase
+	loginThrottle domain.LoginThrottle
 }
 
-func NewUserHandler(userUseCase domain.UserUseCase) *UserHandler {
+func NewUserHandler(userUseCase domain.UserUseCase, loginThrottle domain.LoginThrottle) *UserHandler {
 	return &UserHandler{
-		userUseCase: userUseCase,
+		userUseCase:   userUseCase,
+		loginThrottle: loginThrottle,
 	}
 }
 
@@ -30,6 +35,9 @@ func (h *UserHandler) Register(c *gin.Context) {
 	registeredUser, err := h.userUseCase.Register(&user)
 
 	if err != nil {
+		if respondValidationError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -51,20 +59,53 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	clientIP := c.ClientIP()
+	if wait, err := h.loginThrottle.Check(loginRequest.Email, clientIP); err != nil {
+		if errors.Is(err, domain.ErrTooManyRequests) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get user first to verify credentials
 	user, err := h.userUseCase.GetUserByEmail(loginRequest.Email)
 	if err != nil {
+		h.recordLoginFailure(loginRequest.Email, clientIP)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	log.Default().Printf("User retrieved from database: %+v", user)
+	log.Default().Printf("User retrieved from database: %s", user.Email)
 
 	// Verify password
 	if !h.userUseCase.VerifyPassword(user.Password, loginRequest.Password) {
+		h.recordLoginFailure(loginRequest.Email, clientIP)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	h.userUseCase.UpgradePasswordHash(user, loginRequest.Password)
+
+	if err := h.userUseCase.CheckLoginAllowed(user); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// With two-factor enabled the password only earns a challenge token
+	if user.TwoFactor.Enabled {
+		challenge, err := h.userUseCase.GenerateTwoFactorChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
 
 	// Generate token
 	token, err := h.userUseCase.GenerateToken(user)
@@ -73,12 +114,22 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if err := h.loginThrottle.RecordSuccess(user.Email, clientIP); err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"token": token,
 		"user":  user,
 	})
 }
 
+func (h *UserHandler) recordLoginFailure(email, ip string) {
+	if err := h.loginThrottle.RecordFailure(email, ip); err != nil {
+		log.Printf("Error recording failed login: %v", err)
+	}
+}
+
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
 	user, err := h.userUseCase.GetUserByID(id)
@@ -128,3 +179,304 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+// ForgotPassword handles POST /api/v1/users/forgot-password
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var request struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.RequestPasswordReset(request.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password reset request"})
+		return
+	}
+
+	// Same response whether or not the email is registered
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+// ResetPassword handles POST /api/v1/users/reset-password
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.ResetPassword(request.Token, request.Password); err != nil {
+		if respondValidationError(c, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// VerifyEmail handles POST /api/v1/users/verify-email
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.VerifyEmail(request.Token); err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification handles POST /api/v1/users/resend-verification
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	var request struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.ResendVerificationEmail(request.Email); err != nil {
+		if errors.Is(err, domain.ErrTooManyRequests) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is unverified, a verification email has been sent"})
+}
+
+// LoginTwoFactor handles POST /api/v1/users/login/2fa
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var request struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userUseCase.CompleteTwoFactorLogin(request.ChallengeToken, request.Code)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) || errors.Is(err, domain.ErrInvalidOTP) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrTooManyRequests) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.userUseCase.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
+
+// EnrollTwoFactor handles POST /api/v1/users/2fa/enroll
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	secret, uri, err := h.userUseCase.BeginTwoFactorEnrollment(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTwoFactor handles POST /api/v1/users/2fa/confirm
+func (h *UserHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.userUseCase.ConfirmTwoFactorEnrollment(userID.(string), request.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor handles POST /api/v1/users/2fa/disable
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.DisableTwoFactor(userID.(string), request.Password, request.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ChangePassword handles POST /api/v1/users/me/password
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userUseCase.ChangePassword(userID.(string), request.CurrentPassword, request.NewPassword)
+	if err != nil {
+		if respondValidationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Existing tokens were revoked, hand the caller a fresh one
+	token, err := h.userUseCase.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+		"token":   token,
+	})
+}
+
+// ChangeEmail handles POST /api/v1/users/me/email
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewEmail        string `json:"new_email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.RequestEmailChange(userID.(string), request.CurrentPassword, request.NewEmail); err != nil {
+		if respondValidationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your new email address for a confirmation link"})
+}
+
+// ConfirmEmailChange handles POST /api/v1/users/confirm-email-change
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.ConfirmEmailChange(request.Token); err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondValidationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed, please log in again"})
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	controllers "cognivia-api/Delivery/controllers"
 	"cognivia-api/Delivery/routers"
+	domain "cognivia-api/Domain"
 	mongodb "cognivia-api/Repositories"
 	usecase "cognivia-api/Usecase"
 	"cognivia-api/database"
+	"cognivia-api/infrastructure"
 
 	"github.com/joho/godotenv"
 )
@@ -31,16 +34,131 @@ func main() {
 	snapnotesRepo := mongodb.NewSnapnotesRepository(db)
 	prepPilotRepo := mongodb.NewPrepPilotRepository(db)
 	testResultRepo := mongodb.NewTestResultRepository(db)
+	userTokenRepo := mongodb.NewUserTokenRepository(db)
+	apiKeyRepo := mongodb.NewAPIKeyRepository(db)
+	notebookShareRepo := mongodb.NewNotebookShareRepository(db)
+	folderRepo := mongodb.NewFolderRepository(db)
+	revisionRepo := mongodb.NewRevisionRepository(db)
+	sourceRepo := mongodb.NewNotebookSourceRepository(db)
+	sourceTextRepo := mongodb.NewSourceTextRepository(db)
+	jobRepo := mongodb.NewJobRepository(db)
+	unitOfWork := mongodb.NewUnitOfWork(db)
+
+	var loginAttemptRepo domain.LoginAttemptRepository
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		loginAttemptRepo = mongodb.NewInMemoryLoginAttemptRepository()
+	} else {
+		loginAttemptRepo = mongodb.NewLoginAttemptRepository(db)
+	}
+
+	var searchIndex domain.SearchIndex
+	if os.Getenv("SEARCH_INDEX") == "memory" {
+		searchIndex = mongodb.NewInMemorySearchIndex()
+	} else {
+		searchIndex = mongodb.NewSearchIndex(db)
+	}
+
+	// Initialize services
+	mailer := infrastructure.NewMailer()
+	blobStore := infrastructure.NewBlobStore()
+	textExtractor := infrastructure.NewTextExtractor()
+	contentGenerator := infrastructure.NewContentGenerator()
+	driveClient := infrastructure.NewDriveClient()
 
 	// Initialize use cases
-	userUseCase := usecase.NewUserUseCase(userRepo)
-	notebookUseCase := usecase.NewNotebookUseCase(notebookRepo, snapnotesRepo, prepPilotRepo)
+	loginThrottle := usecase.NewLoginThrottle(loginAttemptRepo, userRepo, mailer)
+	userUseCase := usecase.NewUserUseCase(userRepo, userTokenRepo, apiKeyRepo, mailer, loginThrottle)
+	oidcUseCase := usecase.NewOIDCUseCase(userRepo, userTokenRepo, apiKeyRepo, infrastructure.LoadOIDCProviders())
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(apiKeyRepo)
+	searchUseCase := usecase.NewSearchUseCase(searchIndex, notebookRepo, snapnotesRepo, prepPilotRepo, notebookShareRepo)
+	notebookUseCase := usecase.NewNotebookUseCase(notebookRepo, snapnotesRepo, prepPilotRepo, notebookShareRepo, folderRepo, unitOfWork, searchUseCase, userRepo, mailer)
+	jobUseCase := usecase.NewJobUseCase(jobRepo, notebookUseCase)
+	folderUseCase := usecase.NewFolderUseCase(folderRepo, notebookRepo)
+	revisionUseCase := usecase.NewRevisionUseCase(revisionRepo, notebookUseCase, unitOfWork, searchUseCase)
+	notebookCopyUseCase := usecase.NewNotebookCopyUseCase(notebookRepo, snapnotesRepo, prepPilotRepo, notebookUseCase, unitOfWork, searchUseCase)
+	sourceUseCase := usecase.NewSourceUseCase(sourceRepo, sourceTextRepo, notebookRepo, notebookUseCase, blobStore, jobUseCase)
+	extractionUseCase := usecase.NewExtractionUseCase(sourceRepo, sourceTextRepo, notebookRepo, notebookUseCase, blobStore, textExtractor, jobUseCase)
+	driveUseCase := usecase.NewDriveUseCase(notebookRepo, sourceRepo, notebookUseCase, sourceUseCase, driveClient, jobUseCase)
+	generationUseCase := usecase.NewGenerationUseCase(sourceTextRepo, notebookUseCase, unitOfWork, searchUseCase, contentGenerator, jobUseCase)
+	trashUseCase := usecase.NewTrashUseCase(notebookRepo, unitOfWork, searchUseCase, blobStore, jobUseCase)
+	shareLinkUseCase := usecase.NewShareLinkUseCase(notebookRepo, snapnotesRepo, prepPilotRepo)
 	testResultUseCase := usecase.NewTestResultUseCase(testResultRepo, notebookRepo, prepPilotRepo)
 
-	userHandler := controllers.NewUserHandler(userUseCase)
+	jobUseCase.Register(domain.JobGenerateSnapnotes, generationUseCase.RunJob)
+	jobUseCase.Register(domain.JobGeneratePrepPilot, generationUseCase.RunJob)
+	jobUseCase.Register(domain.JobExtractSource, extractionUseCase.RunJob)
+	jobUseCase.Register(domain.JobQueueExtractions, extractionUseCase.RunJob)
+	jobUseCase.Register(domain.JobPurgeNotebook, trashUseCase.RunJob)
+	jobUseCase.Register(domain.JobPurgeTrash, trashUseCase.RunJob)
+	jobUseCase.Register(domain.JobImportDrive, driveUseCase.RunJob)
+	jobUseCase.Register(domain.JobSyncDrive, driveUseCase.RunJob)
+
+	userHandler := controllers.NewUserHandler(userUseCase, loginThrottle)
+	oidcHandler := controllers.NewOIDCHandler(oidcUseCase, userUseCase)
 	notebookHandler := controllers.NewNotebookHandler(notebookUseCase)
+	shareLinkHandler := controllers.NewShareLinkHandler(shareLinkUseCase)
+	folderHandler := controllers.NewFolderHandler(folderUseCase)
+	searchHandler := controllers.NewSearchHandler(searchUseCase)
+	trashHandler := controllers.NewTrashHandler(trashUseCase)
+	revisionHandler := controllers.NewRevisionHandler(revisionUseCase)
+	notebookCopyHandler := controllers.NewNotebookCopyHandler(notebookCopyUseCase)
+	sourceHandler := controllers.NewSourceHandler(sourceUseCase)
+	extractionHandler := controllers.NewExtractionHandler(extractionUseCase)
+	driveHandler := controllers.NewDriveHandler(driveUseCase)
+	generationHandler := controllers.NewGenerationHandler(generationUseCase)
+	jobHandler := controllers.NewJobHandler(jobUseCase)
 	testResultHandler := controllers.NewTestResultHandler(testResultUseCase)
-	router := routers.SetupRouter(userHandler, notebookHandler, testResultHandler)
+	apiKeyHandler := controllers.NewAPIKeyHandler(apiKeyUseCase)
+	router := routers.SetupRouter(
+		infrastructure.JWTAuth(userRepo, apiKeyRepo),
+		userHandler,
+		oidcHandler,
+		notebookHandler,
+		shareLinkHandler,
+		folderHandler,
+		searchHandler,
+		trashHandler,
+		revisionHandler,
+		notebookCopyHandler,
+		sourceHandler,
+		extractionHandler,
+		driveHandler,
+		generationHandler,
+		jobHandler,
+		testResultHandler,
+		apiKeyHandler,
+	)
+
+	// Run queued jobs in the background
+	jobWorkers := infrastructure.EnvInt("JOB_WORKERS", 4)
+	jobPollInterval := infrastructure.EnvDuration("JOB_POLL_INTERVAL", 2*time.Second)
+	stopWorkers := infrastructure.StartWorkers("job worker", jobWorkers, jobPollInterval, jobUseCase.RunNext)
+	defer stopWorkers()
+
+	// Permanently delete notebooks that stayed in the trash past the retention period
+	purgeInterval := infrastructure.EnvDuration("TRASH_PURGE_INTERVAL", time.Hour)
+	stopPurge := infrastructure.RunPeriodically("trash purge", purgeInterval, func() error {
+		_, err := jobUseCase.Enqueue(&domain.Job{Type: domain.JobPurgeTrash, DedupeKey: domain.JobPurgeTrash})
+		return err
+	})
+	defer stopPurge()
+
+	// Look for Google Drive files that changed since they were imported
+	driveSyncPollInterval := infrastructure.EnvDuration("DRIVE_SYNC_POLL_INTERVAL", 5*time.Minute)
+	stopDriveSync := infrastructure.RunPeriodically("google drive sync", driveSyncPollInterval, func() error {
+		_, err := jobUseCase.Enqueue(&domain.Job{Type: domain.JobSyncDrive, DedupeKey: domain.JobSyncDrive})
+		return err
+	})
+	defer stopDriveSync()
+
+	// Pick up sources whose extraction job was lost, e.g. because queueing it
+	// failed after the upload
+	extractionInterval := infrastructure.EnvDuration("EXTRACTION_INTERVAL", time.Minute)
+	stopExtraction := infrastructure.RunPeriodically("text extraction", extractionInterval, func() error {
+		_, err := jobUseCase.Enqueue(&domain.Job{Type: domain.JobQueueExtractions, DedupeKey: domain.JobQueueExtractions})
+		return err
+	})
+	defer stopExtraction()
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -2,49 +2,163 @@ package routers
 
 import (
 	"cognivia-api/Delivery/controllers"
+	domain "cognivia-api/Domain"
 	"cognivia-api/infrastructure"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRouter(
+	authMiddleware gin.HandlerFunc,
 	authHandler *controllers.UserHandler,
+	oidcHandler *controllers.OIDCHandler,
 	notebookHandler *controllers.NotebookHandler,
+	shareLinkHandler *controllers.ShareLinkHandler,
+	folderHandler *controllers.FolderHandler,
+	searchHandler *controllers.SearchHandler,
+	trashHandler *controllers.TrashHandler,
+	revisionHandler *controllers.RevisionHandler,
+	notebookCopyHandler *controllers.NotebookCopyHandler,
+	sourceHandler *controllers.SourceHandler,
+	extractionHandler *controllers.ExtractionHandler,
+	driveHandler *controllers.DriveHandler,
+	generationHandler *controllers.GenerationHandler,
+	jobHandler *controllers.JobHandler,
 	testResultHandler *controllers.TestResultHandler,
+	apiKeyHandler *controllers.APIKeyHandler,
 ) *gin.Engine {
 	router := gin.Default()
 
+	// Account management is only available to user sessions, not API keys
+	requireSession := infrastructure.RequireSession()
+
 	userRoutes := router.Group("/api/v1/users")
 	{
 		userRoutes.POST("/register", authHandler.Register)
 		userRoutes.POST("/login", authHandler.Login)
+		userRoutes.POST("/login/2fa", authHandler.LoginTwoFactor)
+		userRoutes.POST("/forgot-password", authHandler.ForgotPassword)
+		userRoutes.POST("/reset-password", authHandler.ResetPassword)
+		userRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		userRoutes.POST("/resend-verification", authHandler.ResendVerification)
+		userRoutes.POST("/confirm-email-change", authHandler.ConfirmEmailChange)
+		userRoutes.POST("/me/password", authMiddleware, requireSession, authHandler.ChangePassword)
+		userRoutes.POST("/me/email", authMiddleware, requireSession, authHandler.ChangeEmail)
+		userRoutes.POST("/2fa/enroll", authMiddleware, requireSession, authHandler.EnrollTwoFactor)
+		userRoutes.POST("/2fa/confirm", authMiddleware, requireSession, authHandler.ConfirmTwoFactor)
+		userRoutes.POST("/2fa/disable", authMiddleware, requireSession, authHandler.DisableTwoFactor)
 		userRoutes.GET("/:id", authHandler.GetUser)
 		userRoutes.PUT("/:id", authHandler.UpdateUser)
 		userRoutes.DELETE("/:id", authHandler.DeleteUser)
 	}
 
+	oidcRoutes := router.Group("/api/v1/auth/oidc")
+	{
+		oidcRoutes.GET("/providers", oidcHandler.ListProviders)
+		oidcRoutes.GET("/:provider/login", oidcHandler.Login)
+		oidcRoutes.GET("/:provider/callback", oidcHandler.Callback)
+	}
+
 	notebookRoutes := router.Group("/api/v1/notebooks")
 	{
-		// Protected routes - require JWT authentication
-		notebookRoutes.Use(infrastructure.JWTAuth())
-		notebookRoutes.POST("/", notebookHandler.CreateNotebook)
-		notebookRoutes.GET("/:id", notebookHandler.GetNotebook)
-		notebookRoutes.GET("/user", notebookHandler.GetNotebooksByUserID)
-		notebookRoutes.PUT("/:id", notebookHandler.UpdateNotebook)
-		notebookRoutes.DELETE("/:id", notebookHandler.DeleteNotebook)
-		notebookRoutes.GET("/:id/snapnotes", notebookHandler.GetSnapnotes)
-		notebookRoutes.GET("/:id/prep-pilot", notebookHandler.GetPrepPilot)
+		// Protected routes - require a JWT or an API key with the right scope
+		notebookRoutes.Use(authMiddleware)
+		read := infrastructure.RequireScope(domain.ScopeNotebooksRead)
+		write := infrastructure.RequireScope(domain.ScopeNotebooksWrite)
+		notebookRoutes.POST("/", write, notebookHandler.CreateNotebook)
+		notebookRoutes.GET("/:id", read, notebookHandler.GetNotebook)
+		notebookRoutes.GET("/user", read, notebookHandler.GetNotebooksByUserID)
+		notebookRoutes.GET("/shared", read, notebookHandler.GetSharedNotebooks)
+		notebookRoutes.GET("/tags", read, notebookHandler.GetTags)
+		notebookRoutes.GET("/trash", read, trashHandler.ListTrash)
+		notebookRoutes.PUT("/:id", write, notebookHandler.UpdateNotebook)
+		notebookRoutes.DELETE("/:id", write, notebookHandler.DeleteNotebook)
+		notebookRoutes.POST("/:id/restore", write, trashHandler.RestoreNotebook)
+		notebookRoutes.DELETE("/:id/permanent", write, trashHandler.DeleteNotebookPermanently)
+		notebookRoutes.GET("/:id/snapnotes", read, notebookHandler.GetSnapnotes)
+		notebookRoutes.GET("/:id/prep-pilot", read, notebookHandler.GetPrepPilot)
+		notebookRoutes.POST("/:id/snapnotes/generate", write, generationHandler.GenerateSnapnotes)
+		notebookRoutes.POST("/:id/prep-pilot/generate", write, generationHandler.GeneratePrepPilot)
+		notebookRoutes.POST("/:id/duplicate", write, notebookCopyHandler.DuplicateNotebook)
+		notebookRoutes.GET("/:id/origin", read, notebookCopyHandler.GetOriginStatus)
+		notebookRoutes.POST("/:id/sync", write, notebookCopyHandler.SyncFromOrigin)
+		notebookRoutes.GET("/:id/sources", read, sourceHandler.ListSources)
+		notebookRoutes.POST("/:id/sources", write, sourceHandler.UploadSource)
+		notebookRoutes.GET("/:id/sources/:source_id/content", read, sourceHandler.DownloadSource)
+		notebookRoutes.DELETE("/:id/sources/:source_id", write, sourceHandler.DeleteSource)
+		notebookRoutes.POST("/:id/drive/import", write, driveHandler.ImportDrive)
+		notebookRoutes.GET("/:id/sources/:source_id/text", read, extractionHandler.GetSourceText)
+		notebookRoutes.POST("/:id/sources/:source_id/extract", write, extractionHandler.RetryExtraction)
+		notebookRoutes.GET("/:id/jobs", read, jobHandler.ListNotebookJobs)
+		notebookRoutes.GET("/:id/revisions", read, revisionHandler.ListRevisions)
+		notebookRoutes.GET("/:id/revisions/diff", read, revisionHandler.DiffRevisions)
+		notebookRoutes.GET("/:id/revisions/:revision_id", read, revisionHandler.GetRevision)
+		notebookRoutes.POST("/:id/revisions/:revision_id/restore", write, revisionHandler.RestoreRevision)
+		notebookRoutes.GET("/:id/shares", read, notebookHandler.ListShares)
+		notebookRoutes.POST("/:id/shares", write, notebookHandler.ShareNotebook)
+		notebookRoutes.PUT("/:id/shares/:share_id", write, notebookHandler.UpdateShare)
+		notebookRoutes.DELETE("/:id/shares/:share_id", write, notebookHandler.RevokeShare)
+		notebookRoutes.GET("/:id/links", read, shareLinkHandler.ListShareLinks)
+		notebookRoutes.POST("/:id/links", write, shareLinkHandler.CreateShareLink)
+		notebookRoutes.DELETE("/:id/links/:link_id", write, shareLinkHandler.RevokeShareLink)
+	}
+
+	folderRoutes := router.Group("/api/v1/folders")
+	{
+		// Folders organize notebooks and share their scopes
+		folderRoutes.Use(authMiddleware)
+		read := infrastructure.RequireScope(domain.ScopeNotebooksRead)
+		write := infrastructure.RequireScope(domain.ScopeNotebooksWrite)
+		folderRoutes.POST("/", write, folderHandler.CreateFolder)
+		folderRoutes.GET("/", read, folderHandler.ListFolders)
+		folderRoutes.PUT("/:id", write, folderHandler.RenameFolder)
+		folderRoutes.POST("/:id/move", write, folderHandler.MoveFolder)
+		folderRoutes.DELETE("/:id", write, folderHandler.DeleteFolder)
+	}
+
+	jobRoutes := router.Group("/api/v1/jobs")
+	{
+		// Jobs are about notebooks and share their scopes
+		jobRoutes.Use(authMiddleware)
+		jobRoutes.GET("/:id", infrastructure.RequireScope(domain.ScopeNotebooksRead), jobHandler.GetJob)
+		jobRoutes.POST("/:id/retry", infrastructure.RequireScope(domain.ScopeNotebooksWrite), jobHandler.RetryJob)
+	}
+
+	searchRoutes := router.Group("/api/v1/search")
+	{
+		searchRoutes.Use(authMiddleware)
+		searchRoutes.GET("/", infrastructure.RequireScope(domain.ScopeNotebooksRead), searchHandler.Search)
+		searchRoutes.POST("/reindex", infrastructure.RequireScope(domain.ScopeNotebooksWrite), searchHandler.Reindex)
+	}
+
+	// Public share links, no authentication
+	publicRoutes := router.Group("/api/v1/public/notebooks")
+	{
+		publicRoutes.GET("/:token", shareLinkHandler.GetPublicNotebook)
+		publicRoutes.GET("/:token/snapnotes", shareLinkHandler.GetPublicSnapnotes)
+		publicRoutes.GET("/:token/prep-pilot", shareLinkHandler.GetPracticePrepPilot)
+		publicRoutes.POST("/:token/prep-pilot/submit", shareLinkHandler.SubmitPracticeQuiz)
 	}
 
 	testResultRoutes := router.Group("/api/v1/test-results")
 	{
-		// Protected routes - require JWT authentication
-		testResultRoutes.Use(infrastructure.JWTAuth())
-		testResultRoutes.POST("/", testResultHandler.SubmitTestResultV2)
-		testResultRoutes.GET("/:id", testResultHandler.GetTestResult)
-		testResultRoutes.GET("/user", testResultHandler.GetUserTestResults)
-		testResultRoutes.GET("/notebook/:notebook_id", testResultHandler.GetNotebookTestResults)
-		testResultRoutes.GET("/notebook/:notebook_id/stats", testResultHandler.GetTestResultStats)
+		// Protected routes - require a JWT or an API key with the right scope
+		testResultRoutes.Use(authMiddleware)
+		read := infrastructure.RequireScope(domain.ScopeTestResultsRead)
+		write := infrastructure.RequireScope(domain.ScopeTestResultsWrite)
+		testResultRoutes.POST("/", write, testResultHandler.SubmitTestResultV2)
+		testResultRoutes.GET("/:id", read, testResultHandler.GetTestResult)
+		testResultRoutes.GET("/user", read, testResultHandler.GetUserTestResults)
+		testResultRoutes.GET("/notebook/:notebook_id", read, testResultHandler.GetNotebookTestResults)
+		testResultRoutes.GET("/notebook/:notebook_id/stats", read, testResultHandler.GetTestResultStats)
+	}
+
+	apiKeyRoutes := router.Group("/api/v1/api-keys")
+	{
+		apiKeyRoutes.Use(authMiddleware, requireSession)
+		apiKeyRoutes.POST("/", apiKeyHandler.CreateAPIKey)
+		apiKeyRoutes.GET("/", apiKeyHandler.ListAPIKeys)
+		apiKeyRoutes.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
 	}
 
 	return router
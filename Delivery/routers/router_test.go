@@ -0,0 +1,131 @@
+package routers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cognivia-api/Delivery/routers"
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type userRepository struct {
+	domain.UserRepository
+	user *domain.User
+}
+
+func (r *userRepository) FindByID(id string) (*domain.User, error) {
+	if id != r.user.ID.Hex() {
+		return nil, nil
+	}
+	copied := *r.user
+	return &copied, nil
+}
+
+type apiKeyRepository struct {
+	domain.APIKeyRepository
+	keys map[string]*domain.APIKey
+}
+
+func (r *apiKeyRepository) FindByHash(keyHash string) (*domain.APIKey, error) {
+	return r.keys[keyHash], nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(id primitive.ObjectID, at time.Time) error {
+	return nil
+}
+
+// Routes reachable without credentials
+var publicPrefixes = []string{
+	"/api/v1/users/register", "/api/v1/users/login", "/api/v1/users/forgot-password",
+	"/api/v1/users/reset-password", "/api/v1/users/verify-email", "/api/v1/users/resend-verification",
+	"/api/v1/users/confirm-email-change", "/api/v1/users/:id", "/api/v1/auth/oidc/", "/api/v1/public/",
+}
+
+// Routes only user sessions may call
+var sessionPrefixes = []string{"/api/v1/api-keys", "/api/v1/users/me/", "/api/v1/users/2fa/"}
+
+// requiredScope returns the scope an API key needs for a route
+func requiredScope(t *testing.T, route gin.RouteInfo) string {
+	read := route.Method == http.MethodGet
+	switch {
+	case strings.HasPrefix(route.Path, "/api/v1/test-results"):
+		if read {
+			return domain.ScopeTestResultsRead
+		}
+		return domain.ScopeTestResultsWrite
+	case strings.HasPrefix(route.Path, "/api/v1/notebooks"), strings.HasPrefix(route.Path, "/api/v1/folders"),
+		strings.HasPrefix(route.Path, "/api/v1/jobs"), strings.HasPrefix(route.Path, "/api/v1/search"):
+		if read {
+			return domain.ScopeNotebooksRead
+		}
+		return domain.ScopeNotebooksWrite
+	}
+	t.Fatalf("%s %s is neither public, session-only nor scoped", route.Method, route.Path)
+	return ""
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	return slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) })
+}
+
+// TestAPIKeysNeedTheirScope calls every protected route with keys that lack
+// what it needs, so they never reach a handler
+func TestAPIKeysNeedTheirScope(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+
+	user := &domain.User{ID: primitive.NewObjectID(), Email: "ada@example.com", EmailVerified: true}
+	keys := &apiKeyRepository{keys: map[string]*domain.APIKey{}}
+	addKey := func(scopes ...string) string {
+		plaintext := domain.APIKeyPrefix + strings.Join(scopes, "+")
+		keys.keys[infrastructure.HashToken(plaintext)] = &domain.APIKey{ID: primitive.NewObjectID(), UserID: user.ID, Scopes: scopes}
+		return plaintext
+	}
+	allScopes := addKey(domain.APIKeyScopes...)
+
+	router := routers.SetupRouter(infrastructure.JWTAuth(&userRepository{user: user}, keys),
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	checked := 0
+	for _, route := range router.Routes() {
+		if hasPrefix(route.Path, publicPrefixes) && !hasPrefix(route.Path, sessionPrefixes) {
+			continue
+		}
+		path := strings.NewReplacer(":id", user.ID.Hex(), ":source_id", user.ID.Hex()).Replace(route.Path)
+		for _, param := range []string{":revision_id", ":share_id", ":link_id", ":notebook_id"} {
+			path = strings.ReplaceAll(path, param, primitive.NewObjectID().Hex())
+		}
+
+		var tried []string
+		if hasPrefix(route.Path, sessionPrefixes) {
+			tried = []string{allScopes}
+		} else {
+			scope := requiredScope(t, route)
+			others := slices.DeleteFunc(slices.Clone(domain.APIKeyScopes), func(s string) bool { return s == scope })
+			tried = []string{addKey(), addKey(others...)}
+		}
+		for _, key := range tried {
+			for _, header := range [][2]string{{"X-API-Key", key}, {"Authorization", "Bearer " + key}} {
+				request := httptest.NewRequest(route.Method, path, nil)
+				request.Header.Set(header[0], header[1])
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+				if response.Code != http.StatusForbidden {
+					t.Errorf("%s %s with %q: got %d", route.Method, path, key, response.Code)
+				}
+			}
+		}
+		checked++
+	}
+	if checked < 50 {
+		t.Errorf("checked only %d routes", checked)
+	}
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes. JWT sessions implicitly have every scope.
+const (
+	ScopeNotebooksRead    = "notebooks:read"
+	ScopeNotebooksWrite   = "notebooks:write"
+	ScopeTestResultsRead  = "test-results:read"
+	ScopeTestResultsWrite = "test-results:write"
+)
+
+var APIKeyScopes = []string{
+	ScopeNotebooksRead,
+	ScopeNotebooksWrite,
+	ScopeTestResultsRead,
+	ScopeTestResultsWrite,
+}
+
+// APIKeyPrefix starts every API key so they are easy to recognize (and to
+// find with secret scanners).
+const APIKeyPrefix = "cgv_"
+
+// APIKey is a long-lived credential for scripts. Only the SHA-256 hash of the
+// key is stored; Prefix keeps enough of it to tell keys apart in listings.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type APIKeyRepository interface {
+	Create(key *APIKey) error
+	FindByHash(keyHash string) (*APIKey, error)
+	GetByUserID(userID primitive.ObjectID) ([]*APIKey, error)
+	Revoke(id, userID primitive.ObjectID) (bool, error)
+	// RevokeAllByUserID revokes every active key of the user
+	RevokeAllByUserID(userID primitive.ObjectID) error
+	TouchLastUsed(id primitive.ObjectID, at time.Time) error
+}
+
+type APIKeyUseCase interface {
+	// CreateAPIKey returns the stored key and its plaintext value, which is
+	// only available at creation time.
+	CreateAPIKey(userID, name string, scopes []string) (*APIKey, string, error)
+	ListAPIKeys(userID string) ([]*APIKey, error)
+	RevokeAPIKey(userID, keyID string) error
+}
@@ -0,0 +1,30 @@
+package domain
+
+// GenerationInput is the source text of a notebook that content is
+// generated from
+type GenerationInput struct {
+	// Title names the notebook
+	Title    string
+	Chapters []TextChapter
+}
+
+// ContentGenerator writes study material for the text of a notebook. It
+// returns the JSON of snapnotes or of a prep pilot, without IDs or versions,
+// which is checked against its schema before anything is saved.
+type ContentGenerator interface {
+	GenerateSnapnotes(input GenerationInput) ([]byte, error)
+	GeneratePrepPilot(input GenerationInput) ([]byte, error)
+}
+
+type GenerationUseCase interface {
+	// GenerateSnapnotes queues a job that writes snapnotes from the
+	// extracted text of the notebook's sources, replacing the notebook's own
+	// snapnotes
+	GenerateSnapnotes(userID, notebookID string) (*Job, error)
+	// GeneratePrepPilot queues a job that writes a prep pilot from the
+	// extracted text of the notebook's sources, replacing the notebook's own
+	// prep pilot
+	GeneratePrepPilot(userID, notebookID string) (*Job, error)
+	// RunJob runs generate_snapnotes and generate_prep_pilot jobs
+	RunJob(job *Job) error
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrTooManyRequests    = errors.New("too many requests, please try again later")
+	ErrEmailNotVerified   = errors.New("email address has not been verified")
+	ErrInvalidOTP         = errors.New("invalid two-factor code")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUnknownProvider    = errors.New("unknown sign-in provider")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrNotebookNotFound   = errors.New("notebook not found")
+	ErrForbidden          = errors.New("you do not have permission to do this")
+	ErrShareNotFound      = errors.New("share not found")
+	ErrShareLinkInvalid   = errors.New("share link is invalid, expired or revoked")
+	ErrFolderNotFound     = errors.New("folder not found")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrRevisionNotFound   = errors.New("revision not found")
+	ErrOriginNotFound     = errors.New("notebook is not a copy of a notebook you can access")
+	ErrSourceNotFound     = errors.New("source not found")
+	ErrBlobNotFound       = errors.New("blob not found")
+	ErrSourceTextNotFound = errors.New("source text is not available, check the source's extraction status")
+	ErrVersionConflict    = errors.New("the notebook was changed in the meantime, reload it and try again")
+	ErrContentGeneration  = errors.New("content generation failed")
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobLeaseLost       = errors.New("the job was taken over by another worker")
+	ErrDriveFileNotFound  = errors.New("google drive file not found or not shared")
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries one or more field errors. Handlers return them as
+// 400 responses with a "fields" list.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
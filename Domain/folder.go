@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Folder groups a user's notebooks. Folders nest through ParentID; a nil
+// ParentID is a top-level folder.
+type Folder struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	ParentID  *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id"`
+	Name      string              `bson:"name" json:"name"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+type FolderRepository interface {
+	Create(folder *Folder) error
+	GetByID(id primitive.ObjectID) (*Folder, error)
+	GetByUserID(userID primitive.ObjectID) ([]*Folder, error)
+	Update(folder *Folder) error
+	Delete(id primitive.ObjectID) error
+	// Reparent moves every direct child of from to the parent to
+	Reparent(userID, from primitive.ObjectID, to *primitive.ObjectID) error
+}
+
+type FolderUseCase interface {
+	CreateFolder(userID, name string, parentID *string) (*Folder, error)
+	ListFolders(userID string) ([]*Folder, error)
+	RenameFolder(userID, folderID, name string) (*Folder, error)
+	// MoveFolder changes the parent of a folder, nil moves it to the top level
+	MoveFolder(userID, folderID string, parentID *string) (*Folder, error)
+	// DeleteFolder removes a folder. Its sub-folders and notebooks move up to
+	// the deleted folder's parent.
+	DeleteFolder(userID, folderID string) error
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Generated content that can be flagged in Notebook.StaleContent
+const (
+	StaleSnapnotes = "snapnotes"
+	StalePrepPilot = "prep_pilot"
+)
+
+// NotebookDrive tracks the Google Drive file of a notebook's GoogleDriveLink
+// and the source it was imported as
+type NotebookDrive struct {
+	FileID string `bson:"file_id" json:"file_id"`
+	// SourceID is the source holding the imported file
+	SourceID *primitive.ObjectID `bson:"source_id,omitempty" json:"source_id,omitempty"`
+	// Revision identifies the imported content of the file
+	Revision   string     `bson:"revision,omitempty" json:"revision,omitempty"`
+	ModifiedAt *time.Time `bson:"modified_at,omitempty" json:"modified_at,omitempty"`
+	ImportedAt *time.Time `bson:"imported_at,omitempty" json:"imported_at,omitempty"`
+	// CheckedAt is when the re-sync last looked at the file. It is not
+	// served so that checks do not change the notebook's ETag.
+	CheckedAt time.Time `bson:"checked_at" json:"-"`
+	// Error explains why the latest import or check failed
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// DriveFile is the metadata of a Google Drive file
+type DriveFile struct {
+	ID string
+	// Name is the file name the content downloads as. Google Docs files
+	// get the extension of the format they are exported to.
+	Name     string
+	MimeType string
+	// Revision changes whenever the content of the file changes
+	Revision   string
+	ModifiedAt time.Time
+}
+
+// DriveClient reads files from Google Drive
+type DriveClient interface {
+	// GetFile returns ErrDriveFileNotFound when the file does not exist or
+	// is not shared with the client
+	GetFile(fileID string) (*DriveFile, error)
+	// Download returns the content of the file, which the caller closes
+	Download(file *DriveFile) (io.ReadCloser, error)
+}
+
+type DriveUseCase interface {
+	// ImportDrive queues a job that downloads the file of the notebook's
+	// GoogleDriveLink and attaches it as a source, replacing the file
+	// imported before
+	ImportDrive(userID, notebookID string) (*Job, error)
+	// SyncDue checks the Drive files of notebooks not checked for the sync
+	// interval and queues an import for those that changed. It returns how
+	// many imports were queued.
+	SyncDue() (int, error)
+	// RunJob runs import_drive and sync_drive jobs
+	RunJob(job *Job) error
+}
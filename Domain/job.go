@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Job types
+const (
+	JobGenerateSnapnotes = "generate_snapnotes"
+	JobGeneratePrepPilot = "generate_prep_pilot"
+	JobExtractSource     = "extract_source"
+	JobQueueExtractions  = "queue_extractions"
+	JobPurgeNotebook     = "purge_notebook"
+	JobPurgeTrash        = "purge_trash"
+	JobImportDrive       = "import_drive"
+	JobSyncDrive         = "sync_drive"
+)
+
+// Job states. A job that failed but has attempts left is queued again with
+// a later RunAt; one that runs out of attempts or fails for good is dead.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Job is a unit of background work in the job queue
+type Job struct {
+	ID   primitive.ObjectID `bson:"_id" json:"id"`
+	Type string             `bson:"type" json:"type"`
+	// NotebookID is the notebook the work is about, if any
+	NotebookID *primitive.ObjectID `bson:"notebook_id,omitempty" json:"notebook_id,omitempty"`
+	// UserID is the user who asked for the work; jobs queued by the server
+	// itself have none
+	UserID  *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Payload map[string]string   `bson:"payload,omitempty" json:"payload,omitempty"`
+	Status  string              `bson:"status" json:"status"`
+	// Attempts counts the times the job was leased, MaxAttempts limits them
+	Attempts    int `bson:"attempts" json:"attempts"`
+	MaxAttempts int `bson:"max_attempts" json:"max_attempts"`
+	// Error is the message of the latest failed attempt
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+	// RunAt is the earliest time the job is leased
+	RunAt time.Time `bson:"run_at" json:"run_at"`
+	// DedupeKey, when set, keeps a second job with the same key from being
+	// queued while the first is queued or running
+	DedupeKey string `bson:"dedupe_key,omitempty" json:"-"`
+	// LeasedBy and LeaseExpiresAt tell which worker runs the job and until
+	// when it must renew its lease before another worker takes over
+	LeasedBy       string     `bson:"leased_by,omitempty" json:"-"`
+	LeaseExpiresAt *time.Time `bson:"lease_expires_at,omitempty" json:"-"`
+	HeartbeatAt    *time.Time `bson:"heartbeat_at,omitempty" json:"heartbeat_at,omitempty"`
+	CreatedAt      time.Time  `bson:"created_at" json:"created_at"`
+	StartedAt      *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt     *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// ExpiresAt is when a finished job is removed
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"-"`
+}
+
+// Sort keys accepted by job listings
+var JobSortFields = []string{"created_at"}
+
+// JobListRequest is the query string of GET /notebooks/:id/jobs
+type JobListRequest struct {
+	ListRequest
+	Status string `form:"status"`
+}
+
+// JobQuery selects a page of a notebook's jobs
+type JobQuery struct {
+	// Status limits the listing to jobs in one state
+	Status string
+	PageOptions
+}
+
+type JobRepository interface {
+	// Enqueue stores a new queued job. When a queued or running job has the
+	// same DedupeKey that job is returned instead.
+	Enqueue(job *Job) (*Job, error)
+	GetByID(id primitive.ObjectID) (*Job, error)
+	Find(notebookID primitive.ObjectID, query JobQuery) (*Page[*Job], error)
+	// Lease hands the next due job, or a running job whose lease expired, to
+	// workerID until the lease ends, counting an attempt
+	Lease(workerID string, lease time.Duration) (*Job, error)
+	// Heartbeat extends the lease of a running job. It fails with
+	// ErrJobLeaseLost once another worker has taken the job over.
+	Heartbeat(id primitive.ObjectID, workerID string, lease time.Duration) error
+	Complete(id primitive.ObjectID, workerID string) error
+	// Retry queues a leased job again to run at runAt
+	Retry(id primitive.ObjectID, workerID, message string, runAt time.Time) error
+	// Bury marks a leased job as dead
+	Bury(id primitive.ObjectID, workerID, message string) error
+	// Requeue queues a dead job again with a fresh set of attempts. If a
+	// queued or running job took over its dedupe key, that job is returned
+	// instead.
+	Requeue(id primitive.ObjectID) (*Job, error)
+}
+
+// JobHandler does the work of one job. Errors are retried with backoff,
+// except those retrying cannot fix such as validation errors or a missing
+// notebook.
+type JobHandler func(job *Job) error
+
+// JobQueue accepts work for the background workers
+type JobQueue interface {
+	// Enqueue queues a job, filling in defaults such as MaxAttempts
+	Enqueue(job *Job) (*Job, error)
+}
+
+type JobUseCase interface {
+	JobQueue
+	// Register sets the handler for a job type
+	Register(jobType string, handler JobHandler)
+	// GetJob returns a job the user queued or that belongs to a notebook
+	// the user can access
+	GetJob(userID, jobID string) (*Job, error)
+	ListNotebookJobs(userID, notebookID string, request JobListRequest) (*Page[*Job], error)
+	// RetryJob queues a dead job again
+	RetryJob(userID, jobID string) (*Job, error)
+	// RunNext leases the next due job and runs it as workerID. It reports
+	// whether there was a job to run.
+	RunNext(workerID string) (bool, error)
+}
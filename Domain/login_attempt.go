@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+)
+
+// LoginAttempt tracks consecutive failed logins for one key, either an
+// account ("email:<address>") or a client IP ("ip:<address>").
+type LoginAttempt struct {
+	Key           string    `bson:"_id" json:"key"`
+	Failures      int       `bson:"failures" json:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at" json:"last_failure_at"`
+	LockedUntil   time.Time `bson:"locked_until" json:"locked_until"`
+}
+
+type LoginAttemptRepository interface {
+	Get(key string) (*LoginAttempt, error)
+	// RecordFailure increments the failure counter of key. Counters whose last
+	// failure and lockout both ended more than window ago start again from one.
+	RecordFailure(key string, at time.Time, window time.Duration) (*LoginAttempt, error)
+	SetLockedUntil(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// LoginThrottle decides whether a login may be attempted and records the
+// outcome of each attempt.
+type LoginThrottle interface {
+	// Check returns ErrTooManyRequests and how long to wait if either the
+	// account or the IP is locked out.
+	Check(email, ip string) (time.Duration, error)
+	RecordFailure(email, ip string) error
+	RecordSuccess(email, ip string) error
+}
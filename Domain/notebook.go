@@ -11,6 +11,8 @@ type Notebook struct {
 	UserID          primitive.ObjectID  `bson:"user_id" json:"user_id"`
 	SnapnotesID     *primitive.ObjectID `bson:"snapnotes_id,omitempty" json:"snapnotes_id,omitempty"`
 	PrepPilotID     *primitive.ObjectID `bson:"prep_pilot_id,omitempty" json:"prep_pilot_id,omitempty"`
+	FolderID        *primitive.ObjectID `bson:"folder_id,omitempty" json:"folder_id,omitempty"`
+	Tags            []string            `bson:"tags,omitempty" json:"tags"`
 	Name            string              `bson:"name" json:"name" binding:"required"`
 	Icon            string              `bson:"icon" json:"icon"`
 	Color           string              `bson:"color" json:"color"`
@@ -18,6 +20,28 @@ type Notebook struct {
 	GoogleDriveLink *string             `bson:"google_drive_link,omitempty" json:"google_drive_link,omitempty"`
 	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
 	UpdatedAt       time.Time           `bson:"updated_at" json:"updated_at"`
+	ShareLinks      []ShareLink         `bson:"share_links,omitempty" json:"-"`
+	// DeletedAt is set while the notebook is in the trash
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	// Version is incremented on every change and served as the ETag
+	Version int64 `bson:"version" json:"version"`
+	// Origin is set on notebooks duplicated from another notebook
+	Origin *NotebookOrigin `bson:"origin,omitempty" json:"origin,omitempty"`
+	// Extraction is maintained by the text extraction of the sources
+	Extraction *NotebookExtraction `bson:"extraction,omitempty" json:"extraction,omitempty"`
+	// Drive is set once the file of GoogleDriveLink was imported
+	Drive *NotebookDrive `bson:"drive,omitempty" json:"drive,omitempty"`
+	// StaleContent names the generated content, StaleSnapnotes and
+	// StalePrepPilot, whose sources changed since it was generated
+	StaleContent []string `bson:"stale_content,omitempty" json:"stale_content,omitempty"`
+}
+
+// CreateNotebookRequest creates a notebook, optionally together with
+// snapnotes and a prep pilot prepared elsewhere
+type CreateNotebookRequest struct {
+	Notebook
+	Snapnotes *Snapnotes `json:"snapnotes,omitempty"`
+	PrepPilot *PrepPilot `json:"prep_pilot,omitempty"`
 }
 
 type UpdateRequest struct {
@@ -28,24 +52,120 @@ type UpdateRequest struct {
 	GoogleDriveLink *string `json:"google_drive_link"`
 	SnapnotesID     *string `json:"snapnotes_id"`
 	PrepPilotID     *string `json:"prep_pilot_id"`
+	// FolderID moves the notebook, an empty string moves it out of any folder
+	FolderID *string   `json:"folder_id"`
+	Tags     *[]string `json:"tags"`
 }
 
+// NotebookQuery selects a page of a user's notebooks
+type NotebookQuery struct {
+	// FolderID limits the listing to one folder. With InRoot set only
+	// notebooks outside any folder are listed.
+	FolderID    *primitive.ObjectID
+	InRoot      bool
+	Tag         string
+	Type        string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Trashed lists notebooks in the trash instead of live ones
+	Trashed bool
+	PageOptions
+}
+
+// Sort keys accepted by notebook listings
+var NotebookSortFields = []string{"created_at", "updated_at", "name"}
+
+// Sort keys accepted by the trash listing
+var TrashSortFields = []string{"deleted_at", "name"}
+
 type NotebookRepository interface {
 	Create(notebook *Notebook) error
 	GetByID(id primitive.ObjectID) (*Notebook, error)
 	GetByUserID(userID primitive.ObjectID) ([]*Notebook, error)
+	Find(userID primitive.ObjectID, query NotebookQuery) (*Page[*Notebook], error)
+	GetTags(userID primitive.ObjectID) ([]string, error)
+	// MoveFolder moves the user's notebooks in folder from to folder to
+	MoveFolder(userID, from primitive.ObjectID, to *primitive.ObjectID) error
+	// Update saves the notebook if the stored version still equals
+	// notebook.Version and increments it, otherwise it returns
+	// ErrVersionConflict. Share links are left as stored.
 	Update(notebook *Notebook) error
 	Delete(id primitive.ObjectID) error
+	// SoftDelete moves a notebook at the given version to the trash, Restore
+	// takes it out again
+	SoftDelete(id primitive.ObjectID, version int64, at time.Time) error
+	Restore(id primitive.ObjectID) error
+	// FindDeletedBefore returns up to limit notebooks trashed before cutoff
+	FindDeletedBefore(cutoff time.Time, limit int) ([]*Notebook, error)
+	GetByIDs(ids []primitive.ObjectID) ([]*Notebook, error)
+	FindByShareLinkHash(tokenHash string) (*Notebook, error)
+	// AddShareLink and RevokeShareLink increment the version
+	AddShareLink(notebookID primitive.ObjectID, link *ShareLink) error
+	// RevokeShareLink returns false if the link does not exist or was
+	// already revoked.
+	RevokeShareLink(notebookID, linkID primitive.ObjectID, at time.Time) (bool, error)
+	// SetExtraction replaces the extraction summary and increments the
+	// version, an extraction of nil removes it
+	SetExtraction(id primitive.ObjectID, extraction *NotebookExtraction) error
+	// SetDrive replaces the Drive state and increments the version. Content
+	// names in stale are added to StaleContent.
+	SetDrive(id primitive.ObjectID, drive *NotebookDrive, stale []string) error
+	// FindDriveCheckDue returns up to limit live notebooks with an imported
+	// Drive file that was last checked before cutoff
+	FindDriveCheckDue(cutoff time.Time, limit int) ([]*Notebook, error)
+	// MarkDriveChecked records when the Drive file was checked and why the
+	// check failed, an empty message clears the error. The version only
+	// changes with the error.
+	MarkDriveChecked(id primitive.ObjectID, at time.Time, message string) error
 	// Add other repository methods as needed (e.g., GetByUserID)
 }
 
+// NotebookAuthorizer resolves what a user may do with a notebook
+type NotebookAuthorizer interface {
+	// NotebookAccess returns the notebook with the caller's share role on
+	// it. Deleted notebooks and notebooks the caller cannot see are
+	// reported as ErrNotebookNotFound.
+	NotebookAccess(userID, notebookID string) (*Notebook, string, error)
+}
+
 type NotebookUseCase interface {
-	CreateNotebook(userID string, notebook *Notebook) error
+	NotebookAuthorizer
+	// CreateNotebook stores the notebook and any content in the request
+	// together and fills in the generated IDs
+	CreateNotebook(userID string, request *CreateNotebookRequest) error
 	GetNotebookByID(userID string, notebookID string) (*Notebook, error)
 	GetNotebooksByUserID(userID string) ([]*Notebook, error)
+	// ListNotebooks returns a page of the user's own notebooks
+	ListNotebooks(userID string, request NotebookListRequest) (*Page[*Notebook], error)
+	GetTags(userID string) ([]string, error)
 	GetSnapnotes(userID string, notebookID string) (*Snapnotes, error)
 	GetPrepPilot(userID string, notebookID string) (*PrepPilot, error)
-	UpdateNotebook(notebookID string, notebook UpdateRequest) error
-	DeleteNotebook(userID string, notebookID string) error
+	// UpdateNotebook and DeleteNotebook fail with ErrVersionConflict unless
+	// the notebook is still at expectedVersion; nil skips the check
+	UpdateNotebook(userID string, notebookID string, notebook UpdateRequest, expectedVersion *int64) (*Notebook, error)
+	// DeleteNotebook moves the notebook to the trash
+	DeleteNotebook(userID string, notebookID string, expectedVersion *int64) error
+	GetSharedNotebooks(userID string) ([]*SharedNotebook, error)
+	ShareNotebook(userID, notebookID, email, role string) (*NotebookShare, error)
+	ListShares(userID, notebookID string) ([]*NotebookShare, error)
+	UpdateShare(userID, notebookID, shareID, role string) (*NotebookShare, error)
+	// RevokeShare removes a share. The owner may remove any share, the
+	// recipient may remove their own to leave the notebook.
+	RevokeShare(userID, notebookID, shareID string) error
 	// Add other use case methods as needed
 }
+
+type TrashUseCase interface {
+	ListTrash(userID string, request ListRequest) (*Page[*Notebook], error)
+	RestoreNotebook(userID, notebookID string) (*Notebook, error)
+	// DeleteNotebookPermanently queues a job that removes the notebook with
+	// its snapnotes, prep pilot, test results, shares and search entries.
+	// It fails with ErrVersionConflict unless the notebook is still at
+	// expectedVersion; nil skips the check.
+	DeleteNotebookPermanently(userID, notebookID string, expectedVersion *int64) (*Job, error)
+	// PurgeExpired permanently deletes notebooks that have been in the trash
+	// longer than the retention period and returns how many were deleted.
+	PurgeExpired() (int, error)
+	// RunJob runs purge_notebook and purge_trash jobs
+	RunJob(job *Job) error
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotebookOrigin records which notebook a copy was made from and which
+// versions of the source's snapnotes and prep pilot it last took over
+type NotebookOrigin struct {
+	NotebookID       primitive.ObjectID  `bson:"notebook_id" json:"notebook_id"`
+	SnapnotesID      *primitive.ObjectID `bson:"snapnotes_id,omitempty" json:"snapnotes_id,omitempty"`
+	SnapnotesVersion int64               `bson:"snapnotes_version,omitempty" json:"snapnotes_version,omitempty"`
+	PrepPilotID      *primitive.ObjectID `bson:"prep_pilot_id,omitempty" json:"prep_pilot_id,omitempty"`
+	PrepPilotVersion int64               `bson:"prep_pilot_version,omitempty" json:"prep_pilot_version,omitempty"`
+	SyncedAt         time.Time           `bson:"synced_at" json:"synced_at"`
+}
+
+// DuplicateRequest is the body of POST /notebooks/:id/duplicate
+type DuplicateRequest struct {
+	// Name defaults to the source name followed by "(copy)"
+	Name string `json:"name"`
+}
+
+// OriginStatus tells whether the source of a copy has changed since the
+// copy was made or last synced
+type OriginStatus struct {
+	Origin *NotebookOrigin `json:"origin"`
+	// SourceAvailable is false once the source was deleted or is no longer
+	// shared with the caller
+	SourceAvailable  bool `json:"source_available"`
+	UpdatesAvailable bool `json:"updates_available"`
+}
+
+type NotebookCopyUseCase interface {
+	// DuplicateNotebook copies a notebook with its snapnotes and prep pilot
+	// into the caller's account. Owners get a duplicate, users the notebook
+	// is shared with get a fork they own.
+	DuplicateNotebook(userID, notebookID string, request DuplicateRequest) (*Notebook, error)
+	GetOriginStatus(userID, notebookID string) (*OriginStatus, error)
+	// SyncFromOrigin replaces the copy's snapnotes and prep pilot with the
+	// source's current ones. The notebook's own fields are kept.
+	SyncFromOrigin(userID, notebookID string) (*Notebook, error)
+}
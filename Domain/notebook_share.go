@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Share roles, from least to most privileged. The notebook owner is not
+// stored as a share.
+const (
+	ShareRoleViewer = "viewer"
+	ShareRoleEditor = "editor"
+	ShareRoleOwner  = "owner"
+)
+
+// NotebookShare grants a user access to someone else's notebook. Invitations
+// to addresses without an account are stored with a nil UserID and are
+// claimed once a user with a verified matching email shows up.
+type NotebookShare struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	NotebookID primitive.ObjectID  `bson:"notebook_id" json:"notebook_id"`
+	OwnerID    primitive.ObjectID  `bson:"owner_id" json:"owner_id"`
+	UserID     *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email      string              `bson:"email" json:"email"`
+	Role       string              `bson:"role" json:"role"`
+	InvitedBy  primitive.ObjectID  `bson:"invited_by" json:"invited_by"`
+	AcceptedAt *time.Time          `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// SharedNotebook is a notebook as seen by a user it was shared with
+type SharedNotebook struct {
+	*Notebook
+	Role string `json:"role"`
+}
+
+// CanEdit reports whether the role may modify notebook content
+func CanEdit(role string) bool {
+	return role == ShareRoleEditor || role == ShareRoleOwner
+}
+
+type NotebookShareRepository interface {
+	Create(share *NotebookShare) error
+	GetByID(id primitive.ObjectID) (*NotebookShare, error)
+	FindByNotebookAndUser(notebookID, userID primitive.ObjectID) (*NotebookShare, error)
+	FindByNotebookAndEmail(notebookID primitive.ObjectID, email string) (*NotebookShare, error)
+	GetByNotebookID(notebookID primitive.ObjectID) ([]*NotebookShare, error)
+	GetByUserID(userID primitive.ObjectID) ([]*NotebookShare, error)
+	UpdateRole(id primitive.ObjectID, role string) error
+	// ClaimInvites binds pending invitations for email to userID and returns
+	// how many were claimed.
+	ClaimInvites(email string, userID primitive.ObjectID) (int64, error)
+	Delete(id primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SourceContentTypes maps the file extensions accepted as notebook sources to
+// the content type they are stored and served with
+var SourceContentTypes = map[string]string{
+	".pdf":      "application/pdf",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".txt":      "text/plain; charset=utf-8",
+	".md":       "text/markdown; charset=utf-8",
+	".markdown": "text/markdown; charset=utf-8",
+	".html":     "text/html; charset=utf-8",
+	".htm":      "text/html; charset=utf-8",
+}
+
+// NotebookSource is a file uploaded to a notebook. The content lives in the
+// blob store under StorageKey; this document only holds its metadata.
+type NotebookSource struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	NotebookID  primitive.ObjectID `bson:"notebook_id" json:"notebook_id"`
+	UploadedBy  primitive.ObjectID `bson:"uploaded_by" json:"uploaded_by"`
+	FileName    string             `bson:"file_name" json:"file_name"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	// Checksum is the hex SHA-256 of the content
+	Checksum   string           `bson:"checksum" json:"checksum"`
+	StorageKey string           `bson:"storage_key" json:"-"`
+	Extraction SourceExtraction `bson:"extraction" json:"extraction"`
+	CreatedAt  time.Time        `bson:"created_at" json:"created_at"`
+}
+
+// SourceUpload is a file as received from the client. The content type is
+// derived from the file name and checked against the content, whatever the
+// client declared.
+type SourceUpload struct {
+	FileName string
+	Content  io.Reader
+}
+
+type NotebookSourceRepository interface {
+	Create(source *NotebookSource) error
+	GetByID(id primitive.ObjectID) (*NotebookSource, error)
+	GetByNotebookID(notebookID primitive.ObjectID) ([]*NotebookSource, error)
+	Delete(id primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
+	// FindPendingExtractions returns up to limit sources waiting for
+	// extraction, or whose extraction started before staleBefore and never
+	// finished, oldest first
+	FindPendingExtractions(staleBefore time.Time, limit int) ([]*NotebookSource, error)
+	// ClaimExtraction marks the source as running and returns it if it is
+	// one FindPendingExtractions would return, nil otherwise
+	ClaimExtraction(id primitive.ObjectID, staleBefore time.Time) (*NotebookSource, error)
+	// SetExtraction returns ErrSourceNotFound if the source was deleted
+	SetExtraction(id primitive.ObjectID, extraction SourceExtraction) error
+}
+
+// BlobStore keeps file contents by key
+type BlobStore interface {
+	Put(key string, content io.Reader, size int64, contentType string) error
+	// Get returns ErrBlobNotFound when nothing is stored under key
+	Get(key string) (io.ReadCloser, error)
+	// Delete succeeds when nothing is stored under key
+	Delete(key string) error
+}
+
+type SourceUseCase interface {
+	UploadSource(userID, notebookID string, upload SourceUpload) (*NotebookSource, error)
+	ListSources(userID, notebookID string) ([]*NotebookSource, error)
+	// OpenSource returns the source with its content, which the caller closes
+	OpenSource(userID, notebookID, sourceID string) (*NotebookSource, io.ReadCloser, error)
+	DeleteSource(userID, notebookID, sourceID string) error
+	// MaxSourceSize is the largest file accepted, in bytes
+	MaxSourceSize() int64
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// Page sizes for list endpoints
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Sort orders
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// PageOptions selects one page of a sorted listing. Cursor is the opaque
+// NextCursor of the previous page and is only valid for the same sort.
+type PageOptions struct {
+	Limit  int
+	Cursor string
+	SortBy string
+	Order  string
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListRequest holds the pagination query parameters shared by list endpoints
+type ListRequest struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort"`
+	Order  string `form:"order"`
+}
+
+// NotebookListRequest is the query string of GET /notebooks/user
+type NotebookListRequest struct {
+	ListRequest
+	// FolderID is a folder ID or "root" for notebooks outside any folder
+	FolderID    string    `form:"folder_id"`
+	Tag         string    `form:"tag"`
+	Type        string    `form:"type"`
+	CreatedFrom time.Time `form:"created_from"`
+	CreatedTo   time.Time `form:"created_to"`
+}
+
+// TestResultListRequest is the query string of the test result listings
+type TestResultListRequest struct {
+	ListRequest
+	CompletedFrom time.Time `form:"completed_from"`
+	CompletedTo   time.Time `form:"completed_to"`
+	MinScore      *float64  `form:"min_score"`
+	MaxScore      *float64  `form:"max_score"`
+}
@@ -27,12 +27,17 @@ type PrepPilot struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	NotebookID primitive.ObjectID `bson:"notebook_id" json:"notebook_id"`
 	Chapters   []Chapter          `bson:"chapters" json:"chapters"`
+	Version    int64              `bson:"version" json:"version"`
 }
 
 type PrepPilotRepository interface {
 	GetByID(id primitive.ObjectID) (*PrepPilot, error)
 	GetByNotebookID(notebookID primitive.ObjectID) (*PrepPilot, error)
 	Create(prepPilot *PrepPilot) error
+	// Update saves the prep pilot if the stored version still equals
+	// prepPilot.Version and increments it, otherwise it returns
+	// ErrVersionConflict
 	Update(prepPilot *PrepPilot) error
 	Delete(id primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
 }
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Documents that keep a revision history
+const (
+	RevisionDocumentNotebook  = "notebook"
+	RevisionDocumentSnapnotes = "snapnotes"
+	RevisionDocumentPrepPilot = "prep_pilot"
+)
+
+var RevisionDocuments = []string{RevisionDocumentNotebook, RevisionDocumentSnapnotes, RevisionDocumentPrepPilot}
+
+// Revision actions
+const (
+	RevisionActionCreate   = "create"
+	RevisionActionUpdate   = "update"
+	RevisionActionRestore  = "restore"
+	RevisionActionSync     = "sync"
+	RevisionActionGenerate = "generate"
+	// RevisionActionBaseline records the state a document had before the
+	// first change in its history, for documents older than revisions
+	RevisionActionBaseline = "baseline"
+)
+
+// Revision is one entry in the append-only history of a notebook, its
+// snapnotes or its prep pilot. Exactly one of Notebook, Snapnotes and
+// PrepPilot is set and holds the document as it was after the change.
+// Baseline revisions have the zero AuthorID, their author is unknown.
+type Revision struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	NotebookID   primitive.ObjectID `bson:"notebook_id" json:"notebook_id"`
+	DocumentType string             `bson:"document_type" json:"document_type"`
+	DocumentID   primitive.ObjectID `bson:"document_id" json:"document_id"`
+	// Number counts the revisions of one document, starting at 1
+	Number   int                `bson:"number" json:"number"`
+	Action   string             `bson:"action" json:"action"`
+	AuthorID primitive.ObjectID `bson:"author_id" json:"author_id"`
+	// ChangedFields are the top-level fields that differ from the previous
+	// revision of the document
+	ChangedFields []string `bson:"changed_fields" json:"changed_fields"`
+	// RestoredFrom is the revision a restore went back to
+	RestoredFrom *primitive.ObjectID `bson:"restored_from,omitempty" json:"restored_from,omitempty"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+	Notebook     *Notebook           `bson:"notebook,omitempty" json:"notebook,omitempty"`
+	Snapnotes    *Snapnotes          `bson:"snapnotes,omitempty" json:"snapnotes,omitempty"`
+	PrepPilot    *PrepPilot          `bson:"prep_pilot,omitempty" json:"prep_pilot,omitempty"`
+}
+
+// RevisionChange is one difference between two revisions. Path addresses the
+// changed value, e.g. summaryByChapter[1].keyPoints[0]; Before is null for
+// added values and After is null for removed ones.
+type RevisionChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type RevisionDiff struct {
+	From    *Revision        `json:"from"`
+	To      *Revision        `json:"to"`
+	Changes []RevisionChange `json:"changes"`
+}
+
+// RevisionQuery selects a page of a notebook's revisions
+type RevisionQuery struct {
+	// DocumentType limits the listing to one kind of document
+	DocumentType string
+	PageOptions
+}
+
+// Sort keys accepted by revision listings
+var RevisionSortFields = []string{"created_at"}
+
+// RevisionListRequest is the query string of GET /notebooks/:id/revisions
+type RevisionListRequest struct {
+	ListRequest
+	Document string `form:"document"`
+}
+
+type RevisionRepository interface {
+	// Create returns ErrVersionConflict when the document already has a
+	// revision with the same number
+	Create(revision *Revision) error
+	GetByID(id primitive.ObjectID) (*Revision, error)
+	// Latest returns the newest revision of a document, nil if it has none
+	Latest(documentType string, documentID primitive.ObjectID) (*Revision, error)
+	Find(notebookID primitive.ObjectID, query RevisionQuery) (*Page[*Revision], error)
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
+}
+
+type RevisionUseCase interface {
+	// ListRevisions returns a page of revisions without their snapshots
+	ListRevisions(userID, notebookID string, request RevisionListRequest) (*Page[*Revision], error)
+	GetRevision(userID, notebookID, revisionID string) (*Revision, error)
+	// DiffRevisions compares two revisions of the same document
+	DiffRevisions(userID, notebookID, fromID, toID string) (*RevisionDiff, error)
+	// RestoreRevision puts the snapnotes or prep pilot of a revision back,
+	// links it to the notebook again and records the restore as a new
+	// revision
+	RestoreRevision(userID, notebookID, revisionID string) (*Revision, error)
+}
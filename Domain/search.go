@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of searchable content
+const (
+	SearchKindNotebook  = "notebook"
+	SearchKindSummary   = "summary"
+	SearchKindKeyPoint  = "key_point"
+	SearchKindFlashcard = "flashcard"
+	SearchKindQuestion  = "question"
+)
+
+var SearchKinds = []string{
+	SearchKindNotebook,
+	SearchKindSummary,
+	SearchKindKeyPoint,
+	SearchKindFlashcard,
+	SearchKindQuestion,
+}
+
+// SearchLocation points at the matched text inside a notebook. Indexes are
+// zero-based positions in the snapnotes or prep pilot chapters.
+type SearchLocation struct {
+	Field        string `bson:"field" json:"field"`
+	ChapterIndex *int   `bson:"chapter_index,omitempty" json:"chapter_index,omitempty"`
+	ItemIndex    *int   `bson:"item_index,omitempty" json:"item_index,omitempty"`
+}
+
+// SearchDocument is one indexed piece of text
+type SearchDocument struct {
+	ID           string             `bson:"_id"`
+	UserID       primitive.ObjectID `bson:"user_id"`
+	NotebookID   primitive.ObjectID `bson:"notebook_id"`
+	NotebookName string             `bson:"notebook_name"`
+	Kind         string             `bson:"kind"`
+	Location     SearchLocation     `bson:"location"`
+	Text         string             `bson:"text"`
+}
+
+// TextRange is a half-open range of character (rune) offsets
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type SearchHit struct {
+	NotebookID   primitive.ObjectID `json:"notebook_id"`
+	NotebookName string             `json:"notebook_name"`
+	Kind         string             `json:"kind"`
+	Location     SearchLocation     `json:"location"`
+	Snippet      string             `json:"snippet"`
+	Highlights   []TextRange        `json:"highlights"`
+	Score        float64            `json:"score"`
+}
+
+// SearchQuery searches the notebooks owned by UserID plus NotebookIDs
+type SearchQuery struct {
+	Text        string
+	UserID      primitive.ObjectID
+	NotebookIDs []primitive.ObjectID
+	Kinds       []string
+	Limit       int
+}
+
+type SearchIndex interface {
+	// ReplaceNotebook replaces all indexed documents of a notebook
+	ReplaceNotebook(notebookID primitive.ObjectID, docs []SearchDocument) error
+	DeleteNotebook(notebookID primitive.ObjectID) error
+	Search(query SearchQuery) ([]SearchHit, error)
+}
+
+// NotebookIndexer keeps the search index in step with notebook changes
+type NotebookIndexer interface {
+	IndexNotebook(notebook *Notebook) error
+	RemoveNotebook(notebookID primitive.ObjectID) error
+}
+
+type SearchUseCase interface {
+	NotebookIndexer
+	Search(userID, text string, kinds []string, limit int) ([]SearchHit, error)
+	// ReindexUser rebuilds the index for all notebooks of the user and
+	// returns how many were indexed.
+	ReindexUser(userID string) (int, error)
+}
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShareLink is a public, read-only link to a notebook. Only the SHA-256 hash
+// of the token is stored; the plaintext is returned once when the link is
+// created.
+type ShareLink struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Active reports whether the link can still be used at t
+func (l *ShareLink) Active(t time.Time) bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || t.Before(*l.ExpiresAt)
+}
+
+// PublicNotebook is what anonymous visitors of a share link see
+type PublicNotebook struct {
+	Name         string `json:"name"`
+	Icon         string `json:"icon"`
+	Color        string `json:"color"`
+	Type         string `json:"type"`
+	HasSnapnotes bool   `json:"has_snapnotes"`
+	HasPrepPilot bool   `json:"has_prep_pilot"`
+}
+
+// PracticeQuestion is a prep pilot question with the answer withheld
+type PracticeQuestion struct {
+	Question string         `json:"question"`
+	Options  QuestionOption `json:"options"`
+}
+
+type PracticeChapter struct {
+	ChapterTitle string             `json:"chapterTitle"`
+	Questions    []PracticeQuestion `json:"questions"`
+}
+
+// PracticePrepPilot is a prep pilot in practice mode, answers and
+// explanations are only revealed by submitting the quiz.
+type PracticePrepPilot struct {
+	Chapters []PracticeChapter `json:"chapters"`
+}
+
+// PracticeAnswer is one answer of a submitted practice quiz. Questions are
+// addressed by their position in the prep pilot.
+type PracticeAnswer struct {
+	ChapterIndex  int    `json:"chapter_index"`
+	QuestionIndex int    `json:"question_index"`
+	Answer        string `json:"answer"`
+}
+
+// PracticeResult is the graded, unsaved result of a practice quiz
+type PracticeResult struct {
+	Answers        []TestAnswer `json:"answers"`
+	Score          float64      `json:"score"`
+	TotalQuestions int          `json:"total_questions"`
+	CorrectAnswers int          `json:"correct_answers"`
+}
+
+type ShareLinkUseCase interface {
+	// CreateShareLink returns the stored link and its plaintext token, which
+	// is only available at creation time.
+	CreateShareLink(userID, notebookID string, expiresAt *time.Time) (*ShareLink, string, error)
+	ListShareLinks(userID, notebookID string) ([]ShareLink, error)
+	RevokeShareLink(userID, notebookID, linkID string) error
+
+	GetPublicNotebook(token string) (*PublicNotebook, error)
+	GetPublicSnapnotes(token string) (*Snapnotes, error)
+	GetPracticePrepPilot(token string) (*PracticePrepPilot, error)
+	SubmitPracticeQuiz(token string, answers []PracticeAnswer) (*PracticeResult, error)
+}
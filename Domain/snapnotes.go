@@ -22,12 +22,20 @@ type ChapterFlashcards struct {
 
 type Snapnotes struct {
 	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	NotebookID       primitive.ObjectID  `bson:"notebook_id,omitempty" json:"notebook_id,omitempty"`
 	Title            string              `bson:"title" json:"title"`
 	SummaryByChapter []ChapterSummary    `bson:"summaryByChapter" json:"summaryByChapter"`
 	Flashcards       []ChapterFlashcards `bson:"flashcards" json:"flashcards"`
+	Version          int64               `bson:"version" json:"version"`
 }
 
 type SnapnotesRepository interface {
 	GetByID(id primitive.ObjectID) (*Snapnotes, error)
 	Create(content *Snapnotes) error
+	// Update replaces the snapnotes if the stored version still equals
+	// content.Version and increments it, otherwise it returns
+	// ErrVersionConflict
+	Update(content *Snapnotes) error
+	Delete(id primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
 }
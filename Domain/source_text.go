@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Text extraction states of a source and, summarized, of a notebook
+const (
+	ExtractionPending   = "pending"
+	ExtractionRunning   = "running"
+	ExtractionCompleted = "completed"
+	ExtractionFailed    = "failed"
+)
+
+// SourceExtraction tracks the text extraction of one source
+type SourceExtraction struct {
+	Status string `bson:"status" json:"status"`
+	// Error explains why a failed extraction gave up
+	Error      string     `bson:"error,omitempty" json:"error,omitempty"`
+	Attempts   int        `bson:"attempts" json:"attempts"`
+	StartedAt  *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// NotebookExtraction summarizes the extraction of all sources of a notebook.
+// Status is running while any source is pending or running, then failed if
+// any source failed and completed otherwise.
+type NotebookExtraction struct {
+	Status    string    `bson:"status" json:"status"`
+	Sources   int       `bson:"sources" json:"sources"`
+	Completed int       `bson:"completed" json:"completed"`
+	Failed    int       `bson:"failed" json:"failed"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// TextChapter is a part of a source between two headings
+type TextChapter struct {
+	// Title is the heading that starts the chapter, empty for text before
+	// the first heading
+	Title string `bson:"title" json:"title"`
+	// Text holds paragraphs separated by blank lines
+	Text string `bson:"text" json:"text"`
+}
+
+// SourceText is the normalized plain text of a source, split into chapters
+type SourceText struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	NotebookID primitive.ObjectID `bson:"notebook_id" json:"notebook_id"`
+	SourceID   primitive.ObjectID `bson:"source_id" json:"source_id"`
+	Chapters   []TextChapter      `bson:"chapters" json:"chapters"`
+	WordCount  int                `bson:"word_count" json:"word_count"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type SourceTextRepository interface {
+	// Save stores the text of a source, replacing an earlier extraction
+	Save(text *SourceText) error
+	GetBySourceID(sourceID primitive.ObjectID) (*SourceText, error)
+	GetByNotebookID(notebookID primitive.ObjectID) ([]*SourceText, error)
+	DeleteBySourceID(sourceID primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
+}
+
+// TextExtractor turns a file into chapters of plain text. It fails for
+// content types it does not support, for files without any text and for
+// files it cannot read by the deadline.
+type TextExtractor interface {
+	Extract(contentType string, content []byte, deadline time.Time) ([]TextChapter, error)
+}
+
+type ExtractionUseCase interface {
+	// GetSourceText returns the extracted text of a source, or
+	// ErrSourceTextNotFound while the extraction has not completed
+	GetSourceText(userID, notebookID, sourceID string) (*SourceText, error)
+	// RetryExtraction queues a failed source for extraction again
+	RetryExtraction(userID, notebookID, sourceID string) (*NotebookSource, error)
+	// QueuePending queues an extraction for sources waiting for one whose job
+	// was lost. It returns how many extractions were queued.
+	QueuePending() (int, error)
+	// RunJob runs extract_source and queue_extractions jobs
+	RunJob(job *Job) error
+}
@@ -42,16 +42,31 @@ type TestResultRepository interface {
 	GetByNotebookID(notebookID primitive.ObjectID) ([]*TestResult, error)
 	GetByPrepPilotID(prepPilotID primitive.ObjectID) ([]*TestResult, error)
 	GetByUserAndNotebook(userID, notebookID primitive.ObjectID) ([]*TestResult, error)
+	Find(userID primitive.ObjectID, query TestResultQuery) (*Page[*TestResult], error)
 	Update(testResult *TestResult) error
 	Delete(id primitive.ObjectID) error
+	DeleteByNotebookID(notebookID primitive.ObjectID) error
 }
 
+// TestResultQuery selects a page of a user's test results
+type TestResultQuery struct {
+	NotebookID    *primitive.ObjectID
+	CompletedFrom *time.Time
+	CompletedTo   *time.Time
+	MinScore      *float64
+	MaxScore      *float64
+	PageOptions
+}
+
+// Sort keys accepted by test result listings
+var TestResultSortFields = []string{"created_at", "completed_at", "score"}
+
 // TestResultUseCase interface for business logic
 type TestResultUseCase interface {
 	SubmitTestResult(userID string, testResult *TestResult) error
 	GetTestResultByID(userID string, testResultID string) (*TestResult, error)
-	GetUserTestResults(userID string) ([]*TestResult, error)
-	GetNotebookTestResults(userID string, notebookID string) ([]*TestResult, error)
+	GetUserTestResults(userID string, request TestResultListRequest) (*Page[*TestResult], error)
+	GetNotebookTestResults(userID string, notebookID string, request TestResultListRequest) (*Page[*TestResult], error)
 	GetTestResultStats(userID string, notebookID string) (*TestStats, error)
 }
 
@@ -0,0 +1,23 @@
+package domain
+
+// UnitOfWork applies changes that span several collections together or not
+// at all.
+type UnitOfWork interface {
+	// Do runs fn in a transaction. The repositories passed to fn take part in
+	// it; the changes are committed when fn returns nil and rolled back
+	// otherwise. fn may run more than once when a transient error causes a
+	// retry, so it must not have side effects outside those repositories.
+	Do(fn func(repos TransactionRepositories) error) error
+}
+
+// TransactionRepositories are the repositories bound to one unit of work
+type TransactionRepositories struct {
+	Notebooks   NotebookRepository
+	Snapnotes   SnapnotesRepository
+	PrepPilots  PrepPilotRepository
+	TestResults TestResultRepository
+	Shares      NotebookShareRepository
+	Revisions   RevisionRepository
+	Sources     NotebookSourceRepository
+	SourceTexts SourceTextRepository
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,8 +17,44 @@ type User struct {
 	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
 	Settings   UserSettings       `bson:"settings" json:"settings"`
+	// EmailVerified is set once the user follows the link sent at registration
+	EmailVerified bool `bson:"email_verified" json:"email_verified"`
+	// TokenVersion is embedded in issued JWTs; bumping it revokes them all.
+	TokenVersion int               `bson:"token_version" json:"-"`
+	TwoFactor    TwoFactorSettings `bson:"two_factor" json:"two_factor"`
+	// Identities are external sign-in accounts (OIDC) linked to this user
+	Identities []ExternalIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
 }
 
+// ExternalIdentity links a user to the subject of an OpenID Connect provider.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"-"`
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// TwoFactorSettings holds the TOTP state of a user. Secrets and recovery
+// codes are never serialized to JSON.
+type TwoFactorSettings struct {
+	Enabled       bool   `bson:"enabled" json:"enabled"`
+	Secret        string `bson:"secret,omitempty" json:"-"`
+	PendingSecret string `bson:"pending_secret,omitempty" json:"-"`
+	// SHA-256 hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes,omitempty" json:"-"`
+	// LastUsedStep is the TOTP time step of the last accepted code, used to
+	// reject replays of the same code
+	LastUsedStep int64 `bson:"last_used_step,omitempty" json:"-"`
+}
+
+// Policies for users who have not verified their email yet, selected with
+// UNVERIFIED_USER_POLICY.
+const (
+	UnverifiedPolicyAllow    = "allow"     // full access
+	UnverifiedPolicyReadOnly = "read_only" // may log in but only read data
+	UnverifiedPolicyDeny     = "deny"      // may not log in
+)
+
 type UserSettings struct {
 	Theme                string `bson:"theme" json:"theme"`
 	Language             string `bson:"language" json:"language"`
@@ -30,6 +67,7 @@ type UserRepository interface {
 	Create(user *User) error
 	FindByEmail(email string) (*User, error)
 	FindByID(id string) (*User, error)
+	FindByIdentity(provider, subject string) (*User, error)
 	Update(user *User) error
 	Delete(id string) error
 }
@@ -40,7 +78,35 @@ type UserUseCase interface {
 	GetUserByID(id string) (*User, error)
 	GetUserByEmail(email string) (*User, error)
 	VerifyPassword(hash, password string) bool // Changed to bool to match the return type of PasswordComparator
+	UpgradePasswordHash(user *User, password string)
 	GenerateToken(user *User) (string, error)
 	UpdateUser(user *User) error
 	DeleteUser(id string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, newPassword string) error
+	SendVerificationEmail(user *User) error
+	ResendVerificationEmail(email string) error
+	VerifyEmail(token string) error
+	CheckLoginAllowed(user *User) error
+	// ChangePassword requires the current password and revokes all sessions.
+	// It returns the updated user so a fresh token can be issued.
+	ChangePassword(userID, currentPassword, newPassword string) (*User, error)
+	RequestEmailChange(userID, currentPassword, newEmail string) error
+	ConfirmEmailChange(token string) error
+	BeginTwoFactorEnrollment(userID string) (secret string, uri string, err error)
+	ConfirmTwoFactorEnrollment(userID, code string) (recoveryCodes []string, err error)
+	DisableTwoFactor(userID, password, code string) error
+	GenerateTwoFactorChallenge(user *User) (string, error)
+	CompleteTwoFactorLogin(challengeToken, code string) (*User, error)
+}
+
+// OIDCUseCase signs users in through external OpenID Connect providers.
+type OIDCUseCase interface {
+	// BeginLogin returns the provider URL to redirect to and an opaque flow
+	// token that the client must hand back to CompleteLogin (as a cookie).
+	BeginLogin(ctx context.Context, provider string) (authURL string, flowToken string, err error)
+	// CompleteLogin verifies the callback and returns the linked user, creating
+	// one if no account exists for the verified email yet.
+	CompleteLogin(ctx context.Context, provider, state, code, flowToken string) (*User, error)
+	Providers() []string
 }
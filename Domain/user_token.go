@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Token purposes stored on UserToken
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+// UserToken is a single-use secret emailed to a user. Only the SHA-256 hash of
+// the token is stored, the plaintext value only ever exists in the email.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	// NewEmail is the address an email change token confirms
+	NewEmail string `bson:"new_email,omitempty" json:"new_email,omitempty"`
+}
+
+type UserTokenRepository interface {
+	Create(token *UserToken) error
+	FindByHash(purpose, tokenHash string) (*UserToken, error)
+	FindLatestByUserID(userID primitive.ObjectID, purpose string) (*UserToken, error)
+	// MarkUsed atomically flags the token as used. It returns false if the
+	// token had already been used.
+	MarkUsed(id primitive.ObjectID) (bool, error)
+	DeleteByUserID(userID primitive.ObjectID, purpose string) error
+}
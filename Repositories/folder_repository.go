@@ -0,0 +1,120 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type folderRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewFolderRepository(db *mongo.Database) domain.FolderRepository {
+	r := &folderRepository{
+		db:         db,
+		collection: db.Collection("folders"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *folderRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "name", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating folders indexes: %v", err)
+	}
+}
+
+func (r *folderRepository) Create(folder *domain.Folder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	folder.CreatedAt = time.Now()
+	folder.UpdatedAt = folder.CreatedAt
+
+	result, err := r.collection.InsertOne(ctx, folder)
+	if err != nil {
+		return err
+	}
+
+	folder.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *folderRepository) GetByID(id primitive.ObjectID) (*domain.Folder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var folder domain.Folder
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&folder)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (r *folderRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.Folder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	folders := []*domain.Folder{}
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *folderRepository) Update(folder *domain.Folder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	folder.UpdatedAt = time.Now()
+
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": folder.ID}, folder)
+	return err
+}
+
+func (r *folderRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *folderRepository) Reparent(userID, from primitive.ObjectID, to *primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"parent_id": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	if to != nil {
+		update = bson.M{"$set": bson.M{"parent_id": *to, "updated_at": time.Now()}}
+	}
+
+	_, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID, "parent_id": from}, update)
+	return err
+}
@@ -0,0 +1,254 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long finished jobs are kept. Dead jobs stay longer so failures can be
+// looked into and retried.
+const (
+	succeededJobRetention = 7 * 24 * time.Hour
+	deadJobRetention      = 30 * 24 * time.Hour
+)
+
+type jobRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewJobRepository(db *mongo.Database) domain.JobRepository {
+	r := &jobRepository{
+		db:         db,
+		collection: db.Collection("jobs"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *jobRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lease_expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "notebook_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{
+			// Only queued and running jobs keep their dedupe key
+			Keys: bson.D{{Key: "dedupe_key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"dedupe_key": bson.M{"$exists": true}}),
+		},
+		// Let MongoDB drop finished jobs once they expire
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Printf("Error creating jobs indexes: %v", err)
+	}
+}
+
+func (r *jobRepository) Enqueue(job *domain.Job) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job.ID = primitive.NewObjectID()
+	job.Status = domain.JobQueued
+	job.CreatedAt = time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = job.CreatedAt
+	}
+
+	// The job holding the key may finish between the failed insert and the
+	// lookup, then the insert is tried again
+	for range 3 {
+		_, err := r.collection.InsertOne(ctx, job)
+		if err == nil || !mongo.IsDuplicateKeyError(err) || job.DedupeKey == "" {
+			return job, err
+		}
+		existing, err := r.findOne(ctx, bson.M{"dedupe_key": job.DedupeKey})
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+	return nil, errors.New("could not queue job " + job.DedupeKey)
+}
+
+func (r *jobRepository) GetByID(id primitive.ObjectID) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *jobRepository) findOne(ctx context.Context, filter bson.M) (*domain.Job, error) {
+	var job domain.Job
+	err := r.collection.FindOne(ctx, filter).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *jobRepository) Find(notebookID primitive.ObjectID, query domain.JobQuery) (*domain.Page[*domain.Job], error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"notebook_id": notebookID}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	return findPage[*domain.Job](ctx, r.collection, filter, query.PageOptions)
+}
+
+func (r *jobRepository) Lease(workerID string, lease time.Duration) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": domain.JobQueued, "run_at": bson.M{"$lte": now}},
+		// The worker holding the job stopped renewing its lease
+		bson.M{"status": domain.JobRunning, "lease_expires_at": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":           domain.JobRunning,
+			"leased_by":        workerID,
+			"lease_expires_at": now.Add(lease),
+			"heartbeat_at":     now,
+			"started_at":       now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job domain.Job
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *jobRepository) Heartbeat(id primitive.ObjectID, workerID string, lease time.Duration) error {
+	now := time.Now()
+	return r.updateLeased(id, workerID, bson.M{
+		"$set": bson.M{"lease_expires_at": now.Add(lease), "heartbeat_at": now},
+	})
+}
+
+func (r *jobRepository) Complete(id primitive.ObjectID, workerID string) error {
+	now := time.Now()
+	return r.updateLeased(id, workerID, bson.M{
+		"$set": bson.M{
+			"status":      domain.JobSucceeded,
+			"finished_at": now,
+			"expires_at":  now.Add(succeededJobRetention),
+		},
+		"$unset": bson.M{"leased_by": "", "lease_expires_at": "", "dedupe_key": "", "error": ""},
+	})
+}
+
+func (r *jobRepository) Retry(id primitive.ObjectID, workerID, message string, runAt time.Time) error {
+	return r.updateLeased(id, workerID, bson.M{
+		"$set":   bson.M{"status": domain.JobQueued, "error": message, "run_at": runAt},
+		"$unset": bson.M{"leased_by": "", "lease_expires_at": ""},
+	})
+}
+
+func (r *jobRepository) Bury(id primitive.ObjectID, workerID, message string) error {
+	now := time.Now()
+	return r.updateLeased(id, workerID, bson.M{
+		"$set": bson.M{
+			"status":      domain.JobDead,
+			"error":       message,
+			"finished_at": now,
+			"expires_at":  now.Add(deadJobRetention),
+		},
+		"$unset": bson.M{"leased_by": "", "lease_expires_at": ""},
+		// Kept aside for Requeue, a dead job does not hold the key
+		"$rename": bson.M{"dedupe_key": "dead_dedupe_key"},
+	})
+}
+
+// updateLeased changes a running job as long as workerID still holds it
+func (r *jobRepository) updateLeased(id primitive.ObjectID, workerID string, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "status": domain.JobRunning, "leased_by": workerID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrJobLeaseLost
+	}
+	return nil
+}
+
+// Requeue gives the job its dedupe key back. When another job with the key
+// was queued in the meantime, that job is returned and the dead one stays
+// dead.
+func (r *jobRepository) Requeue(id primitive.ObjectID) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"status":   domain.JobQueued,
+			"attempts": 0,
+			"run_at":   time.Now(),
+			// Left out when the job has no key
+			"dedupe_key": "$dead_dedupe_key",
+		}},
+		bson.M{"$unset": bson.A{"finished_at", "expires_at", "dead_dedupe_key"}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// The live job holding the key may finish between the failed update and
+	// the lookup, then the update is tried again
+	for range 3 {
+		var job domain.Job
+		err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id, "status": domain.JobDead}, update, opts).Decode(&job)
+		if err == nil {
+			return &job, nil
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrJobNotFound
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		var dead struct {
+			DedupeKey string `bson:"dead_dedupe_key"`
+		}
+		if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&dead); err != nil {
+			return nil, err
+		}
+		existing, err := r.findOne(ctx, bson.M{"dedupe_key": dead.DedupeKey})
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+	return nil, errors.New("could not requeue job " + id.Hex())
+}
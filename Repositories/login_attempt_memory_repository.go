@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"sync"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// inMemoryLoginAttemptRepository keeps login attempts in process memory. It is
+// meant for single-instance deployments, local development and tests.
+type inMemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempt
+}
+
+func NewInMemoryLoginAttemptRepository() domain.LoginAttemptRepository {
+	return &inMemoryLoginAttemptRepository{
+		attempts: make(map[string]domain.LoginAttempt),
+	}
+}
+
+func (r *inMemoryLoginAttemptRepository) Get(key string) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (r *inMemoryLoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := r.attempts[key]
+	attempt.Key = key
+	if attempt.LastFailureAt.Before(at.Add(-window)) && attempt.LockedUntil.Before(at.Add(-window)) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	r.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (r *inMemoryLoginAttemptRepository) SetLockedUntil(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.LockedUntil = until
+		r.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (r *inMemoryLoginAttemptRepository) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
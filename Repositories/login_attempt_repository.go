@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type loginAttemptRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepository(db *mongo.Database) domain.LoginAttemptRepository {
+	r := &loginAttemptRepository{
+		db:         db,
+		collection: db.Collection("login_attempts"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *loginAttemptRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Forget about keys that have been quiet for a day
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "last_failure_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+	})
+	if err != nil {
+		log.Printf("Error creating login_attempts indexes: %v", err)
+	}
+}
+
+func (r *loginAttemptRepository) Get(key string) (*domain.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var attempt domain.LoginAttempt
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (*domain.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Pipeline update so the window check and the increment happen atomically
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{
+					bson.M{"$max": bson.A{
+						bson.M{"$ifNull": bson.A{"$last_failure_at", time.Time{}}},
+						bson.M{"$ifNull": bson.A{"$locked_until", time.Time{}}},
+					}},
+					at.Add(-window),
+				}},
+				1,
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+			}},
+			"last_failure_at": at,
+			"locked_until":    bson.M{"$ifNull": bson.A{"$locked_until", time.Time{}}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempt domain.LoginAttempt
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempt); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) SetLockedUntil(key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (r *loginAttemptRepository) Reset(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
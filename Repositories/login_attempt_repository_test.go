@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase returns a scratch database on the server at MONGODB_TEST_URI
+// and skips the test when it is not set
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("cognivia_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestLoginAttemptRepositories(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testLoginAttemptRepository(t, NewInMemoryLoginAttemptRepository())
+	})
+	t.Run("mongo", func(t *testing.T) {
+		testLoginAttemptRepository(t, NewLoginAttemptRepository(testDatabase(t)))
+	})
+}
+
+func testLoginAttemptRepository(t *testing.T, repo domain.LoginAttemptRepository) {
+	const window = 15 * time.Minute
+	start := time.Now().UTC().Truncate(time.Millisecond)
+
+	failures := func(key string, at time.Time) int {
+		t.Helper()
+		attempt, err := repo.RecordFailure(key, at, window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempt.Key != key || !attempt.LastFailureAt.Equal(at) {
+			t.Errorf("got %+v", attempt)
+		}
+		return attempt.Failures
+	}
+
+	if attempt, err := repo.Get("email:ada@example.com"); attempt != nil || err != nil {
+		t.Fatalf("unknown key: got %+v, %v", attempt, err)
+	}
+	// Locking a key without failures does not create it
+	repo.SetLockedUntil("email:ada@example.com", start.Add(time.Hour))
+	if attempt, _ := repo.Get("email:ada@example.com"); attempt != nil {
+		t.Fatalf("locking created %+v", attempt)
+	}
+
+	for i, at := range []time.Time{start, start.Add(time.Minute), start.Add(window)} {
+		if got := failures("email:ada@example.com", at); got != i+1 {
+			t.Fatalf("failure %d counted as %d", i+1, got)
+		}
+	}
+	// Keys are counted apart
+	if got := failures("ip:192.0.2.1", start); got != 1 {
+		t.Errorf("other key counted %d failures", got)
+	}
+
+	// The window runs from the end of the lockout
+	lockedUntil := start.Add(window + time.Hour)
+	if err := repo.SetLockedUntil("email:ada@example.com", lockedUntil); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := repo.Get("email:ada@example.com"); attempt == nil || !attempt.LockedUntil.Equal(lockedUntil) || attempt.Failures != 3 {
+		t.Fatalf("got %+v", attempt)
+	}
+	if got := failures("email:ada@example.com", lockedUntil.Add(window-time.Second)); got != 4 {
+		t.Errorf("failure after the lockout counted as %d, want 4", got)
+	}
+	if got := failures("email:ada@example.com", lockedUntil.Add(3*window)); got != 1 {
+		t.Errorf("failure after a quiet window counted as %d, want 1", got)
+	}
+
+	if err := repo.Reset("email:ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := repo.Get("email:ada@example.com"); attempt != nil {
+		t.Errorf("reset kept %+v", attempt)
+	}
+	if attempt, _ := repo.Get("ip:192.0.2.1"); attempt == nil || attempt.Failures != 1 {
+		t.Errorf("reset of another key changed %+v", attempt)
+	}
+}
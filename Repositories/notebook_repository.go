@@ -3,6 +3,10 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	domain "cognivia-api/Domain"
@@ -10,28 +14,74 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type notebookRepository struct {
 	db         *mongo.Database
 	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
 }
 
 func NewNotebookRepository(db *mongo.Database) domain.NotebookRepository {
+	r := newNotebookRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newNotebookRepository(db *mongo.Database) *notebookRepository {
 	return &notebookRepository{
 		db:         db,
 		collection: db.Collection("notebooks"),
 	}
 }
 
-func (r *notebookRepository) Create(notebook *domain.Notebook) error {
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *notebookRepository) inSession(ctx context.Context) *notebookRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *notebookRepository) ensureIndexes() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "folder_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "share_links.token_hash", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "drive.checked_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating notebooks indexes: %v", err)
+	}
+}
+
+func (r *notebookRepository) Create(notebook *domain.Notebook) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
 	// Generate new ObjectID for the notebook
 	notebook.ID = primitive.NewObjectID()
 	notebook.CreatedAt = time.Now()
 	notebook.UpdatedAt = time.Now()
+	notebook.Version = 1
 
 	_, err := r.collection.InsertOne(ctx, notebook)
 	if err != nil {
@@ -42,7 +92,7 @@ func (r *notebookRepository) Create(notebook *domain.Notebook) error {
 }
 
 func (r *notebookRepository) GetByID(id primitive.ObjectID) (*domain.Notebook, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var notebook domain.Notebook
@@ -57,11 +107,11 @@ func (r *notebookRepository) GetByID(id primitive.ObjectID) (*domain.Notebook, e
 }
 
 func (r *notebookRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.Notebook, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var notebooks []*domain.Notebook
-	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return nil, err
 	}
@@ -74,26 +124,334 @@ func (r *notebookRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.N
 	return notebooks, nil
 }
 
+func (r *notebookRepository) Find(userID primitive.ObjectID, query domain.NotebookQuery) (*domain.Page[*domain.Notebook], error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": query.Trashed}}
+	if query.InRoot {
+		filter["folder_id"] = bson.M{"$exists": false}
+	} else if query.FolderID != nil {
+		filter["folder_id"] = *query.FolderID
+	}
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if created := timeRange(query.CreatedFrom, query.CreatedTo); created != nil {
+		filter["created_at"] = created
+	}
+
+	return findPage[*domain.Notebook](ctx, r.collection, filter, query.PageOptions)
+}
+
+func (r *notebookRepository) GetTags(userID primitive.ObjectID) ([]string, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	values, err := r.collection.Distinct(ctx, "tags", bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		if tag, ok := value.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (r *notebookRepository) MoveFolder(userID, from primitive.ObjectID, to *primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"folder_id": ""}, "$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+	if to != nil {
+		update = bson.M{"$set": bson.M{"folder_id": *to, "updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+	}
+
+	_, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID, "folder_id": from}, update)
+	return err
+}
+
 func (r *notebookRepository) Update(notebook *domain.Notebook) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
-	notebook.UpdatedAt = time.Now()
+	stored := *notebook
+	stored.UpdatedAt = time.Now()
+	stored.Version++
 
-	_, err := r.collection.ReplaceOne(
+	update, err := notebookUpdate(&stored)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.UpdateOne(ctx, versionFilter(notebook.ID, notebook.Version), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrVersionConflict
+	}
+	notebook.UpdatedAt, notebook.Version = stored.UpdatedAt, stored.Version
+	return nil
+}
+
+// notebookFields are the stored fields of a notebook, from its bson tags
+var notebookFields = bsonFieldNames(reflect.TypeOf(domain.Notebook{}))
+
+// notebookUpdate sets every field of notebook and unsets the empty ones,
+// except share links. They are only changed by AddShareLink and
+// RevokeShareLink, so an update made from a copy read before a link was
+// created or revoked neither drops nor revives it.
+func notebookUpdate(notebook *domain.Notebook) (bson.M, error) {
+	document, err := bson.Marshal(notebook)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(document).Elements()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]bson.RawValue, len(elements))
+	for _, element := range elements {
+		values[element.Key()] = element.Value()
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	for _, field := range notebookFields {
+		if field == "_id" || field == "share_links" {
+			continue
+		}
+		if value, ok := values[field]; ok {
+			set[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+func bsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("bson"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (r *notebookRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *notebookRepository) SoftDelete(id primitive.ObjectID, version int64, at time.Time) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": notebook.ID},
-		notebook,
+		versionFilter(id, version),
+		bson.M{"$set": bson.M{"deleted_at": at}, "$inc": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *notebookRepository) Restore(id primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"version": 1}},
 	)
 	return err
 }
 
-func (r *notebookRepository) Delete(id primitive.ObjectID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *notebookRepository) FindDeletedBefore(cutoff time.Time, limit int) ([]*domain.Notebook, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
-	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notebooks := []*domain.Notebook{}
+	if err := cursor.All(ctx, &notebooks); err != nil {
+		return nil, err
+	}
+
+	return notebooks, nil
+}
+
+func (r *notebookRepository) GetByIDs(ids []primitive.ObjectID) ([]*domain.Notebook, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	notebooks := []*domain.Notebook{}
+	if len(ids) == 0 {
+		return notebooks, nil
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &notebooks); err != nil {
+		return nil, err
+	}
+
+	return notebooks, nil
+}
+
+func (r *notebookRepository) FindByShareLinkHash(tokenHash string) (*domain.Notebook, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	var notebook domain.Notebook
+	err := r.collection.FindOne(ctx, bson.M{"share_links.token_hash": tokenHash}).Decode(&notebook)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notebook, nil
+}
+
+func (r *notebookRepository) AddShareLink(notebookID primitive.ObjectID, link *domain.ShareLink) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	link.ID = primitive.NewObjectID()
+	link.CreatedAt = time.Now()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": notebookID},
+		bson.M{"$push": bson.M{"share_links": link}, "$inc": bson.M{"version": 1}},
+	)
 	return err
 }
 
+func (r *notebookRepository) RevokeShareLink(notebookID, linkID primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": notebookID,
+			"share_links": bson.M{"$elemMatch": bson.M{
+				"_id":        linkID,
+				"revoked_at": bson.M{"$exists": false},
+			}},
+		},
+		bson.M{"$set": bson.M{"share_links.$.revoked_at": at}, "$inc": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (r *notebookRepository) SetExtraction(id primitive.ObjectID, extraction *domain.NotebookExtraction) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"extraction": extraction}, "$inc": bson.M{"version": 1}}
+	if extraction == nil {
+		update = bson.M{"$unset": bson.M{"extraction": ""}, "$inc": bson.M{"version": 1}}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *notebookRepository) SetDrive(id primitive.ObjectID, drive *domain.NotebookDrive, stale []string) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"drive": drive}, "$inc": bson.M{"version": 1}}
+	if len(stale) > 0 {
+		update["$addToSet"] = bson.M{"stale_content": bson.M{"$each": stale}}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *notebookRepository) MarkDriveChecked(id primitive.ObjectID, at time.Time, message string) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	sameError := bson.M{"$exists": false}
+	if message != "" {
+		sameError = bson.M{"$eq": message}
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "drive.error": sameError},
+		bson.M{"$set": bson.M{"drive.checked_at": at}},
+	)
+	if err != nil || result.MatchedCount > 0 {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"drive.checked_at": at, "drive.error": message}, "$inc": bson.M{"version": 1}}
+	if message == "" {
+		update = bson.M{"$set": bson.M{"drive.checked_at": at}, "$unset": bson.M{"drive.error": ""}, "$inc": bson.M{"version": 1}}
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *notebookRepository) FindDriveCheckDue(cutoff time.Time, limit int) ([]*domain.Notebook, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	filter := bson.M{
+		"drive.checked_at":  bson.M{"$lt": cutoff},
+		"google_drive_link": bson.M{"$nin": bson.A{nil, ""}},
+		"deleted_at":        bson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "drive.checked_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notebooks := []*domain.Notebook{}
+	if err := cursor.All(ctx, &notebooks); err != nil {
+		return nil, err
+	}
+
+	return notebooks, nil
+}
+
 // Add other repository methods as needed
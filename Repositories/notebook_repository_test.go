@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNotebookUpdateLeavesShareLinksAlone(t *testing.T) {
+	folderID := primitive.NewObjectID()
+	notebook := &domain.Notebook{
+		ID:         primitive.NewObjectID(),
+		UserID:     primitive.NewObjectID(),
+		FolderID:   &folderID,
+		Name:       "Biology",
+		Version:    4,
+		UpdatedAt:  time.Now(),
+		ShareLinks: []domain.ShareLink{{ID: primitive.NewObjectID(), TokenHash: "hash"}},
+	}
+
+	update, err := notebookUpdate(notebook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, unset := update["$set"].(bson.M), update["$unset"].(bson.M)
+
+	for _, field := range []string{"_id", "share_links"} {
+		if _, ok := set[field]; ok {
+			t.Errorf("%s is set", field)
+		}
+		if _, ok := unset[field]; ok {
+			t.Errorf("%s is unset", field)
+		}
+	}
+	for _, field := range []string{"user_id", "folder_id", "name", "version", "updated_at"} {
+		if _, ok := set[field]; !ok {
+			t.Errorf("%s is not set", field)
+		}
+	}
+	// Cleared optional fields are removed, e.g. when restoring from the trash
+	for _, field := range []string{"deleted_at", "snapnotes_id", "drive", "stale_content", "google_drive_link"} {
+		if _, ok := unset[field]; !ok {
+			t.Errorf("empty %s is not unset", field)
+		}
+	}
+
+	var name string
+	if err := set["name"].(bson.RawValue).Unmarshal(&name); err != nil || name != "Biology" {
+		t.Errorf("name set to %q, %v", name, err)
+	}
+}
@@ -0,0 +1,172 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type notebookShareRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
+}
+
+func NewNotebookShareRepository(db *mongo.Database) domain.NotebookShareRepository {
+	r := newNotebookShareRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newNotebookShareRepository(db *mongo.Database) *notebookShareRepository {
+	return &notebookShareRepository{
+		db:         db,
+		collection: db.Collection("notebook_shares"),
+	}
+}
+
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *notebookShareRepository) inSession(ctx context.Context) *notebookShareRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *notebookShareRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "notebook_id", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating notebook_shares indexes: %v", err)
+	}
+}
+
+func (r *notebookShareRepository) Create(share *domain.NotebookShare) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	share.CreatedAt = time.Now()
+	share.UpdatedAt = share.CreatedAt
+
+	result, err := r.collection.InsertOne(ctx, share)
+	if err != nil {
+		return err
+	}
+
+	share.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *notebookShareRepository) findOne(filter bson.M) (*domain.NotebookShare, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	var share domain.NotebookShare
+	err := r.collection.FindOne(ctx, filter).Decode(&share)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *notebookShareRepository) find(filter bson.M) ([]*domain.NotebookShare, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	shares := []*domain.NotebookShare{}
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func (r *notebookShareRepository) GetByID(id primitive.ObjectID) (*domain.NotebookShare, error) {
+	return r.findOne(bson.M{"_id": id})
+}
+
+func (r *notebookShareRepository) FindByNotebookAndUser(notebookID, userID primitive.ObjectID) (*domain.NotebookShare, error) {
+	return r.findOne(bson.M{"notebook_id": notebookID, "user_id": userID})
+}
+
+func (r *notebookShareRepository) FindByNotebookAndEmail(notebookID primitive.ObjectID, email string) (*domain.NotebookShare, error) {
+	return r.findOne(bson.M{"notebook_id": notebookID, "email": email})
+}
+
+func (r *notebookShareRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.NotebookShare, error) {
+	return r.find(bson.M{"notebook_id": notebookID})
+}
+
+func (r *notebookShareRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.NotebookShare, error) {
+	return r.find(bson.M{"user_id": userID})
+}
+
+func (r *notebookShareRepository) UpdateRole(id primitive.ObjectID, role string) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *notebookShareRepository) ClaimInvites(email string, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	now := time.Now()
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"email": email, "user_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"user_id": userID, "accepted_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *notebookShareRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *notebookShareRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -0,0 +1,184 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type notebookSourceRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
+}
+
+func NewNotebookSourceRepository(db *mongo.Database) domain.NotebookSourceRepository {
+	r := newNotebookSourceRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newNotebookSourceRepository(db *mongo.Database) *notebookSourceRepository {
+	return &notebookSourceRepository{
+		db:         db,
+		collection: db.Collection("notebook_sources"),
+	}
+}
+
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *notebookSourceRepository) inSession(ctx context.Context) *notebookSourceRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *notebookSourceRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "notebook_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "extraction.status", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating notebook_sources indexes: %v", err)
+	}
+}
+
+// Create inserts the source, keeping an ID the caller already picked so the
+// blob can be stored under it first
+func (r *notebookSourceRepository) Create(source *domain.NotebookSource) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	if source.ID.IsZero() {
+		source.ID = primitive.NewObjectID()
+	}
+	source.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, source)
+	return err
+}
+
+func (r *notebookSourceRepository) GetByID(id primitive.ObjectID) (*domain.NotebookSource, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	var source domain.NotebookSource
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&source)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &source, nil
+}
+
+func (r *notebookSourceRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.NotebookSource, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"notebook_id": notebookID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sources := []*domain.NotebookSource{}
+	if err := cursor.All(ctx, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+func (r *notebookSourceRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *notebookSourceRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
+
+func (r *notebookSourceRepository) FindPendingExtractions(staleBefore time.Time, limit int) ([]*domain.NotebookSource, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, pendingExtraction(bson.M{}, staleBefore), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sources := []*domain.NotebookSource{}
+	if err := cursor.All(ctx, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+func (r *notebookSourceRepository) ClaimExtraction(id primitive.ObjectID, staleBefore time.Time) (*domain.NotebookSource, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	update := bson.M{
+		"$set":   bson.M{"extraction.status": domain.ExtractionRunning, "extraction.started_at": time.Now()},
+		"$unset": bson.M{"extraction.error": "", "extraction.finished_at": ""},
+		"$inc":   bson.M{"extraction.attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var source domain.NotebookSource
+	err := r.collection.FindOneAndUpdate(ctx, pendingExtraction(bson.M{"_id": id}, staleBefore), update, opts).Decode(&source)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &source, nil
+}
+
+// pendingExtraction narrows filter to sources waiting for extraction and
+// extractions interrupted before staleBefore
+func pendingExtraction(filter bson.M, staleBefore time.Time) bson.M {
+	filter["$or"] = bson.A{
+		// Sources uploaded before extraction existed have no status
+		bson.M{"extraction.status": bson.M{"$in": bson.A{domain.ExtractionPending, nil}}},
+		bson.M{"extraction.status": domain.ExtractionRunning, "extraction.started_at": bson.M{"$lt": staleBefore}},
+	}
+	return filter
+}
+
+func (r *notebookSourceRepository) SetExtraction(id primitive.ObjectID, extraction domain.SourceExtraction) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"extraction": extraction}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrSourceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pageCursor is the decoded form of an opaque page cursor: the sort key and
+// _id of the last item of the previous page. The sort field is included so a
+// cursor cannot be reused with a different sort.
+type pageCursor struct {
+	SortBy string             `bson:"s"`
+	Value  bson.RawValue      `bson:"v"`
+	ID     primitive.ObjectID `bson:"id"`
+}
+
+// sortKeyTypes lists the BSON types a cursor value may have for each sort key.
+// A cursor holding any other type is refused, it would compare across types
+// and skip or repeat items.
+var sortKeyTypes = map[string][]bsontype.Type{
+	"name":         {bson.TypeString},
+	"created_at":   {bson.TypeDateTime},
+	"updated_at":   {bson.TypeDateTime},
+	"completed_at": {bson.TypeDateTime},
+	"deleted_at":   {bson.TypeDateTime},
+	"score":        {bson.TypeDouble, bson.TypeInt32, bson.TypeInt64},
+}
+
+func encodeCursor(c pageCursor) (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s, sortBy string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := bson.Unmarshal(data, &c); err != nil || c.SortBy != sortBy {
+		return nil, domain.ErrInvalidCursor
+	}
+	// The tie-breaker must be an ObjectID, the decoder would also accept a
+	// string
+	if id, ok := bson.Raw(data).Lookup("id").ObjectIDOK(); !ok || id.IsZero() {
+		return nil, domain.ErrInvalidCursor
+	}
+	if !slices.Contains(sortKeyTypes[sortBy], c.Value.Type) {
+		return nil, domain.ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// findPage runs filter sorted by page.SortBy with _id as tie-breaker and
+// returns up to page.Limit items after page.Cursor.
+func findPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, page domain.PageOptions) (*domain.Page[T], error) {
+	direction, comparison := -1, "$lt"
+	if page.Order == domain.SortAsc {
+		direction, comparison = 1, "$gt"
+	}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		filter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+			bson.M{page.SortBy: bson.M{comparison: c.Value}},
+			bson.M{page.SortBy: c.Value, "_id": bson.M{comparison: c.ID}},
+		}}}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: page.SortBy, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(page.Limit) + 1)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &domain.Page[T]{Items: []T{}}
+	var last bson.Raw
+	for cursor.Next(ctx) {
+		if len(result.Items) == page.Limit {
+			// There is at least one more item, point the cursor at the last
+			// item of this page
+			next, err := nextCursor(last, page.SortBy)
+			if err != nil {
+				return nil, err
+			}
+			if result.NextCursor, err = encodeCursor(*next); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, item)
+		last = append(last[:0], cursor.Current...)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// nextCursor points at item, which must have an ObjectID _id and a sort key
+// of the type decodeCursor accepts
+func nextCursor(item bson.Raw, sortBy string) (*pageCursor, error) {
+	value, err := item.LookupErr(sortBy)
+	if err != nil {
+		return nil, fmt.Errorf("cannot page by %s: the item has no %s", sortBy, sortBy)
+	}
+	if !slices.Contains(sortKeyTypes[sortBy], value.Type) {
+		return nil, fmt.Errorf("cannot page by %s: the item has %s of type %s", sortBy, sortBy, value.Type)
+	}
+	id, ok := item.Lookup("_id").ObjectIDOK()
+	if !ok {
+		return nil, fmt.Errorf("cannot page by %s: the item has no ObjectID _id", sortBy)
+	}
+	return &pageCursor{SortBy: sortBy, Value: value, ID: id}, nil
+}
+
+// timeRange builds an inclusive range condition, nil when both ends are open
+func timeRange(from, to *time.Time) bson.M {
+	if from == nil && to == nil {
+		return nil
+	}
+	condition := bson.M{}
+	if from != nil {
+		condition["$gte"] = *from
+	}
+	if to != nil {
+		condition["$lte"] = *to
+	}
+	return condition
+}
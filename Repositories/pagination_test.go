@@ -0,0 +1,128 @@
+package mongodb
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func rawCursor(t *testing.T, cursor bson.M) string {
+	t.Helper()
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	for sortBy, value := range map[string]any{
+		"name":       "Biology",
+		"created_at": time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		"score":      87.5,
+	} {
+		item, _ := bson.Marshal(bson.M{"_id": id, sortBy: value, "other": 1})
+		next, err := nextCursor(item, sortBy)
+		if err != nil {
+			t.Fatalf("%s: %v", sortBy, err)
+		}
+		encoded, err := encodeCursor(*next)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := decodeCursor(encoded, sortBy)
+		if err != nil {
+			t.Fatalf("%s: %v", sortBy, err)
+		}
+		if decoded.ID != id || !decoded.Value.Equal(next.Value) {
+			t.Errorf("%s: got %+v", sortBy, decoded)
+		}
+		// A cursor is only valid for the sort it was made for
+		if _, err := decodeCursor(encoded, "updated_at"); !errors.Is(err, domain.ErrInvalidCursor) {
+			t.Errorf("%s cursor used for updated_at: got %v", sortBy, err)
+		}
+	}
+}
+
+func TestDecodeCursorChecksTypes(t *testing.T) {
+	id := primitive.NewObjectID()
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		sortBy string
+		value  any
+	}{
+		{"name", "Biology"},
+		{"created_at", date},
+		{"score", 87.5},
+		{"score", int32(87)},
+		{"score", int64(87)},
+	} {
+		if _, err := decodeCursor(rawCursor(t, bson.M{"s": tc.sortBy, "v": tc.value, "id": id}), tc.sortBy); err != nil {
+			t.Errorf("%s %T: %v", tc.sortBy, tc.value, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, sortBy, cursor string
+	}{
+		{"not base64", "created_at", "not a cursor!"},
+		{"not bson", "created_at", base64.RawURLEncoding.EncodeToString([]byte("cursor"))},
+		{"string date", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": "2024-03-01", "id": id})},
+		{"null date", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": nil, "id": id})},
+		{"number date", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": int64(1709294400000), "id": id})},
+		{"regex date", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": primitive.Regex{Pattern: ".*"}, "id": id})},
+		{"document date", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": bson.M{"$gt": date}, "id": id})},
+		{"missing value", "created_at", rawCursor(t, bson.M{"s": "created_at", "id": id})},
+		{"number name", "name", rawCursor(t, bson.M{"s": "name", "v": 42, "id": id})},
+		{"string score", "score", rawCursor(t, bson.M{"s": "score", "v": "87.5", "id": id})},
+		{"date score", "score", rawCursor(t, bson.M{"s": "score", "v": date, "id": id})},
+		{"unknown sort key", "owner", rawCursor(t, bson.M{"s": "owner", "v": "someone", "id": id})},
+		{"missing id", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": date})},
+		{"zero id", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": date, "id": primitive.NilObjectID})},
+		{"hex string id", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": date, "id": id.Hex()})},
+		{"twelve character id", "created_at", rawCursor(t, bson.M{"s": "created_at", "v": date, "id": "abcdefghijkl"})},
+	} {
+		if _, err := decodeCursor(tc.cursor, tc.sortBy); !errors.Is(err, domain.ErrInvalidCursor) {
+			t.Errorf("%s: got %v", tc.name, err)
+		}
+	}
+}
+
+func TestNextCursorNeedsSortKeyAndObjectID(t *testing.T) {
+	for name, item := range map[string]bson.M{
+		"missing sort key": {"_id": primitive.NewObjectID()},
+		"wrong type":       {"_id": primitive.NewObjectID(), "created_at": "yesterday"},
+		"string _id":       {"_id": "notebook-1", "created_at": time.Now()},
+	} {
+		raw, _ := bson.Marshal(item)
+		if _, err := nextCursor(raw, "created_at"); err == nil {
+			t.Errorf("%s: made a cursor", name)
+		}
+	}
+}
+
+// Every field a listing can be sorted by needs the types of its cursor
+func TestSortKeyTypesCoverSortFields(t *testing.T) {
+	for _, fields := range [][]string{
+		domain.NotebookSortFields,
+		domain.TrashSortFields,
+		domain.RevisionSortFields,
+		domain.JobSortFields,
+		domain.TestResultSortFields,
+	} {
+		for _, field := range fields {
+			if len(sortKeyTypes[field]) == 0 {
+				t.Errorf("no cursor types for %s", field)
+			}
+		}
+	}
+}
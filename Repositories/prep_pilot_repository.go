@@ -3,7 +3,6 @@ package mongodb
 import (
 	"context"
 	"errors"
-	"time"
 
 	domain "cognivia-api/Domain"
 
@@ -15,17 +14,31 @@ import (
 type prepPilotRepository struct {
 	db         *mongo.Database
 	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
 }
 
 func NewPrepPilotRepository(db *mongo.Database) domain.PrepPilotRepository {
+	return newPrepPilotRepository(db)
+}
+
+func newPrepPilotRepository(db *mongo.Database) *prepPilotRepository {
 	return &prepPilotRepository{
 		db:         db,
 		collection: db.Collection("prep_pilot"),
 	}
 }
 
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *prepPilotRepository) inSession(ctx context.Context) *prepPilotRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
 func (r *prepPilotRepository) GetByID(id primitive.ObjectID) (*domain.PrepPilot, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var prepPilot domain.PrepPilot
@@ -40,7 +53,7 @@ func (r *prepPilotRepository) GetByID(id primitive.ObjectID) (*domain.PrepPilot,
 }
 
 func (r *prepPilotRepository) GetByNotebookID(notebookID primitive.ObjectID) (*domain.PrepPilot, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var prepPilot domain.PrepPilot
@@ -55,9 +68,10 @@ func (r *prepPilotRepository) GetByNotebookID(notebookID primitive.ObjectID) (*d
 }
 
 func (r *prepPilotRepository) Create(prepPilot *domain.PrepPilot) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
+	prepPilot.Version = 1
 	result, err := r.collection.InsertOne(ctx, prepPilot)
 	if err != nil {
 		return err
@@ -67,21 +81,39 @@ func (r *prepPilotRepository) Create(prepPilot *domain.PrepPilot) error {
 }
 
 func (r *prepPilotRepository) Update(prepPilot *domain.PrepPilot) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
-	_, err := r.collection.UpdateOne(
+	stored := *prepPilot
+	stored.Version++
+
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": prepPilot.ID},
-		bson.M{"$set": prepPilot},
+		versionFilter(prepPilot.ID, prepPilot.Version),
+		bson.M{"$set": &stored},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrVersionConflict
+	}
+	prepPilot.Version = stored.Version
+	return nil
 }
 
 func (r *prepPilotRepository) Delete(id primitive.ObjectID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+func (r *prepPilotRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -0,0 +1,120 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type revisionRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
+}
+
+func NewRevisionRepository(db *mongo.Database) domain.RevisionRepository {
+	r := newRevisionRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newRevisionRepository(db *mongo.Database) *revisionRepository {
+	return &revisionRepository{
+		db:         db,
+		collection: db.Collection("revisions"),
+	}
+}
+
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *revisionRepository) inSession(ctx context.Context) *revisionRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *revisionRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// Two concurrent writers cannot both append the same number
+			Keys:    bson.D{{Key: "document_type", Value: 1}, {Key: "document_id", Value: 1}, {Key: "number", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "notebook_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating revisions indexes: %v", err)
+	}
+}
+
+func (r *revisionRepository) Create(revision *domain.Revision) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	revision.ID = primitive.NewObjectID()
+	revision.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, revision)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrVersionConflict
+	}
+	return err
+}
+
+func (r *revisionRepository) findOne(filter bson.M, opts ...*options.FindOneOptions) (*domain.Revision, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	var revision domain.Revision
+	err := r.collection.FindOne(ctx, filter, opts...).Decode(&revision)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (r *revisionRepository) GetByID(id primitive.ObjectID) (*domain.Revision, error) {
+	return r.findOne(bson.M{"_id": id})
+}
+
+func (r *revisionRepository) Latest(documentType string, documentID primitive.ObjectID) (*domain.Revision, error) {
+	return r.findOne(
+		bson.M{"document_type": documentType, "document_id": documentID},
+		options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}),
+	)
+}
+
+func (r *revisionRepository) Find(notebookID primitive.ObjectID, query domain.RevisionQuery) (*domain.Page[*domain.Revision], error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	filter := bson.M{"notebook_id": notebookID}
+	if query.DocumentType != "" {
+		filter["document_type"] = query.DocumentType
+	}
+
+	return findPage[*domain.Revision](ctx, r.collection, filter, query.PageOptions)
+}
+
+func (r *revisionRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -0,0 +1,130 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// searchIndex stores SearchDocuments in their own collection and queries
+// them through a MongoDB text index.
+type searchIndex struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewSearchIndex(db *mongo.Database) domain.SearchIndex {
+	i := &searchIndex{
+		db:         db,
+		collection: db.Collection("search_documents"),
+	}
+	i.ensureIndexes()
+	return i
+}
+
+func (i *searchIndex) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := i.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "text", Value: "text"}},
+			Options: options.Index().SetName("text_search"),
+		},
+		{Keys: bson.D{{Key: "notebook_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating search_documents indexes: %v", err)
+	}
+}
+
+func (i *searchIndex) ReplaceNotebook(notebookID primitive.ObjectID, docs []domain.SearchDocument) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := i.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID}); err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(docs))
+	for n := range docs {
+		documents[n] = docs[n]
+	}
+	_, err := i.collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (i *searchIndex) DeleteNotebook(notebookID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := i.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
+
+func (i *searchIndex) Search(query domain.SearchQuery) ([]domain.SearchHit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$text": bson.M{"$search": query.Text}}
+	if len(query.NotebookIDs) > 0 {
+		filter["$or"] = bson.A{
+			bson.M{"user_id": query.UserID},
+			bson.M{"notebook_id": bson.M{"$in": query.NotebookIDs}},
+		}
+	} else {
+		filter["user_id"] = query.UserID
+	}
+	if len(query.Kinds) > 0 {
+		filter["kind"] = bson.M{"$in": query.Kinds}
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}})
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := i.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		domain.SearchDocument `bson:",inline"`
+		Score                 float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(query.Text)
+	hits := make([]domain.SearchHit, len(results))
+	for n, result := range results {
+		snippet, highlights, _ := highlight(result.Text, terms)
+		hits[n] = domain.SearchHit{
+			NotebookID:   result.NotebookID,
+			NotebookName: result.NotebookName,
+			Kind:         result.Kind,
+			Location:     result.Location,
+			Snippet:      snippet,
+			Highlights:   highlights,
+			Score:        result.Score,
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// inMemorySearchIndex is a SearchIndex for tests and single-process
+// development setups. It scores documents by the number of matched words.
+type inMemorySearchIndex struct {
+	mu   sync.RWMutex
+	docs map[primitive.ObjectID][]domain.SearchDocument
+}
+
+func NewInMemorySearchIndex() domain.SearchIndex {
+	return &inMemorySearchIndex{
+		docs: make(map[primitive.ObjectID][]domain.SearchDocument),
+	}
+}
+
+func (i *inMemorySearchIndex) ReplaceNotebook(notebookID primitive.ObjectID, docs []domain.SearchDocument) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.docs[notebookID] = slices.Clone(docs)
+	return nil
+}
+
+func (i *inMemorySearchIndex) DeleteNotebook(notebookID primitive.ObjectID) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.docs, notebookID)
+	return nil
+}
+
+func (i *inMemorySearchIndex) Search(query domain.SearchQuery) ([]domain.SearchHit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	terms := searchTerms(query.Text)
+	hits := []domain.SearchHit{}
+	if len(terms) == 0 {
+		return hits, nil
+	}
+
+	for notebookID, docs := range i.docs {
+		if len(docs) == 0 || (docs[0].UserID != query.UserID && !slices.Contains(query.NotebookIDs, notebookID)) {
+			continue
+		}
+		for _, doc := range docs {
+			if len(query.Kinds) > 0 && !slices.Contains(query.Kinds, doc.Kind) {
+				continue
+			}
+
+			snippet, highlights, count := highlight(doc.Text, terms)
+			if count == 0 {
+				continue
+			}
+			hits = append(hits, domain.SearchHit{
+				NotebookID:   doc.NotebookID,
+				NotebookName: doc.NotebookName,
+				Kind:         doc.Kind,
+				Location:     doc.Location,
+				Snippet:      snippet,
+				Highlights:   highlights,
+				Score:        float64(count),
+			})
+		}
+	}
+
+	sort.SliceStable(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].NotebookName < hits[b].NotebookName
+	})
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
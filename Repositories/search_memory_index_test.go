@@ -0,0 +1,137 @@
+package mongodb
+
+import (
+	"testing"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func searchDoc(userID, notebookID primitive.ObjectID, notebookName, kind, field, text string) domain.SearchDocument {
+	return domain.SearchDocument{
+		ID:           notebookID.Hex() + ":" + field,
+		UserID:       userID,
+		NotebookID:   notebookID,
+		NotebookName: notebookName,
+		Kind:         kind,
+		Location:     domain.SearchLocation{Field: field},
+		Text:         text,
+	}
+}
+
+func hitFields(hits []domain.SearchHit) []string {
+	fields := make([]string, len(hits))
+	for i, hit := range hits {
+		fields[i] = hit.NotebookName + "/" + hit.Location.Field
+	}
+	return fields
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInMemorySearchRanking(t *testing.T) {
+	index := NewInMemorySearchIndex()
+	userID := primitive.NewObjectID()
+	biology, anatomy := primitive.NewObjectID(), primitive.NewObjectID()
+
+	index.ReplaceNotebook(biology, []domain.SearchDocument{
+		searchDoc(userID, biology, "Biology", domain.SearchKindSummary, "summary", "A cell divides into two cells. Each cell grows."),
+		searchDoc(userID, biology, "Biology", domain.SearchKindKeyPoint, "key_point", "The membrane surrounds the cell."),
+		searchDoc(userID, biology, "Biology", domain.SearchKindQuestion, "question", "What does chlorophyll absorb?"),
+	})
+	index.ReplaceNotebook(anatomy, []domain.SearchDocument{
+		searchDoc(userID, anatomy, "Anatomy", domain.SearchKindFlashcard, "key_term", "Cell"),
+		searchDoc(userID, anatomy, "Anatomy", domain.SearchKindQuestion, "question", "How do cells divide?"),
+	})
+
+	for _, tc := range []struct {
+		name  string
+		query domain.SearchQuery
+		want  []string
+	}{
+		// More matches rank first, equal scores go by notebook name
+		{"by score", domain.SearchQuery{Text: "cells"}, []string{"Biology/summary", "Anatomy/key_term", "Anatomy/question", "Biology/key_point"}},
+		{"limit", domain.SearchQuery{Text: "cells", Limit: 2}, []string{"Biology/summary", "Anatomy/key_term"}},
+		{"kinds", domain.SearchQuery{Text: "cell", Kinds: []string{domain.SearchKindKeyPoint, domain.SearchKindQuestion}}, []string{"Anatomy/question", "Biology/key_point"}},
+		{"every term counts", domain.SearchQuery{Text: "cell membrane"}, []string{"Biology/summary", "Biology/key_point", "Anatomy/key_term", "Anatomy/question"}},
+		{"no match", domain.SearchQuery{Text: "ribosome"}, []string{}},
+		{"only short words", domain.SearchQuery{Text: "a ."}, []string{}},
+	} {
+		tc.query.UserID = userID
+		hits, err := index.Search(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hits == nil || !equalStrings(hitFields(hits), tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, hitFields(hits), tc.want)
+		}
+	}
+
+	hits, _ := index.Search(domain.SearchQuery{Text: "chlorophyll", UserID: userID})
+	if len(hits) != 1 || hits[0].Score != 1 || hits[0].NotebookID != biology || hits[0].Kind != domain.SearchKindQuestion ||
+		hits[0].Snippet != "What does chlorophyll absorb?" || hits[0].Highlights[0] != (domain.TextRange{Start: 10, End: 21}) {
+		t.Errorf("got %+v", hits)
+	}
+}
+
+func TestInMemorySearchAccess(t *testing.T) {
+	index := NewInMemorySearchIndex()
+	owner, sharee, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	shared, private, own := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	index.ReplaceNotebook(shared, []domain.SearchDocument{
+		searchDoc(owner, shared, "Shared", domain.SearchKindSummary, "summary", "Enzymes speed up reactions."),
+	})
+	index.ReplaceNotebook(private, []domain.SearchDocument{
+		searchDoc(owner, private, "Private", domain.SearchKindSummary, "summary", "Enzymes are proteins."),
+	})
+	index.ReplaceNotebook(own, []domain.SearchDocument{
+		searchDoc(sharee, own, "Own", domain.SearchKindSummary, "summary", "An enzyme has an active site."),
+	})
+
+	for _, tc := range []struct {
+		name        string
+		userID      primitive.ObjectID
+		notebookIDs []primitive.ObjectID
+		want        []string
+	}{
+		{"owner", owner, nil, []string{"Private/summary", "Shared/summary"}},
+		{"sharee", sharee, []primitive.ObjectID{shared}, []string{"Own/summary", "Shared/summary"}},
+		{"stranger", stranger, nil, []string{}},
+		// Sharing a notebook the user owns anyway changes nothing
+		{"own notebook shared", sharee, []primitive.ObjectID{own}, []string{"Own/summary"}},
+	} {
+		hits, err := index.Search(domain.SearchQuery{Text: "enzyme", UserID: tc.userID, NotebookIDs: tc.notebookIDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalStrings(hitFields(hits), tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, hitFields(hits), tc.want)
+		}
+	}
+
+	// Reindexing replaces the old documents and deleting removes them all
+	index.ReplaceNotebook(shared, []domain.SearchDocument{
+		searchDoc(owner, shared, "Shared", domain.SearchKindSummary, "summary", "Catalysts speed up reactions."),
+	})
+	index.DeleteNotebook(own)
+	hits, _ := index.Search(domain.SearchQuery{Text: "enzyme", UserID: sharee, NotebookIDs: []primitive.ObjectID{shared}})
+	if len(hits) != 0 {
+		t.Errorf("got %q after reindexing and deleting", hitFields(hits))
+	}
+	hits, _ = index.Search(domain.SearchQuery{Text: "catalysts", UserID: sharee, NotebookIDs: []primitive.ObjectID{shared}})
+	if !equalStrings(hitFields(hits), []string{"Shared/summary"}) {
+		t.Errorf("got %q after reindexing", hitFields(hits))
+	}
+}
@@ -0,0 +1,113 @@
+package mongodb
+
+import (
+	"strings"
+	"unicode"
+
+	domain "cognivia-api/Domain"
+)
+
+const (
+	snippetLength  = 160
+	snippetContext = 60
+	minStemLength  = 3
+)
+
+// searchTerms splits a query into distinct lower-cased words
+func searchTerms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isNotWordRune) {
+		if len([]rune(word)) < 2 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// termMatches approximates the stemming of the text index: a word matches a
+// term when one is a prefix of the other ("cell", "cells") or they differ only
+// in a short ending ("mitochondria", "mitochondrion").
+func termMatches(word, term string) bool {
+	if word == term {
+		return true
+	}
+	shorter := min(len(word), len(term))
+	common := 0
+	for common < shorter && word[common] == term[common] {
+		common++
+	}
+	if common == shorter {
+		return shorter >= minStemLength
+	}
+	return common >= 2*minStemLength-1 && common >= shorter-2
+}
+
+// highlight finds the words of text matching any term and cuts a snippet
+// around the first match. It returns the snippet, the match ranges relative to
+// the snippet and the total number of matches in text.
+func highlight(text string, terms []string) (string, []domain.TextRange, int) {
+	runes := []rune(text)
+
+	var matches []domain.TextRange
+	for start := 0; start < len(runes); {
+		if isNotWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && !isNotWordRune(runes[end]) {
+			end++
+		}
+		word := strings.ToLower(string(runes[start:end]))
+		for _, term := range terms {
+			if termMatches(word, term) {
+				matches = append(matches, domain.TextRange{Start: start, End: end})
+				break
+			}
+		}
+		start = end
+	}
+
+	if len(runes) <= snippetLength {
+		if matches == nil {
+			matches = []domain.TextRange{}
+		}
+		return text, matches, len(matches)
+	}
+
+	from := 0
+	if len(matches) > 0 && matches[0].Start > snippetContext {
+		from = matches[0].Start - snippetContext
+		// Start at a word boundary
+		for from < matches[0].Start && !unicode.IsSpace(runes[from-1]) {
+			from++
+		}
+	}
+	to := min(from+snippetLength, len(runes))
+
+	var snippet strings.Builder
+	offset := -from
+	if from > 0 {
+		snippet.WriteString("…")
+		offset++
+	}
+	snippet.WriteString(string(runes[from:to]))
+	if to < len(runes) {
+		snippet.WriteString("…")
+	}
+
+	ranges := []domain.TextRange{}
+	for _, m := range matches {
+		if m.Start >= from && m.End <= to {
+			ranges = append(ranges, domain.TextRange{Start: m.Start + offset, End: m.End + offset})
+		}
+	}
+	return snippet.String(), ranges, len(matches)
+}
@@ -0,0 +1,108 @@
+package mongodb
+
+import (
+	"strings"
+	"testing"
+
+	domain "cognivia-api/Domain"
+)
+
+func TestSearchTerms(t *testing.T) {
+	for text, want := range map[string][]string{
+		"Cell, cells and a CELL":  {"cell", "cells", "and"},
+		"Zellkern-Teilung 2024":   {"zellkern", "teilung", "2024"},
+		"Ökologie & ökologie":     {"ökologie"},
+		"  , . ! a b ":            nil,
+		"mitochondria's function": {"mitochondria", "function"},
+	} {
+		if got := searchTerms(text); !equalStrings(got, want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestTermMatches(t *testing.T) {
+	for _, tc := range []struct {
+		word, term string
+		want       bool
+	}{
+		{"cell", "cell", true},
+		{"cells", "cell", true},
+		{"cell", "cells", true},
+		{"membranes", "membrane", true},
+		{"digestion", "digest", true},
+		{"mitochondrion", "mitochondria", true},
+		{"nuclei", "nucleus", true},
+		{"ce", "cell", false},
+		{"cat", "car", false},
+		{"photograph", "photosynthesis", false},
+		{"zellmembran", "zelle", false},
+	} {
+		if got := termMatches(tc.word, tc.term); got != tc.want {
+			t.Errorf("termMatches(%q, %q) = %v, want %v", tc.word, tc.term, got, tc.want)
+		}
+	}
+}
+
+func highlighted(snippet string, ranges []domain.TextRange) []string {
+	runes := []rune(snippet)
+	words := make([]string, len(ranges))
+	for i, r := range ranges {
+		words[i] = string(runes[r.Start:r.End])
+	}
+	return words
+}
+
+func TestHighlightShortText(t *testing.T) {
+	// Offsets count characters, not bytes
+	text := "Ökosysteme und Zellen: die Zelle."
+	snippet, ranges, count := highlight(text, []string{"zelle"})
+	if snippet != text || count != 2 {
+		t.Fatalf("got %q, %d matches", snippet, count)
+	}
+	want := []domain.TextRange{{Start: 15, End: 21}, {Start: 27, End: 32}}
+	if len(ranges) != 2 || ranges[0] != want[0] || ranges[1] != want[1] {
+		t.Errorf("got %v, want %v", ranges, want)
+	}
+
+	snippet, ranges, count = highlight(text, []string{"ribosom"})
+	if snippet != text || ranges == nil || len(ranges) != 0 || count != 0 {
+		t.Errorf("without match: got %q, %v, %d", snippet, ranges, count)
+	}
+}
+
+func TestHighlightLongText(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum ", 20)
+	text := filler + "dolores mitochondrion makes energy for the cell. " + filler + filler + "Every mitochondria has DNA."
+
+	snippet, ranges, count := highlight(text, []string{"mitochondria", "cell"})
+	if count != 3 {
+		t.Errorf("counted %d matches, want 3", count)
+	}
+	// The snippet starts at the word boundary before its context, ends
+	// before the last match and is marked as cut on both sides
+	if !strings.HasPrefix(snippet, "…lorem ipsum") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("got snippet %q", snippet)
+	}
+	if length := len([]rune(snippet)); length > snippetLength+2 {
+		t.Errorf("snippet has %d characters", length)
+	}
+	if words := highlighted(snippet, ranges); !equalStrings(words, []string{"mitochondrion", "cell"}) {
+		t.Errorf("highlighted %q in %q", words, snippet)
+	}
+	if ranges[0].Start-1 > snippetContext {
+		t.Errorf("first match at %d, want at most %d characters of context", ranges[0].Start, snippetContext)
+	}
+
+	// A match near the start keeps the beginning of the text
+	snippet, ranges, _ = highlight("The cell "+filler, []string{"cell"})
+	if !strings.HasPrefix(snippet, "The cell lorem") || ranges[0] != (domain.TextRange{Start: 4, End: 8}) {
+		t.Errorf("got %q, %v", snippet, ranges)
+	}
+
+	// Without a match the snippet is the beginning of the text
+	snippet, ranges, count = highlight(text, []string{"ribosome"})
+	if snippet != string([]rune(text)[:snippetLength])+"…" || len(ranges) != 0 || count != 0 {
+		t.Errorf("without match: got %q, %v, %d", snippet, ranges, count)
+	}
+}
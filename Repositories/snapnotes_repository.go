@@ -4,7 +4,6 @@ import (
 	domain "cognivia-api/Domain"
 	"context"
 	"errors"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,17 +13,31 @@ import (
 type snapnotesRepository struct {
 	db         *mongo.Database
 	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
 }
 
 func NewSnapnotesRepository(db *mongo.Database) domain.SnapnotesRepository {
+	return newSnapnotesRepository(db)
+}
+
+func newSnapnotesRepository(db *mongo.Database) *snapnotesRepository {
 	return &snapnotesRepository{
 		db:         db,
 		collection: db.Collection("snapnotes"),
 	}
 }
 
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *snapnotesRepository) inSession(ctx context.Context) *snapnotesRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
 func (r *snapnotesRepository) GetByID(id primitive.ObjectID) (*domain.Snapnotes, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var snapnotes domain.Snapnotes
@@ -39,9 +52,10 @@ func (r *snapnotesRepository) GetByID(id primitive.ObjectID) (*domain.Snapnotes,
 }
 
 func (r *snapnotesRepository) Create(snapnotes *domain.Snapnotes) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
+	snapnotes.Version = 1
 	result, err := r.collection.InsertOne(ctx, snapnotes)
 	if err != nil {
 		return err
@@ -49,3 +63,37 @@ func (r *snapnotesRepository) Create(snapnotes *domain.Snapnotes) error {
 	snapnotes.ID = result.InsertedID.(primitive.ObjectID)
 	return nil
 }
+
+func (r *snapnotesRepository) Update(snapnotes *domain.Snapnotes) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	stored := *snapnotes
+	stored.Version++
+
+	result, err := r.collection.ReplaceOne(ctx, versionFilter(snapnotes.ID, snapnotes.Version), &stored)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrVersionConflict
+	}
+	snapnotes.Version = stored.Version
+	return nil
+}
+
+func (r *snapnotesRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *snapnotesRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -0,0 +1,123 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type sourceTextRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
+}
+
+func NewSourceTextRepository(db *mongo.Database) domain.SourceTextRepository {
+	r := newSourceTextRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newSourceTextRepository(db *mongo.Database) *sourceTextRepository {
+	return &sourceTextRepository{
+		db:         db,
+		collection: db.Collection("source_texts"),
+	}
+}
+
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *sourceTextRepository) inSession(ctx context.Context) *sourceTextRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *sourceTextRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "source_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "notebook_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating source_texts indexes: %v", err)
+	}
+}
+
+func (r *sourceTextRepository) Save(text *domain.SourceText) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	text.CreatedAt = time.Now()
+	existing, err := r.GetBySourceID(text.SourceID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		text.ID = existing.ID
+	} else {
+		text.ID = primitive.NewObjectID()
+	}
+
+	_, err = r.collection.ReplaceOne(ctx, bson.M{"source_id": text.SourceID}, text, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *sourceTextRepository) GetBySourceID(sourceID primitive.ObjectID) (*domain.SourceText, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	var text domain.SourceText
+	err := r.collection.FindOne(ctx, bson.M{"source_id": sourceID}).Decode(&text)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &text, nil
+}
+
+func (r *sourceTextRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.SourceText, error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"notebook_id": notebookID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	texts := []*domain.SourceText{}
+	if err := cursor.All(ctx, &texts); err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
+
+func (r *sourceTextRepository) DeleteBySourceID(sourceID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"source_id": sourceID})
+	return err
+}
+
+func (r *sourceTextRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -3,6 +3,7 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
 	domain "cognivia-api/Domain"
@@ -16,19 +17,48 @@ import (
 type testResultRepository struct {
 	db         *mongo.Database
 	collection *mongo.Collection
+	// sessionCtx is set on copies bound to a unit of work
+	sessionCtx context.Context
 }
 
 func NewTestResultRepository(db *mongo.Database) domain.TestResultRepository {
+	r := newTestResultRepository(db)
+	r.ensureIndexes()
+	return r
+}
+
+func newTestResultRepository(db *mongo.Database) *testResultRepository {
 	return &testResultRepository{
 		db:         db,
 		collection: db.Collection("test_results"),
 	}
 }
 
-func (r *testResultRepository) Create(testResult *domain.TestResult) error {
+// inSession returns a copy of the repository whose operations join the
+// session carried by ctx
+func (r *testResultRepository) inSession(ctx context.Context) *testResultRepository {
+	bound := *r
+	bound.sessionCtx = ctx
+	return &bound
+}
+
+func (r *testResultRepository) ensureIndexes() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "notebook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Error creating test_results indexes: %v", err)
+	}
+}
+
+func (r *testResultRepository) Create(testResult *domain.TestResult) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
 	testResult.CreatedAt = time.Now()
 
 	result, err := r.collection.InsertOne(ctx, testResult)
@@ -41,7 +71,7 @@ func (r *testResultRepository) Create(testResult *domain.TestResult) error {
 }
 
 func (r *testResultRepository) GetByID(id primitive.ObjectID) (*domain.TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	var testResult domain.TestResult
@@ -56,7 +86,7 @@ func (r *testResultRepository) GetByID(id primitive.ObjectID) (*domain.TestResul
 }
 
 func (r *testResultRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	// Sort by created_at descending to get most recent tests first
@@ -84,7 +114,7 @@ func (r *testResultRepository) GetByUserID(userID primitive.ObjectID) ([]*domain
 }
 
 func (r *testResultRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
@@ -111,7 +141,7 @@ func (r *testResultRepository) GetByNotebookID(notebookID primitive.ObjectID) ([
 }
 
 func (r *testResultRepository) GetByPrepPilotID(prepPilotID primitive.ObjectID) ([]*domain.TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
@@ -138,7 +168,7 @@ func (r *testResultRepository) GetByPrepPilotID(prepPilotID primitive.ObjectID)
 }
 
 func (r *testResultRepository) GetByUserAndNotebook(userID, notebookID primitive.ObjectID) ([]*domain.TestResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	filter := bson.M{
@@ -169,8 +199,33 @@ func (r *testResultRepository) GetByUserAndNotebook(userID, notebookID primitive
 	return testResults, nil
 }
 
+func (r *testResultRepository) Find(userID primitive.ObjectID, query domain.TestResultQuery) (*domain.Page[*domain.TestResult], error) {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if query.NotebookID != nil {
+		filter["notebook_id"] = *query.NotebookID
+	}
+	if completed := timeRange(query.CompletedFrom, query.CompletedTo); completed != nil {
+		filter["completed_at"] = completed
+	}
+	if query.MinScore != nil || query.MaxScore != nil {
+		score := bson.M{}
+		if query.MinScore != nil {
+			score["$gte"] = *query.MinScore
+		}
+		if query.MaxScore != nil {
+			score["$lte"] = *query.MaxScore
+		}
+		filter["score"] = score
+	}
+
+	return findPage[*domain.TestResult](ctx, r.collection, filter, query.PageOptions)
+}
+
 func (r *testResultRepository) Update(testResult *domain.TestResult) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	filter := bson.M{"_id": testResult.ID}
@@ -181,9 +236,17 @@ func (r *testResultRepository) Update(testResult *domain.TestResult) error {
 }
 
 func (r *testResultRepository) Delete(id primitive.ObjectID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := operationContext(r.sessionCtx)
 	defer cancel()
 
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+func (r *testResultRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	ctx, cancel := operationContext(r.sessionCtx)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"notebook_id": notebookID})
+	return err
+}
@@ -0,0 +1,120 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// transactionTimeout bounds a whole unit of work including its retries
+const transactionTimeout = 30 * time.Second
+
+type unitOfWork struct {
+	client       *mongo.Client
+	notebooks    *notebookRepository
+	snapnotes    *snapnotesRepository
+	prepPilots   *prepPilotRepository
+	testResults  *testResultRepository
+	shares       *notebookShareRepository
+	revisions    *revisionRepository
+	sources      *notebookSourceRepository
+	sourceTexts  *sourceTextRepository
+	transactions bool
+}
+
+// NewUnitOfWork returns a unit of work backed by MongoDB transactions.
+// Transactions need a replica set or a sharded cluster; against a standalone
+// server the changes are applied one by one and a warning is logged.
+func NewUnitOfWork(db *mongo.Database) domain.UnitOfWork {
+	u := &unitOfWork{
+		client:      db.Client(),
+		notebooks:   newNotebookRepository(db),
+		snapnotes:   newSnapnotesRepository(db),
+		prepPilots:  newPrepPilotRepository(db),
+		testResults: newTestResultRepository(db),
+		shares:      newNotebookShareRepository(db),
+		revisions:   newRevisionRepository(db),
+		sources:     newNotebookSourceRepository(db),
+		sourceTexts: newSourceTextRepository(db),
+	}
+	u.transactions = supportsTransactions(db)
+	if !u.transactions {
+		log.Println("MongoDB is not a replica set, cascading changes run without transactions")
+	}
+	return u
+}
+
+func (u *unitOfWork) Do(fn func(repos domain.TransactionRepositories) error) error {
+	if !u.transactions {
+		return fn(u.repositories(nil))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(u.repositories(sessionCtx))
+	})
+	return err
+}
+
+func (u *unitOfWork) repositories(ctx context.Context) domain.TransactionRepositories {
+	return domain.TransactionRepositories{
+		Notebooks:   u.notebooks.inSession(ctx),
+		Snapnotes:   u.snapnotes.inSession(ctx),
+		PrepPilots:  u.prepPilots.inSession(ctx),
+		TestResults: u.testResults.inSession(ctx),
+		Shares:      u.shares.inSession(ctx),
+		Revisions:   u.revisions.inSession(ctx),
+		Sources:     u.sources.inSession(ctx),
+		SourceTexts: u.sourceTexts.inSession(ctx),
+	}
+}
+
+// operationContext bounds a single repository operation. Repositories bound
+// to a unit of work derive it from the session context so the operation
+// joins the transaction.
+func operationContext(sessionCtx context.Context) (context.Context, context.CancelFunc) {
+	if sessionCtx == nil {
+		sessionCtx = context.Background()
+	}
+	return context.WithTimeout(sessionCtx, 10*time.Second)
+}
+
+// versionFilter matches the document with the given ID at the given version.
+// Documents stored before versioning have no version field and count as
+// version 0.
+func versionFilter(id primitive.ObjectID, version int64) bson.M {
+	if version == 0 {
+		return bson.M{"_id": id, "version": bson.M{"$in": bson.A{0, nil}}}
+	}
+	return bson.M{"_id": id, "version": version}
+}
+
+func supportsTransactions(db *mongo.Database) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		log.Printf("Error checking MongoDB topology: %v", err)
+		return false
+	}
+	// mongos answers with msg "isdbgrid"
+	return hello.SetName != "" || hello.Msg == "isdbgrid"
+}
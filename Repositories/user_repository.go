@@ -19,10 +19,24 @@ type userRepository struct {
 }
 
 func NewUserRepository(db *mongo.Database) domain.UserRepository {
-	return &userRepository{
+	r := &userRepository{
 		db:         db,
 		collection: db.Collection("users"),
 	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *userRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Error creating users indexes: %v", err)
+	}
 }
 
 func (r *userRepository) Create(user *domain.User) error {
@@ -89,6 +103,23 @@ func (r *userRepository) FindByID(id string) (*domain.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) FindByIdentity(provider, subject string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+
+	var user domain.User
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Update(user *domain.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
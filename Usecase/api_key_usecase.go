@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type apiKeyUseCase struct {
+	apiKeyRepo domain.APIKeyRepository
+}
+
+func NewAPIKeyUseCase(apiKeyRepo domain.APIKeyRepository) domain.APIKeyUseCase {
+	return &apiKeyUseCase{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+func (u *apiKeyUseCase) CreateAPIKey(userID, name string, scopes []string) (*domain.APIKey, string, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APIKeyScopes, scope) {
+			return nil, "", fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	token, err := infrastructure.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := domain.APIKeyPrefix + token
+
+	key := &domain.APIKey{
+		UserID:  objectUserID,
+		Name:    name,
+		Prefix:  plaintext[:len(domain.APIKeyPrefix)+6],
+		KeyHash: infrastructure.HashToken(plaintext),
+		Scopes:  slices.Compact(slices.Sorted(slices.Values(scopes))),
+	}
+	if err := u.apiKeyRepo.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (u *apiKeyUseCase) ListAPIKeys(userID string) ([]*domain.APIKey, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.apiKeyRepo.GetByUserID(objectUserID)
+}
+
+func (u *apiKeyUseCase) RevokeAPIKey(userID, keyID string) error {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	objectKeyID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := u.apiKeyRepo.Revoke(objectKeyID, objectUserID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// driveSyncBatch limits how many files one sync checks
+const driveSyncBatch = 100
+
+var driveFileIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{10,}$`)
+
+type driveUseCase struct {
+	notebookRepo domain.NotebookRepository
+	sourceRepo   domain.NotebookSourceRepository
+	notebooks    domain.NotebookAuthorizer
+	sources      domain.SourceUseCase
+	client       domain.DriveClient
+	jobs         domain.JobQueue
+	syncInterval time.Duration
+}
+
+func NewDriveUseCase(
+	notebookRepo domain.NotebookRepository,
+	sourceRepo domain.NotebookSourceRepository,
+	notebooks domain.NotebookAuthorizer,
+	sources domain.SourceUseCase,
+	client domain.DriveClient,
+	jobs domain.JobQueue,
+) domain.DriveUseCase {
+	return &driveUseCase{
+		notebookRepo: notebookRepo,
+		sourceRepo:   sourceRepo,
+		notebooks:    notebooks,
+		sources:      sources,
+		client:       client,
+		jobs:         jobs,
+		syncInterval: infrastructure.EnvDuration("DRIVE_SYNC_INTERVAL", time.Hour),
+	}
+}
+
+func (u *driveUseCase) ImportDrive(userID, notebookID string) (*domain.Job, error) {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
+	}
+	if _, err := driveFileID(notebook.GoogleDriveLink); err != nil {
+		return nil, err
+	}
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.queueImport(notebook, objectUserID)
+}
+
+// queueImport queues one import per notebook at a time
+func (u *driveUseCase) queueImport(notebook *domain.Notebook, userID primitive.ObjectID) (*domain.Job, error) {
+	return u.jobs.Enqueue(&domain.Job{
+		Type:       domain.JobImportDrive,
+		NotebookID: &notebook.ID,
+		UserID:     &userID,
+		DedupeKey:  domain.JobImportDrive + ":" + notebook.ID.Hex(),
+	})
+}
+
+func (u *driveUseCase) RunJob(job *domain.Job) error {
+	switch job.Type {
+	case domain.JobImportDrive:
+		userID, notebookID, err := jobTarget(job)
+		if err != nil {
+			return err
+		}
+		return u.importFile(userID, notebookID)
+	case domain.JobSyncDrive:
+		queued, err := u.SyncDue()
+		if queued > 0 {
+			log.Printf("Queued the import of %d changed Google Drive files", queued)
+		}
+		return err
+	default:
+		return fmt.Errorf("unexpected job type %s", job.Type)
+	}
+}
+
+// importFile attaches the Drive file as a source. A file whose content did
+// not change since the last import is left alone. A changed file replaces
+// the source it was imported as before and flags the generated content of
+// the notebook as stale.
+func (u *driveUseCase) importFile(userID, notebookID string) error {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return err
+	}
+	if !domain.CanEdit(role) {
+		return domain.ErrForbidden
+	}
+	fileID, err := driveFileID(notebook.GoogleDriveLink)
+	if err != nil {
+		return err
+	}
+
+	if err := u.importChanged(userID, notebook, fileID); err != nil {
+		u.recordError(notebook, fileID, err)
+		return err
+	}
+	return nil
+}
+
+func (u *driveUseCase) importChanged(userID string, notebook *domain.Notebook, fileID string) error {
+	file, err := u.client.GetFile(fileID)
+	if err != nil {
+		return err
+	}
+
+	previous, err := u.importedSource(notebook)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	drive := &domain.NotebookDrive{
+		FileID:     file.ID,
+		Revision:   file.Revision,
+		ModifiedAt: &file.ModifiedAt,
+		ImportedAt: &now,
+		CheckedAt:  now,
+	}
+	if previous != nil && notebook.Drive.FileID == file.ID && notebook.Drive.Revision == file.Revision {
+		return u.notebookRepo.MarkDriveChecked(notebook.ID, now, "")
+	}
+
+	body, err := u.client.Download(file)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(io.LimitReader(body, u.sources.MaxSourceSize()+1))
+	if err != nil {
+		return err
+	}
+
+	// A new revision may only carry changed metadata, e.g. a renamed file
+	sum := sha256.Sum256(content)
+	if previous != nil && previous.Checksum == hex.EncodeToString(sum[:]) {
+		drive.SourceID, drive.ImportedAt = &previous.ID, notebook.Drive.ImportedAt
+		return u.notebookRepo.SetDrive(notebook.ID, drive, nil)
+	}
+
+	source, err := u.sources.UploadSource(userID, notebook.ID.Hex(), domain.SourceUpload{
+		FileName: file.Name,
+		Content:  bytes.NewReader(content),
+	})
+	if err != nil {
+		return err
+	}
+	drive.SourceID = &source.ID
+
+	var stale []string
+	if previous != nil {
+		if err := u.sources.DeleteSource(userID, notebook.ID.Hex(), previous.ID.Hex()); err != nil {
+			log.Printf("Error deleting source %s replaced by its Google Drive file: %v", previous.ID.Hex(), err)
+		}
+		if notebook.SnapnotesID != nil {
+			stale = append(stale, domain.StaleSnapnotes)
+		}
+		if notebook.PrepPilotID != nil {
+			stale = append(stale, domain.StalePrepPilot)
+		}
+	}
+	return u.notebookRepo.SetDrive(notebook.ID, drive, stale)
+}
+
+// importedSource returns the source the Drive file was last imported as,
+// or nil when it was deleted since
+func (u *driveUseCase) importedSource(notebook *domain.Notebook) (*domain.NotebookSource, error) {
+	if notebook.Drive == nil || notebook.Drive.SourceID == nil {
+		return nil, nil
+	}
+	source, err := u.sourceRepo.GetByID(*notebook.Drive.SourceID)
+	if err != nil || source == nil || source.NotebookID != notebook.ID {
+		return nil, err
+	}
+	return source, nil
+}
+
+// recordError keeps the reason of a failed import or check on the notebook
+// so its owner can fix the link or the file's sharing
+func (u *driveUseCase) recordError(notebook *domain.Notebook, fileID string, cause error) {
+	var err error
+	if notebook.Drive == nil {
+		err = u.notebookRepo.SetDrive(notebook.ID, &domain.NotebookDrive{
+			FileID: fileID, CheckedAt: time.Now(), Error: cause.Error(),
+		}, nil)
+	} else {
+		err = u.notebookRepo.MarkDriveChecked(notebook.ID, time.Now(), cause.Error())
+	}
+	if err != nil {
+		log.Printf("Error saving Google Drive state of notebook %s: %v", notebook.ID.Hex(), err)
+	}
+}
+
+func (u *driveUseCase) SyncDue() (int, error) {
+	notebooks, err := u.notebookRepo.FindDriveCheckDue(time.Now().Add(-u.syncInterval), driveSyncBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, notebook := range notebooks {
+		changed, err := u.check(notebook)
+		if err != nil {
+			log.Printf("Error checking Google Drive file of notebook %s: %v", notebook.ID.Hex(), err)
+			continue
+		}
+		if changed {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// check compares the Drive file with the imported one and queues an import
+// on the owner's behalf when the link or the content changed
+func (u *driveUseCase) check(notebook *domain.Notebook) (bool, error) {
+	fileID, err := driveFileID(notebook.GoogleDriveLink)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			err = errors.New(validationErr.Fields[0].Message)
+		}
+		return false, u.notebookRepo.MarkDriveChecked(notebook.ID, time.Now(), err.Error())
+	}
+
+	file, err := u.client.GetFile(fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrDriveFileNotFound) {
+			return false, u.notebookRepo.MarkDriveChecked(notebook.ID, time.Now(), err.Error())
+		}
+		return false, err
+	}
+
+	drive := notebook.Drive
+	if drive.SourceID != nil && drive.FileID == file.ID && drive.Revision == file.Revision {
+		return false, u.notebookRepo.MarkDriveChecked(notebook.ID, time.Now(), "")
+	}
+	// Checked first, so a failing import is not queued again before the
+	// next interval
+	if err := u.notebookRepo.MarkDriveChecked(notebook.ID, time.Now(), drive.Error); err != nil {
+		return false, err
+	}
+	if _, err := u.queueImport(notebook, notebook.UserID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// driveFileID reads the file ID from links such as
+// https://drive.google.com/file/d/<id>/view,
+// https://drive.google.com/open?id=<id> and
+// https://docs.google.com/document/d/<id>/edit
+func driveFileID(link *string) (string, error) {
+	if link == nil || strings.TrimSpace(*link) == "" {
+		return "", &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "google_drive_link", Code: "missing", Message: "the notebook has no Google Drive link",
+		}}}
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(*link))
+	if err == nil && (parsed.Host == "drive.google.com" || parsed.Host == "docs.google.com") {
+		id := parsed.Query().Get("id")
+		segments := strings.Split(parsed.Path, "/")
+		for i, segment := range segments {
+			if segment == "d" && i+1 < len(segments) {
+				id = segments[i+1]
+				break
+			}
+		}
+		if driveFileIDPattern.MatchString(id) {
+			return id, nil
+		}
+	}
+	return "", &domain.ValidationError{Fields: []domain.FieldError{{
+		Field: "google_drive_link", Code: "invalid", Message: "must link to a Google Drive file",
+	}}}
+}
@@ -0,0 +1,322 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testDriveFileID = "1AbCdEfGhIjKlMnOp"
+
+// fakeDriveClient serves files from memory and counts downloads
+type fakeDriveClient struct {
+	mu        sync.Mutex
+	files     map[string]domain.DriveFile
+	contents  map[string]string
+	downloads int
+}
+
+func (c *fakeDriveClient) GetFile(fileID string) (*domain.DriveFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file, ok := c.files[fileID]
+	if !ok {
+		return nil, domain.ErrDriveFileNotFound
+	}
+	return &file, nil
+}
+
+func (c *fakeDriveClient) Download(file *domain.DriveFile) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downloads++
+	return io.NopCloser(strings.NewReader(c.contents[file.ID])), nil
+}
+
+// put stores a new revision of the file
+func (c *fakeDriveClient) put(revision, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[testDriveFileID] = domain.DriveFile{
+		ID: testDriveFileID, Name: "Lecture.pdf", MimeType: "application/pdf", Revision: revision, ModifiedAt: time.Now(),
+	}
+	c.contents[testDriveFileID] = content
+}
+
+// fakeSourceUseCase stores uploads without checking or extracting them
+type fakeSourceUseCase struct {
+	domain.SourceUseCase
+	sources *memorySourceRepository
+}
+
+func (u *fakeSourceUseCase) UploadSource(userID, notebookID string, upload domain.SourceUpload) (*domain.NotebookSource, error) {
+	content, err := io.ReadAll(upload.Content)
+	if err != nil {
+		return nil, err
+	}
+	objectNotebookID, _ := primitive.ObjectIDFromHex(notebookID)
+	sum := sha256.Sum256(content)
+	source := &domain.NotebookSource{
+		NotebookID: objectNotebookID, FileName: upload.FileName, Size: int64(len(content)), Checksum: hex.EncodeToString(sum[:]),
+	}
+	return source, u.sources.Create(source)
+}
+
+func (u *fakeSourceUseCase) DeleteSource(userID, notebookID, sourceID string) error {
+	objectSourceID, _ := primitive.ObjectIDFromHex(sourceID)
+	return u.sources.Delete(objectSourceID)
+}
+
+func (u *fakeSourceUseCase) MaxSourceSize() int64 {
+	return 1 << 20
+}
+
+type driveFixture struct {
+	*notebookFixture
+	useCase *driveUseCase
+	client  *fakeDriveClient
+	sources *memorySourceRepository
+	jobs    *memoryJobQueue
+}
+
+func newDriveFixture(t *testing.T) *driveFixture {
+	t.Helper()
+	f := &driveFixture{
+		notebookFixture: newNotebookFixture(t),
+		client:          &fakeDriveClient{files: map[string]domain.DriveFile{}, contents: map[string]string{}},
+		sources:         newMemorySourceRepository(),
+		jobs:            &memoryJobQueue{},
+	}
+	f.useCase = NewDriveUseCase(
+		f.notebooks, f.sources, f.notebookFixture.useCase, &fakeSourceUseCase{sources: f.sources}, f.client, f.jobs,
+	).(*driveUseCase)
+
+	link := "https://drive.google.com/file/d/" + testDriveFileID + "/view?usp=sharing"
+	f.notebook.GoogleDriveLink = &link
+	f.notebooks.Update(f.notebook)
+	f.client.put("rev-1", "Cells are the basic unit of life.")
+	return f
+}
+
+func (f *driveFixture) stored() *domain.Notebook {
+	notebook, _ := f.notebooks.GetByID(f.notebook.ID)
+	return notebook
+}
+
+// runImports runs the queued jobs as the worker would
+func (f *driveFixture) runImports(t *testing.T) {
+	t.Helper()
+	for _, job := range f.jobs.take() {
+		if err := f.useCase.RunJob(job); err != nil {
+			t.Fatalf("%s job: %v", job.Type, err)
+		}
+	}
+}
+
+func (f *driveFixture) importAs(t *testing.T, user *domain.User) {
+	t.Helper()
+	if _, err := f.useCase.ImportDrive(user.ID.Hex(), f.notebook.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	f.runImports(t)
+}
+
+func TestImportDriveQueuesOneJob(t *testing.T) {
+	f := newDriveFixture(t)
+
+	first, err := f.useCase.ImportDrive(f.editor.ID.Hex(), f.notebook.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := f.useCase.ImportDrive(f.owner.ID.Hex(), f.notebook.ID.Hex())
+	if first.ID != again.ID || first.Type != domain.JobImportDrive || *first.UserID != f.editor.ID {
+		t.Fatalf("unexpected jobs %+v and %+v", first, again)
+	}
+
+	if _, err := f.useCase.ImportDrive(f.viewer.ID.Hex(), f.notebook.ID.Hex()); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("viewer: got %v", err)
+	}
+	if _, err := f.useCase.ImportDrive(f.stranger.ID.Hex(), f.notebook.ID.Hex()); !errors.Is(err, domain.ErrNotebookNotFound) {
+		t.Errorf("stranger: got %v", err)
+	}
+
+	f.notebook = f.stored()
+	f.notebook.GoogleDriveLink = nil
+	f.notebooks.Update(f.notebook)
+	var validationErr *domain.ValidationError
+	if _, err := f.useCase.ImportDrive(f.owner.ID.Hex(), f.notebook.ID.Hex()); !errors.As(err, &validationErr) {
+		t.Errorf("notebook without link: got %v", err)
+	}
+}
+
+func TestDriveImportAndReimport(t *testing.T) {
+	f := newDriveFixture(t)
+	f.importAs(t, f.owner)
+
+	drive := f.stored().Drive
+	if drive == nil || drive.SourceID == nil || drive.Revision != "rev-1" || drive.FileID != testDriveFileID || drive.Error != "" {
+		t.Fatalf("unexpected Drive state %+v", drive)
+	}
+	first, _ := f.sources.GetByID(*drive.SourceID)
+	if first == nil || first.FileName != "Lecture.pdf" {
+		t.Fatalf("unexpected source %+v", first)
+	}
+
+	t.Run("revision unchanged", func(t *testing.T) {
+		version := f.stored().Version
+		f.importAs(t, f.owner)
+		if f.client.downloads != 1 {
+			t.Errorf("an unchanged file was downloaded again")
+		}
+		if stored := f.stored(); stored.Version != version || *stored.Drive.SourceID != first.ID {
+			t.Errorf("checking an unchanged file changed the notebook: %+v", stored.Drive)
+		}
+	})
+
+	t.Run("checksum unchanged", func(t *testing.T) {
+		// A new revision with the same content, e.g. after renaming
+		f.client.put("rev-2", "Cells are the basic unit of life.")
+		f.importAs(t, f.owner)
+		stored := f.stored()
+		if *stored.Drive.SourceID != first.ID || stored.Drive.Revision != "rev-2" || !stored.Drive.ImportedAt.Equal(*drive.ImportedAt) {
+			t.Errorf("same content was imported again: %+v", stored.Drive)
+		}
+		if sources, _ := f.sources.GetByNotebookID(f.notebook.ID); len(sources) != 1 {
+			t.Errorf("got %d sources, want 1", len(sources))
+		}
+	})
+
+	t.Run("content changed", func(t *testing.T) {
+		// Generated content was made from the old source
+		notebook := f.stored()
+		snapnotesID := primitive.NewObjectID()
+		notebook.SnapnotesID = &snapnotesID
+		f.notebooks.Update(notebook)
+
+		f.client.put("rev-3", "Cells divide by mitosis.")
+		f.importAs(t, f.owner)
+		stored := f.stored()
+		if *stored.Drive.SourceID == first.ID || stored.Drive.Revision != "rev-3" {
+			t.Fatalf("changed content was not imported: %+v", stored.Drive)
+		}
+		if replaced, _ := f.sources.GetByID(first.ID); replaced != nil {
+			t.Error("the replaced source was kept")
+		}
+		if sources, _ := f.sources.GetByNotebookID(f.notebook.ID); len(sources) != 1 {
+			t.Errorf("got %d sources, want 1", len(sources))
+		}
+		// Only the content the notebook has is flagged
+		if len(stored.StaleContent) != 1 || stored.StaleContent[0] != domain.StaleSnapnotes {
+			t.Errorf("stale content %v, want [snapnotes]", stored.StaleContent)
+		}
+	})
+
+	t.Run("source deleted", func(t *testing.T) {
+		f.sources.Delete(*f.stored().Drive.SourceID)
+		f.importAs(t, f.owner)
+		stored := f.stored()
+		if source, _ := f.sources.GetByID(*stored.Drive.SourceID); source == nil {
+			t.Fatal("a deleted source was not imported again")
+		}
+	})
+}
+
+func TestDriveImportRecordsErrors(t *testing.T) {
+	f := newDriveFixture(t)
+	delete(f.client.files, testDriveFileID)
+	if _, err := f.useCase.ImportDrive(f.owner.ID.Hex(), f.notebook.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	job := f.jobs.take()[0]
+	err := f.useCase.RunJob(job)
+	if !errors.Is(err, domain.ErrDriveFileNotFound) || !permanentJobError(err) {
+		t.Fatalf("got %v, want a permanent ErrDriveFileNotFound", err)
+	}
+	if drive := f.stored().Drive; drive == nil || drive.Error != domain.ErrDriveFileNotFound.Error() || drive.SourceID != nil {
+		t.Fatalf("the error was not recorded: %+v", drive)
+	}
+
+	// A later successful import clears it
+	f.client.put("rev-1", "Cells are the basic unit of life.")
+	f.importAs(t, f.owner)
+	if drive := f.stored().Drive; drive.Error != "" || drive.SourceID == nil {
+		t.Fatalf("unexpected Drive state %+v", drive)
+	}
+}
+
+func TestDriveSyncDue(t *testing.T) {
+	f := newDriveFixture(t)
+	f.importAs(t, f.editor)
+	f.useCase.syncInterval = -time.Minute
+
+	// Unchanged files are only marked as checked
+	checked := f.stored().Drive.CheckedAt
+	if queued, err := f.useCase.SyncDue(); err != nil || queued != 0 {
+		t.Fatalf("unchanged file: queued %d, %v", queued, err)
+	}
+	if stored := f.stored(); !stored.Drive.CheckedAt.After(checked) || len(f.jobs.take()) != 0 {
+		t.Fatalf("unexpected check of an unchanged file: %+v", stored.Drive)
+	}
+
+	// Changed files are imported on the owner's behalf
+	f.client.put("rev-2", "Cells divide by mitosis.")
+	if queued, err := f.useCase.SyncDue(); err != nil || queued != 1 {
+		t.Fatalf("changed file: queued %d, %v", queued, err)
+	}
+	jobs := f.jobs.take()
+	if len(jobs) != 1 || *jobs[0].UserID != f.owner.ID || *jobs[0].NotebookID != f.notebook.ID {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+	f.useCase.RunJob(jobs[0])
+	if f.stored().Drive.Revision != "rev-2" {
+		t.Fatal("the changed file was not imported")
+	}
+
+	// A file that is gone is reported on the notebook
+	delete(f.client.files, testDriveFileID)
+	if _, err := f.useCase.SyncDue(); err != nil {
+		t.Fatal(err)
+	}
+	if drive := f.stored().Drive; drive.Error != domain.ErrDriveFileNotFound.Error() || len(f.jobs.take()) != 0 {
+		t.Fatalf("missing file: %+v", drive)
+	}
+
+	// So is a link that no longer points at a file
+	notebook := f.stored()
+	link := "https://example.com/notes.pdf"
+	notebook.GoogleDriveLink = &link
+	f.notebooks.Update(notebook)
+	f.useCase.SyncDue()
+	if drive := f.stored().Drive; drive.Error != "must link to a Google Drive file" {
+		t.Fatalf("invalid link: %+v", drive)
+	}
+}
+
+func TestDriveFileID(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://drive.google.com/file/d/" + testDriveFileID + "/view?usp=sharing": testDriveFileID,
+		"https://drive.google.com/open?id=" + testDriveFileID:                      testDriveFileID,
+		"https://docs.google.com/document/d/" + testDriveFileID + "/edit":          testDriveFileID,
+		" https://docs.google.com/spreadsheets/d/" + testDriveFileID + "/ ":        testDriveFileID,
+		"https://drive.google.com/drive/folders/" + testDriveFileID:                "",
+		"https://evil.example.com/file/d/" + testDriveFileID + "/view":             "",
+		"https://drive.google.com/file/d/short/view":                               "",
+		"https://drive.google.com/file/d/../view":                                  "",
+		"": "",
+	} {
+		id, err := driveFileID(&link)
+		if id != want || (want == "") != (err != nil) {
+			t.Errorf("%q: got %q, %v", link, id, err)
+		}
+	}
+}
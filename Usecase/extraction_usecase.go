@@ -0,0 +1,318 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxExtractionAttempts bounds how often a source whose file cannot be read
+// is claimed again before its extraction fails
+const maxExtractionAttempts = 3
+
+// pendingExtractionBatch limits how many sources one queue_extractions job
+// looks at
+const pendingExtractionBatch = 100
+
+type extractionUseCase struct {
+	sourceRepo   domain.NotebookSourceRepository
+	textRepo     domain.SourceTextRepository
+	notebookRepo domain.NotebookRepository
+	notebooks    domain.NotebookAuthorizer
+	blobStore    domain.BlobStore
+	extractor    domain.TextExtractor
+	jobs         domain.JobQueue
+	timeout      time.Duration
+}
+
+func NewExtractionUseCase(
+	sourceRepo domain.NotebookSourceRepository,
+	textRepo domain.SourceTextRepository,
+	notebookRepo domain.NotebookRepository,
+	notebooks domain.NotebookAuthorizer,
+	blobStore domain.BlobStore,
+	extractor domain.TextExtractor,
+	jobs domain.JobQueue,
+) domain.ExtractionUseCase {
+	return &extractionUseCase{
+		sourceRepo:   sourceRepo,
+		textRepo:     textRepo,
+		notebookRepo: notebookRepo,
+		notebooks:    notebooks,
+		blobStore:    blobStore,
+		extractor:    extractor,
+		jobs:         jobs,
+		timeout:      infrastructure.EnvDuration("EXTRACTION_TIMEOUT", 10*time.Minute),
+	}
+}
+
+func (u *extractionUseCase) GetSourceText(userID, notebookID, sourceID string) (*domain.SourceText, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := findNotebookSource(u.sourceRepo, notebook, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := u.textRepo.GetBySourceID(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	if text == nil {
+		return nil, domain.ErrSourceTextNotFound
+	}
+	return text, nil
+}
+
+func (u *extractionUseCase) RetryExtraction(userID, notebookID, sourceID string) (*domain.NotebookSource, error) {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
+	}
+	source, err := findNotebookSource(u.sourceRepo, notebook, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Extraction.Status != domain.ExtractionFailed {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "extraction", Code: "not_failed", Message: "only failed extractions can be retried",
+		}}}
+	}
+
+	source.Extraction = domain.SourceExtraction{Status: domain.ExtractionPending}
+	if err := u.sourceRepo.SetExtraction(source.ID, source.Extraction); err != nil {
+		return nil, err
+	}
+	if err := refreshExtractionStatus(u.sourceRepo, u.notebookRepo, notebook.ID); err != nil {
+		log.Printf("Error updating extraction status of notebook %s: %v", notebook.ID.Hex(), err)
+	}
+	queueExtraction(u.jobs, source, userID)
+	return source, nil
+}
+
+// QueuePending goes through sources that wait for extraction or whose
+// extraction was interrupted. Sources with a queued or running job keep it,
+// the others get a new one, e.g. when queueing failed after the upload.
+func (u *extractionUseCase) QueuePending() (int, error) {
+	sources, err := u.sourceRepo.FindPendingExtractions(time.Now().Add(-u.timeout), pendingExtractionBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, source := range sources {
+		if queueExtraction(u.jobs, source, "") {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+func (u *extractionUseCase) RunJob(job *domain.Job) error {
+	switch job.Type {
+	case domain.JobExtractSource:
+		return u.extractJobSource(job)
+	case domain.JobQueueExtractions:
+		queued, err := u.QueuePending()
+		if queued > 0 {
+			log.Printf("Queued the extraction of %d sources whose job was lost", queued)
+		}
+		return err
+	default:
+		return fmt.Errorf("unexpected job type %s", job.Type)
+	}
+}
+
+// extractJobSource extracts the text of the source of an extract_source
+// job. A source that another worker is extracting, or whose extraction
+// already finished, is left alone. When the extraction can be tried again
+// the source goes back to pending and the job is retried.
+func (u *extractionUseCase) extractJobSource(job *domain.Job) error {
+	sourceID, err := primitive.ObjectIDFromHex(job.Payload["source_id"])
+	if err != nil {
+		return domain.ErrSourceNotFound
+	}
+	source, err := u.sourceRepo.ClaimExtraction(sourceID, time.Now().Add(-u.timeout))
+	if err != nil || source == nil {
+		return err
+	}
+
+	if err := u.extract(source); err != nil {
+		extraction := source.Extraction
+		extraction.Status, extraction.StartedAt = domain.ExtractionPending, nil
+		if err := u.sourceRepo.SetExtraction(source.ID, extraction); err != nil && !errors.Is(err, domain.ErrSourceNotFound) {
+			log.Printf("Error saving extraction status of source %s: %v", source.ID.Hex(), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// queueExtraction queues an extract_source job and tells whether a new one
+// was queued. Without one the source waits for the next queue_extractions
+// job.
+func queueExtraction(jobs domain.JobQueue, source *domain.NotebookSource, userID string) bool {
+	job := &domain.Job{
+		Type:       domain.JobExtractSource,
+		NotebookID: &source.NotebookID,
+		Payload:    map[string]string{"source_id": source.ID.Hex()},
+		DedupeKey:  domain.JobExtractSource + ":" + source.ID.Hex(),
+	}
+	if objectUserID, err := primitive.ObjectIDFromHex(userID); err == nil {
+		job.UserID = &objectUserID
+	}
+	queued, err := jobs.Enqueue(job)
+	if err != nil {
+		log.Printf("Error queueing text extraction of source %s: %v", source.ID.Hex(), err)
+		return false
+	}
+	// A live job with the same dedupe key is returned instead of ours
+	return queued == job
+}
+
+// extract stores the text of a claimed source. Files the extractor cannot
+// handle fail right away. When the file or the text cannot be accessed the
+// error is returned, until the source runs out of attempts and fails.
+func (u *extractionUseCase) extract(source *domain.NotebookSource) error {
+	chapters, err := u.readChapters(source)
+	var failure extractionFailure
+	if errors.As(err, &failure) {
+		u.finish(source, domain.ExtractionFailed, failure.message)
+		return nil
+	}
+	if err == nil {
+		err = u.textRepo.Save(&domain.SourceText{
+			NotebookID: source.NotebookID,
+			SourceID:   source.ID,
+			Chapters:   chapters,
+			WordCount:  wordCount(chapters),
+		})
+	}
+	if err != nil {
+		if source.Extraction.Attempts >= maxExtractionAttempts {
+			log.Printf("Error extracting text of source %s (attempt %d): %v", source.ID.Hex(), source.Extraction.Attempts, err)
+			u.finish(source, domain.ExtractionFailed, "the file could not be read")
+			return nil
+		}
+		return err
+	}
+	u.finish(source, domain.ExtractionCompleted, "")
+	return nil
+}
+
+// extractionFailure is an error that retrying will not fix, with a message
+// for the user
+type extractionFailure struct {
+	message string
+}
+
+func (e extractionFailure) Error() string {
+	return e.message
+}
+
+func (u *extractionUseCase) readChapters(source *domain.NotebookSource) ([]domain.TextChapter, error) {
+	file, err := u.blobStore.Get(source.StorageKey)
+	if err != nil {
+		if errors.Is(err, domain.ErrBlobNotFound) {
+			return nil, extractionFailure{message: "the file is missing from storage"}
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	// Past the timeout the extraction is started over, so there is no use
+	// going on
+	deadline := time.Now().Add(u.timeout)
+	if source.Extraction.StartedAt != nil {
+		deadline = source.Extraction.StartedAt.Add(u.timeout)
+	}
+	chapters, err := u.extractor.Extract(source.ContentType, content, deadline)
+	if err != nil {
+		return nil, extractionFailure{message: err.Error()}
+	}
+	return chapters, nil
+}
+
+// finish records the outcome of an extraction. A source deleted in the
+// meantime takes its text with it.
+func (u *extractionUseCase) finish(source *domain.NotebookSource, status, message string) {
+	now := time.Now()
+	extraction := source.Extraction
+	extraction.Status, extraction.Error, extraction.FinishedAt = status, message, &now
+	if status == domain.ExtractionFailed {
+		log.Printf("Text extraction of source %s failed: %s", source.ID.Hex(), message)
+	}
+
+	if err := u.sourceRepo.SetExtraction(source.ID, extraction); err != nil {
+		if !errors.Is(err, domain.ErrSourceNotFound) {
+			log.Printf("Error saving extraction status of source %s: %v", source.ID.Hex(), err)
+			return
+		}
+		if err := u.textRepo.DeleteBySourceID(source.ID); err != nil {
+			log.Printf("Error deleting text of source %s: %v", source.ID.Hex(), err)
+		}
+	}
+	if err := refreshExtractionStatus(u.sourceRepo, u.notebookRepo, source.NotebookID); err != nil {
+		log.Printf("Error updating extraction status of notebook %s: %v", source.NotebookID.Hex(), err)
+	}
+}
+
+func wordCount(chapters []domain.TextChapter) int {
+	count := 0
+	for _, chapter := range chapters {
+		count += len(strings.Fields(chapter.Title)) + len(strings.Fields(chapter.Text))
+	}
+	return count
+}
+
+// refreshExtractionStatus recomputes the extraction summary of a notebook
+// from its sources, removing it when the notebook has none
+func refreshExtractionStatus(
+	sourceRepo domain.NotebookSourceRepository,
+	notebookRepo domain.NotebookRepository,
+	notebookID primitive.ObjectID,
+) error {
+	sources, err := sourceRepo.GetByNotebookID(notebookID)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return notebookRepo.SetExtraction(notebookID, nil)
+	}
+
+	summary := &domain.NotebookExtraction{Sources: len(sources), UpdatedAt: time.Now()}
+	for _, source := range sources {
+		switch source.Extraction.Status {
+		case domain.ExtractionCompleted:
+			summary.Completed++
+		case domain.ExtractionFailed:
+			summary.Failed++
+		}
+	}
+	switch {
+	case summary.Completed+summary.Failed < summary.Sources:
+		summary.Status = domain.ExtractionRunning
+	case summary.Failed > 0:
+		summary.Status = domain.ExtractionFailed
+	default:
+		summary.Status = domain.ExtractionCompleted
+	}
+	return notebookRepo.SetExtraction(notebookID, summary)
+}
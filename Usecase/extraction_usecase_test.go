@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestQueuePendingSkipsSourcesWithLiveJob(t *testing.T) {
+	sources := newMemorySourceRepository()
+	jobs := &memoryJobQueue{}
+	useCase := NewExtractionUseCase(sources, nil, nil, nil, nil, nil, jobs).(*extractionUseCase)
+
+	notebookID := primitive.NewObjectID()
+	add := func(extraction domain.SourceExtraction) *domain.NotebookSource {
+		source := &domain.NotebookSource{NotebookID: notebookID, Extraction: extraction}
+		sources.Create(source)
+		return source
+	}
+	stale := time.Now().Add(-2 * useCase.timeout)
+	recent := time.Now()
+
+	queued := add(domain.SourceExtraction{Status: domain.ExtractionPending})
+	lost := add(domain.SourceExtraction{Status: domain.ExtractionPending})
+	legacy := add(domain.SourceExtraction{})
+	interrupted := add(domain.SourceExtraction{Status: domain.ExtractionRunning, StartedAt: &stale})
+	add(domain.SourceExtraction{Status: domain.ExtractionRunning, StartedAt: &recent})
+	add(domain.SourceExtraction{Status: domain.ExtractionCompleted})
+	add(domain.SourceExtraction{Status: domain.ExtractionFailed})
+
+	// The upload of the first source queued its job, the others' got lost
+	if !queueExtraction(jobs, queued, "") {
+		t.Fatal("the first job was not queued")
+	}
+
+	count, err := useCase.QueuePending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("queued %d extractions, want 3", count)
+	}
+
+	want := map[string]bool{queued.ID.Hex(): true, lost.ID.Hex(): true, legacy.ID.Hex(): true, interrupted.ID.Hex(): true}
+	taken := jobs.take()
+	if len(taken) != len(want) {
+		t.Fatalf("got %d jobs, want %d", len(taken), len(want))
+	}
+	for _, job := range taken {
+		if job.Type != domain.JobExtractSource || !want[job.Payload["source_id"]] {
+			t.Errorf("unexpected job %+v", job)
+		}
+		delete(want, job.Payload["source_id"])
+	}
+
+	// A second run finds the jobs it queued
+	for _, job := range taken {
+		jobs.Enqueue(job)
+	}
+	if count, _ := useCase.QueuePending(); count != 0 {
+		t.Errorf("the second run queued %d extractions", count)
+	}
+}
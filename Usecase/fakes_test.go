@@ -0,0 +1,867 @@
+package usecase
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// In-memory repositories shared by the use case tests. They keep copies so
+// that a use case changing a returned value does not change what is stored.
+
+type memoryUserRepository struct {
+	mu    sync.Mutex
+	users map[primitive.ObjectID]domain.User
+}
+
+func newMemoryUserRepository() *memoryUserRepository {
+	return &memoryUserRepository{users: map[primitive.ObjectID]domain.User{}}
+}
+
+func (r *memoryUserRepository) Create(user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) find(match func(user *domain.User) bool) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if match(&user) {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(email string) (*domain.User, error) {
+	return r.find(func(user *domain.User) bool { return user.Email == email })
+}
+
+func (r *memoryUserRepository) FindByID(id string) (*domain.User, error) {
+	return r.find(func(user *domain.User) bool { return user.ID.Hex() == id })
+}
+
+func (r *memoryUserRepository) FindByIdentity(provider, subject string) (*domain.User, error) {
+	return r.find(func(user *domain.User) bool {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r *memoryUserRepository) Update(user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.users {
+		if key.Hex() == id {
+			delete(r.users, key)
+		}
+	}
+	return nil
+}
+
+type memoryUserTokenRepository struct {
+	mu     sync.Mutex
+	tokens []domain.UserToken
+}
+
+func (r *memoryUserTokenRepository) Create(token *domain.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *memoryUserTokenRepository) FindByHash(purpose, tokenHash string) (*domain.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserTokenRepository) FindLatestByUserID(userID primitive.ObjectID, purpose string) (*domain.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		if token := r.tokens[i]; token.UserID == userID && token.Purpose == purpose {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserTokenRepository) MarkUsed(id primitive.ObjectID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if r.tokens[i].ID == id && r.tokens[i].UsedAt == nil {
+			now := time.Now()
+			r.tokens[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserTokenRepository) DeleteByUserID(userID primitive.ObjectID, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.tokens[:0]
+	for _, token := range r.tokens {
+		if token.UserID != userID || token.Purpose != purpose {
+			kept = append(kept, token)
+		}
+	}
+	r.tokens = kept
+	return nil
+}
+
+func (r *memoryUserTokenRepository) count(userID primitive.ObjectID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+type memoryAPIKeyRepository struct {
+	mu   sync.Mutex
+	keys []*domain.APIKey
+}
+
+func (r *memoryAPIKeyRepository) Create(key *domain.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = time.Now()
+	stored := *key
+	r.keys = append(r.keys, &stored)
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(keyHash string) (*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAPIKeyRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := []*domain.APIKey{}
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			found := *key
+			keys = append(keys, &found)
+		}
+	}
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) Revoke(id, userID primitive.ObjectID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.ID == id && key.UserID == userID && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryAPIKeyRepository) RevokeAllByUserID(userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			key.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) TouchLastUsed(id primitive.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.ID == id {
+			key.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+// memoryNotebookRepository implements the notebook methods the use cases
+// under test need; calling any other method panics through the nil
+// embedded interface.
+type memoryNotebookRepository struct {
+	domain.NotebookRepository
+
+	mu        sync.Mutex
+	notebooks map[primitive.ObjectID]domain.Notebook
+}
+
+func newMemoryNotebookRepository() *memoryNotebookRepository {
+	return &memoryNotebookRepository{notebooks: map[primitive.ObjectID]domain.Notebook{}}
+}
+
+func (r *memoryNotebookRepository) Create(notebook *domain.Notebook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebook.ID = primitive.NewObjectID()
+	notebook.CreatedAt, notebook.UpdatedAt = time.Now(), time.Now()
+	notebook.Version = 1
+	r.notebooks[notebook.ID] = *notebook
+	return nil
+}
+
+func (r *memoryNotebookRepository) GetByID(id primitive.ObjectID) (*domain.Notebook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebook, ok := r.notebooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &notebook, nil
+}
+
+func (r *memoryNotebookRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.Notebook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebooks := []*domain.Notebook{}
+	for _, notebook := range r.notebooks {
+		if notebook.UserID == userID && notebook.DeletedAt == nil {
+			notebooks = append(notebooks, &notebook)
+		}
+	}
+	return notebooks, nil
+}
+
+func (r *memoryNotebookRepository) GetByIDs(ids []primitive.ObjectID) ([]*domain.Notebook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebooks := []*domain.Notebook{}
+	for _, id := range ids {
+		if notebook, ok := r.notebooks[id]; ok && notebook.DeletedAt == nil {
+			notebooks = append(notebooks, &notebook)
+		}
+	}
+	return notebooks, nil
+}
+
+// Update keeps the stored share links like the Mongo repository does
+func (r *memoryNotebookRepository) Update(notebook *domain.Notebook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.notebooks[notebook.ID]
+	if !ok || stored.Version != notebook.Version {
+		return domain.ErrVersionConflict
+	}
+	notebook.UpdatedAt = time.Now()
+	notebook.Version++
+	updated := *notebook
+	updated.ShareLinks = stored.ShareLinks
+	r.notebooks[notebook.ID] = updated
+	return nil
+}
+
+func (r *memoryNotebookRepository) Delete(id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.notebooks, id)
+	return nil
+}
+
+func (r *memoryNotebookRepository) SoftDelete(id primitive.ObjectID, version int64, at time.Time) error {
+	return r.change(id, &version, func(notebook *domain.Notebook) { notebook.DeletedAt = &at })
+}
+
+func (r *memoryNotebookRepository) Restore(id primitive.ObjectID) error {
+	return r.change(id, nil, func(notebook *domain.Notebook) { notebook.DeletedAt = nil })
+}
+
+func (r *memoryNotebookRepository) AddShareLink(notebookID primitive.ObjectID, link *domain.ShareLink) error {
+	link.ID = primitive.NewObjectID()
+	link.CreatedAt = time.Now()
+	return r.change(notebookID, nil, func(notebook *domain.Notebook) {
+		notebook.ShareLinks = append(slices.Clone(notebook.ShareLinks), *link)
+	})
+}
+
+// change applies fn to the stored notebook and increments its version. A
+// non-nil version must match the stored one.
+func (r *memoryNotebookRepository) change(id primitive.ObjectID, version *int64, fn func(notebook *domain.Notebook)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebook, ok := r.notebooks[id]
+	if !ok {
+		return nil
+	}
+	if version != nil && notebook.Version != *version {
+		return domain.ErrVersionConflict
+	}
+	fn(&notebook)
+	notebook.Version++
+	r.notebooks[id] = notebook
+	return nil
+}
+
+type memoryNotebookShareRepository struct {
+	mu     sync.Mutex
+	shares map[primitive.ObjectID]domain.NotebookShare
+}
+
+func newMemoryNotebookShareRepository() *memoryNotebookShareRepository {
+	return &memoryNotebookShareRepository{shares: map[primitive.ObjectID]domain.NotebookShare{}}
+}
+
+func (r *memoryNotebookShareRepository) Create(share *domain.NotebookShare) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	share.ID = primitive.NewObjectID()
+	share.CreatedAt, share.UpdatedAt = time.Now(), time.Now()
+	r.shares[share.ID] = *share
+	return nil
+}
+
+func (r *memoryNotebookShareRepository) GetByID(id primitive.ObjectID) (*domain.NotebookShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	share, ok := r.shares[id]
+	if !ok {
+		return nil, nil
+	}
+	return &share, nil
+}
+
+func (r *memoryNotebookShareRepository) filter(match func(share *domain.NotebookShare) bool) []*domain.NotebookShare {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shares := []*domain.NotebookShare{}
+	for _, share := range r.shares {
+		if match(&share) {
+			shares = append(shares, &share)
+		}
+	}
+	return shares
+}
+
+func (r *memoryNotebookShareRepository) first(match func(share *domain.NotebookShare) bool) *domain.NotebookShare {
+	if shares := r.filter(match); len(shares) > 0 {
+		return shares[0]
+	}
+	return nil
+}
+
+func (r *memoryNotebookShareRepository) FindByNotebookAndUser(notebookID, userID primitive.ObjectID) (*domain.NotebookShare, error) {
+	return r.first(func(share *domain.NotebookShare) bool {
+		return share.NotebookID == notebookID && share.UserID != nil && *share.UserID == userID
+	}), nil
+}
+
+func (r *memoryNotebookShareRepository) FindByNotebookAndEmail(notebookID primitive.ObjectID, email string) (*domain.NotebookShare, error) {
+	return r.first(func(share *domain.NotebookShare) bool {
+		return share.NotebookID == notebookID && share.Email == email
+	}), nil
+}
+
+func (r *memoryNotebookShareRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.NotebookShare, error) {
+	return r.filter(func(share *domain.NotebookShare) bool { return share.NotebookID == notebookID }), nil
+}
+
+func (r *memoryNotebookShareRepository) GetByUserID(userID primitive.ObjectID) ([]*domain.NotebookShare, error) {
+	return r.filter(func(share *domain.NotebookShare) bool { return share.UserID != nil && *share.UserID == userID }), nil
+}
+
+func (r *memoryNotebookShareRepository) UpdateRole(id primitive.ObjectID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if share, ok := r.shares[id]; ok {
+		share.Role = role
+		r.shares[id] = share
+	}
+	return nil
+}
+
+func (r *memoryNotebookShareRepository) ClaimInvites(email string, userID primitive.ObjectID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed int64
+	for id, share := range r.shares {
+		if share.Email == email && share.UserID == nil {
+			now := time.Now()
+			share.UserID, share.AcceptedAt = &userID, &now
+			r.shares[id] = share
+			claimed++
+		}
+	}
+	return claimed, nil
+}
+
+func (r *memoryNotebookShareRepository) Delete(id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.shares, id)
+	return nil
+}
+
+func (r *memoryNotebookShareRepository) DeleteByNotebookID(notebookID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, share := range r.shares {
+		if share.NotebookID == notebookID {
+			delete(r.shares, id)
+		}
+	}
+	return nil
+}
+
+type memoryRevisionRepository struct {
+	domain.RevisionRepository
+
+	mu        sync.Mutex
+	revisions []domain.Revision
+}
+
+func (r *memoryRevisionRepository) Create(revision *domain.Revision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.revisions {
+		if stored.DocumentType == revision.DocumentType && stored.DocumentID == revision.DocumentID && stored.Number == revision.Number {
+			return domain.ErrVersionConflict
+		}
+	}
+	revision.ID = primitive.NewObjectID()
+	revision.CreatedAt = time.Now()
+	r.revisions = append(r.revisions, *revision)
+	return nil
+}
+
+func (r *memoryRevisionRepository) GetByID(id primitive.ObjectID) (*domain.Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, revision := range r.revisions {
+		if revision.ID == id {
+			return &revision, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRevisionRepository) Latest(documentType string, documentID primitive.ObjectID) (*domain.Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *domain.Revision
+	for _, revision := range r.revisions {
+		if revision.DocumentType == documentType && revision.DocumentID == documentID && (latest == nil || revision.Number > latest.Number) {
+			latest = &revision
+		}
+	}
+	return latest, nil
+}
+
+type memorySnapnotesRepository struct {
+	domain.SnapnotesRepository
+
+	mu        sync.Mutex
+	snapnotes map[primitive.ObjectID]domain.Snapnotes
+}
+
+func newMemorySnapnotesRepository() *memorySnapnotesRepository {
+	return &memorySnapnotesRepository{snapnotes: map[primitive.ObjectID]domain.Snapnotes{}}
+}
+
+func (r *memorySnapnotesRepository) GetByID(id primitive.ObjectID) (*domain.Snapnotes, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if snapnotes, ok := r.snapnotes[id]; ok {
+		return &snapnotes, nil
+	}
+	return nil, nil
+}
+
+func (r *memorySnapnotesRepository) Create(content *domain.Snapnotes) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	content.ID = primitive.NewObjectID()
+	r.snapnotes[content.ID] = *content
+	return nil
+}
+
+func (r *memorySnapnotesRepository) Update(content *domain.Snapnotes) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.snapnotes[content.ID]; !ok || stored.Version != content.Version {
+		return domain.ErrVersionConflict
+	}
+	content.Version++
+	r.snapnotes[content.ID] = *content
+	return nil
+}
+
+// memoryUnitOfWork runs the function once against the given repositories,
+// without rolling anything back
+type memoryUnitOfWork struct {
+	repos domain.TransactionRepositories
+}
+
+func (u *memoryUnitOfWork) Do(fn func(repos domain.TransactionRepositories) error) error {
+	return fn(u.repos)
+}
+
+type recordingIndexer struct {
+	mu      sync.Mutex
+	indexed []primitive.ObjectID
+	removed []primitive.ObjectID
+}
+
+func (i *recordingIndexer) IndexNotebook(notebook *domain.Notebook) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.indexed = append(i.indexed, notebook.ID)
+	return nil
+}
+
+func (i *recordingIndexer) RemoveNotebook(notebookID primitive.ObjectID) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.removed = append(i.removed, notebookID)
+	return nil
+}
+
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func (r *memoryNotebookRepository) SetDrive(id primitive.ObjectID, drive *domain.NotebookDrive, stale []string) error {
+	return r.change(id, nil, func(notebook *domain.Notebook) {
+		copied := *drive
+		notebook.Drive = &copied
+		for _, content := range stale {
+			if !slices.Contains(notebook.StaleContent, content) {
+				notebook.StaleContent = append(slices.Clone(notebook.StaleContent), content)
+			}
+		}
+	})
+}
+
+func (r *memoryNotebookRepository) MarkDriveChecked(id primitive.ObjectID, at time.Time, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebook, ok := r.notebooks[id]
+	if !ok || notebook.Drive == nil {
+		return nil
+	}
+	drive := *notebook.Drive
+	drive.CheckedAt = at
+	if drive.Error != message {
+		drive.Error = message
+		notebook.Version++
+	}
+	notebook.Drive = &drive
+	r.notebooks[id] = notebook
+	return nil
+}
+
+func (r *memoryNotebookRepository) FindDriveCheckDue(cutoff time.Time, limit int) ([]*domain.Notebook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notebooks := []*domain.Notebook{}
+	for _, notebook := range r.notebooks {
+		if notebook.Drive != nil && notebook.Drive.CheckedAt.Before(cutoff) && notebook.DeletedAt == nil &&
+			notebook.GoogleDriveLink != nil && *notebook.GoogleDriveLink != "" && len(notebooks) < limit {
+			notebooks = append(notebooks, &notebook)
+		}
+	}
+	return notebooks, nil
+}
+
+type memorySourceRepository struct {
+	domain.NotebookSourceRepository
+
+	mu      sync.Mutex
+	sources map[primitive.ObjectID]domain.NotebookSource
+}
+
+func newMemorySourceRepository() *memorySourceRepository {
+	return &memorySourceRepository{sources: map[primitive.ObjectID]domain.NotebookSource{}}
+}
+
+func (r *memorySourceRepository) Create(source *domain.NotebookSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	source.ID = primitive.NewObjectID()
+	source.CreatedAt = time.Now()
+	r.sources[source.ID] = *source
+	return nil
+}
+
+func (r *memorySourceRepository) GetByID(id primitive.ObjectID) (*domain.NotebookSource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	source, ok := r.sources[id]
+	if !ok {
+		return nil, nil
+	}
+	return &source, nil
+}
+
+func (r *memorySourceRepository) GetByNotebookID(notebookID primitive.ObjectID) ([]*domain.NotebookSource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := []*domain.NotebookSource{}
+	for _, source := range r.sources {
+		if source.NotebookID == notebookID {
+			sources = append(sources, &source)
+		}
+	}
+	return sources, nil
+}
+
+func (r *memorySourceRepository) FindPendingExtractions(staleBefore time.Time, limit int) ([]*domain.NotebookSource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := []*domain.NotebookSource{}
+	for _, source := range r.sources {
+		extraction := source.Extraction
+		if extraction.Status == "" || extraction.Status == domain.ExtractionPending ||
+			extraction.Status == domain.ExtractionRunning && extraction.StartedAt.Before(staleBefore) {
+			sources = append(sources, &source)
+		}
+	}
+	slices.SortFunc(sources, func(a, b *domain.NotebookSource) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return sources[:min(limit, len(sources))], nil
+}
+
+func (r *memorySourceRepository) Delete(id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, id)
+	return nil
+}
+
+// memoryJobQueue returns the live job with the same dedupe key instead of
+// queueing another one, like the job repository
+type memoryJobQueue struct {
+	mu   sync.Mutex
+	jobs []*domain.Job
+}
+
+func (q *memoryJobQueue) Enqueue(job *domain.Job) (*domain.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, queued := range q.jobs {
+		if job.DedupeKey != "" && queued.DedupeKey == job.DedupeKey &&
+			(queued.Status == domain.JobQueued || queued.Status == domain.JobRunning) {
+			return queued, nil
+		}
+	}
+	job.ID = primitive.NewObjectID()
+	job.Status = domain.JobQueued
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+// take removes and returns the queued jobs
+func (q *memoryJobQueue) take() []*domain.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := q.jobs
+	q.jobs = nil
+	return jobs
+}
+
+// memoryJobRepository leases, renews and settles jobs like the job
+// repository. Buried jobs keep their dedupe key aside for Requeue.
+type memoryJobRepository struct {
+	domain.JobRepository
+
+	mu         sync.Mutex
+	jobs       map[primitive.ObjectID]domain.Job
+	deadKeys   map[primitive.ObjectID]string
+	heartbeats int
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{jobs: map[primitive.ObjectID]domain.Job{}, deadKeys: map[primitive.ObjectID]string{}}
+}
+
+func (r *memoryJobRepository) Enqueue(job *domain.Job) (*domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if live := r.live(job.DedupeKey); live != nil {
+		return live, nil
+	}
+	job.ID = primitive.NewObjectID()
+	job.Status = domain.JobQueued
+	job.CreatedAt = time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = job.CreatedAt
+	}
+	r.jobs[job.ID] = *job
+	return job, nil
+}
+
+// live returns the queued or running job holding key
+func (r *memoryJobRepository) live(key string) *domain.Job {
+	for _, job := range r.jobs {
+		if key != "" && job.DedupeKey == key && (job.Status == domain.JobQueued || job.Status == domain.JobRunning) {
+			return &job
+		}
+	}
+	return nil
+}
+
+func (r *memoryJobRepository) GetByID(id primitive.ObjectID) (*domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *memoryJobRepository) Lease(workerID string, lease time.Duration) (*domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var next *domain.Job
+	for _, job := range r.jobs {
+		due := job.Status == domain.JobQueued && !job.RunAt.After(now) ||
+			job.Status == domain.JobRunning && job.LeaseExpiresAt.Before(now)
+		if due && (next == nil || job.RunAt.Before(next.RunAt)) {
+			next = &job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	expires := now.Add(lease)
+	next.Status, next.LeasedBy, next.LeaseExpiresAt = domain.JobRunning, workerID, &expires
+	next.HeartbeatAt, next.StartedAt = &now, &now
+	next.Attempts++
+	r.jobs[next.ID] = *next
+	return next, nil
+}
+
+func (r *memoryJobRepository) Heartbeat(id primitive.ObjectID, workerID string, lease time.Duration) error {
+	return r.updateLeased(id, workerID, func(job *domain.Job) {
+		now := time.Now()
+		expires := now.Add(lease)
+		job.LeaseExpiresAt, job.HeartbeatAt = &expires, &now
+		r.heartbeats++
+	})
+}
+
+func (r *memoryJobRepository) Complete(id primitive.ObjectID, workerID string) error {
+	return r.updateLeased(id, workerID, func(job *domain.Job) {
+		now := time.Now()
+		job.Status, job.FinishedAt, job.Error, job.DedupeKey = domain.JobSucceeded, &now, "", ""
+		job.LeasedBy, job.LeaseExpiresAt = "", nil
+	})
+}
+
+func (r *memoryJobRepository) Retry(id primitive.ObjectID, workerID, message string, runAt time.Time) error {
+	return r.updateLeased(id, workerID, func(job *domain.Job) {
+		job.Status, job.Error, job.RunAt = domain.JobQueued, message, runAt
+		job.LeasedBy, job.LeaseExpiresAt = "", nil
+	})
+}
+
+func (r *memoryJobRepository) Bury(id primitive.ObjectID, workerID, message string) error {
+	return r.updateLeased(id, workerID, func(job *domain.Job) {
+		now := time.Now()
+		job.Status, job.Error, job.FinishedAt = domain.JobDead, message, &now
+		job.LeasedBy, job.LeaseExpiresAt = "", nil
+		if job.DedupeKey != "" {
+			r.deadKeys[job.ID], job.DedupeKey = job.DedupeKey, ""
+		}
+	})
+}
+
+func (r *memoryJobRepository) updateLeased(id primitive.ObjectID, workerID string, fn func(job *domain.Job)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || job.Status != domain.JobRunning || job.LeasedBy != workerID {
+		return domain.ErrJobLeaseLost
+	}
+	fn(&job)
+	r.jobs[id] = job
+	return nil
+}
+
+func (r *memoryJobRepository) Requeue(id primitive.ObjectID) (*domain.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || job.Status != domain.JobDead {
+		return nil, domain.ErrJobNotFound
+	}
+	if live := r.live(r.deadKeys[id]); live != nil {
+		return live, nil
+	}
+	job.Status, job.Attempts, job.RunAt, job.FinishedAt = domain.JobQueued, 0, time.Now(), nil
+	job.DedupeKey = r.deadKeys[id]
+	delete(r.deadKeys, id)
+	r.jobs[id] = job
+	return &job, nil
+}
+
+// expireLease makes the job's lease run out, as if its worker had stopped
+func (r *memoryJobRepository) expireLease(id primitive.ObjectID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.jobs[id]
+	expired := time.Now().Add(-time.Second)
+	job.LeaseExpiresAt = &expired
+	r.jobs[id] = job
+}
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"strings"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxFolderNameLength = 100
+
+type folderUseCase struct {
+	folderRepo   domain.FolderRepository
+	notebookRepo domain.NotebookRepository
+}
+
+func NewFolderUseCase(folderRepo domain.FolderRepository, notebookRepo domain.NotebookRepository) domain.FolderUseCase {
+	return &folderUseCase{
+		folderRepo:   folderRepo,
+		notebookRepo: notebookRepo,
+	}
+}
+
+func (u *folderUseCase) CreateFolder(userID, name string, parentID *string) (*domain.Folder, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err = validateFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &domain.Folder{UserID: objectUserID, Name: name}
+	if parentID != nil {
+		parent, err := findOwnedFolder(u.folderRepo, objectUserID, *parentID, "parent_id")
+		if err != nil {
+			return nil, err
+		}
+		folder.ParentID = &parent.ID
+	}
+
+	if err := u.folderRepo.Create(folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (u *folderUseCase) ListFolders(userID string) ([]*domain.Folder, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.folderRepo.GetByUserID(objectUserID)
+}
+
+func (u *folderUseCase) RenameFolder(userID, folderID, name string) (*domain.Folder, error) {
+	folder, err := u.ownedFolder(userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	folder.Name, err = validateFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.folderRepo.Update(folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (u *folderUseCase) MoveFolder(userID, folderID string, parentID *string) (*domain.Folder, error) {
+	folder, err := u.ownedFolder(userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID == nil {
+		folder.ParentID = nil
+	} else {
+		parent, err := findOwnedFolder(u.folderRepo, folder.UserID, *parentID, "parent_id")
+		if err != nil {
+			return nil, err
+		}
+
+		// Walk up from the new parent, reaching the folder itself means the
+		// move would create a cycle
+		seen := map[primitive.ObjectID]bool{}
+		for ancestor := parent; ancestor != nil; {
+			if ancestor.ID == folder.ID {
+				return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+					Field: "parent_id", Code: "cycle", Message: "cannot be the folder itself or one of its sub-folders",
+				}}}
+			}
+			if ancestor.ParentID == nil || seen[ancestor.ID] {
+				break
+			}
+			seen[ancestor.ID] = true
+
+			ancestor, err = u.folderRepo.GetByID(*ancestor.ParentID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		folder.ParentID = &parent.ID
+	}
+
+	if err := u.folderRepo.Update(folder); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (u *folderUseCase) DeleteFolder(userID, folderID string) error {
+	folder, err := u.ownedFolder(userID, folderID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.folderRepo.Reparent(folder.UserID, folder.ID, folder.ParentID); err != nil {
+		return err
+	}
+	if err := u.notebookRepo.MoveFolder(folder.UserID, folder.ID, folder.ParentID); err != nil {
+		return err
+	}
+	return u.folderRepo.Delete(folder.ID)
+}
+
+func (u *folderUseCase) ownedFolder(userID, folderID string) (*domain.Folder, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOwnedFolder(u.folderRepo, objectUserID, folderID, "")
+}
+
+// findOwnedFolder loads a folder of the user. When field is set a missing
+// folder is reported as a validation error on that request field, otherwise
+// as ErrFolderNotFound.
+func findOwnedFolder(repo domain.FolderRepository, userID primitive.ObjectID, folderID, field string) (*domain.Folder, error) {
+	notFound := error(domain.ErrFolderNotFound)
+	if field != "" {
+		notFound = &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: field, Code: "not_found", Message: "does not refer to one of your folders",
+		}}}
+	}
+
+	objectFolderID, err := primitive.ObjectIDFromHex(folderID)
+	if err != nil {
+		return nil, notFound
+	}
+
+	folder, err := repo.GetByID(objectFolderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil || folder.UserID != userID {
+		return nil, notFound
+	}
+	return folder, nil
+}
+
+func validateFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxFolderNameLength {
+		return "", &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "name", Code: "invalid_length", Message: "must be between 1 and 100 characters",
+		}}}
+	}
+	return name, nil
+}
@@ -0,0 +1,258 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type generationUseCase struct {
+	textRepo      domain.SourceTextRepository
+	notebooks     domain.NotebookAuthorizer
+	unitOfWork    domain.UnitOfWork
+	indexer       domain.NotebookIndexer
+	generator     domain.ContentGenerator
+	jobs          domain.JobQueue
+	maxInputBytes int
+}
+
+func NewGenerationUseCase(
+	textRepo domain.SourceTextRepository,
+	notebooks domain.NotebookAuthorizer,
+	unitOfWork domain.UnitOfWork,
+	indexer domain.NotebookIndexer,
+	generator domain.ContentGenerator,
+	jobs domain.JobQueue,
+) domain.GenerationUseCase {
+	return &generationUseCase{
+		textRepo:      textRepo,
+		notebooks:     notebooks,
+		unitOfWork:    unitOfWork,
+		indexer:       indexer,
+		generator:     generator,
+		jobs:          jobs,
+		maxInputBytes: infrastructure.EnvInt("GENERATION_MAX_INPUT_BYTES", 200000),
+	}
+}
+
+func (u *generationUseCase) GenerateSnapnotes(userID, notebookID string) (*domain.Job, error) {
+	return u.queue(domain.JobGenerateSnapnotes, userID, notebookID)
+}
+
+func (u *generationUseCase) GeneratePrepPilot(userID, notebookID string) (*domain.Job, error) {
+	return u.queue(domain.JobGeneratePrepPilot, userID, notebookID)
+}
+
+// queue checks what the job will check before it is queued, so that
+// requests that cannot succeed fail right away. One job per notebook and
+// kind of content is queued at a time.
+func (u *generationUseCase) queue(jobType, userID, notebookID string) (*domain.Job, error) {
+	notebook, authorID, _, err := u.prepare(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	return u.jobs.Enqueue(&domain.Job{
+		Type:       jobType,
+		NotebookID: &notebook.ID,
+		UserID:     &authorID,
+		DedupeKey:  jobType + ":" + notebook.ID.Hex(),
+	})
+}
+
+func (u *generationUseCase) RunJob(job *domain.Job) error {
+	userID, notebookID, err := jobTarget(job)
+	if err != nil {
+		return err
+	}
+	switch job.Type {
+	case domain.JobGenerateSnapnotes:
+		_, err = u.generateSnapnotes(userID, notebookID)
+	case domain.JobGeneratePrepPilot:
+		_, err = u.generatePrepPilot(userID, notebookID)
+	default:
+		err = fmt.Errorf("unexpected job type %s", job.Type)
+	}
+	return err
+}
+
+func (u *generationUseCase) generateSnapnotes(userID, notebookID string) (*domain.Snapnotes, error) {
+	notebook, authorID, input, err := u.prepare(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := u.generator.GenerateSnapnotes(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrContentGeneration, err)
+	}
+	var snapnotes domain.Snapnotes
+	if err := decodeGenerated(output, infrastructure.SnapnotesSchema, &snapnotes); err != nil {
+		return nil, err
+	}
+
+	current, err := u.save(notebook.ID, domain.StaleSnapnotes, func(tx domain.TransactionRepositories, current *domain.Notebook) error {
+		snapnotes.NotebookID = current.ID
+		replaced, err := saveCopiedSnapnotes(tx.Snapnotes, current, &snapnotes)
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: current.ID, Action: domain.RevisionActionGenerate, AuthorID: authorID, Snapnotes: &snapnotes,
+		}, snapnotesBefore(replaced))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.reindex(current)
+	return &snapnotes, nil
+}
+
+func (u *generationUseCase) generatePrepPilot(userID, notebookID string) (*domain.PrepPilot, error) {
+	notebook, authorID, input, err := u.prepare(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := u.generator.GeneratePrepPilot(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrContentGeneration, err)
+	}
+	var prepPilot domain.PrepPilot
+	if err := decodeGenerated(output, infrastructure.PrepPilotSchema, &prepPilot); err != nil {
+		return nil, err
+	}
+
+	current, err := u.save(notebook.ID, domain.StalePrepPilot, func(tx domain.TransactionRepositories, current *domain.Notebook) error {
+		prepPilot.NotebookID = current.ID
+		replaced, err := saveCopiedPrepPilot(tx.PrepPilots, current, &prepPilot)
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: current.ID, Action: domain.RevisionActionGenerate, AuthorID: authorID, PrepPilot: &prepPilot,
+		}, prepPilotBefore(replaced))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.reindex(current)
+	return &prepPilot, nil
+}
+
+// prepare checks that the user may edit the notebook and collects the text
+// of its sources, cut off after maxInputBytes
+func (u *generationUseCase) prepare(userID, notebookID string) (*domain.Notebook, primitive.ObjectID, domain.GenerationInput, error) {
+	input := domain.GenerationInput{}
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, primitive.NilObjectID, input, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, primitive.NilObjectID, input, domain.ErrForbidden
+	}
+	authorID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, primitive.NilObjectID, input, err
+	}
+
+	texts, err := u.textRepo.GetByNotebookID(notebook.ID)
+	if err != nil {
+		return nil, primitive.NilObjectID, input, err
+	}
+	input.Title = notebook.Name
+	remaining := u.maxInputBytes
+	for _, text := range texts {
+		for _, chapter := range text.Chapters {
+			if remaining <= 0 {
+				break
+			}
+			if len(chapter.Text) > remaining {
+				chapter.Text = truncateText(chapter.Text, remaining)
+			}
+			remaining -= len(chapter.Title) + len(chapter.Text)
+			input.Chapters = append(input.Chapters, chapter)
+		}
+	}
+	if len(input.Chapters) == 0 {
+		return nil, primitive.NilObjectID, input, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "sources", Code: "no_source_text", Message: "the notebook has no sources with extracted text",
+		}}}
+	}
+	return notebook, authorID, input, nil
+}
+
+// truncateText cuts text at the last paragraph or word break before limit
+// bytes
+func truncateText(text string, limit int) string {
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	cut := text[:limit]
+	if i := strings.LastIndex(cut, "\n\n"); i > limit/2 {
+		return cut[:i]
+	}
+	if i := strings.LastIndexAny(cut, " \n"); i > 0 {
+		return cut[:i]
+	}
+	return cut
+}
+
+// decodeGenerated checks generated content against its schema before
+// decoding it
+func decodeGenerated(output []byte, schema *infrastructure.JSONSchema, content any) error {
+	if err := schema.Validate(output); err != nil {
+		return fmt.Errorf("%w: generated content %v", domain.ErrContentGeneration, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(content); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrContentGeneration, err)
+	}
+	return nil
+}
+
+// save runs write on the notebook inside a transaction and saves the
+// notebook when write linked new content to it or the content was flagged
+// as stale
+func (u *generationUseCase) save(notebookID primitive.ObjectID, content string, write func(tx domain.TransactionRepositories, current *domain.Notebook) error) (*domain.Notebook, error) {
+	var current *domain.Notebook
+	err := u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		var err error
+		current, err = tx.Notebooks.GetByID(notebookID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return domain.ErrNotebookNotFound
+		}
+
+		snapnotesID, prepPilotID := current.SnapnotesID, current.PrepPilotID
+		if err := write(tx, current); err != nil {
+			return err
+		}
+		stale := len(current.StaleContent)
+		current.StaleContent = slices.DeleteFunc(current.StaleContent, func(name string) bool { return name == content })
+		if current.SnapnotesID == snapnotesID && current.PrepPilotID == prepPilotID && len(current.StaleContent) == stale {
+			return nil
+		}
+		return tx.Notebooks.Update(current)
+	})
+	return current, err
+}
+
+func (u *generationUseCase) reindex(notebook *domain.Notebook) {
+	if err := u.indexer.IndexNotebook(notebook); err != nil {
+		log.Printf("Error indexing notebook %s: %v", notebook.ID.Hex(), err)
+	}
+}
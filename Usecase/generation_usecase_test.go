@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+)
+
+func TestDecodeGenerated(t *testing.T) {
+	valid := `{"title":"Biology","summaryByChapter":[{"chapterTitle":"Cells","summary":"Cells are small.","keyPoints":["Cells are small."]}],` +
+		`"flashcards":[{"chapterTitle":"Cells","flashcards":[{"key term":"Cell","definition":"The smallest unit of life."}]}]}`
+
+	var snapnotes struct {
+		Title            string                     `json:"title"`
+		SummaryByChapter []domain.ChapterSummary    `json:"summaryByChapter"`
+		Flashcards       []domain.ChapterFlashcards `json:"flashcards"`
+	}
+	if err := decodeGenerated([]byte(valid), infrastructure.SnapnotesSchema, &snapnotes); err != nil {
+		t.Fatal(err)
+	}
+	if snapnotes.Title != "Biology" || snapnotes.Flashcards[0].Flashcards[0].KeyTerm != "Cell" {
+		t.Errorf("decoded %+v", snapnotes)
+	}
+
+	for name, output := range map[string]string{
+		"not json":        "Here are your snapnotes:",
+		"markdown fence":  "```json\n" + valid + "\n```",
+		"missing title":   strings.Replace(valid, `"title":"Biology",`, ``, 1),
+		"extra field":     strings.Replace(valid, `"title":"Biology"`, `"title":"Biology","id":"1"`, 1),
+		"wrong type":      strings.Replace(valid, `"keyPoints":["Cells are small."]`, `"keyPoints":"Cells are small."`, 1),
+		"no key points":   strings.Replace(valid, `["Cells are small."]`, `[]`, 1),
+		"two documents":   valid + valid,
+		"prep pilot json": `{"chapters":[]}`,
+	} {
+		err := decodeGenerated([]byte(output), infrastructure.SnapnotesSchema, &snapnotes)
+		if !errors.Is(err, domain.ErrContentGeneration) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	// Fields the schema allows but the target does not know are refused too
+	var titleOnly struct {
+		Title string `json:"title"`
+	}
+	if err := decodeGenerated([]byte(valid), infrastructure.SnapnotesSchema, &titleOnly); !errors.Is(err, domain.ErrContentGeneration) {
+		t.Errorf("unknown fields: got %v", err)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"First paragraph.\n\nSecond paragraph is long.", 30, "First paragraph."},
+		{"One two three four", 12, "One two"},
+		{"Unbreakable", 5, "Unbre"},
+		// Paragraph breaks early in the text are passed over for a later word break
+		{"A\n\nlong second paragraph here", 20, "A\n\nlong second"},
+		{"Zellkern und Mitochondrien", 9, "Zellkern"},
+		{"日本語のテキスト", 7, "日本"},
+		{"naïve café", 3, "na"},
+		{"🧬🧬", 5, "🧬"},
+	} {
+		if got := truncateText(tc.text, tc.limit); got != tc.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tc.text, tc.limit, got, tc.want)
+		}
+	}
+
+	// Every cut of multi-byte text stays valid UTF-8 and within the limit
+	text := "Ümläüte, 漢字 and émojis 🧬🔬 in one sentence.\n\nZweiter Absatz mit ß."
+	for limit := 1; limit < len(text); limit++ {
+		got := truncateText(text, limit)
+		if !utf8.ValidString(got) || len(got) > limit || !strings.HasPrefix(text, got) {
+			t.Errorf("limit %d: got %q", limit, got)
+		}
+	}
+}
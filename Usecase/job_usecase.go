@@ -0,0 +1,230 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var jobStatuses = []string{domain.JobQueued, domain.JobRunning, domain.JobSucceeded, domain.JobDead}
+
+type jobUseCase struct {
+	jobRepo     domain.JobRepository
+	notebooks   domain.NotebookAuthorizer
+	handlers    map[string]domain.JobHandler
+	lease       time.Duration
+	maxAttempts int
+	retryBase   time.Duration
+	retryMax    time.Duration
+}
+
+func NewJobUseCase(jobRepo domain.JobRepository, notebooks domain.NotebookAuthorizer) domain.JobUseCase {
+	return &jobUseCase{
+		jobRepo:     jobRepo,
+		notebooks:   notebooks,
+		handlers:    map[string]domain.JobHandler{},
+		lease:       infrastructure.EnvDuration("JOB_LEASE", 2*time.Minute),
+		maxAttempts: infrastructure.EnvInt("JOB_MAX_ATTEMPTS", 5),
+		retryBase:   infrastructure.EnvDuration("JOB_RETRY_BASE", 30*time.Second),
+		retryMax:    infrastructure.EnvDuration("JOB_RETRY_MAX", time.Hour),
+	}
+}
+
+// Register is called while wiring up the application, before any worker
+// runs
+func (u *jobUseCase) Register(jobType string, handler domain.JobHandler) {
+	u.handlers[jobType] = handler
+}
+
+func (u *jobUseCase) Enqueue(job *domain.Job) (*domain.Job, error) {
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = u.maxAttempts
+	}
+	return u.jobRepo.Enqueue(job)
+}
+
+func (u *jobUseCase) GetJob(userID, jobID string) (*domain.Job, error) {
+	job, _, err := u.jobAccess(userID, jobID)
+	return job, err
+}
+
+func (u *jobUseCase) ListNotebookJobs(userID, notebookID string, request domain.JobListRequest) (*domain.Page[*domain.Job], error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Status != "" && !slices.Contains(jobStatuses, request.Status) {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "status", Code: "invalid", Message: "must be one of " + strings.Join(jobStatuses, ", "),
+		}}}
+	}
+	page, err := pageOptions(request.ListRequest, domain.JobSortFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.jobRepo.Find(notebook.ID, domain.JobQuery{Status: request.Status, PageOptions: page})
+}
+
+func (u *jobUseCase) RetryJob(userID, jobID string) (*domain.Job, error) {
+	job, canEdit, err := u.jobAccess(userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !canEdit {
+		return nil, domain.ErrForbidden
+	}
+	if job.Status != domain.JobDead {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "status", Code: "not_dead", Message: "only dead jobs can be retried",
+		}}}
+	}
+	return u.jobRepo.Requeue(job.ID)
+}
+
+// jobAccess returns a job the user queued or that belongs to a notebook the
+// user can access, and whether the user may change it. Other jobs are
+// reported as not found.
+func (u *jobUseCase) jobAccess(userID, jobID string) (*domain.Job, bool, error) {
+	objectJobID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, false, domain.ErrJobNotFound
+	}
+	job, err := u.jobRepo.GetByID(objectJobID)
+	if err != nil {
+		return nil, false, err
+	}
+	if job == nil {
+		return nil, false, domain.ErrJobNotFound
+	}
+
+	if job.UserID != nil && job.UserID.Hex() == userID {
+		return job, true, nil
+	}
+	if job.NotebookID != nil {
+		_, role, err := u.notebooks.NotebookAccess(userID, job.NotebookID.Hex())
+		if err == nil {
+			return job, domain.CanEdit(role), nil
+		}
+		if !errors.Is(err, domain.ErrNotebookNotFound) {
+			return nil, false, err
+		}
+	}
+	return nil, false, domain.ErrJobNotFound
+}
+
+func (u *jobUseCase) RunNext(workerID string) (bool, error) {
+	job, err := u.jobRepo.Lease(workerID, u.lease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	// A job leased more often than allowed kept losing its worker, e.g. to
+	// crashes while running it
+	if job.Attempts > job.MaxAttempts {
+		return true, u.settle(job, workerID, u.jobRepo.Bury(job.ID, workerID, "the job did not finish within its lease"))
+	}
+	handler, ok := u.handlers[job.Type]
+	if !ok {
+		return true, u.settle(job, workerID, u.jobRepo.Bury(job.ID, workerID, "unknown job type "+job.Type))
+	}
+
+	stopHeartbeat := u.keepLeased(job, workerID)
+	err = runJobHandler(handler, job)
+	stopHeartbeat()
+
+	if err == nil {
+		return true, u.settle(job, workerID, u.jobRepo.Complete(job.ID, workerID))
+	}
+	if permanentJobError(err) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %s (%s) failed for good on attempt %d: %v", job.ID.Hex(), job.Type, job.Attempts, err)
+		return true, u.settle(job, workerID, u.jobRepo.Bury(job.ID, workerID, err.Error()))
+	}
+	log.Printf("Job %s (%s) failed on attempt %d, retrying: %v", job.ID.Hex(), job.Type, job.Attempts, err)
+	return true, u.settle(job, workerID, u.jobRepo.Retry(job.ID, workerID, err.Error(), time.Now().Add(u.backoff(job.Attempts))))
+}
+
+// settle ignores a lost lease; the worker that took the job over records
+// its outcome
+func (u *jobUseCase) settle(job *domain.Job, workerID string, err error) error {
+	if errors.Is(err, domain.ErrJobLeaseLost) {
+		log.Printf("Job %s (%s) was taken over from worker %s", job.ID.Hex(), job.Type, workerID)
+		return nil
+	}
+	return err
+}
+
+// keepLeased renews the job's lease every third of its duration until the
+// returned function is called
+func (u *jobUseCase) keepLeased(job *domain.Job, workerID string) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(u.lease / 3)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := u.jobRepo.Heartbeat(job.ID, workerID, u.lease); err != nil {
+					log.Printf("Error renewing lease of job %s: %v", job.ID.Hex(), err)
+					if errors.Is(err, domain.ErrJobLeaseLost) {
+						return
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// backoff doubles the delay before each further attempt
+func (u *jobUseCase) backoff(attempts int) time.Duration {
+	delay := u.retryBase
+	for i := 1; i < attempts && delay < u.retryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, u.retryMax)
+}
+
+// runJobHandler turns a panicking handler into a failed attempt
+func runJobHandler(handler domain.JobHandler, job *domain.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(job)
+}
+
+// permanentJobError reports errors that retrying will not fix
+func permanentJobError(err error) bool {
+	var validationErr *domain.ValidationError
+	return errors.As(err, &validationErr) ||
+		errors.Is(err, domain.ErrNotebookNotFound) ||
+		errors.Is(err, domain.ErrSourceNotFound) ||
+		errors.Is(err, domain.ErrDriveFileNotFound) ||
+		errors.Is(err, domain.ErrForbidden) ||
+		errors.Is(err, domain.ErrVersionConflict)
+}
+
+// jobTarget reads the user and notebook of a job queued on a user's behalf
+func jobTarget(job *domain.Job) (string, string, error) {
+	if job.UserID == nil || job.NotebookID == nil {
+		return "", "", &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "job", Code: "invalid", Message: job.Type + " jobs need a user and a notebook",
+		}}}
+	}
+	return job.UserID.Hex(), job.NotebookID.Hex(), nil
+}
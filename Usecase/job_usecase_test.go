@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+func newTestJobUseCase(handler domain.JobHandler) (*jobUseCase, *memoryJobRepository) {
+	repo := newMemoryJobRepository()
+	useCase := NewJobUseCase(repo, nil).(*jobUseCase)
+	useCase.lease, useCase.maxAttempts = time.Minute, 3
+	useCase.retryBase, useCase.retryMax = 30*time.Second, time.Hour
+	useCase.Register("test", handler)
+	return useCase, repo
+}
+
+// runOnce enqueues a job and runs it once on worker-a
+func runOnce(t *testing.T, useCase *jobUseCase, repo *memoryJobRepository, job *domain.Job) domain.Job {
+	t.Helper()
+	if _, err := useCase.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+	ran, err := useCase.RunNext("worker-a")
+	if err != nil || !ran {
+		t.Fatalf("RunNext: %v, %v", ran, err)
+	}
+	stored, _ := repo.GetByID(job.ID)
+	return *stored
+}
+
+func TestRunNextCompletesJob(t *testing.T) {
+	useCase, repo := newTestJobUseCase(func(job *domain.Job) error { return nil })
+
+	if ran, err := useCase.RunNext("worker-a"); ran || err != nil {
+		t.Fatalf("ran a job from an empty queue: %v, %v", ran, err)
+	}
+	job := runOnce(t, useCase, repo, &domain.Job{Type: "test", DedupeKey: "test"})
+	if job.Status != domain.JobSucceeded || job.Attempts != 1 || job.LeasedBy != "" || job.DedupeKey != "" {
+		t.Errorf("got %+v", job)
+	}
+}
+
+func TestRunNextRetriesWithBackoff(t *testing.T) {
+	useCase, repo := newTestJobUseCase(func(job *domain.Job) error { return errors.New("service unavailable") })
+
+	before := time.Now()
+	job := runOnce(t, useCase, repo, &domain.Job{Type: "test"})
+	if job.Status != domain.JobQueued || job.Error != "service unavailable" || job.LeasedBy != "" {
+		t.Fatalf("got %+v", job)
+	}
+	if delay := job.RunAt.Sub(before); delay < 30*time.Second || delay > 31*time.Second {
+		t.Errorf("retried after %v, want 30s", delay)
+	}
+	// Not due yet
+	if ran, _ := useCase.RunNext("worker-a"); ran {
+		t.Error("the retry ran before its backoff")
+	}
+
+	for _, tc := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	} {
+		if got := useCase.backoff(tc.attempts); got != tc.want {
+			t.Errorf("backoff after %d attempts: got %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+func TestRunNextBuriesJobs(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		attempts int
+		message  string
+	}{
+		{"permanent error", &domain.ValidationError{Fields: []domain.FieldError{{Field: "job", Code: "invalid", Message: "bad"}}}, 0, "bad"},
+		{"missing notebook", domain.ErrNotebookNotFound, 0, domain.ErrNotebookNotFound.Error()},
+		{"last attempt", errors.New("service unavailable"), 2, "service unavailable"},
+		{"leased too often", nil, 3, "the job did not finish within its lease"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			useCase, repo := newTestJobUseCase(func(job *domain.Job) error { return tc.err })
+			job := &domain.Job{Type: "test", DedupeKey: "test"}
+			useCase.Enqueue(job)
+			stored := repo.jobs[job.ID]
+			stored.Attempts = tc.attempts
+			repo.jobs[job.ID] = stored
+
+			ran, err := useCase.RunNext("worker-a")
+			if err != nil || !ran {
+				t.Fatalf("RunNext: %v, %v", ran, err)
+			}
+			buried := repo.jobs[job.ID]
+			if buried.Status != domain.JobDead || !strings.Contains(buried.Error, tc.message) || buried.FinishedAt == nil {
+				t.Errorf("got %+v", buried)
+			}
+			// A dead job no longer holds its key
+			if again, _ := useCase.Enqueue(&domain.Job{Type: "test", DedupeKey: "test"}); again.ID == job.ID {
+				t.Error("the dead job kept its dedupe key")
+			}
+		})
+	}
+
+	t.Run("unknown type", func(t *testing.T) {
+		useCase, repo := newTestJobUseCase(nil)
+		job := runOnce(t, useCase, repo, &domain.Job{Type: "export"})
+		if job.Status != domain.JobDead || job.Error != "unknown job type export" {
+			t.Errorf("got %+v", job)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		useCase, repo := newTestJobUseCase(func(job *domain.Job) error { panic("nil map") })
+		job := runOnce(t, useCase, repo, &domain.Job{Type: "test"})
+		if job.Status != domain.JobQueued || job.Error != "job panicked: nil map" {
+			t.Errorf("got %+v", job)
+		}
+	})
+}
+
+func TestExpiredLeaseIsTakenOver(t *testing.T) {
+	var useCase *jobUseCase
+	var repo *memoryJobRepository
+	runs := 0
+	useCase, repo = newTestJobUseCase(func(job *domain.Job) error {
+		runs++
+		if runs == 1 {
+			// worker-a stalls past its lease and worker-b takes the job over
+			repo.expireLease(job.ID)
+			if ran, err := useCase.RunNext("worker-b"); err != nil || !ran {
+				t.Errorf("worker-b: %v, %v", ran, err)
+			}
+		}
+		return nil
+	})
+
+	// worker-a finds its lease lost when it tries to complete the job
+	job := runOnce(t, useCase, repo, &domain.Job{Type: "test"})
+	if runs != 2 || job.Status != domain.JobSucceeded || job.Attempts != 2 {
+		t.Errorf("got %+v after %d runs", job, runs)
+	}
+	if err := repo.Heartbeat(job.ID, "worker-a", time.Minute); !errors.Is(err, domain.ErrJobLeaseLost) {
+		t.Errorf("worker-a renewed the lease of a job it lost: %v", err)
+	}
+}
+
+func TestHeartbeatKeepsLease(t *testing.T) {
+	var useCase *jobUseCase
+	var repo *memoryJobRepository
+	var takenOver atomic.Bool
+	useCase, repo = newTestJobUseCase(func(job *domain.Job) error {
+		// Runs for several leases while another worker keeps looking for jobs
+		for deadline := time.Now().Add(400 * time.Millisecond); time.Now().Before(deadline); {
+			if other, _ := repo.Lease("worker-b", useCase.lease); other != nil {
+				takenOver.Store(true)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	})
+	useCase.lease = 150 * time.Millisecond
+
+	job := runOnce(t, useCase, repo, &domain.Job{Type: "test"})
+	if takenOver.Load() {
+		t.Error("another worker took over a job whose lease was renewed")
+	}
+	if job.Status != domain.JobSucceeded {
+		t.Errorf("got %+v", job)
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.heartbeats < 4 {
+		t.Errorf("renewed the lease %d times, want at least 4", repo.heartbeats)
+	}
+}
@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+)
+
+type loginThrottle struct {
+	attemptRepo        domain.LoginAttemptRepository
+	userRepo           domain.UserRepository
+	mailer             infrastructure.Mailer
+	maxAccountFailures int
+	maxIPFailures      int
+	window             time.Duration
+	baseLockout        time.Duration
+	maxLockout         time.Duration
+	now                func() time.Time
+}
+
+func NewLoginThrottle(
+	attemptRepo domain.LoginAttemptRepository,
+	userRepo domain.UserRepository,
+	mailer infrastructure.Mailer,
+) domain.LoginThrottle {
+	return &loginThrottle{
+		attemptRepo:        attemptRepo,
+		userRepo:           userRepo,
+		mailer:             mailer,
+		maxAccountFailures: infrastructure.EnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		maxIPFailures:      infrastructure.EnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		window:             infrastructure.EnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		baseLockout:        infrastructure.EnvDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		maxLockout:         infrastructure.EnvDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		now:                time.Now,
+	}
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (t *loginThrottle) Check(email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range t.keys(email, ip) {
+		attempt, err := t.attemptRepo.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		if attempt != nil {
+			if remaining := attempt.LockedUntil.Sub(t.now()); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+
+	if wait > 0 {
+		return wait, domain.ErrTooManyRequests
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failed attempt against the account and the IP. Once a
+// key passes its limit it is locked, and every further failure doubles the
+// lockout up to LOGIN_LOCKOUT_MAX. The window runs from the end of a lockout,
+// so failures right after it keep escalating.
+func (t *loginThrottle) RecordFailure(email, ip string) error {
+	now := t.now()
+
+	if email != "" {
+		attempt, err := t.attemptRepo.RecordFailure(accountKey(email), now, t.window)
+		if err != nil {
+			return err
+		}
+		if lockout := t.lockoutFor(attempt.Failures, t.maxAccountFailures); lockout > 0 {
+			if err := t.attemptRepo.SetLockedUntil(attempt.Key, now.Add(lockout)); err != nil {
+				return err
+			}
+			// Tell the owner the first time the account gets locked
+			if attempt.Failures == t.maxAccountFailures {
+				t.notifyLockout(email, lockout)
+			}
+		}
+	}
+
+	if ip != "" {
+		attempt, err := t.attemptRepo.RecordFailure(ipKey(ip), now, t.window)
+		if err != nil {
+			return err
+		}
+		if lockout := t.lockoutFor(attempt.Failures, t.maxIPFailures); lockout > 0 {
+			if err := t.attemptRepo.SetLockedUntil(attempt.Key, now.Add(lockout)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the account counter. The IP counter is left to expire
+// on its own so one valid login cannot hide guessing against other accounts.
+func (t *loginThrottle) RecordSuccess(email, ip string) error {
+	return t.attemptRepo.Reset(accountKey(email))
+}
+
+func (t *loginThrottle) keys(email, ip string) []string {
+	var keys []string
+	if email != "" {
+		keys = append(keys, accountKey(email))
+	}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func (t *loginThrottle) lockoutFor(failures, limit int) time.Duration {
+	if limit <= 0 || failures < limit {
+		return 0
+	}
+
+	lockout := t.baseLockout
+	for i := limit; i < failures && lockout < t.maxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, t.maxLockout)
+}
+
+func (t *loginThrottle) notifyLockout(email string, lockout time.Duration) {
+	user, err := t.userRepo.FindByEmail(email)
+	if err != nil || user == nil {
+		return
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nWe noticed several failed sign-in attempts on your Cognivia account, "+
+			"so we have temporarily locked it for %s.\n\n"+
+			"If this was you, wait and try again or reset your password. "+
+			"If it was not, we recommend resetting your password and enabling two-factor authentication.\n",
+		user.Name, lockout,
+	)
+	if err := t.mailer.Send(user.Email, "Your Cognivia account was temporarily locked", body); err != nil {
+		log.Printf("Error sending lockout email: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+	mongodb "cognivia-api/Repositories"
+)
+
+// newTestLoginThrottle returns a throttle locking accounts after 3 failures
+// and IPs after 6, and a function moving its clock forward
+func newTestLoginThrottle() (*loginThrottle, *recordingMailer, func(time.Duration)) {
+	users := newMemoryUserRepository()
+	users.Create(&domain.User{Email: "ada@example.com", Name: "Ada"})
+	mailer := &recordingMailer{}
+
+	throttle := NewLoginThrottle(mongodb.NewInMemoryLoginAttemptRepository(), users, mailer).(*loginThrottle)
+	throttle.maxAccountFailures, throttle.maxIPFailures = 3, 6
+	throttle.window, throttle.baseLockout, throttle.maxLockout = 15*time.Minute, time.Minute, time.Hour
+	now := time.Now()
+	throttle.now = func() time.Time { return now }
+	return throttle, mailer, func(d time.Duration) { now = now.Add(d) }
+}
+
+func checkWait(t *testing.T, throttle *loginThrottle, email, ip string) time.Duration {
+	t.Helper()
+	wait, err := throttle.Check(email, ip)
+	if (wait > 0) != errors.Is(err, domain.ErrTooManyRequests) {
+		t.Fatalf("waiting %v with %v", wait, err)
+	}
+	return wait
+}
+
+func TestLoginThrottleEscalates(t *testing.T) {
+	throttle, mailer, advance := newTestLoginThrottle()
+
+	for range 2 {
+		throttle.RecordFailure("ada@example.com", "")
+		if wait := checkWait(t, throttle, "ada@example.com", ""); wait != 0 {
+			t.Fatalf("locked after fewer failures than the limit for %v", wait)
+		}
+	}
+
+	// Each failure after a lockout doubles it, also once lockouts outlast the
+	// failure window
+	for _, want := range []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute,
+		32 * time.Minute, time.Hour, time.Hour,
+	} {
+		throttle.RecordFailure("ada@example.com", "")
+		if wait := checkWait(t, throttle, "Ada@Example.com ", ""); wait != want {
+			t.Fatalf("locked for %v, want %v", wait, want)
+		}
+		advance(want)
+		if wait := checkWait(t, throttle, "ada@example.com", ""); wait != 0 {
+			t.Fatalf("still locked for %v after the lockout", wait)
+		}
+	}
+
+	// The owner hears about the first lockout only
+	if len(mailer.sent) != 1 || mailer.sent[0] != "ada@example.com" {
+		t.Errorf("sent %q", mailer.sent)
+	}
+
+	// A quiet window after the last lockout starts over
+	advance(throttle.window + time.Second)
+	throttle.RecordFailure("ada@example.com", "")
+	if wait := checkWait(t, throttle, "ada@example.com", ""); wait != 0 {
+		t.Errorf("locked for %v after a quiet window", wait)
+	}
+}
+
+func TestLoginThrottleResetsOnSuccess(t *testing.T) {
+	throttle, mailer, advance := newTestLoginThrottle()
+
+	for range 3 {
+		throttle.RecordFailure("ada@example.com", "192.0.2.1")
+	}
+	advance(time.Minute)
+	if err := throttle.RecordSuccess("ada@example.com", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		throttle.RecordFailure("ada@example.com", "192.0.2.1")
+	}
+	if wait := checkWait(t, throttle, "ada@example.com", ""); wait != 0 {
+		t.Errorf("the count survived a successful login, locked for %v", wait)
+	}
+
+	// The IP counter is kept: a sixth failure from it locks every account
+	throttle.RecordFailure("grace@example.com", "192.0.2.1")
+	if wait := checkWait(t, throttle, "someone@example.com", "192.0.2.1"); wait != time.Minute {
+		t.Errorf("IP locked for %v, want 1m", wait)
+	}
+	if wait := checkWait(t, throttle, "someone@example.com", "192.0.2.2"); wait != 0 {
+		t.Errorf("another IP locked for %v", wait)
+	}
+
+	// Locking the account again after a success notifies the owner again
+	throttle.RecordFailure("ada@example.com", "")
+	if len(mailer.sent) != 2 {
+		t.Errorf("sent %q", mailer.sent)
+	}
+}
@@ -0,0 +1,318 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type notebookCopyUseCase struct {
+	notebookRepo  domain.NotebookRepository
+	snapnotesRepo domain.SnapnotesRepository
+	prepPilotRepo domain.PrepPilotRepository
+	notebooks     domain.NotebookAuthorizer
+	unitOfWork    domain.UnitOfWork
+	indexer       domain.NotebookIndexer
+}
+
+func NewNotebookCopyUseCase(
+	notebookRepo domain.NotebookRepository,
+	snapnotesRepo domain.SnapnotesRepository,
+	prepPilotRepo domain.PrepPilotRepository,
+	notebooks domain.NotebookAuthorizer,
+	unitOfWork domain.UnitOfWork,
+	indexer domain.NotebookIndexer,
+) domain.NotebookCopyUseCase {
+	return &notebookCopyUseCase{
+		notebookRepo:  notebookRepo,
+		snapnotesRepo: snapnotesRepo,
+		prepPilotRepo: prepPilotRepo,
+		notebooks:     notebooks,
+		unitOfWork:    unitOfWork,
+		indexer:       indexer,
+	}
+}
+
+func (u *notebookCopyUseCase) DuplicateNotebook(userID, notebookID string, request domain.DuplicateRequest) (*domain.Notebook, error) {
+	source, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+
+	var duplicate *domain.Notebook
+	err = u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		duplicate = &domain.Notebook{
+			UserID:          objectUserID,
+			Name:            name,
+			Icon:            source.Icon,
+			Color:           source.Color,
+			Type:            source.Type,
+			GoogleDriveLink: source.GoogleDriveLink,
+			Tags:            slices.Clone(source.Tags),
+			Origin:          &domain.NotebookOrigin{NotebookID: source.ID, SyncedAt: time.Now()},
+		}
+		// Folders belong to the owner, a fork starts outside any folder
+		if role == domain.ShareRoleOwner {
+			duplicate.FolderID = source.FolderID
+		}
+		if err := tx.Notebooks.Create(duplicate); err != nil {
+			return err
+		}
+
+		// Content is read inside the transaction so the copy matches one
+		// state of the source
+		current, err := tx.Notebooks.GetByID(source.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return domain.ErrNotebookNotFound
+		}
+		if _, err := pullContent(tx, current, duplicate, objectUserID, domain.RevisionActionCreate); err != nil {
+			return err
+		}
+
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: duplicate.ID, Action: domain.RevisionActionCreate, AuthorID: objectUserID, Notebook: duplicate,
+		}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.reindex(duplicate)
+	return duplicate, nil
+}
+
+func (u *notebookCopyUseCase) GetOriginStatus(userID, notebookID string) (*domain.OriginStatus, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook.Origin == nil {
+		return nil, domain.ErrOriginNotFound
+	}
+
+	status := &domain.OriginStatus{Origin: notebook.Origin}
+	source, _, err := u.notebooks.NotebookAccess(userID, notebook.Origin.NotebookID.Hex())
+	if errors.Is(err, domain.ErrNotebookNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.SourceAvailable = true
+
+	if source.SnapnotesID != nil {
+		snapnotes, err := u.snapnotesRepo.GetByID(*source.SnapnotesID)
+		if err != nil {
+			return nil, err
+		}
+		if snapnotes != nil && !sameContent(notebook.Origin.SnapnotesID, notebook.Origin.SnapnotesVersion, snapnotes.ID, snapnotes.Version) {
+			status.UpdatesAvailable = true
+		}
+	}
+	if source.PrepPilotID != nil {
+		prepPilot, err := u.prepPilotRepo.GetByID(*source.PrepPilotID)
+		if err != nil {
+			return nil, err
+		}
+		if prepPilot != nil && !sameContent(notebook.Origin.PrepPilotID, notebook.Origin.PrepPilotVersion, prepPilot.ID, prepPilot.Version) {
+			status.UpdatesAvailable = true
+		}
+	}
+	return status, nil
+}
+
+func (u *notebookCopyUseCase) SyncFromOrigin(userID, notebookID string) (*domain.Notebook, error) {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
+	}
+	if notebook.Origin == nil {
+		return nil, domain.ErrOriginNotFound
+	}
+	// Pulling needs read access to the source
+	if _, _, err := u.notebooks.NotebookAccess(userID, notebook.Origin.NotebookID.Hex()); err != nil {
+		if errors.Is(err, domain.ErrNotebookNotFound) {
+			return nil, domain.ErrOriginNotFound
+		}
+		return nil, err
+	}
+	authorID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied *domain.Notebook
+	err = u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		copied, err = tx.Notebooks.GetByID(notebook.ID)
+		if err != nil {
+			return err
+		}
+		if copied == nil || copied.DeletedAt != nil || copied.Origin == nil {
+			return domain.ErrNotebookNotFound
+		}
+		source, err := tx.Notebooks.GetByID(copied.Origin.NotebookID)
+		if err != nil {
+			return err
+		}
+		if source == nil || source.DeletedAt != nil {
+			return domain.ErrOriginNotFound
+		}
+		before := notebookBefore(copied)
+
+		changed, err := pullContent(tx, source, copied, authorID, domain.RevisionActionSync)
+		if err != nil || !changed {
+			return err
+		}
+		copied.Origin.SyncedAt = time.Now()
+		if err := tx.Notebooks.Update(copied); err != nil {
+			return err
+		}
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: copied.ID, Action: domain.RevisionActionSync, AuthorID: authorID, Notebook: copied,
+		}, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.reindex(copied)
+	return copied, nil
+}
+
+// pullContent copies the source's snapnotes and prep pilot into target where
+// they differ from what target's origin last took over. Content the copy
+// already has is overwritten in place; its earlier state stays in the
+// revision history. It reports whether anything was copied; target itself is
+// saved only when it had no content of that kind yet.
+func pullContent(tx domain.TransactionRepositories, source, target *domain.Notebook, authorID primitive.ObjectID, action string) (bool, error) {
+	changed := false
+
+	if source.SnapnotesID != nil {
+		snapnotes, err := tx.Snapnotes.GetByID(*source.SnapnotesID)
+		if err != nil {
+			return false, err
+		}
+		if snapnotes != nil && !sameContent(target.Origin.SnapnotesID, target.Origin.SnapnotesVersion, snapnotes.ID, snapnotes.Version) {
+			content := *snapnotes
+			content.NotebookID = target.ID
+			replaced, err := saveCopiedSnapnotes(tx.Snapnotes, target, &content)
+			if err != nil {
+				return false, err
+			}
+			if err := recordRevision(tx.Revisions, &domain.Revision{
+				NotebookID: target.ID, Action: action, AuthorID: authorID, Snapnotes: &content,
+			}, snapnotesBefore(replaced)); err != nil {
+				return false, err
+			}
+			target.Origin.SnapnotesID, target.Origin.SnapnotesVersion = &snapnotes.ID, snapnotes.Version
+			changed = true
+		}
+	}
+
+	if source.PrepPilotID != nil {
+		prepPilot, err := tx.PrepPilots.GetByID(*source.PrepPilotID)
+		if err != nil {
+			return false, err
+		}
+		if prepPilot != nil && !sameContent(target.Origin.PrepPilotID, target.Origin.PrepPilotVersion, prepPilot.ID, prepPilot.Version) {
+			content := *prepPilot
+			content.NotebookID = target.ID
+			replaced, err := saveCopiedPrepPilot(tx.PrepPilots, target, &content)
+			if err != nil {
+				return false, err
+			}
+			if err := recordRevision(tx.Revisions, &domain.Revision{
+				NotebookID: target.ID, Action: action, AuthorID: authorID, PrepPilot: &content,
+			}, prepPilotBefore(replaced)); err != nil {
+				return false, err
+			}
+			target.Origin.PrepPilotID, target.Origin.PrepPilotVersion = &prepPilot.ID, prepPilot.Version
+			changed = true
+		}
+	}
+
+	if changed && action == domain.RevisionActionCreate {
+		// A new duplicate has to be saved with its links and origin
+		return true, tx.Notebooks.Update(target)
+	}
+	return changed, nil
+}
+
+// saveCopiedSnapnotes writes content over the target's own snapnotes, or
+// creates them when the target has none yet. It returns the snapnotes it
+// replaced.
+func saveCopiedSnapnotes(snapnotesRepo domain.SnapnotesRepository, target *domain.Notebook, content *domain.Snapnotes) (*domain.Snapnotes, error) {
+	if target.SnapnotesID != nil {
+		existing, err := snapnotesRepo.GetByID(*target.SnapnotesID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.NotebookID == target.ID {
+			content.ID, content.Version = existing.ID, existing.Version
+			return existing, snapnotesRepo.Update(content)
+		}
+	}
+
+	content.ID = primitive.NilObjectID
+	if err := snapnotesRepo.Create(content); err != nil {
+		return nil, err
+	}
+	target.SnapnotesID = &content.ID
+	return nil, nil
+}
+
+// saveCopiedPrepPilot writes content over the target's own prep pilot, or
+// creates one when the target has none yet. It returns the prep pilot it
+// replaced.
+func saveCopiedPrepPilot(prepPilotRepo domain.PrepPilotRepository, target *domain.Notebook, content *domain.PrepPilot) (*domain.PrepPilot, error) {
+	if target.PrepPilotID != nil {
+		existing, err := prepPilotRepo.GetByID(*target.PrepPilotID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.NotebookID == target.ID {
+			content.ID, content.Version = existing.ID, existing.Version
+			return existing, prepPilotRepo.Update(content)
+		}
+	}
+
+	content.ID = primitive.NilObjectID
+	if err := prepPilotRepo.Create(content); err != nil {
+		return nil, err
+	}
+	target.PrepPilotID = &content.ID
+	return nil, nil
+}
+
+// sameContent reports whether the recorded origin document is still the
+// source's current one at the same version
+func sameContent(originID *primitive.ObjectID, originVersion int64, id primitive.ObjectID, version int64) bool {
+	return originID != nil && *originID == id && originVersion == version
+}
+
+func (u *notebookCopyUseCase) reindex(notebook *domain.Notebook) {
+	if err := u.indexer.IndexNotebook(notebook); err != nil {
+		log.Printf("Error indexing notebook %s: %v", notebook.ID.Hex(), err)
+	}
+}
@@ -2,9 +2,16 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -13,21 +20,39 @@ type notebookUseCase struct {
 	notebookRepo  domain.NotebookRepository
 	snapnotesRepo domain.SnapnotesRepository
 	prepPilotRepo domain.PrepPilotRepository
+	shareRepo     domain.NotebookShareRepository
+	folderRepo    domain.FolderRepository
+	unitOfWork    domain.UnitOfWork
+	indexer       domain.NotebookIndexer
+	userRepo      domain.UserRepository
+	mailer        infrastructure.Mailer
 }
 
 func NewNotebookUseCase(
 	notebookRepo domain.NotebookRepository,
 	snapnotesRepo domain.SnapnotesRepository,
 	prepPilotRepo domain.PrepPilotRepository,
+	shareRepo domain.NotebookShareRepository,
+	folderRepo domain.FolderRepository,
+	unitOfWork domain.UnitOfWork,
+	indexer domain.NotebookIndexer,
+	userRepo domain.UserRepository,
+	mailer infrastructure.Mailer,
 ) domain.NotebookUseCase {
 	return &notebookUseCase{
 		notebookRepo:  notebookRepo,
 		snapnotesRepo: snapnotesRepo,
 		prepPilotRepo: prepPilotRepo,
+		shareRepo:     shareRepo,
+		folderRepo:    folderRepo,
+		unitOfWork:    unitOfWork,
+		indexer:       indexer,
+		userRepo:      userRepo,
+		mailer:        mailer,
 	}
 }
 
-func (u *notebookUseCase) CreateNotebook(userID string, notebook *domain.Notebook) error {
+func (u *notebookUseCase) CreateNotebook(userID string, request *domain.CreateNotebookRequest) error {
 
 	// Convert userID string to primitive.ObjectID
 	objectID, err := primitive.ObjectIDFromHex(userID)
@@ -35,49 +60,102 @@ func (u *notebookUseCase) CreateNotebook(userID string, notebook *domain.Noteboo
 		return err
 	}
 
+	notebook := &request.Notebook
 	notebook.UserID = objectID
+	// Content can only be linked when it is created with the notebook
+	notebook.SnapnotesID = nil
+	notebook.PrepPilotID = nil
+	// Only duplicates have an origin, the rest is maintained by the server
+	notebook.Origin = nil
+	notebook.Extraction = nil
+	notebook.Drive = nil
+	notebook.StaleContent = nil
+	notebook.DeletedAt = nil
+
+	if notebook.FolderID != nil {
+		if _, err := findOwnedFolder(u.folderRepo, objectID, notebook.FolderID.Hex(), "folder_id"); err != nil {
+			return err
+		}
+	}
+	if notebook.Tags, err = normalizeTags(notebook.Tags); err != nil {
+		return err
+	}
 
-	// Add any business logic here before creating the notebook
-	return u.notebookRepo.Create(notebook)
-}
+	// The notebook and its content are written together so a failure never
+	// leaves content without a notebook or a notebook pointing at nothing
+	err = u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		if err := tx.Notebooks.Create(notebook); err != nil {
+			return err
+		}
 
-func (u *notebookUseCase) GetNotebookByID(userID string, notebookID string) (*domain.Notebook, error) {
-	objectUserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return nil, err
-	}
+		if request.Snapnotes != nil {
+			request.Snapnotes.ID = primitive.NilObjectID
+			request.Snapnotes.NotebookID = notebook.ID
+			if err := tx.Snapnotes.Create(request.Snapnotes); err != nil {
+				return err
+			}
+			notebook.SnapnotesID = &request.Snapnotes.ID
+			if err := recordRevision(tx.Revisions, &domain.Revision{
+				NotebookID: notebook.ID, Action: domain.RevisionActionCreate, AuthorID: objectID, Snapnotes: request.Snapnotes,
+			}, nil); err != nil {
+				return err
+			}
+		}
+		if request.PrepPilot != nil {
+			request.PrepPilot.ID = primitive.NilObjectID
+			request.PrepPilot.NotebookID = notebook.ID
+			if err := tx.PrepPilots.Create(request.PrepPilot); err != nil {
+				return err
+			}
+			notebook.PrepPilotID = &request.PrepPilot.ID
+			if err := recordRevision(tx.Revisions, &domain.Revision{
+				NotebookID: notebook.ID, Action: domain.RevisionActionCreate, AuthorID: objectID, PrepPilot: request.PrepPilot,
+			}, nil); err != nil {
+				return err
+			}
+		}
+		if notebook.SnapnotesID != nil || notebook.PrepPilotID != nil {
+			if err := tx.Notebooks.Update(notebook); err != nil {
+				return err
+			}
+		}
 
-	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: notebook.ID, Action: domain.RevisionActionCreate, AuthorID: objectID, Notebook: notebook,
+		}, nil)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	u.reindex(notebook)
+	return nil
+}
 
-	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
+func (u *notebookUseCase) GetNotebookByID(userID string, notebookID string) (*domain.Notebook, error) {
+	notebook, _, err := u.NotebookAccess(userID, notebookID)
 	if err != nil {
 		return nil, err
 	}
-
-	if notebook == nil || notebook.UserID != objectUserID {
-		return nil, errors.New("notebook not found or does not belong to user")
-	}
-
 	return notebook, nil
 }
 
-func (u *notebookUseCase) UpdateNotebook(notebookID string, notebook domain.UpdateRequest) error {
-	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+func (u *notebookUseCase) UpdateNotebook(userID string, notebookID string, notebook domain.UpdateRequest, expectedVersion *int64) (*domain.Notebook, error) {
+	existingNotebook, role, err := u.NotebookAccess(userID, notebookID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	existingNotebook, err := u.notebookRepo.GetByID(objectNotebookID)
-	if err != nil {
-		return err
+	// Viewers are refused whatever version they send
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
 	}
-
-	if existingNotebook == nil {
-		return errors.New("notebook not found")
+	if err := checkVersion(expectedVersion, existingNotebook.Version); err != nil {
+		return nil, err
+	}
+	authorID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
 	}
+	before := notebookBefore(existingNotebook)
 
 	// Update allowed fields
 	if notebook.Name != nil {
@@ -95,45 +173,152 @@ func (u *notebookUseCase) UpdateNotebook(notebookID string, notebook domain.Upda
 	if notebook.GoogleDriveLink != nil {
 		existingNotebook.GoogleDriveLink = notebook.GoogleDriveLink
 	}
-	if notebook.SnapnotesID != nil {
-		snapnotesID, err := primitive.ObjectIDFromHex(*notebook.SnapnotesID)
+	if notebook.Tags != nil {
+		tags, err := normalizeTags(*notebook.Tags)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		existingNotebook.SnapnotesID = &snapnotesID
+		existingNotebook.Tags = tags
 	}
-	if notebook.PrepPilotID != nil {
-		prepPilotID, err := primitive.ObjectIDFromHex(*notebook.PrepPilotID)
-		if err != nil {
+	if notebook.FolderID != nil {
+		// Folders belong to the owner, editors cannot file the notebook
+		if role != domain.ShareRoleOwner {
+			return nil, domain.ErrForbidden
+		}
+		if *notebook.FolderID == "" {
+			existingNotebook.FolderID = nil
+		} else {
+			folder, err := findOwnedFolder(u.folderRepo, existingNotebook.UserID, *notebook.FolderID, "folder_id")
+			if err != nil {
+				return nil, err
+			}
+			existingNotebook.FolderID = &folder.ID
+		}
+	}
+
+	// Relinked content is checked in the same transaction that saves the
+	// notebook, so a concurrent purge cannot leave it pointing at deleted
+	// documents
+	snapnotesID, prepPilotID, version := existingNotebook.SnapnotesID, existingNotebook.PrepPilotID, existingNotebook.Version
+	err = u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		// Start from the stored state again when the transaction is retried
+		existingNotebook.SnapnotesID, existingNotebook.PrepPilotID = snapnotesID, prepPilotID
+		existingNotebook.Version = version
+		if notebook.SnapnotesID != nil {
+			snapnotesID, err := linkedSnapnotesID(tx.Snapnotes, existingNotebook, *notebook.SnapnotesID)
+			if err != nil {
+				return err
+			}
+			existingNotebook.SnapnotesID = &snapnotesID
+		}
+		if notebook.PrepPilotID != nil {
+			prepPilotID, err := linkedPrepPilotID(tx.PrepPilots, existingNotebook, *notebook.PrepPilotID)
+			if err != nil {
+				return err
+			}
+			existingNotebook.PrepPilotID = &prepPilotID
+		}
+		if err := tx.Notebooks.Update(existingNotebook); err != nil {
 			return err
 		}
-		existingNotebook.PrepPilotID = &prepPilotID
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: existingNotebook.ID, Action: domain.RevisionActionUpdate, AuthorID: authorID, Notebook: existingNotebook,
+		}, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.reindex(existingNotebook)
+	return existingNotebook, nil
+}
+
+// linkedSnapnotesID checks that the snapnotes a notebook is pointed at were
+// generated for that notebook.
+func linkedSnapnotesID(snapnotesRepo domain.SnapnotesRepository, notebook *domain.Notebook, id string) (primitive.ObjectID, error) {
+	invalid := &domain.ValidationError{Fields: []domain.FieldError{{
+		Field: "snapnotes_id", Code: "not_found", Message: "does not refer to snapnotes of this notebook",
+	}}}
+
+	snapnotesID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, invalid
+	}
+	if notebook.SnapnotesID != nil && *notebook.SnapnotesID == snapnotesID {
+		return snapnotesID, nil
 	}
 
-	return u.notebookRepo.Update(existingNotebook)
+	snapnotes, err := snapnotesRepo.GetByID(snapnotesID)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if snapnotes == nil || snapnotes.NotebookID != notebook.ID {
+		return primitive.NilObjectID, invalid
+	}
+	return snapnotesID, nil
 }
 
-func (u *notebookUseCase) DeleteNotebook(userID string, notebookID string) error {
-	objectUserID, err := primitive.ObjectIDFromHex(userID)
+// linkedPrepPilotID checks that the prep pilot a notebook is pointed at was
+// generated for that notebook.
+func linkedPrepPilotID(prepPilotRepo domain.PrepPilotRepository, notebook *domain.Notebook, id string) (primitive.ObjectID, error) {
+	invalid := &domain.ValidationError{Fields: []domain.FieldError{{
+		Field: "prep_pilot_id", Code: "not_found", Message: "does not refer to a prep pilot of this notebook",
+	}}}
+
+	prepPilotID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return primitive.NilObjectID, invalid
+	}
+	if notebook.PrepPilotID != nil && *notebook.PrepPilotID == prepPilotID {
+		return prepPilotID, nil
 	}
 
-	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	prepPilot, err := prepPilotRepo.GetByID(prepPilotID)
 	if err != nil {
-		return err
+		return primitive.NilObjectID, err
 	}
+	if prepPilot == nil || prepPilot.NotebookID != notebook.ID {
+		return primitive.NilObjectID, invalid
+	}
+	return prepPilotID, nil
+}
 
-	existingNotebook, err := u.notebookRepo.GetByID(objectNotebookID)
+func (u *notebookUseCase) DeleteNotebook(userID string, notebookID string, expectedVersion *int64) error {
+	existingNotebook, role, err := u.NotebookAccess(userID, notebookID)
 	if err != nil {
 		return err
 	}
+	if role != domain.ShareRoleOwner {
+		return domain.ErrForbidden
+	}
+	if err := checkVersion(expectedVersion, existingNotebook.Version); err != nil {
+		return err
+	}
 
-	if existingNotebook == nil || existingNotebook.UserID != objectUserID {
-		return errors.New("notebook not found or does not belong to user")
+	// Shares and content are kept so the notebook can be restored from the trash
+	if err := u.notebookRepo.SoftDelete(existingNotebook.ID, existingNotebook.Version, time.Now()); err != nil {
+		return err
 	}
+	if err := u.indexer.RemoveNotebook(existingNotebook.ID); err != nil {
+		log.Printf("Error removing notebook %s from search index: %v", existingNotebook.ID.Hex(), err)
+	}
+	return nil
+}
 
-	return u.notebookRepo.Delete(objectNotebookID)
+// checkVersion compares the version a client last saw with the stored one.
+// A nil expected version accepts any version.
+func checkVersion(expected *int64, current int64) error {
+	if expected != nil && *expected != current {
+		return domain.ErrVersionConflict
+	}
+	return nil
+}
+
+// reindex refreshes the search index after a change. Failures only make
+// search results stale, so they are logged rather than returned.
+func (u *notebookUseCase) reindex(notebook *domain.Notebook) {
+	if err := u.indexer.IndexNotebook(notebook); err != nil {
+		log.Printf("Error indexing notebook %s: %v", notebook.ID.Hex(), err)
+	}
 }
 
 func (u *notebookUseCase) GetNotebooksByUserID(userID string) ([]*domain.Notebook, error) {
@@ -144,24 +329,51 @@ func (u *notebookUseCase) GetNotebooksByUserID(userID string) ([]*domain.Noteboo
 	return u.notebookRepo.GetByUserID(objectUserID)
 }
 
-func (u *notebookUseCase) GetSnapnotes(userID string, notebookID string) (*domain.Snapnotes, error) {
+func (u *notebookUseCase) ListNotebooks(userID string, request domain.NotebookListRequest) (*domain.Page[*domain.Notebook], error) {
 	objectUserID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	page, err := pageOptions(request.ListRequest, domain.NotebookSortFields)
 	if err != nil {
 		return nil, err
 	}
 
-	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
+	query := domain.NotebookQuery{
+		Tag:         normalizeTag(request.Tag),
+		Type:        request.Type,
+		CreatedFrom: optionalTime(request.CreatedFrom),
+		CreatedTo:   optionalTime(request.CreatedTo),
+		PageOptions: page,
+	}
+	switch request.FolderID {
+	case "":
+	case "root":
+		query.InRoot = true
+	default:
+		folder, err := findOwnedFolder(u.folderRepo, objectUserID, request.FolderID, "")
+		if err != nil {
+			return nil, err
+		}
+		query.FolderID = &folder.ID
+	}
+
+	return u.notebookRepo.Find(objectUserID, query)
+}
+
+func (u *notebookUseCase) GetTags(userID string) ([]string, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, err
 	}
+	return u.notebookRepo.GetTags(objectUserID)
+}
 
-	if notebook == nil || notebook.UserID != objectUserID {
-		return nil, errors.New("notebook not found or does not belong to user")
+func (u *notebookUseCase) GetSnapnotes(userID string, notebookID string) (*domain.Snapnotes, error) {
+	notebook, _, err := u.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
 	}
 
 	if notebook.SnapnotesID == nil {
@@ -172,32 +384,327 @@ func (u *notebookUseCase) GetSnapnotes(userID string, notebookID string) (*domai
 }
 
 func (u *notebookUseCase) GetPrepPilot(userID string, notebookID string) (*domain.PrepPilot, error) {
-	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	notebook, _, err := u.NotebookAccess(userID, notebookID)
 	if err != nil {
 		return nil, err
 	}
 
+	if notebook.PrepPilotID == nil {
+		return nil, errors.New("no prep pilot associated with this notebook")
+	}
+
+	return u.prepPilotRepo.GetByID(*notebook.PrepPilotID)
+}
+
+// NotebookAccess loads a notebook and resolves the caller's role on it.
+// Callers without any access get ErrNotebookNotFound so that notebook IDs
+// cannot be probed.
+func (u *notebookUseCase) NotebookAccess(userID, notebookID string) (*domain.Notebook, string, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
 	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
 	if err != nil {
-		return nil, err
+		return nil, "", domain.ErrNotebookNotFound
 	}
 
 	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
+	if err != nil {
+		return nil, "", err
+	}
+	if notebook == nil || notebook.DeletedAt != nil {
+		return nil, "", domain.ErrNotebookNotFound
+	}
+	if notebook.UserID == objectUserID {
+		return notebook, domain.ShareRoleOwner, nil
+	}
+
+	share, err := u.shareRepo.FindByNotebookAndUser(objectNotebookID, objectUserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if share != nil {
+		return notebook, share.Role, nil
+	}
+
+	// The caller may have a pending invitation that has not been claimed yet
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user == nil || !user.EmailVerified {
+		return nil, "", domain.ErrNotebookNotFound
+	}
+	share, err = u.shareRepo.FindByNotebookAndEmail(objectNotebookID, normalizeEmail(user.Email))
+	if err != nil {
+		return nil, "", err
+	}
+	if share == nil || share.UserID != nil {
+		return nil, "", domain.ErrNotebookNotFound
+	}
+	if _, err := u.shareRepo.ClaimInvites(normalizeEmail(user.Email), objectUserID); err != nil {
+		return nil, "", err
+	}
+
+	return notebook, share.Role, nil
+}
+
+// GetSharedNotebooks lists the notebooks other users shared with the caller,
+// claiming any invitations sent to their verified email first.
+func (u *notebookUseCase) GetSharedNotebooks(userID string) ([]*domain.SharedNotebook, error) {
+	user, err := u.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
 
-	log.Default().Printf("Notebook: %+v", notebook)
+	if user.EmailVerified {
+		if _, err := u.shareRepo.ClaimInvites(normalizeEmail(user.Email), user.ID); err != nil {
+			return nil, err
+		}
+	}
 
-	if notebook == nil || notebook.UserID != objectUserID {
-		return nil, errors.New("notebook not found or does not belong to user")
+	shares, err := u.shareRepo.GetByUserID(user.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	if notebook.PrepPilotID == nil {
-		return nil, errors.New("no prep pilot associated with this notebook")
+	roles := make(map[primitive.ObjectID]string, len(shares))
+	ids := make([]primitive.ObjectID, 0, len(shares))
+	for _, share := range shares {
+		roles[share.NotebookID] = share.Role
+		ids = append(ids, share.NotebookID)
 	}
 
-	return u.prepPilotRepo.GetByID(*notebook.PrepPilotID)
+	notebooks, err := u.notebookRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make([]*domain.SharedNotebook, 0, len(notebooks))
+	for _, notebook := range notebooks {
+		shared = append(shared, &domain.SharedNotebook{Notebook: notebook, Role: roles[notebook.ID]})
+	}
+	return shared, nil
+}
+
+// ShareNotebook grants access to the notebook to the given email address.
+// Sharing the same notebook with the same address again changes the role.
+func (u *notebookUseCase) ShareNotebook(userID, notebookID, email, role string) (*domain.NotebookShare, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateShareRole(role); err != nil {
+		return nil, err
+	}
+
+	owner, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	email = normalizeEmail(email)
+	if email == normalizeEmail(owner.Email) {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "email", Code: "owner", Message: "is the notebook owner",
+		}}}
+	}
+
+	recipient, err := u.userRepo.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := u.shareRepo.FindByNotebookAndEmail(notebook.ID, email)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil && recipient != nil {
+		// The recipient may have changed their email since it was shared
+		existing, err = u.shareRepo.FindByNotebookAndUser(notebook.ID, recipient.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if existing != nil {
+		if existing.Role != role {
+			if err := u.shareRepo.UpdateRole(existing.ID, role); err != nil {
+				return nil, err
+			}
+			existing.Role = role
+		}
+		return existing, nil
+	}
+
+	share := &domain.NotebookShare{
+		NotebookID: notebook.ID,
+		OwnerID:    notebook.UserID,
+		Email:      email,
+		Role:       role,
+		InvitedBy:  owner.ID,
+	}
+	// Unverified accounts claim the invitation once they verify the address
+	if recipient != nil && recipient.EmailVerified {
+		now := time.Now()
+		share.UserID = &recipient.ID
+		share.AcceptedAt = &now
+	}
+	if err := u.shareRepo.Create(share); err != nil {
+		return nil, err
+	}
+
+	u.sendShareEmail(owner, recipient, notebook, share)
+	return share, nil
+}
+
+func (u *notebookUseCase) sendShareEmail(owner, recipient *domain.User, notebook *domain.Notebook, share *domain.NotebookShare) {
+	var subject, body string
+	if recipient != nil {
+		subject = fmt.Sprintf("%s shared \"%s\" with you", owner.Name, notebook.Name)
+		body = fmt.Sprintf(
+			"Hi %s,\n\n%s shared the notebook \"%s\" with you as %s:\n\n%s/notebooks/%s\n",
+			recipient.Name, owner.Name, notebook.Name, share.Role, os.Getenv("APP_BASE_URL"), notebook.ID.Hex(),
+		)
+	} else {
+		subject = fmt.Sprintf("%s invited you to a notebook on Cognivia", owner.Name)
+		body = fmt.Sprintf(
+			"Hi,\n\n%s invited you to the notebook \"%s\" as %s. "+
+				"Create a Cognivia account with this email address to open it:\n\n%s/register?email=%s\n",
+			owner.Name, notebook.Name, share.Role, os.Getenv("APP_BASE_URL"), url.QueryEscape(share.Email),
+		)
+	}
+
+	if err := u.mailer.Send(share.Email, subject, body); err != nil {
+		log.Printf("Error sending notebook share email: %v", err)
+	}
+}
+
+func (u *notebookUseCase) ListShares(userID, notebookID string) ([]*domain.NotebookShare, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	return u.shareRepo.GetByNotebookID(notebook.ID)
+}
+
+func (u *notebookUseCase) UpdateShare(userID, notebookID, shareID, role string) (*domain.NotebookShare, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateShareRole(role); err != nil {
+		return nil, err
+	}
+
+	share, err := u.notebookShare(notebook, shareID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.shareRepo.UpdateRole(share.ID, role); err != nil {
+		return nil, err
+	}
+	share.Role = role
+	return share, nil
+}
+
+func (u *notebookUseCase) RevokeShare(userID, notebookID, shareID string) error {
+	notebook, role, err := u.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return err
+	}
+
+	share, err := u.notebookShare(notebook, shareID)
+	if err != nil {
+		return err
+	}
+
+	if role != domain.ShareRoleOwner && (share.UserID == nil || share.UserID.Hex() != userID) {
+		return domain.ErrForbidden
+	}
+
+	return u.shareRepo.Delete(share.ID)
+}
+
+// ownedNotebook is NotebookAccess restricted to the notebook owner
+func (u *notebookUseCase) ownedNotebook(userID, notebookID string) (*domain.Notebook, error) {
+	notebook, role, err := u.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if role != domain.ShareRoleOwner {
+		return nil, domain.ErrForbidden
+	}
+	return notebook, nil
+}
+
+func (u *notebookUseCase) notebookShare(notebook *domain.Notebook, shareID string) (*domain.NotebookShare, error) {
+	objectShareID, err := primitive.ObjectIDFromHex(shareID)
+	if err != nil {
+		return nil, domain.ErrShareNotFound
+	}
+
+	share, err := u.shareRepo.GetByID(objectShareID)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil || share.NotebookID != notebook.ID {
+		return nil, domain.ErrShareNotFound
+	}
+	return share, nil
+}
+
+func validateShareRole(role string) error {
+	if role != domain.ShareRoleViewer && role != domain.ShareRoleEditor {
+		return &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "role", Code: "invalid", Message: "must be viewer or editor",
+		}}}
+	}
+	return nil
+}
+
+const (
+	maxTagsPerNotebook = 20
+	maxTagLength       = 32
+)
+
+// normalizeTags lower-cases, trims and de-duplicates tags
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+				Field: "tags", Code: "too_long", Message: fmt.Sprintf("must be at most %d characters each", maxTagLength),
+			}}}
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTagsPerNotebook {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "tags", Code: "too_many", Message: fmt.Sprintf("must contain at most %d tags", maxTagsPerNotebook),
+		}}}
+	}
+	return normalized, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // Add other use case methods as needed
@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type notebookFixture struct {
+	useCase   *notebookUseCase
+	notebooks *memoryNotebookRepository
+	shares    *memoryNotebookShareRepository
+	revisions *memoryRevisionRepository
+	users     *memoryUserRepository
+	mailer    *recordingMailer
+
+	owner, editor, viewer, revoked, stranger *domain.User
+	notebook                                 *domain.Notebook
+}
+
+// newNotebookFixture stores a notebook shared with an editor and a viewer,
+// and a user whose share was revoked
+func newNotebookFixture(t *testing.T) *notebookFixture {
+	t.Helper()
+	f := &notebookFixture{
+		notebooks: newMemoryNotebookRepository(),
+		shares:    newMemoryNotebookShareRepository(),
+		revisions: &memoryRevisionRepository{},
+		users:     newMemoryUserRepository(),
+		mailer:    &recordingMailer{},
+	}
+	f.useCase = NewNotebookUseCase(
+		f.notebooks, nil, nil, f.shares, nil,
+		&memoryUnitOfWork{repos: domain.TransactionRepositories{Notebooks: f.notebooks, Shares: f.shares, Revisions: f.revisions}},
+		&recordingIndexer{}, f.users, f.mailer,
+	).(*notebookUseCase)
+
+	newUser := func(email string) *domain.User {
+		user := &domain.User{Email: email, Name: email, EmailVerified: true}
+		f.users.Create(user)
+		return user
+	}
+	f.owner, f.editor, f.viewer = newUser("owner@example.com"), newUser("editor@example.com"), newUser("viewer@example.com")
+	f.revoked, f.stranger = newUser("revoked@example.com"), newUser("stranger@example.com")
+
+	f.notebook = &domain.Notebook{UserID: f.owner.ID, Name: "Biology"}
+	f.notebooks.Create(f.notebook)
+	f.share(f.editor, domain.ShareRoleEditor)
+	f.share(f.viewer, domain.ShareRoleViewer)
+	revoked := f.share(f.revoked, domain.ShareRoleEditor)
+	f.shares.Delete(revoked.ID)
+	return f
+}
+
+func (f *notebookFixture) share(user *domain.User, role string) *domain.NotebookShare {
+	share := &domain.NotebookShare{
+		NotebookID: f.notebook.ID, OwnerID: f.owner.ID, UserID: &user.ID, Email: user.Email, Role: role,
+	}
+	f.shares.Create(share)
+	return share
+}
+
+func (f *notebookFixture) version(t *testing.T) *int64 {
+	t.Helper()
+	stored, _ := f.notebooks.GetByID(f.notebook.ID)
+	return &stored.Version
+}
+
+func TestNotebookAccessRoles(t *testing.T) {
+	f := newNotebookFixture(t)
+
+	for _, tc := range []struct {
+		name string
+		user *domain.User
+		role string
+		err  error
+	}{
+		{"owner", f.owner, domain.ShareRoleOwner, nil},
+		{"editor", f.editor, domain.ShareRoleEditor, nil},
+		{"viewer", f.viewer, domain.ShareRoleViewer, nil},
+		{"revoked sharee", f.revoked, "", domain.ErrNotebookNotFound},
+		{"stranger", f.stranger, "", domain.ErrNotebookNotFound},
+	} {
+		_, role, err := f.useCase.NotebookAccess(tc.user.ID.Hex(), f.notebook.ID.Hex())
+		if role != tc.role || !errors.Is(err, tc.err) {
+			t.Errorf("%s: got role %q and %v, want %q and %v", tc.name, role, err, tc.role, tc.err)
+		}
+	}
+
+	if _, _, err := f.useCase.NotebookAccess(f.owner.ID.Hex(), "not-an-id"); !errors.Is(err, domain.ErrNotebookNotFound) {
+		t.Errorf("malformed notebook ID: got %v", err)
+	}
+	if _, _, err := f.useCase.NotebookAccess(f.owner.ID.Hex(), primitive.NewObjectID().Hex()); !errors.Is(err, domain.ErrNotebookNotFound) {
+		t.Errorf("missing notebook: got %v", err)
+	}
+
+	// Trashed notebooks are hidden from everyone, the owner included
+	f.notebooks.SoftDelete(f.notebook.ID, *f.version(t), time.Now())
+	for _, user := range []*domain.User{f.owner, f.editor} {
+		if _, _, err := f.useCase.NotebookAccess(user.ID.Hex(), f.notebook.ID.Hex()); !errors.Is(err, domain.ErrNotebookNotFound) {
+			t.Errorf("trashed notebook opened by %s: %v", user.Email, err)
+		}
+	}
+}
+
+func TestNotebookAccessClaimsInvitations(t *testing.T) {
+	f := newNotebookFixture(t)
+	invited := &domain.User{Email: "invited@example.com"}
+	f.users.Create(invited)
+	f.shares.Create(&domain.NotebookShare{NotebookID: f.notebook.ID, OwnerID: f.owner.ID, Email: invited.Email, Role: domain.ShareRoleViewer})
+
+	// An unverified account could have been registered by anyone
+	if _, _, err := f.useCase.NotebookAccess(invited.ID.Hex(), f.notebook.ID.Hex()); !errors.Is(err, domain.ErrNotebookNotFound) {
+		t.Fatalf("unverified account opened an invitation: %v", err)
+	}
+
+	invited.EmailVerified = true
+	f.users.Update(invited)
+	_, role, err := f.useCase.NotebookAccess(invited.ID.Hex(), f.notebook.ID.Hex())
+	if err != nil || role != domain.ShareRoleViewer {
+		t.Fatalf("got role %q and %v", role, err)
+	}
+	if share, _ := f.shares.FindByNotebookAndUser(f.notebook.ID, invited.ID); share == nil {
+		t.Fatal("the invitation was not claimed")
+	}
+}
+
+// Every notebook operation refuses users without access as if the notebook
+// did not exist, and refuses viewers anything but reading
+func TestNotebookOperationsCheckAccess(t *testing.T) {
+	operations := []struct {
+		name          string
+		viewerAllowed bool
+		editorAllowed bool
+		run           func(f *notebookFixture, user *domain.User) error
+	}{
+		{"get", true, true, func(f *notebookFixture, user *domain.User) error {
+			_, err := f.useCase.GetNotebookByID(user.ID.Hex(), f.notebook.ID.Hex())
+			return err
+		}},
+		{"get snapnotes", true, true, func(f *notebookFixture, user *domain.User) error {
+			_, err := f.useCase.GetSnapnotes(user.ID.Hex(), f.notebook.ID.Hex())
+			return err
+		}},
+		{"get prep pilot", true, true, func(f *notebookFixture, user *domain.User) error {
+			_, err := f.useCase.GetPrepPilot(user.ID.Hex(), f.notebook.ID.Hex())
+			return err
+		}},
+		{"update", false, true, func(f *notebookFixture, user *domain.User) error {
+			name := "Renamed"
+			_, err := f.useCase.UpdateNotebook(user.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, nil)
+			return err
+		}},
+		{"delete", false, false, func(f *notebookFixture, user *domain.User) error {
+			return f.useCase.DeleteNotebook(user.ID.Hex(), f.notebook.ID.Hex(), nil)
+		}},
+		{"list shares", false, false, func(f *notebookFixture, user *domain.User) error {
+			_, err := f.useCase.ListShares(user.ID.Hex(), f.notebook.ID.Hex())
+			return err
+		}},
+		{"share", false, false, func(f *notebookFixture, user *domain.User) error {
+			_, err := f.useCase.ShareNotebook(user.ID.Hex(), f.notebook.ID.Hex(), "new@example.com", domain.ShareRoleEditor)
+			return err
+		}},
+		{"update share", false, false, func(f *notebookFixture, user *domain.User) error {
+			share, _ := f.shares.FindByNotebookAndUser(f.notebook.ID, f.viewer.ID)
+			_, err := f.useCase.UpdateShare(user.ID.Hex(), f.notebook.ID.Hex(), share.ID.Hex(), domain.ShareRoleEditor)
+			return err
+		}},
+		{"revoke another share", false, false, func(f *notebookFixture, user *domain.User) error {
+			share, _ := f.shares.FindByNotebookAndUser(f.notebook.ID, f.editor.ID)
+			if user.ID == f.editor.ID {
+				share, _ = f.shares.FindByNotebookAndUser(f.notebook.ID, f.viewer.ID)
+			}
+			return f.useCase.RevokeShare(user.ID.Hex(), f.notebook.ID.Hex(), share.ID.Hex())
+		}},
+	}
+
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			f := newNotebookFixture(t)
+			for _, user := range []*domain.User{f.stranger, f.revoked} {
+				if err := op.run(f, user); !errors.Is(err, domain.ErrNotebookNotFound) {
+					t.Errorf("%s: got %v, want ErrNotebookNotFound", user.Email, err)
+				}
+			}
+			for _, tc := range []struct {
+				user    *domain.User
+				allowed bool
+			}{{f.viewer, op.viewerAllowed}, {f.editor, op.editorAllowed}} {
+				err := op.run(f, tc.user)
+				if refused := errors.Is(err, domain.ErrForbidden); refused == tc.allowed {
+					t.Errorf("%s: got %v", tc.user.Email, err)
+				}
+				if errors.Is(err, domain.ErrNotebookNotFound) {
+					t.Errorf("%s: notebook not found", tc.user.Email)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateNotebookByEditor(t *testing.T) {
+	f := newNotebookFixture(t)
+	name := "Cell Biology"
+
+	updated, err := f.useCase.UpdateNotebook(f.editor.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, f.version(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != name || updated.Version != 2 {
+		t.Fatalf("unexpected notebook %+v", updated)
+	}
+
+	// Folders belong to the owner
+	folderID := primitive.NewObjectID().Hex()
+	if _, err := f.useCase.UpdateNotebook(f.editor.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{FolderID: &folderID}, nil); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("editor moved the notebook: %v", err)
+	}
+
+	stale := int64(1)
+	if _, err := f.useCase.UpdateNotebook(f.editor.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, &stale); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("stale version: got %v", err)
+	}
+}
+
+func TestUpdateNotebookKeepsShareLinks(t *testing.T) {
+	f := newNotebookFixture(t)
+	// A share link created after the notebook was read for the update
+	read, _ := f.notebooks.GetByID(f.notebook.ID)
+	f.notebooks.AddShareLink(f.notebook.ID, &domain.ShareLink{TokenHash: "hash"})
+
+	name := "Renamed"
+	if _, err := f.useCase.UpdateNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, &read.Version); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("an update from before the share link was created: got %v", err)
+	}
+	if _, err := f.useCase.UpdateNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, nil); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := f.notebooks.GetByID(f.notebook.ID)
+	if len(stored.ShareLinks) != 1 {
+		t.Fatal("the update dropped the share link")
+	}
+}
+
+func TestShareeCanLeave(t *testing.T) {
+	f := newNotebookFixture(t)
+	share, _ := f.shares.FindByNotebookAndUser(f.notebook.ID, f.viewer.ID)
+
+	if err := f.useCase.RevokeShare(f.viewer.ID.Hex(), f.notebook.ID.Hex(), share.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.useCase.NotebookAccess(f.viewer.ID.Hex(), f.notebook.ID.Hex()); !errors.Is(err, domain.ErrNotebookNotFound) {
+		t.Fatalf("the viewer kept access after leaving: %v", err)
+	}
+}
+
+func TestShareNotebook(t *testing.T) {
+	f := newNotebookFixture(t)
+
+	// Sharing again with the same address changes the role
+	share, err := f.useCase.ShareNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), " Viewer@Example.com ", domain.ShareRoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share.UserID == nil || *share.UserID != f.viewer.ID || share.Role != domain.ShareRoleEditor {
+		t.Fatalf("unexpected share %+v", share)
+	}
+	if shares, _ := f.shares.GetByNotebookID(f.notebook.ID); len(shares) != 2 {
+		t.Fatalf("got %d shares, want 2", len(shares))
+	}
+
+	invite, err := f.useCase.ShareNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), "new@example.com", domain.ShareRoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.UserID != nil || len(f.mailer.sent) != 1 || f.mailer.sent[0] != "new@example.com" {
+		t.Fatalf("unexpected invitation %+v, mails %v", invite, f.mailer.sent)
+	}
+
+	for _, tc := range []struct{ email, role string }{
+		{"owner@example.com", domain.ShareRoleViewer},
+		{"other@example.com", domain.ShareRoleOwner},
+	} {
+		var validationErr *domain.ValidationError
+		if _, err := f.useCase.ShareNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), tc.email, tc.role); !errors.As(err, &validationErr) {
+			t.Errorf("sharing with %s as %s: got %v", tc.email, tc.role, err)
+		}
+	}
+}
+
+// A viewer learns nothing from the version it sends
+func TestUpdateNotebookRefusesViewerBeforeVersionCheck(t *testing.T) {
+	f := newNotebookFixture(t)
+	name := "Renamed"
+
+	stale := *f.version(t) - 1
+	for description, version := range map[string]*int64{"no": nil, "the current": f.version(t), "a stale": &stale} {
+		if _, err := f.useCase.UpdateNotebook(f.viewer.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, version); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("viewer with %s version: got %v, want ErrForbidden", description, err)
+		}
+	}
+}
+
+func TestCreateNotebookIgnoresServerFields(t *testing.T) {
+	f := newNotebookFixture(t)
+	now := time.Now()
+	otherID := primitive.NewObjectID()
+	request := &domain.CreateNotebookRequest{Notebook: domain.Notebook{
+		Name:         "Physics",
+		UserID:       f.stranger.ID,
+		SnapnotesID:  &otherID,
+		DeletedAt:    &now,
+		Origin:       &domain.NotebookOrigin{NotebookID: f.notebook.ID},
+		Extraction:   &domain.NotebookExtraction{},
+		Drive:        &domain.NotebookDrive{FileID: "1AbCdEfGhIjKlMnOp", SourceID: &otherID},
+		StaleContent: []string{domain.StaleSnapnotes},
+	}}
+
+	if err := f.useCase.CreateNotebook(f.owner.ID.Hex(), request); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := f.notebooks.GetByID(request.ID)
+	switch {
+	case stored.UserID != f.owner.ID:
+		t.Error("the notebook was created for another user")
+	case stored.DeletedAt != nil:
+		t.Error("the notebook was created in the trash")
+	case stored.SnapnotesID != nil || stored.Origin != nil || stored.Extraction != nil:
+		t.Errorf("content, origin or extraction were taken from the request: %+v", stored)
+	case stored.Drive != nil || stored.StaleContent != nil:
+		t.Errorf("Drive state was taken from the request: %+v", stored)
+	}
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcFlowPurpose marks the signed flow token that carries the state, nonce
+// and PKCE verifier between the login redirect and the callback.
+const oidcFlowPurpose = "oidc_flow"
+
+const oidcFlowTTL = 10 * time.Minute
+
+type oidcUseCase struct {
+	userRepo        domain.UserRepository
+	tokenRepo       domain.UserTokenRepository
+	apiKeyRepo      domain.APIKeyRepository
+	providers       map[string]infrastructure.OIDCClient
+	passwordService infrastructure.PasswordService
+}
+
+func NewOIDCUseCase(
+	userRepo domain.UserRepository,
+	tokenRepo domain.UserTokenRepository,
+	apiKeyRepo domain.APIKeyRepository,
+	providers map[string]infrastructure.OIDCClient,
+) domain.OIDCUseCase {
+	return &oidcUseCase{
+		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		apiKeyRepo:      apiKeyRepo,
+		providers:       providers,
+		passwordService: infrastructure.NewPasswordService(),
+	}
+}
+
+func (u *oidcUseCase) Providers() []string {
+	names := make([]string, 0, len(u.providers))
+	for name := range u.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (u *oidcUseCase) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	client, ok := u.providers[provider]
+	if !ok {
+		return "", "", domain.ErrUnknownProvider
+	}
+
+	state, err := infrastructure.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := infrastructure.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := infrastructure.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	flow := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":       oidcFlowPurpose,
+		"provider":      provider,
+		"state":         state,
+		"nonce":         nonce,
+		"code_verifier": verifier,
+		"exp":           time.Now().Add(oidcFlowTTL).Unix(),
+	})
+	flowToken, err := flow.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, flowToken, nil
+}
+
+func (u *oidcUseCase) CompleteLogin(ctx context.Context, provider, state, code, flowToken string) (*domain.User, error) {
+	client, ok := u.providers[provider]
+	if !ok {
+		return nil, domain.ErrUnknownProvider
+	}
+
+	token, err := jwt.Parse(flowToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, domain.ErrInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != oidcFlowPurpose || claims["provider"] != provider {
+		return nil, domain.ErrInvalidToken
+	}
+
+	expectedState, _ := claims["state"].(string)
+	if expectedState == "" || subtle.ConstantTimeCompare([]byte(expectedState), []byte(state)) != 1 {
+		return nil, domain.ErrInvalidToken
+	}
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["code_verifier"].(string)
+
+	identity, err := client.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.linkIdentity(provider, identity)
+}
+
+// linkIdentity finds the user for an external identity. Unknown identities are
+// linked to the account with the same email, but only if the provider has
+// verified that email; otherwise anyone could claim an account by registering
+// its address with a provider. An account whose email was never verified may
+// have been registered by someone else, so its password, two-factor setup,
+// sessions and API keys are discarded before the identity is linked.
+func (u *oidcUseCase) linkIdentity(provider string, identity *infrastructure.OIDCIdentity) (*domain.User, error) {
+	user, err := u.userRepo.FindByIdentity(provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("the provider did not return a verified email address")
+	}
+	email := identity.Email
+
+	link := domain.ExternalIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err = u.userRepo.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		unverified := !user.EmailVerified
+		if unverified {
+			if err := u.resetCredentials(user); err != nil {
+				return nil, err
+			}
+		}
+		user.Identities = append(user.Identities, link)
+		user.EmailVerified = true
+		if err := u.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+		if unverified {
+			if err := revokeCredentials(u.tokenRepo, u.apiKeyRepo, user); err != nil {
+				return nil, err
+			}
+		}
+		log.Printf("Linked %s identity to user: %s", provider, user.Email)
+		return user, nil
+	}
+
+	// New account. It gets a random password so only the provider (or a
+	// password reset) can be used to sign in.
+	hashedPassword, err := u.randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	user = &domain.User{
+		Email:    email,
+		Password: hashedPassword,
+		Name:     identity.Name,
+	}
+	applyNewUserDefaults(user)
+	user.EmailVerified = true
+	user.Identities = []domain.ExternalIdentity{link}
+
+	if err := u.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	log.Printf("User created from %s sign-in: %s", provider, user.Email)
+	return user, nil
+}
+
+// resetCredentials replaces the password with a random one and signs out
+// every session, leaving the provider (or a password reset) to sign in
+func (u *oidcUseCase) resetCredentials(user *domain.User) error {
+	hashedPassword, err := u.randomPasswordHash()
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.TwoFactor = domain.TwoFactorSettings{}
+	user.TokenVersion++
+	return nil
+}
+
+func (u *oidcUseCase) randomPasswordHash() (string, error) {
+	randomPassword, err := infrastructure.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	return u.passwordService.PasswordHasher(randomPassword)
+}
@@ -0,0 +1,343 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testClientID    = "cognivia-test"
+	testRedirectURL = "http://localhost:8080/api/v1/auth/oidc/mock/callback"
+)
+
+// mockOIDCProvider is an OpenID Connect provider that issues an ID token for
+// every code it was told about, after checking the PKCE verifier
+type mockOIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// issuer is the issuer of the discovery document
+	issuer string
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockOIDCProvider{key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.issuer,
+			"authorization_endpoint": provider.URL + "/authorize",
+			"token_endpoint":         provider.URL + "/token",
+			"jwks_uri":               provider.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		provider.mu.Lock()
+		grant, ok := provider.grants[r.PostForm.Get("code")]
+		delete(provider.grants, r.PostForm.Get("code"))
+		provider.mu.Unlock()
+		if !ok || r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("redirect_uri") != testRedirectURL ||
+			infrastructure.PKCEChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   provider.URL,
+			"aud":   testClientID,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": grant.nonce,
+		}
+		for name, value := range grant.claims {
+			claims[name] = value
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+
+	provider.Server = httptest.NewServer(mux)
+	provider.issuer = provider.URL
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+// authorize plays the user approving the sign-in at the authorization URL and
+// returns the state and code the provider redirects back with
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL without PKCE: %s", authURL)
+	}
+
+	code := primitive.NewObjectID().Hex()
+	p.mu.Lock()
+	p.grants[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: claims}
+	p.mu.Unlock()
+	return query.Get("state"), code
+}
+
+type oidcFixture struct {
+	useCase  domain.OIDCUseCase
+	provider *mockOIDCProvider
+	users    *memoryUserRepository
+	tokens   *memoryUserTokenRepository
+	apiKeys  *memoryAPIKeyRepository
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Setenv("JWT_SECRET", "test-secret")
+	provider := newMockOIDCProvider(t)
+	fixture := &oidcFixture{
+		provider: provider,
+		users:    newMemoryUserRepository(),
+		tokens:   &memoryUserTokenRepository{},
+		apiKeys:  &memoryAPIKeyRepository{},
+	}
+	client := infrastructure.NewOIDCClient(infrastructure.OIDCProviderConfig{
+		Name:        "mock",
+		Issuer:      provider.URL,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+		Scopes:      []string{"openid", "email"},
+	}, provider.Client())
+	fixture.useCase = NewOIDCUseCase(fixture.users, fixture.tokens, fixture.apiKeys,
+		map[string]infrastructure.OIDCClient{"mock": client})
+	return fixture
+}
+
+func (f *oidcFixture) signIn(t *testing.T, claims jwt.MapClaims) (*domain.User, error) {
+	t.Helper()
+	authURL, flowToken, err := f.useCase.BeginLogin(context.Background(), "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := f.provider.authorize(t, authURL, claims)
+	return f.useCase.CompleteLogin(context.Background(), "mock", state, code, flowToken)
+}
+
+func TestOIDCSignInCreatesVerifiedUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	user, err := f.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": true, "name": "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified || user.Name != "Ada" || len(user.Identities) != 1 || user.Identities[0].Subject != "subject-1" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	again, err := f.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != user.ID {
+		t.Fatalf("second sign-in created another user")
+	}
+}
+
+func TestOIDCSignInLinksVerifiedAccount(t *testing.T) {
+	f := newOIDCFixture(t)
+	existing := &domain.User{Email: "ada@example.com", Password: "hash", EmailVerified: true, TokenVersion: 3}
+	f.users.Create(existing)
+	f.apiKeys.Create(&domain.APIKey{UserID: existing.ID, Name: "script"})
+
+	user, err := f.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != existing.ID || user.Password != "hash" || user.TokenVersion != 3 || len(user.Identities) != 1 {
+		t.Fatalf("verified account was changed by linking: %+v", user)
+	}
+	keys, _ := f.apiKeys.GetByUserID(existing.ID)
+	if keys[0].RevokedAt != nil {
+		t.Fatal("linking a verified account revoked its API keys")
+	}
+}
+
+// An attacker registers the victim's address with a password and waits for the
+// victim to sign in with the provider
+func TestOIDCSignInResetsCredentialsOfUnverifiedAccount(t *testing.T) {
+	f := newOIDCFixture(t)
+	attacker := &domain.User{
+		Email:     "victim@example.com",
+		Password:  "attacker-hash",
+		TwoFactor: domain.TwoFactorSettings{Enabled: true, Secret: "attacker-secret"},
+	}
+	f.users.Create(attacker)
+	f.apiKeys.Create(&domain.APIKey{UserID: attacker.ID, Name: "backdoor"})
+	f.tokens.Create(&domain.UserToken{UserID: attacker.ID, Purpose: domain.TokenPurposePasswordReset, TokenHash: "reset"})
+	f.tokens.Create(&domain.UserToken{UserID: attacker.ID, Purpose: domain.TokenPurposeEmailChange, TokenHash: "change"})
+
+	user, err := f.signIn(t, jwt.MapClaims{"sub": "victim", "email": "victim@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := f.users.FindByID(attacker.ID.Hex())
+	switch {
+	case user.ID != attacker.ID || !stored.EmailVerified:
+		t.Fatalf("identity was not linked: %+v", stored)
+	case stored.Password == "attacker-hash" || stored.Password == "":
+		t.Fatal("the password of the unverified account still works")
+	case stored.TwoFactor.Enabled || stored.TwoFactor.Secret != "":
+		t.Fatal("the two-factor setup of the unverified account was kept")
+	case stored.TokenVersion != 1:
+		t.Fatalf("sessions were not revoked, token version %d", stored.TokenVersion)
+	case f.tokens.count(attacker.ID) != 0:
+		t.Fatal("emailed tokens were not deleted")
+	}
+	keys, _ := f.apiKeys.GetByUserID(attacker.ID)
+	if keys[0].RevokedAt == nil {
+		t.Fatal("API keys of the unverified account were not revoked")
+	}
+}
+
+func TestOIDCSignInRejectsUnverifiedProviderEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.users.Create(&domain.User{Email: "ada@example.com", EmailVerified: true})
+
+	if _, err := f.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": false}); err == nil {
+		t.Fatal("an unverified provider email was linked")
+	}
+}
+
+func TestOIDCCallbackChecks(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": true}
+
+	authURL, flowToken, err := f.useCase.BeginLogin(ctx, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := f.provider.authorize(t, authURL, claims)
+	if _, err := f.useCase.CompleteLogin(ctx, "mock", "forged-state", code, flowToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("forged state: got %v", err)
+	}
+
+	authURL, flowToken, _ = f.useCase.BeginLogin(ctx, "mock")
+	state, code := f.provider.authorize(t, authURL, claims)
+	if _, err := f.useCase.CompleteLogin(ctx, "other", state, code, flowToken); !errors.Is(err, domain.ErrUnknownProvider) {
+		t.Errorf("unknown provider: got %v", err)
+	}
+
+	// The state belongs to another flow token
+	_, otherFlow, _ := f.useCase.BeginLogin(ctx, "mock")
+	if _, err := f.useCase.CompleteLogin(ctx, "mock", state, code, otherFlow); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("callback accepted the flow token of another sign-in: %v", err)
+	}
+	// A stolen code redeemed through the attacker's own flow
+	authURL, _, _ = f.useCase.BeginLogin(ctx, "mock")
+	_, code = f.provider.authorize(t, authURL, claims)
+	_, attackerFlow, _ := f.useCase.BeginLogin(ctx, "mock")
+	attackerState := mustFlowClaim(t, attackerFlow, "state")
+	if _, err := f.useCase.CompleteLogin(ctx, "mock", attackerState, code, attackerFlow); err == nil {
+		t.Error("token endpoint accepted a code with the wrong PKCE verifier")
+	}
+
+	if len(f.users.users) != 0 {
+		t.Fatal("a failed callback created a user")
+	}
+}
+
+func TestOIDCChecksIssuer(t *testing.T) {
+	for name, issuer := range map[string]string{
+		"missing issuer":   "",
+		"another issuer":   "https://accounts.example.com",
+		"issuer with path": "/tenant",
+	} {
+		f := newOIDCFixture(t)
+		if strings.HasPrefix(issuer, "/") {
+			issuer = f.provider.URL + issuer
+		}
+		f.provider.issuer = issuer
+		if _, _, err := f.useCase.BeginLogin(context.Background(), "mock"); err == nil {
+			t.Errorf("%s: discovery accepted", name)
+		}
+	}
+
+	// A trailing slash in the metadata names the same issuer
+	f := newOIDCFixture(t)
+	f.provider.issuer = f.provider.URL + "/"
+	if _, err := f.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": true}); err != nil {
+		t.Errorf("trailing slash: %v", err)
+	}
+
+	// ID tokens must come from the configured issuer
+	for name, iss := range map[string]any{
+		"another issuer": "https://accounts.example.com",
+		"empty issuer":   "",
+		"numeric issuer": 1,
+	} {
+		f := newOIDCFixture(t)
+		claims := jwt.MapClaims{"sub": "subject-1", "email": "ada@example.com", "email_verified": true, "iss": iss}
+		if _, err := f.signIn(t, claims); err == nil {
+			t.Errorf("%s: id_token accepted", name)
+		}
+		if len(f.users.users) != 0 {
+			t.Errorf("%s: a rejected sign-in created a user", name)
+		}
+	}
+}
+
+func mustFlowClaim(t *testing.T, flowToken, name string) string {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(flowToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := claims[name].(string)
+	return value
+}
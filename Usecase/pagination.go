@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// pageOptions validates the pagination parameters of a list request. The
+// first sort field is the default; name sorts default to ascending and time
+// or score sorts to newest/highest first.
+func pageOptions(request domain.ListRequest, sortFields []string) (domain.PageOptions, error) {
+	var fields []domain.FieldError
+
+	page := domain.PageOptions{
+		Limit:  request.Limit,
+		Cursor: request.Cursor,
+		SortBy: request.Sort,
+		Order:  request.Order,
+	}
+
+	switch {
+	case page.Limit == 0:
+		page.Limit = domain.DefaultPageLimit
+	case page.Limit < 0 || page.Limit > domain.MaxPageLimit:
+		fields = append(fields, domain.FieldError{
+			Field: "limit", Code: "out_of_range", Message: "must be between 1 and 100",
+		})
+	}
+
+	if page.SortBy == "" {
+		page.SortBy = sortFields[0]
+	} else if !slices.Contains(sortFields, page.SortBy) {
+		fields = append(fields, domain.FieldError{
+			Field: "sort", Code: "invalid", Message: "must be one of " + strings.Join(sortFields, ", "),
+		})
+	}
+
+	switch page.Order {
+	case "":
+		page.Order = domain.SortDesc
+		if page.SortBy == "name" {
+			page.Order = domain.SortAsc
+		}
+	case domain.SortAsc, domain.SortDesc:
+	default:
+		fields = append(fields, domain.FieldError{
+			Field: "order", Code: "invalid", Message: "must be asc or desc",
+		})
+	}
+
+	if len(fields) > 0 {
+		return page, &domain.ValidationError{Fields: fields}
+	}
+	return page, nil
+}
+
+// optionalTime turns an unset query time into nil
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,454 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type revisionUseCase struct {
+	revisionRepo domain.RevisionRepository
+	notebooks    domain.NotebookAuthorizer
+	unitOfWork   domain.UnitOfWork
+	indexer      domain.NotebookIndexer
+}
+
+func NewRevisionUseCase(
+	revisionRepo domain.RevisionRepository,
+	notebooks domain.NotebookAuthorizer,
+	unitOfWork domain.UnitOfWork,
+	indexer domain.NotebookIndexer,
+) domain.RevisionUseCase {
+	return &revisionUseCase{
+		revisionRepo: revisionRepo,
+		notebooks:    notebooks,
+		unitOfWork:   unitOfWork,
+		indexer:      indexer,
+	}
+}
+
+func (u *revisionUseCase) ListRevisions(userID, notebookID string, request domain.RevisionListRequest) (*domain.Page[*domain.Revision], error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Document != "" && !slices.Contains(domain.RevisionDocuments, request.Document) {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "document", Code: "invalid", Message: "must be one of " + strings.Join(domain.RevisionDocuments, ", "),
+		}}}
+	}
+	page, err := pageOptions(request.ListRequest, domain.RevisionSortFields)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := u.revisionRepo.Find(notebook.ID, domain.RevisionQuery{DocumentType: request.Document, PageOptions: page})
+	if err != nil {
+		return nil, err
+	}
+	for i, revision := range revisions.Items {
+		revisions.Items[i] = withoutSnapshot(revision)
+	}
+	return revisions, nil
+}
+
+func (u *revisionUseCase) GetRevision(userID, notebookID, revisionID string) (*domain.Revision, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	return u.notebookRevision(notebook, revisionID)
+}
+
+func (u *revisionUseCase) DiffRevisions(userID, notebookID, fromID, toID string) (*domain.RevisionDiff, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := u.notebookRevision(notebook, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := u.notebookRevision(notebook, toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.DocumentID != to.DocumentID {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "to", Code: "invalid", Message: "must be a revision of the same document",
+		}}}
+	}
+
+	before, err := genericJSON(revisionSnapshot(from))
+	if err != nil {
+		return nil, err
+	}
+	after, err := genericJSON(revisionSnapshot(to))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.RevisionDiff{
+		From:    withoutSnapshot(from),
+		To:      withoutSnapshot(to),
+		Changes: diffValues("", before, after, []domain.RevisionChange{}),
+	}, nil
+}
+
+func (u *revisionUseCase) RestoreRevision(userID, notebookID, revisionID string) (*domain.Revision, error) {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
+	}
+	authorID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := u.notebookRevision(notebook, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision.DocumentType == domain.RevisionDocumentNotebook {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "revision_id", Code: "not_restorable", Message: "only snapnotes and prep pilot revisions can be restored",
+		}}}
+	}
+
+	var restored *domain.Revision
+	var current *domain.Notebook
+	err = u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		current, err = tx.Notebooks.GetByID(notebook.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return domain.ErrNotebookNotFound
+		}
+		before := notebookBefore(current)
+
+		restored = &domain.Revision{
+			NotebookID:   current.ID,
+			Action:       domain.RevisionActionRestore,
+			AuthorID:     authorID,
+			RestoredFrom: &revision.ID,
+		}
+		relinked := false
+		var replaced *domain.Revision
+		switch revision.DocumentType {
+		case domain.RevisionDocumentSnapnotes:
+			snapnotes := *revision.Snapnotes
+			existing, err := tx.Snapnotes.GetByID(snapnotes.ID)
+			if err != nil {
+				return err
+			}
+			replaced = snapnotesBefore(existing)
+			if existing == nil {
+				err = tx.Snapnotes.Create(&snapnotes)
+			} else {
+				snapnotes.Version = existing.Version
+				err = tx.Snapnotes.Update(&snapnotes)
+			}
+			if err != nil {
+				return err
+			}
+			restored.Snapnotes = &snapnotes
+			if current.SnapnotesID == nil || *current.SnapnotesID != snapnotes.ID {
+				current.SnapnotesID = &snapnotes.ID
+				relinked = true
+			}
+		case domain.RevisionDocumentPrepPilot:
+			prepPilot := *revision.PrepPilot
+			existing, err := tx.PrepPilots.GetByID(prepPilot.ID)
+			if err != nil {
+				return err
+			}
+			replaced = prepPilotBefore(existing)
+			if existing == nil {
+				err = tx.PrepPilots.Create(&prepPilot)
+			} else {
+				prepPilot.Version = existing.Version
+				err = tx.PrepPilots.Update(&prepPilot)
+			}
+			if err != nil {
+				return err
+			}
+			restored.PrepPilot = &prepPilot
+			if current.PrepPilotID == nil || *current.PrepPilotID != prepPilot.ID {
+				current.PrepPilotID = &prepPilot.ID
+				relinked = true
+			}
+		}
+		if err := recordRevision(tx.Revisions, restored, replaced); err != nil {
+			return err
+		}
+
+		if !relinked {
+			return nil
+		}
+		if err := tx.Notebooks.Update(current); err != nil {
+			return err
+		}
+		return recordRevision(tx.Revisions, &domain.Revision{
+			NotebookID: current.ID,
+			Action:     domain.RevisionActionUpdate,
+			AuthorID:   authorID,
+			Notebook:   current,
+		}, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.indexer.IndexNotebook(current); err != nil {
+		log.Printf("Error indexing notebook %s: %v", current.ID.Hex(), err)
+	}
+	return withoutSnapshot(restored), nil
+}
+
+// notebookRevision loads a revision and checks that it belongs to the notebook
+func (u *revisionUseCase) notebookRevision(notebook *domain.Notebook, revisionID string) (*domain.Revision, error) {
+	objectRevisionID, err := primitive.ObjectIDFromHex(revisionID)
+	if err != nil {
+		return nil, domain.ErrRevisionNotFound
+	}
+
+	revision, err := u.revisionRepo.GetByID(objectRevisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil || revision.NotebookID != notebook.ID {
+		return nil, domain.ErrRevisionNotFound
+	}
+	return revision, nil
+}
+
+// recordRevision appends a revision for the document it holds. The caller
+// sets the notebook, action, author and exactly one snapshot; the number and
+// changed fields are derived from the previous revision. Updates that change
+// nothing are not recorded. before holds the document as it was before the
+// change, nil for new documents; it is recorded as a baseline when the
+// document has no history yet, so its first recorded change can be undone.
+func recordRevision(revisionRepo domain.RevisionRepository, revision, before *domain.Revision) error {
+	if err := setDocument(revision); err != nil {
+		return err
+	}
+	if before != nil {
+		if err := setDocument(before); err != nil {
+			return err
+		}
+		before.NotebookID, before.Action, before.AuthorID = revision.NotebookID, domain.RevisionActionBaseline, primitive.NilObjectID
+	}
+
+	// Another writer can take the number between the lookup and the insert.
+	// Inside a transaction the retry fails because the insert aborted it,
+	// and the unit of work runs again.
+	for range 3 {
+		err := appendRevision(revisionRepo, revision, before)
+		if !errors.Is(err, domain.ErrVersionConflict) {
+			return err
+		}
+	}
+	return domain.ErrVersionConflict
+}
+
+func appendRevision(revisionRepo domain.RevisionRepository, revision, before *domain.Revision) error {
+	previous, err := revisionRepo.Latest(revision.DocumentType, revision.DocumentID)
+	if err != nil {
+		return err
+	}
+	baseline := previous == nil && before != nil && before.DocumentID == revision.DocumentID
+	if baseline {
+		before.Number, before.ChangedFields = 1, []string{}
+		previous = before
+	}
+
+	revision.Number = 1
+	revision.ChangedFields = []string{}
+	if previous != nil {
+		revision.Number = previous.Number + 1
+		if revision.ChangedFields, err = changedFields(previous, revision); err != nil {
+			return err
+		}
+		if revision.Action == domain.RevisionActionUpdate && len(revision.ChangedFields) == 0 {
+			return nil
+		}
+	}
+
+	if baseline {
+		if err := revisionRepo.Create(before); err != nil {
+			return err
+		}
+	}
+	return revisionRepo.Create(revision)
+}
+
+// setDocument copies the snapshot of a revision and sets the document it
+// belongs to
+func setDocument(revision *domain.Revision) error {
+	switch {
+	case revision.Notebook != nil:
+		snapshot := *revision.Notebook
+		// Share links carry token hashes and have their own lifecycle, the
+		// extraction summary follows the sources
+		snapshot.ShareLinks = nil
+		snapshot.Extraction = nil
+		revision.Notebook = &snapshot
+		revision.DocumentType, revision.DocumentID = domain.RevisionDocumentNotebook, snapshot.ID
+	case revision.Snapnotes != nil:
+		snapshot := *revision.Snapnotes
+		revision.Snapnotes = &snapshot
+		revision.DocumentType, revision.DocumentID = domain.RevisionDocumentSnapnotes, snapshot.ID
+	case revision.PrepPilot != nil:
+		snapshot := *revision.PrepPilot
+		revision.PrepPilot = &snapshot
+		revision.DocumentType, revision.DocumentID = domain.RevisionDocumentPrepPilot, snapshot.ID
+	default:
+		return errors.New("revision has no snapshot")
+	}
+	return nil
+}
+
+// notebookBefore returns the state of a notebook for recordRevision. Origin
+// is copied because syncs change it in place.
+func notebookBefore(notebook *domain.Notebook) *domain.Revision {
+	snapshot := *notebook
+	if notebook.Origin != nil {
+		origin := *notebook.Origin
+		snapshot.Origin = &origin
+	}
+	return &domain.Revision{Notebook: &snapshot}
+}
+
+// snapnotesBefore returns the replaced snapnotes for recordRevision, nil if
+// there were none
+func snapnotesBefore(snapnotes *domain.Snapnotes) *domain.Revision {
+	if snapnotes == nil {
+		return nil
+	}
+	return &domain.Revision{Snapnotes: snapnotes}
+}
+
+// prepPilotBefore returns the replaced prep pilot for recordRevision, nil if
+// there was none
+func prepPilotBefore(prepPilot *domain.PrepPilot) *domain.Revision {
+	if prepPilot == nil {
+		return nil
+	}
+	return &domain.Revision{PrepPilot: prepPilot}
+}
+
+// changedFields lists the top-level fields that differ between two
+// revisions. The version and update timestamp change on every save and are
+// left out.
+func changedFields(from, to *domain.Revision) ([]string, error) {
+	before, err := genericJSON(revisionSnapshot(from))
+	if err != nil {
+		return nil, err
+	}
+	after, err := genericJSON(revisionSnapshot(to))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for _, change := range diffValues("", before, after, nil) {
+		field, _, _ := strings.Cut(change.Path, ".")
+		field, _, _ = strings.Cut(field, "[")
+		if field != "updated_at" && field != "version" && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func revisionSnapshot(revision *domain.Revision) any {
+	switch {
+	case revision.Notebook != nil:
+		return revision.Notebook
+	case revision.Snapnotes != nil:
+		return revision.Snapnotes
+	default:
+		return revision.PrepPilot
+	}
+}
+
+func withoutSnapshot(revision *domain.Revision) *domain.Revision {
+	stripped := *revision
+	stripped.Notebook, stripped.Snapnotes, stripped.PrepPilot = nil, nil, nil
+	return &stripped
+}
+
+// genericJSON converts a document to its JSON form as maps, slices and
+// scalars, so revisions are compared by what the API shows
+func genericJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+// diffValues appends the differences between two JSON values to changes.
+// Objects are compared key by key and arrays index by index, so an inserted
+// array element shows up as changes to every following index.
+func diffValues(path string, before, after any, changes []domain.RevisionChange) []domain.RevisionChange {
+	switch b := before.(type) {
+	case map[string]any:
+		if a, ok := after.(map[string]any); ok {
+			keys := make([]string, 0, len(b)+len(a))
+			for key := range b {
+				keys = append(keys, key)
+			}
+			for key := range a {
+				if _, ok := b[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+
+			for _, key := range keys {
+				keyPath := key
+				if path != "" {
+					keyPath = path + "." + key
+				}
+				changes = diffValues(keyPath, b[key], a[key], changes)
+			}
+			return changes
+		}
+	case []any:
+		if a, ok := after.([]any); ok {
+			for i := range max(len(b), len(a)) {
+				var elementBefore, elementAfter any
+				if i < len(b) {
+					elementBefore = b[i]
+				}
+				if i < len(a) {
+					elementAfter = a[i]
+				}
+				changes = diffValues(fmt.Sprintf("%s[%d]", path, i), elementBefore, elementAfter, changes)
+			}
+			return changes
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		changes = append(changes, domain.RevisionChange{Path: path, Before: before, After: after})
+	}
+	return changes
+}
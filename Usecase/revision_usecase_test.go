@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChangedFields(t *testing.T) {
+	folderID := primitive.NewObjectID()
+	notebook := domain.Notebook{
+		ID: primitive.NewObjectID(), Name: "Biology", Tags: []string{"exam"}, Version: 3,
+		UpdatedAt: time.Unix(1700000000, 0), Origin: &domain.NotebookOrigin{NotebookID: primitive.NewObjectID()},
+	}
+	snapnotes := domain.Snapnotes{
+		Title: "Cells",
+		SummaryByChapter: []domain.ChapterSummary{
+			{ChapterTitle: "Membranes", KeyPoints: []string{"lipid bilayer"}},
+			{ChapterTitle: "Organelles", KeyPoints: []string{"mitochondria", "ribosomes"}},
+		},
+		Flashcards: []domain.ChapterFlashcards{{ChapterTitle: "Membranes"}},
+	}
+
+	for _, tc := range []struct {
+		name string
+		edit func(n *domain.Notebook, s *domain.Snapnotes)
+		want []string
+	}{
+		{"nothing", func(n *domain.Notebook, s *domain.Snapnotes) {}, []string{}},
+		{"version and update time only", func(n *domain.Notebook, s *domain.Snapnotes) {
+			n.Version++
+			n.UpdatedAt = n.UpdatedAt.Add(time.Hour)
+			s.Version++
+		}, []string{}},
+		{"scalars", func(n *domain.Notebook, s *domain.Snapnotes) {
+			n.Name, n.Color = "Chemistry", "#00ff00"
+			s.Title = "Atoms"
+		}, []string{"color", "name", "title"}},
+		{"added and removed fields", func(n *domain.Notebook, s *domain.Snapnotes) {
+			n.FolderID, n.Origin = &folderID, nil
+		}, []string{"folder_id", "origin"}},
+		{"nested values are reported by their top-level field once", func(n *domain.Notebook, s *domain.Snapnotes) {
+			n.Tags = append(n.Tags, "biology", "cells")
+			s.SummaryByChapter[1].KeyPoints[1] = "golgi"
+			s.SummaryByChapter[0].ChapterTitle = "Membranes and transport"
+			s.Flashcards[0].Flashcards = []domain.Flashcard{{KeyTerm: "osmosis"}}
+		}, []string{"flashcards", "summaryByChapter", "tags"}},
+		{"nested field of an object", func(n *domain.Notebook, s *domain.Snapnotes) {
+			n.Origin.SyncedAt = time.Unix(1700000000, 0)
+		}, []string{"origin"}},
+	} {
+		// Deep copies, the edits change nested slices and pointers
+		beforeNotebook, beforeSnapnotes := notebook, snapnotes
+		afterNotebook, afterSnapnotes := notebook, snapnotes
+		afterNotebook.Tags = slices.Clone(notebook.Tags)
+		origin := *notebook.Origin
+		afterNotebook.Origin = &origin
+		afterSnapnotes.SummaryByChapter = slices.Clone(snapnotes.SummaryByChapter)
+		for i := range afterSnapnotes.SummaryByChapter {
+			afterSnapnotes.SummaryByChapter[i].KeyPoints = slices.Clone(snapnotes.SummaryByChapter[i].KeyPoints)
+		}
+		afterSnapnotes.Flashcards = slices.Clone(snapnotes.Flashcards)
+		tc.edit(&afterNotebook, &afterSnapnotes)
+
+		got, err := changedFields(&domain.Revision{Notebook: &beforeNotebook}, &domain.Revision{Notebook: &afterNotebook})
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapnotesFields, err := changedFields(&domain.Revision{Snapnotes: &beforeSnapnotes}, &domain.Revision{Snapnotes: &afterSnapnotes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, snapnotesFields...)
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestRestoreFirstRecordedEdit undoes the first change to snapnotes that
+// were stored before they had a history
+func TestRestoreFirstRecordedEdit(t *testing.T) {
+	f := newNotebookFixture(t)
+	snapnotesRepo := newMemorySnapnotesRepository()
+	unitOfWork := &memoryUnitOfWork{repos: domain.TransactionRepositories{
+		Notebooks: f.notebooks, Snapnotes: snapnotesRepo, Revisions: f.revisions,
+	}}
+	revisions := NewRevisionUseCase(f.revisions, f.useCase, unitOfWork, &recordingIndexer{})
+
+	original := &domain.Snapnotes{
+		NotebookID:       f.notebook.ID,
+		Title:            "Cells",
+		SummaryByChapter: []domain.ChapterSummary{{ChapterTitle: "Membranes", Summary: "Written by hand", KeyPoints: []string{"lipid bilayer"}}},
+	}
+	snapnotesRepo.Create(original)
+	f.notebook.SnapnotesID = &original.ID
+	f.notebooks.Update(f.notebook)
+
+	// Overwrite them the way generation and syncs do
+	overwrite := func(title string) {
+		t.Helper()
+		err := unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+			current, _ := tx.Notebooks.GetByID(f.notebook.ID)
+			content := domain.Snapnotes{NotebookID: current.ID, Title: title}
+			replaced, err := saveCopiedSnapnotes(tx.Snapnotes, current, &content)
+			if err != nil {
+				return err
+			}
+			return recordRevision(tx.Revisions, &domain.Revision{
+				NotebookID: current.ID, Action: domain.RevisionActionGenerate, AuthorID: f.editor.ID, Snapnotes: &content,
+			}, snapnotesBefore(replaced))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	overwrite("Generated cells")
+
+	if len(f.revisions.revisions) != 2 {
+		t.Fatalf("got %d revisions, want a baseline and the change", len(f.revisions.revisions))
+	}
+	baseline, generated := f.revisions.revisions[0], f.revisions.revisions[1]
+	if baseline.Action != domain.RevisionActionBaseline || baseline.Number != 1 || !baseline.AuthorID.IsZero() ||
+		baseline.DocumentID != original.ID || baseline.Snapnotes.Title != "Cells" || len(baseline.ChangedFields) != 0 {
+		t.Errorf("baseline %+v", baseline)
+	}
+	if generated.Action != domain.RevisionActionGenerate || generated.Number != 2 ||
+		!slices.Equal(generated.ChangedFields, []string{"summaryByChapter", "title"}) {
+		t.Errorf("change %+v", generated)
+	}
+
+	restored, err := revisions.RestoreRevision(f.editor.ID.Hex(), f.notebook.ID.Hex(), baseline.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Number != 3 || *restored.RestoredFrom != baseline.ID || !slices.Equal(restored.ChangedFields, []string{"summaryByChapter", "title"}) {
+		t.Errorf("restore %+v", restored)
+	}
+	stored, _ := snapnotesRepo.GetByID(original.ID)
+	if stored.Title != "Cells" || len(stored.SummaryByChapter) != 1 || stored.SummaryByChapter[0].Summary != "Written by hand" {
+		t.Errorf("restored %+v", stored)
+	}
+
+	// Documents with a history get no second baseline
+	overwrite("Generated again")
+	if latest := f.revisions.revisions[len(f.revisions.revisions)-1]; len(f.revisions.revisions) != 4 || latest.Number != 4 {
+		t.Errorf("got %d revisions, the latest %+v", len(f.revisions.revisions), latest)
+	}
+}
+
+func TestUpdateRecordsBaselineOfUntrackedNotebook(t *testing.T) {
+	f := newNotebookFixture(t)
+
+	same := "Biology"
+	if _, err := f.useCase.UpdateNotebook(f.owner.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &same}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.revisions.revisions) != 0 {
+		t.Fatalf("an update that changed nothing recorded %d revisions", len(f.revisions.revisions))
+	}
+
+	name := "Chemistry"
+	if _, err := f.useCase.UpdateNotebook(f.editor.ID.Hex(), f.notebook.ID.Hex(), domain.UpdateRequest{Name: &name}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.revisions.revisions) != 2 {
+		t.Fatalf("got %d revisions", len(f.revisions.revisions))
+	}
+	baseline, update := f.revisions.revisions[0], f.revisions.revisions[1]
+	if baseline.Action != domain.RevisionActionBaseline || baseline.Notebook.Name != "Biology" || baseline.Number != 1 {
+		t.Errorf("baseline %+v", baseline)
+	}
+	if update.Notebook.Name != "Chemistry" || update.Number != 2 || update.AuthorID != f.editor.ID ||
+		!slices.Equal(update.ChangedFields, []string{"name"}) {
+		t.Errorf("update %+v", update)
+	}
+}
+
+// racingRevisionRepository records a revision of another writer before the
+// first insert
+type racingRevisionRepository struct {
+	*memoryRevisionRepository
+	raced bool
+}
+
+func (r *racingRevisionRepository) Create(revision *domain.Revision) error {
+	if !r.raced {
+		r.raced = true
+		other := *revision
+		other.Action = domain.RevisionActionSync
+		r.memoryRevisionRepository.Create(&other)
+	}
+	return r.memoryRevisionRepository.Create(revision)
+}
+
+func TestRecordRevisionRetriesTakenNumbers(t *testing.T) {
+	repo := &racingRevisionRepository{memoryRevisionRepository: &memoryRevisionRepository{}}
+	notebook := &domain.Notebook{ID: primitive.NewObjectID(), Name: "Biology"}
+	if err := recordRevision(repo, &domain.Revision{Action: domain.RevisionActionCreate, Notebook: notebook}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []int
+	for _, revision := range repo.revisions {
+		numbers = append(numbers, revision.Number)
+	}
+	if !slices.Equal(numbers, []int{1, 2}) {
+		t.Errorf("numbers %v", numbers)
+	}
+}
@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	domain "cognivia-api/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxSearchQueryLength = 200
+
+type searchUseCase struct {
+	searchIndex   domain.SearchIndex
+	notebookRepo  domain.NotebookRepository
+	snapnotesRepo domain.SnapnotesRepository
+	prepPilotRepo domain.PrepPilotRepository
+	shareRepo     domain.NotebookShareRepository
+}
+
+func NewSearchUseCase(
+	searchIndex domain.SearchIndex,
+	notebookRepo domain.NotebookRepository,
+	snapnotesRepo domain.SnapnotesRepository,
+	prepPilotRepo domain.PrepPilotRepository,
+	shareRepo domain.NotebookShareRepository,
+) domain.SearchUseCase {
+	return &searchUseCase{
+		searchIndex:   searchIndex,
+		notebookRepo:  notebookRepo,
+		snapnotesRepo: snapnotesRepo,
+		prepPilotRepo: prepPilotRepo,
+		shareRepo:     shareRepo,
+	}
+}
+
+// Search looks through the user's own notebooks and the ones shared with them
+func (u *searchUseCase) Search(userID, text string, kinds []string, limit int) ([]domain.SearchHit, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []domain.FieldError
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxSearchQueryLength {
+		fields = append(fields, domain.FieldError{
+			Field: "q", Code: "invalid_length", Message: fmt.Sprintf("must be between 1 and %d characters", maxSearchQueryLength),
+		})
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(domain.SearchKinds, kind) {
+			fields = append(fields, domain.FieldError{
+				Field: "kind", Code: "invalid", Message: "must be one of " + strings.Join(domain.SearchKinds, ", "),
+			})
+			break
+		}
+	}
+	if limit == 0 {
+		limit = domain.DefaultPageLimit
+	} else if limit < 0 || limit > domain.MaxPageLimit {
+		fields = append(fields, domain.FieldError{
+			Field: "limit", Code: "out_of_range", Message: "must be between 1 and 100",
+		})
+	}
+	if len(fields) > 0 {
+		return nil, &domain.ValidationError{Fields: fields}
+	}
+
+	shares, err := u.shareRepo.GetByUserID(objectUserID)
+	if err != nil {
+		return nil, err
+	}
+	notebookIDs := make([]primitive.ObjectID, len(shares))
+	for i, share := range shares {
+		notebookIDs[i] = share.NotebookID
+	}
+
+	return u.searchIndex.Search(domain.SearchQuery{
+		Text:        text,
+		UserID:      objectUserID,
+		NotebookIDs: notebookIDs,
+		Kinds:       kinds,
+		Limit:       limit,
+	})
+}
+
+func (u *searchUseCase) ReindexUser(userID string) (int, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	notebooks, err := u.notebookRepo.GetByUserID(objectUserID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, notebook := range notebooks {
+		if err := u.IndexNotebook(notebook); err != nil {
+			return 0, err
+		}
+	}
+	return len(notebooks), nil
+}
+
+// IndexNotebook replaces the indexed content of a notebook with its current
+// name, snapnotes and prep pilot.
+func (u *searchUseCase) IndexNotebook(notebook *domain.Notebook) error {
+	docs := []domain.SearchDocument{
+		u.document(notebook, domain.SearchKindNotebook, domain.SearchLocation{Field: "name"}, notebook.Name),
+	}
+
+	if notebook.SnapnotesID != nil {
+		snapnotes, err := u.snapnotesRepo.GetByID(*notebook.SnapnotesID)
+		if err != nil {
+			return err
+		}
+		if snapnotes != nil {
+			docs = append(docs, u.snapnotesDocuments(notebook, snapnotes)...)
+		}
+	}
+
+	if notebook.PrepPilotID != nil {
+		prepPilot, err := u.prepPilotRepo.GetByID(*notebook.PrepPilotID)
+		if err != nil {
+			return err
+		}
+		if prepPilot != nil {
+			for c, chapter := range prepPilot.Chapters {
+				for q, question := range chapter.Questions {
+					docs = append(docs, u.document(notebook, domain.SearchKindQuestion,
+						location("question", c, q), question.Question))
+				}
+			}
+		}
+	}
+
+	// Skip empty text, the text index ignores it anyway
+	docs = slices.DeleteFunc(docs, func(doc domain.SearchDocument) bool {
+		return strings.TrimSpace(doc.Text) == ""
+	})
+	return u.searchIndex.ReplaceNotebook(notebook.ID, docs)
+}
+
+func (u *searchUseCase) snapnotesDocuments(notebook *domain.Notebook, snapnotes *domain.Snapnotes) []domain.SearchDocument {
+	var docs []domain.SearchDocument
+	for c, chapter := range snapnotes.SummaryByChapter {
+		docs = append(docs, u.document(notebook, domain.SearchKindSummary,
+			domain.SearchLocation{Field: "summary", ChapterIndex: &c}, chapter.Summary))
+		for k, point := range chapter.KeyPoints {
+			docs = append(docs, u.document(notebook, domain.SearchKindKeyPoint, location("key_point", c, k), point))
+		}
+	}
+	for c, chapter := range snapnotes.Flashcards {
+		for f, card := range chapter.Flashcards {
+			docs = append(docs,
+				u.document(notebook, domain.SearchKindFlashcard, location("key_term", c, f), card.KeyTerm),
+				u.document(notebook, domain.SearchKindFlashcard, location("definition", c, f), card.Definition),
+			)
+		}
+	}
+	return docs
+}
+
+func (u *searchUseCase) RemoveNotebook(notebookID primitive.ObjectID) error {
+	return u.searchIndex.DeleteNotebook(notebookID)
+}
+
+func (u *searchUseCase) document(notebook *domain.Notebook, kind string, loc domain.SearchLocation, text string) domain.SearchDocument {
+	id := notebook.ID.Hex() + ":" + loc.Field
+	if loc.ChapterIndex != nil {
+		id += fmt.Sprintf(":%d", *loc.ChapterIndex)
+	}
+	if loc.ItemIndex != nil {
+		id += fmt.Sprintf(":%d", *loc.ItemIndex)
+	}
+
+	return domain.SearchDocument{
+		ID:           id,
+		UserID:       notebook.UserID,
+		NotebookID:   notebook.ID,
+		NotebookName: notebook.Name,
+		Kind:         kind,
+		Location:     loc,
+		Text:         text,
+	}
+}
+
+func location(field string, chapter, item int) domain.SearchLocation {
+	return domain.SearchLocation{Field: field, ChapterIndex: &chapter, ItemIndex: &item}
+}
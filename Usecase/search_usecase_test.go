@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	domain "cognivia-api/Domain"
+	mongodb "cognivia-api/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearchCoversSharedNotebooks(t *testing.T) {
+	index := mongodb.NewInMemorySearchIndex()
+	shares := newMemoryNotebookShareRepository()
+	useCase := NewSearchUseCase(index, nil, nil, nil, shares).(*searchUseCase)
+
+	owner, member := primitive.NewObjectID(), primitive.NewObjectID()
+	notebooks := map[string]*domain.Notebook{}
+	for _, name := range []string{"Member", "Shared", "Invited", "Private"} {
+		userID := owner
+		if name == "Member" {
+			userID = member
+		}
+		notebook := &domain.Notebook{ID: primitive.NewObjectID(), UserID: userID, Name: name}
+		notebooks[name] = notebook
+		if err := useCase.IndexNotebook(notebook); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	accepted := &domain.NotebookShare{NotebookID: notebooks["Shared"].ID, OwnerID: owner, UserID: &member, Email: "member@example.com", Role: domain.ShareRoleViewer}
+	shares.Create(accepted)
+	// An invitation the member has not accepted yet gives no access
+	shares.Create(&domain.NotebookShare{NotebookID: notebooks["Invited"].ID, OwnerID: owner, Email: "member@example.com", Role: domain.ShareRoleViewer})
+
+	search := func(userID primitive.ObjectID) []string {
+		t.Helper()
+		hits, err := useCase.Search(userID.Hex(), "  member shared invited private  ", nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, len(hits))
+		for i, hit := range hits {
+			names[i] = hit.NotebookName
+		}
+		return names
+	}
+
+	if got := search(member); len(got) != 2 || got[0] != "Member" || got[1] != "Shared" {
+		t.Errorf("member found %q", got)
+	}
+	if got := search(owner); len(got) != 3 {
+		t.Errorf("owner found %q", got)
+	}
+
+	shares.Delete(accepted.ID)
+	if got := search(member); len(got) != 1 || got[0] != "Member" {
+		t.Errorf("member found %q after the share was revoked", got)
+	}
+
+	for _, tc := range []struct {
+		text  string
+		kinds []string
+		limit int
+		field string
+	}{
+		{" ", nil, 0, "q"},
+		{strings.Repeat("a", maxSearchQueryLength+1), nil, 0, "q"},
+		{"cells", []string{"chapter"}, 0, "kind"},
+		{"cells", nil, domain.MaxPageLimit + 1, "limit"},
+		{"cells", nil, -1, "limit"},
+	} {
+		_, err := useCase.Search(member.Hex(), tc.text, tc.kinds, tc.limit)
+		var validationErr *domain.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != tc.field {
+			t.Errorf("%q %v %d: got %v", tc.text, tc.kinds, tc.limit, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type shareLinkUseCase struct {
+	notebookRepo  domain.NotebookRepository
+	snapnotesRepo domain.SnapnotesRepository
+	prepPilotRepo domain.PrepPilotRepository
+}
+
+func NewShareLinkUseCase(
+	notebookRepo domain.NotebookRepository,
+	snapnotesRepo domain.SnapnotesRepository,
+	prepPilotRepo domain.PrepPilotRepository,
+) domain.ShareLinkUseCase {
+	return &shareLinkUseCase{
+		notebookRepo:  notebookRepo,
+		snapnotesRepo: snapnotesRepo,
+		prepPilotRepo: prepPilotRepo,
+	}
+}
+
+func (u *shareLinkUseCase) CreateShareLink(userID, notebookID string, expiresAt *time.Time) (*domain.ShareLink, string, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "expires_at", Code: "in_past", Message: "must be in the future",
+		}}}
+	}
+
+	token, err := infrastructure.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	link := &domain.ShareLink{
+		TokenHash: infrastructure.HashToken(token),
+		CreatedBy: notebook.UserID,
+		ExpiresAt: expiresAt,
+	}
+	if err := u.notebookRepo.AddShareLink(notebook.ID, link); err != nil {
+		return nil, "", err
+	}
+
+	return link, token, nil
+}
+
+func (u *shareLinkUseCase) ListShareLinks(userID, notebookID string) ([]domain.ShareLink, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook.ShareLinks == nil {
+		return []domain.ShareLink{}, nil
+	}
+	return notebook.ShareLinks, nil
+}
+
+func (u *shareLinkUseCase) RevokeShareLink(userID, notebookID, linkID string) error {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return err
+	}
+
+	objectLinkID, err := primitive.ObjectIDFromHex(linkID)
+	if err != nil {
+		return domain.ErrShareNotFound
+	}
+
+	revoked, err := u.notebookRepo.RevokeShareLink(notebook.ID, objectLinkID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrShareNotFound
+	}
+	return nil
+}
+
+func (u *shareLinkUseCase) GetPublicNotebook(token string) (*domain.PublicNotebook, error) {
+	notebook, err := u.sharedNotebook(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PublicNotebook{
+		Name:         notebook.Name,
+		Icon:         notebook.Icon,
+		Color:        notebook.Color,
+		Type:         notebook.Type,
+		HasSnapnotes: notebook.SnapnotesID != nil,
+		HasPrepPilot: notebook.PrepPilotID != nil,
+	}, nil
+}
+
+func (u *shareLinkUseCase) GetPublicSnapnotes(token string) (*domain.Snapnotes, error) {
+	notebook, err := u.sharedNotebook(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if notebook.SnapnotesID == nil {
+		return nil, errors.New("no snapnotes associated with this notebook")
+	}
+
+	return u.snapnotesRepo.GetByID(*notebook.SnapnotesID)
+}
+
+func (u *shareLinkUseCase) GetPracticePrepPilot(token string) (*domain.PracticePrepPilot, error) {
+	prepPilot, err := u.sharedPrepPilot(token)
+	if err != nil {
+		return nil, err
+	}
+
+	practice := &domain.PracticePrepPilot{Chapters: make([]domain.PracticeChapter, len(prepPilot.Chapters))}
+	for i, chapter := range prepPilot.Chapters {
+		questions := make([]domain.PracticeQuestion, len(chapter.Questions))
+		for j, question := range chapter.Questions {
+			questions[j] = domain.PracticeQuestion{Question: question.Question, Options: question.Options}
+		}
+		practice.Chapters[i] = domain.PracticeChapter{ChapterTitle: chapter.ChapterTitle, Questions: questions}
+	}
+	return practice, nil
+}
+
+// SubmitPracticeQuiz grades the answers against the prep pilot. Unanswered
+// questions count as wrong. Nothing is stored since the visitor is anonymous.
+func (u *shareLinkUseCase) SubmitPracticeQuiz(token string, answers []domain.PracticeAnswer) (*domain.PracticeResult, error) {
+	prepPilot, err := u.sharedPrepPilot(token)
+	if err != nil {
+		return nil, err
+	}
+
+	given := make(map[[2]int]string, len(answers))
+	for _, answer := range answers {
+		if answer.ChapterIndex < 0 || answer.ChapterIndex >= len(prepPilot.Chapters) ||
+			answer.QuestionIndex < 0 || answer.QuestionIndex >= len(prepPilot.Chapters[answer.ChapterIndex].Questions) {
+			return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+				Field: "answers", Code: "unknown_question", Message: "refers to a question that does not exist",
+			}}}
+		}
+		given[[2]int{answer.ChapterIndex, answer.QuestionIndex}] = strings.ToUpper(strings.TrimSpace(answer.Answer))
+	}
+
+	result := &domain.PracticeResult{Answers: []domain.TestAnswer{}}
+	for i, chapter := range prepPilot.Chapters {
+		for j, question := range chapter.Questions {
+			userAnswer := given[[2]int{i, j}]
+			correct := userAnswer != "" && userAnswer == strings.ToUpper(question.Answer)
+			result.Answers = append(result.Answers, domain.TestAnswer{
+				Question:      question.Question,
+				Options:       question.Options,
+				CorrectAnswer: question.Answer,
+				UserAnswer:    userAnswer,
+				IsCorrect:     correct,
+				ChapterTitle:  chapter.ChapterTitle,
+				Explanation:   question.Explanation,
+			})
+			result.TotalQuestions++
+			if correct {
+				result.CorrectAnswers++
+			}
+		}
+	}
+	if result.TotalQuestions > 0 {
+		result.Score = float64(result.CorrectAnswers) / float64(result.TotalQuestions) * 100
+	}
+
+	return result, nil
+}
+
+func (u *shareLinkUseCase) ownedNotebook(userID, notebookID string) (*domain.Notebook, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	if err != nil {
+		return nil, domain.ErrNotebookNotFound
+	}
+
+	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook == nil || notebook.UserID != objectUserID || notebook.DeletedAt != nil {
+		return nil, domain.ErrNotebookNotFound
+	}
+	return notebook, nil
+}
+
+// sharedNotebook resolves a share link token to its notebook
+func (u *shareLinkUseCase) sharedNotebook(token string) (*domain.Notebook, error) {
+	hash := infrastructure.HashToken(token)
+
+	notebook, err := u.notebookRepo.FindByShareLinkHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if notebook == nil || notebook.DeletedAt != nil {
+		return nil, domain.ErrShareLinkInvalid
+	}
+
+	for i := range notebook.ShareLinks {
+		link := &notebook.ShareLinks[i]
+		if link.TokenHash == hash && link.Active(time.Now()) {
+			return notebook, nil
+		}
+	}
+	return nil, domain.ErrShareLinkInvalid
+}
+
+func (u *shareLinkUseCase) sharedPrepPilot(token string) (*domain.PrepPilot, error) {
+	notebook, err := u.sharedNotebook(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if notebook.PrepPilotID == nil {
+		return nil, errors.New("no prep pilot associated with this notebook")
+	}
+
+	prepPilot, err := u.prepPilotRepo.GetByID(*notebook.PrepPilotID)
+	if err != nil {
+		return nil, err
+	}
+	if prepPilot == nil {
+		return nil, errors.New("no prep pilot associated with this notebook")
+	}
+	return prepPilot, nil
+}
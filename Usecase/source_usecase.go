@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type sourceUseCase struct {
+	sourceRepo     domain.NotebookSourceRepository
+	textRepo       domain.SourceTextRepository
+	notebookRepo   domain.NotebookRepository
+	notebooks      domain.NotebookAuthorizer
+	blobStore      domain.BlobStore
+	jobs           domain.JobQueue
+	maxSize        int64
+	maxPerNotebook int
+}
+
+func NewSourceUseCase(
+	sourceRepo domain.NotebookSourceRepository,
+	textRepo domain.SourceTextRepository,
+	notebookRepo domain.NotebookRepository,
+	notebooks domain.NotebookAuthorizer,
+	blobStore domain.BlobStore,
+	jobs domain.JobQueue,
+) domain.SourceUseCase {
+	return &sourceUseCase{
+		sourceRepo:     sourceRepo,
+		textRepo:       textRepo,
+		notebookRepo:   notebookRepo,
+		notebooks:      notebooks,
+		blobStore:      blobStore,
+		jobs:           jobs,
+		maxSize:        int64(infrastructure.EnvInt("SOURCE_MAX_SIZE", 25<<20)),
+		maxPerNotebook: infrastructure.EnvInt("SOURCE_MAX_PER_NOTEBOOK", 20),
+	}
+}
+
+func (u *sourceUseCase) MaxSourceSize() int64 {
+	return u.maxSize
+}
+
+func (u *sourceUseCase) UploadSource(userID, notebookID string, upload domain.SourceUpload) (*domain.NotebookSource, error) {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanEdit(role) {
+		return nil, domain.ErrForbidden
+	}
+	uploaderID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Browsers on Windows may send the full client path
+	fileName := strings.TrimSpace(path.Base(strings.ReplaceAll(upload.FileName, "\\", "/")))
+	contentType, ok := domain.SourceContentTypes[strings.ToLower(path.Ext(fileName))]
+	if fileName == "" || fileName == "." || fileName == "/" || !ok {
+		return nil, sourceError("unsupported_type", "must be a PDF, DOCX, text, Markdown or HTML file")
+	}
+
+	content, err := io.ReadAll(io.LimitReader(upload.Content, u.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(content) == 0:
+		return nil, sourceError("empty", "must not be empty")
+	case int64(len(content)) > u.maxSize:
+		return nil, sourceError("too_large", fmt.Sprintf("must not be larger than %d bytes", u.maxSize))
+	case !contentMatches(contentType, content):
+		return nil, sourceError("type_mismatch", "content does not match the file extension")
+	}
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	existing, err := u.sourceRepo.GetByNotebookID(notebook.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= u.maxPerNotebook {
+		return nil, sourceError("limit_reached", fmt.Sprintf("a notebook can have at most %d sources", u.maxPerNotebook))
+	}
+	for _, source := range existing {
+		if source.Checksum == checksum {
+			return nil, sourceError("duplicate", "the notebook already has this file as "+source.FileName)
+		}
+	}
+
+	source := &domain.NotebookSource{
+		ID:          primitive.NewObjectID(),
+		NotebookID:  notebook.ID,
+		UploadedBy:  uploaderID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		Checksum:    checksum,
+		Extraction:  domain.SourceExtraction{Status: domain.ExtractionPending},
+	}
+	source.StorageKey = "notebooks/" + notebook.ID.Hex() + "/sources/" + source.ID.Hex()
+
+	if err := u.blobStore.Put(source.StorageKey, bytes.NewReader(content), source.Size, contentType); err != nil {
+		return nil, err
+	}
+	if err := u.sourceRepo.Create(source); err != nil {
+		u.deleteBlob(source.StorageKey)
+		return nil, err
+	}
+	u.refreshExtractionStatus(notebook.ID)
+	queueExtraction(u.jobs, source, userID)
+	return source, nil
+}
+
+func (u *sourceUseCase) ListSources(userID, notebookID string) ([]*domain.NotebookSource, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	return u.sourceRepo.GetByNotebookID(notebook.ID)
+}
+
+func (u *sourceUseCase) OpenSource(userID, notebookID, sourceID string) (*domain.NotebookSource, io.ReadCloser, error) {
+	notebook, _, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := findNotebookSource(u.sourceRepo, notebook, sourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := u.blobStore.Get(source.StorageKey)
+	if err != nil {
+		if errors.Is(err, domain.ErrBlobNotFound) {
+			log.Printf("Content of source %s is missing from the blob store", source.ID.Hex())
+			return nil, nil, domain.ErrSourceNotFound
+		}
+		return nil, nil, err
+	}
+	return source, content, nil
+}
+
+func (u *sourceUseCase) DeleteSource(userID, notebookID, sourceID string) error {
+	notebook, role, err := u.notebooks.NotebookAccess(userID, notebookID)
+	if err != nil {
+		return err
+	}
+	if !domain.CanEdit(role) {
+		return domain.ErrForbidden
+	}
+	source, err := findNotebookSource(u.sourceRepo, notebook, sourceID)
+	if err != nil {
+		return err
+	}
+
+	// The metadata goes first, a blob left behind is only wasted space
+	if err := u.sourceRepo.Delete(source.ID); err != nil {
+		return err
+	}
+	u.deleteBlob(source.StorageKey)
+	if err := u.textRepo.DeleteBySourceID(source.ID); err != nil {
+		log.Printf("Error deleting text of source %s: %v", source.ID.Hex(), err)
+	}
+	u.refreshExtractionStatus(notebook.ID)
+	return nil
+}
+
+// findNotebookSource loads a source and checks that it belongs to the
+// notebook
+func findNotebookSource(sourceRepo domain.NotebookSourceRepository, notebook *domain.Notebook, sourceID string) (*domain.NotebookSource, error) {
+	objectSourceID, err := primitive.ObjectIDFromHex(sourceID)
+	if err != nil {
+		return nil, domain.ErrSourceNotFound
+	}
+
+	source, err := sourceRepo.GetByID(objectSourceID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil || source.NotebookID != notebook.ID {
+		return nil, domain.ErrSourceNotFound
+	}
+	return source, nil
+}
+
+// refreshExtractionStatus only logs errors, the summary catches up with the
+// next change
+func (u *sourceUseCase) refreshExtractionStatus(notebookID primitive.ObjectID) {
+	if err := refreshExtractionStatus(u.sourceRepo, u.notebookRepo, notebookID); err != nil {
+		log.Printf("Error updating extraction status of notebook %s: %v", notebookID.Hex(), err)
+	}
+}
+
+func (u *sourceUseCase) deleteBlob(key string) {
+	if err := u.blobStore.Delete(key); err != nil {
+		log.Printf("Error deleting blob %s: %v", key, err)
+	}
+}
+
+func sourceError(code, message string) error {
+	return &domain.ValidationError{Fields: []domain.FieldError{{Field: "file", Code: code, Message: message}}}
+}
+
+// contentMatches sniffs the content so a renamed file cannot pass as another
+// type. DOCX files are ZIP archives with a word/document.xml part.
+func contentMatches(contentType string, content []byte) bool {
+	sniffed := http.DetectContentType(content)
+	switch {
+	case contentType == "application/pdf":
+		return sniffed == "application/pdf"
+	case strings.HasPrefix(contentType, "application/vnd.openxmlformats"):
+		if sniffed != "application/zip" {
+			return false
+		}
+		archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return false
+		}
+		for _, file := range archive.File {
+			if file.Name == "word/document.xml" {
+				return true
+			}
+		}
+		return false
+	default:
+		return strings.HasPrefix(sniffed, "text/") && utf8.Valid(content)
+	}
+}
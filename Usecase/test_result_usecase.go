@@ -40,7 +40,7 @@ func (u *testResultUseCase) SubmitTestResult(userID string, testResult *domain.T
 	if err != nil {
 		return err
 	}
-	if notebook == nil || notebook.UserID != objectUserID {
+	if notebook == nil || notebook.UserID != objectUserID || notebook.DeletedAt != nil {
 		return errors.New("notebook not found or does not belong to user")
 	}
 
@@ -118,40 +118,81 @@ func (u *testResultUseCase) GetTestResultByID(userID string, testResultID string
 	return testResult, nil
 }
 
-func (u *testResultUseCase) GetUserTestResults(userID string) ([]*domain.TestResult, error) {
+func (u *testResultUseCase) GetUserTestResults(userID string, request domain.TestResultListRequest) (*domain.Page[*domain.TestResult], error) {
 	objectUserID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return u.testResultRepo.GetByUserID(objectUserID)
+	query, err := testResultQuery(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.testResultRepo.Find(objectUserID, query)
 }
 
-func (u *testResultUseCase) GetNotebookTestResults(userID string, notebookID string) ([]*domain.TestResult, error) {
-	objectUserID, err := primitive.ObjectIDFromHex(userID)
+func (u *testResultUseCase) GetNotebookTestResults(userID string, notebookID string, request domain.TestResultListRequest) (*domain.Page[*domain.TestResult], error) {
+	objectUserID, objectNotebookID, err := u.ownedNotebookIDs(userID, notebookID)
 	if err != nil {
 		return nil, err
 	}
 
-	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	query, err := testResultQuery(request)
 	if err != nil {
 		return nil, err
 	}
+	query.NotebookID = &objectNotebookID
+
+	return u.testResultRepo.Find(objectUserID, query)
+}
+
+// ownedNotebookIDs parses the IDs and validates that the notebook belongs to the user
+func (u *testResultUseCase) ownedNotebookIDs(userID string, notebookID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+
+	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
 
 	// Validate that the notebook belongs to the user
 	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
 	if err != nil {
-		return nil, err
+		return primitive.NilObjectID, primitive.NilObjectID, err
 	}
-	if notebook == nil || notebook.UserID != objectUserID {
-		return nil, errors.New("notebook not found or does not belong to user")
+	if notebook == nil || notebook.UserID != objectUserID || notebook.DeletedAt != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("notebook not found or does not belong to user")
 	}
 
-	return u.testResultRepo.GetByUserAndNotebook(objectUserID, objectNotebookID)
+	return objectUserID, objectNotebookID, nil
+}
+
+func testResultQuery(request domain.TestResultListRequest) (domain.TestResultQuery, error) {
+	page, err := pageOptions(request.ListRequest, domain.TestResultSortFields)
+	if err != nil {
+		return domain.TestResultQuery{}, err
+	}
+
+	return domain.TestResultQuery{
+		CompletedFrom: optionalTime(request.CompletedFrom),
+		CompletedTo:   optionalTime(request.CompletedTo),
+		MinScore:      request.MinScore,
+		MaxScore:      request.MaxScore,
+		PageOptions:   page,
+	}, nil
 }
 
 func (u *testResultUseCase) GetTestResultStats(userID string, notebookID string) (*domain.TestStats, error) {
-	testResults, err := u.GetNotebookTestResults(userID, notebookID)
+	objectUserID, objectNotebookID, err := u.ownedNotebookIDs(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+
+	testResults, err := u.testResultRepo.GetByUserAndNotebook(objectUserID, objectNotebookID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	domain "cognivia-api/Domain"
+	"cognivia-api/infrastructure"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const purgeBatchSize = 100
+
+type trashUseCase struct {
+	notebookRepo domain.NotebookRepository
+	unitOfWork   domain.UnitOfWork
+	indexer      domain.NotebookIndexer
+	blobStore    domain.BlobStore
+	jobs         domain.JobQueue
+	retention    time.Duration
+}
+
+func NewTrashUseCase(
+	notebookRepo domain.NotebookRepository,
+	unitOfWork domain.UnitOfWork,
+	indexer domain.NotebookIndexer,
+	blobStore domain.BlobStore,
+	jobs domain.JobQueue,
+) domain.TrashUseCase {
+	return &trashUseCase{
+		notebookRepo: notebookRepo,
+		unitOfWork:   unitOfWork,
+		indexer:      indexer,
+		blobStore:    blobStore,
+		jobs:         jobs,
+		retention:    infrastructure.EnvDuration("NOTEBOOK_TRASH_RETENTION", 30*24*time.Hour),
+	}
+}
+
+func (u *trashUseCase) ListTrash(userID string, request domain.ListRequest) (*domain.Page[*domain.Notebook], error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := pageOptions(request, domain.TrashSortFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.notebookRepo.Find(objectUserID, domain.NotebookQuery{Trashed: true, PageOptions: page})
+}
+
+func (u *trashUseCase) RestoreNotebook(userID, notebookID string) (*domain.Notebook, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook.DeletedAt == nil {
+		return nil, domain.ErrNotebookNotFound
+	}
+
+	if err := u.notebookRepo.Restore(notebook.ID); err != nil {
+		return nil, err
+	}
+	notebook.DeletedAt = nil
+	notebook.UpdatedAt = time.Now()
+	notebook.Version++
+
+	if err := u.indexer.IndexNotebook(notebook); err != nil {
+		log.Printf("Error indexing notebook %s: %v", notebook.ID.Hex(), err)
+	}
+	return notebook, nil
+}
+
+func (u *trashUseCase) DeleteNotebookPermanently(userID, notebookID string, expectedVersion *int64) (*domain.Job, error) {
+	notebook, err := u.ownedNotebook(userID, notebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook.DeletedAt == nil {
+		return nil, domain.ErrNotebookNotFound
+	}
+	if err := checkVersion(expectedVersion, notebook.Version); err != nil {
+		return nil, err
+	}
+
+	job := &domain.Job{
+		Type:       domain.JobPurgeNotebook,
+		NotebookID: &notebook.ID,
+		UserID:     &notebook.UserID,
+		DedupeKey:  domain.JobPurgeNotebook + ":" + notebook.ID.Hex(),
+	}
+	if expectedVersion != nil {
+		job.Payload = map[string]string{"version": strconv.FormatInt(*expectedVersion, 10)}
+	}
+	return u.jobs.Enqueue(job)
+}
+
+func (u *trashUseCase) RunJob(job *domain.Job) error {
+	switch job.Type {
+	case domain.JobPurgeTrash:
+		purged, err := u.PurgeExpired()
+		if purged > 0 {
+			log.Printf("Purged %d notebooks from the trash", purged)
+		}
+		return err
+	case domain.JobPurgeNotebook:
+		if job.NotebookID == nil {
+			return domain.ErrNotebookNotFound
+		}
+		var expectedVersion *int64
+		if version, ok := job.Payload["version"]; ok {
+			v, err := strconv.ParseInt(version, 10, 64)
+			if err != nil {
+				return err
+			}
+			expectedVersion = &v
+		}
+		// purge leaves notebooks that are gone or were restored alone
+		return u.purge(&domain.Notebook{ID: *job.NotebookID}, expectedVersion)
+	default:
+		return fmt.Errorf("unexpected job type %s", job.Type)
+	}
+}
+
+func (u *trashUseCase) PurgeExpired() (int, error) {
+	cutoff := time.Now().Add(-u.retention)
+
+	purged := 0
+	for {
+		notebooks, err := u.notebookRepo.FindDeletedBefore(cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, notebook := range notebooks {
+			if err := u.purge(notebook, nil); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(notebooks) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purge deletes a trashed notebook and everything that hangs off it in one
+// transaction. A notebook that was restored in the meantime is left alone,
+// one that changed from expectedVersion is a conflict. Source files are
+// removed from the blob store once the transaction committed.
+func (u *trashUseCase) purge(notebook *domain.Notebook, expectedVersion *int64) error {
+	var blobKeys []string
+	err := u.unitOfWork.Do(func(tx domain.TransactionRepositories) error {
+		blobKeys = nil
+		current, err := tx.Notebooks.GetByID(notebook.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt == nil {
+			return nil
+		}
+		if err := checkVersion(expectedVersion, current.Version); err != nil {
+			return err
+		}
+
+		if current.SnapnotesID != nil {
+			// Older snapnotes have no notebook_id. Never touch ones recorded
+			// for another notebook.
+			snapnotes, err := tx.Snapnotes.GetByID(*current.SnapnotesID)
+			if err != nil {
+				return err
+			}
+			if snapnotes != nil && (snapnotes.NotebookID.IsZero() || snapnotes.NotebookID == current.ID) {
+				if err := tx.Snapnotes.Delete(snapnotes.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Snapnotes.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		if err := tx.PrepPilots.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		if err := tx.TestResults.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		if err := tx.Shares.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		if err := tx.Revisions.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		sources, err := tx.Sources.GetByNotebookID(current.ID)
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			blobKeys = append(blobKeys, source.StorageKey)
+		}
+		if err := tx.Sources.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		if err := tx.SourceTexts.DeleteByNotebookID(current.ID); err != nil {
+			return err
+		}
+		return tx.Notebooks.Delete(current.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range blobKeys {
+		if err := u.blobStore.Delete(key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+
+	// Trashed notebooks are already out of the index, this only catches
+	// entries left behind by a failed removal
+	if err := u.indexer.RemoveNotebook(notebook.ID); err != nil {
+		log.Printf("Error removing notebook %s from search index: %v", notebook.ID.Hex(), err)
+	}
+	return nil
+}
+
+func (u *trashUseCase) ownedNotebook(userID, notebookID string) (*domain.Notebook, error) {
+	objectUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	objectNotebookID, err := primitive.ObjectIDFromHex(notebookID)
+	if err != nil {
+		return nil, domain.ErrNotebookNotFound
+	}
+
+	notebook, err := u.notebookRepo.GetByID(objectNotebookID)
+	if err != nil {
+		return nil, err
+	}
+	if notebook == nil || notebook.UserID != objectUserID {
+		return nil, domain.ErrNotebookNotFound
+	}
+	return notebook, nil
+}
@@ -2,8 +2,11 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	domain "cognivia-api/Domain"
@@ -14,13 +17,41 @@ import (
 
 type userUseCase struct {
 	userRepo        domain.UserRepository
+	tokenRepo       domain.UserTokenRepository
+	apiKeyRepo      domain.APIKeyRepository
 	passwordService infrastructure.PasswordService
+	passwordPolicy  infrastructure.PasswordPolicy
+	mailer          infrastructure.Mailer
+	loginThrottle   domain.LoginThrottle
+	resetTokenTTL   time.Duration
+	verifyTokenTTL  time.Duration
+	resendInterval  time.Duration
+	unverifiedRule  string
+	challengeTTL    time.Duration
+	now             func() time.Time
 }
 
-func NewUserUseCase(userRepo domain.UserRepository) domain.UserUseCase {
+func NewUserUseCase(
+	userRepo domain.UserRepository,
+	tokenRepo domain.UserTokenRepository,
+	apiKeyRepo domain.APIKeyRepository,
+	mailer infrastructure.Mailer,
+	loginThrottle domain.LoginThrottle,
+) domain.UserUseCase {
 	return &userUseCase{
 		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		apiKeyRepo:      apiKeyRepo,
 		passwordService: infrastructure.NewPasswordService(),
+		passwordPolicy:  infrastructure.NewPasswordPolicy(),
+		mailer:          mailer,
+		loginThrottle:   loginThrottle,
+		resetTokenTTL:   infrastructure.EnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		verifyTokenTTL:  infrastructure.EnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		resendInterval:  infrastructure.EnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+		unverifiedRule:  infrastructure.UnverifiedUserPolicy(),
+		challengeTTL:    infrastructure.EnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+		now:             time.Now,
 	}
 }
 
@@ -36,9 +67,9 @@ func (u *userUseCase) Register(user *domain.User) (*domain.User, error) {
 		return nil, errors.New("user already exists")
 	}
 
-	// Log original password
-	log.Printf("Original password: %s", user.Password)
-	log.Printf("Original password length: %d", len(user.Password))
+	if err := u.checkPasswordPolicy("password", user.Password, user); err != nil {
+		return nil, err
+	}
 
 	// Hash password using password service
 	hashedPassword, err := u.passwordService.PasswordHasher(user.Password)
@@ -48,10 +79,30 @@ func (u *userUseCase) Register(user *domain.User) (*domain.User, error) {
 	}
 	user.Password = hashedPassword
 
-	// Log hashed password details
-	log.Printf("Hashed password: %s", user.Password)
-	log.Printf("Hashed password length: %d", len(user.Password))
+	applyNewUserDefaults(user)
+	user.EmailVerified = false
+
+	// Create user
+	err = u.userRepo.Create(user)
+	if err != nil {
+		log.Printf("Error creating user: %v", err)
+		return nil, err
+	}
 
+	log.Printf("User created successfully: %s", user.Email)
+
+	// A failed email should not fail the registration, the user can ask
+	// for another one
+	if err := u.SendVerificationEmail(user); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
+	return user, nil
+}
+
+// applyNewUserDefaults resets everything a new account must not take from the
+// request: profile defaults, settings and security state.
+func applyNewUserDefaults(user *domain.User) {
 	//defaults for bio, profile pic and settings
 	user.Bio = ""
 	user.ProfilePic = "https://avatar.iran.liara.run/public/1"
@@ -62,16 +113,9 @@ func (u *userUseCase) Register(user *domain.User) (*domain.User, error) {
 		BrowserNotifications: false,
 		MobileNotifications:  false,
 	}
-
-	// Create user
-	err = u.userRepo.Create(user)
-	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		return nil, err
-	}
-
-	log.Printf("User created successfully: %s", user.Email)
-	return user, nil
+	user.TokenVersion = 0
+	user.TwoFactor = domain.TwoFactorSettings{}
+	user.Identities = nil
 }
 
 func (u *userUseCase) Login(email, password string) (string, error) {
@@ -80,9 +124,14 @@ func (u *userUseCase) Login(email, password string) (string, error) {
 		return "", err
 	}
 
-	if u.VerifyPassword(user.Password, password) {
+	if !u.VerifyPassword(user.Password, password) {
 		return "", errors.New("invalid credentials")
 	}
+	u.UpgradePasswordHash(user, password)
+
+	if err := u.CheckLoginAllowed(user); err != nil {
+		return "", err
+	}
 
 	return u.GenerateToken(user)
 }
@@ -99,14 +148,37 @@ func (u *userUseCase) GetUserByEmail(email string) (*domain.User, error) {
 }
 
 func (u *userUseCase) VerifyPassword(hash, password string) bool {
-	return !u.passwordService.PasswordComparator(hash, password)
+	return u.passwordService.PasswordComparator(hash, password)
+}
+
+// UpgradePasswordHash rehashes a just-verified password when the stored hash
+// uses an outdated algorithm or parameters. Failures are logged only, the
+// login itself has already succeeded.
+func (u *userUseCase) UpgradePasswordHash(user *domain.User, password string) {
+	if !u.passwordService.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := u.passwordService.PasswordHasher(password)
+	if err != nil {
+		log.Printf("Error rehashing password: %v", err)
+		return
+	}
+	user.Password = hashedPassword
+
+	if err := u.userRepo.Update(user); err != nil {
+		log.Printf("Error storing rehashed password: %v", err)
+		return
+	}
+	log.Printf("Upgraded password hash for user: %s", user.Email)
 }
 
 func (u *userUseCase) GenerateToken(user *domain.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"user_id":       user.ID.Hex(),
+		"email":         user.Email,
+		"token_version": user.TokenVersion,
+		"exp":           time.Now().Add(time.Hour * 24).Unix(),
 	})
 
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
@@ -116,10 +188,562 @@ func (u *userUseCase) GetUserByID(id string) (*domain.User, error) {
 	return u.userRepo.FindByID(id)
 }
 
+// UpdateUser updates the profile fields of a user. Credentials, verification
+// and two-factor state have dedicated flows and are never taken from the
+// request.
 func (u *userUseCase) UpdateUser(user *domain.User) error {
-	return u.userRepo.Update(user)
+	existing, err := u.userRepo.FindByID(user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return domain.ErrUserNotFound
+	}
+
+	existing.Name = user.Name
+	existing.Bio = user.Bio
+	existing.ProfilePic = user.ProfilePic
+	existing.Settings = user.Settings
+
+	return u.userRepo.Update(existing)
 }
 
 func (u *userUseCase) DeleteUser(id string) error {
 	return u.userRepo.Delete(id)
 }
+
+// RequestPasswordReset emails a reset link if the address belongs to a user.
+// Unknown addresses are ignored so the endpoint cannot be used to probe for
+// registered emails.
+func (u *userUseCase) RequestPasswordReset(email string) error {
+	user, err := u.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown email: %s", email)
+		return nil
+	}
+
+	token, err := u.issueToken(user, domain.TokenPurposePasswordReset, u.resetTokenTTL, "")
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", os.Getenv("APP_BASE_URL"), token)
+	body := fmt.Sprintf(
+		"Hi %s,\n\nWe received a request to reset your Cognivia password. "+
+			"Use the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %s. If you did not request a reset you can ignore this email.\n",
+		user.Name, link, u.resetTokenTTL,
+	)
+	return u.mailer.Send(user.Email, "Reset your Cognivia password", body)
+}
+
+// ResetPassword consumes a reset token and sets a new password. All reset
+// tokens, API keys and previously issued JWTs of the user are revoked.
+func (u *userUseCase) ResetPassword(token, newPassword string) error {
+	// Validate before consuming the token so a rejected password can be retried
+	user, _, err := u.consumeToken(token, domain.TokenPurposePasswordReset, func(user *domain.User, _ *domain.UserToken) error {
+		return u.checkPasswordPolicy("password", newPassword, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := u.passwordService.PasswordHasher(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.TokenVersion++
+
+	if err := u.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return u.revokeCredentialTokens(user)
+}
+
+// SendVerificationEmail emails a fresh verification link, replacing any
+// previous one.
+func (u *userUseCase) SendVerificationEmail(user *domain.User) error {
+	token, err := u.issueToken(user, domain.TokenPurposeEmailVerification, u.verifyTokenTTL, "")
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", os.Getenv("APP_BASE_URL"), token)
+	body := fmt.Sprintf(
+		"Hi %s,\n\nWelcome to Cognivia! Please confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+		user.Name, link, u.verifyTokenTTL,
+	)
+	return u.mailer.Send(user.Email, "Verify your Cognivia email address", body)
+}
+
+// ResendVerificationEmail sends another verification link unless one was sent
+// less than EMAIL_VERIFICATION_RESEND_INTERVAL ago. Unknown and already
+// verified addresses are ignored.
+func (u *userUseCase) ResendVerificationEmail(email string) error {
+	user, err := u.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerified {
+		return nil
+	}
+
+	latest, err := u.tokenRepo.FindLatestByUserID(user.ID, domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < u.resendInterval {
+		return domain.ErrTooManyRequests
+	}
+
+	return u.SendVerificationEmail(user)
+}
+
+func (u *userUseCase) VerifyEmail(token string) error {
+	user, _, err := u.consumeToken(token, domain.TokenPurposeEmailVerification, nil)
+	if err != nil {
+		return err
+	}
+
+	user.EmailVerified = true
+	if err := u.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return u.tokenRepo.DeleteByUserID(user.ID, domain.TokenPurposeEmailVerification)
+}
+
+// CheckLoginAllowed applies UNVERIFIED_USER_POLICY to a user whose password
+// has already been verified.
+func (u *userUseCase) CheckLoginAllowed(user *domain.User) error {
+	if !user.EmailVerified && u.unverifiedRule == domain.UnverifiedPolicyDeny {
+		return domain.ErrEmailNotVerified
+	}
+	return nil
+}
+
+// issueToken stores a new token for purpose and returns its plaintext value.
+// Older tokens with the same purpose are revoked so only the latest link works.
+func (u *userUseCase) issueToken(user *domain.User, purpose string, ttl time.Duration, newEmail string) (string, error) {
+	if err := u.tokenRepo.DeleteByUserID(user.ID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := infrastructure.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = u.tokenRepo.Create(&domain.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: infrastructure.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+		NewEmail:  newEmail,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeToken marks a token as used and returns it with the user it was
+// issued to.
+// When check is set it runs before the token is consumed and can reject the
+// request without burning the token.
+func (u *userUseCase) consumeToken(token, purpose string, check func(user *domain.User, token *domain.UserToken) error) (*domain.User, *domain.UserToken, error) {
+	stored, err := u.tokenRepo.FindByHash(purpose, infrastructure.HashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil, domain.ErrInvalidToken
+	}
+
+	user, err := u.userRepo.FindByID(stored.UserID.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, domain.ErrInvalidToken
+	}
+
+	if check != nil {
+		if err := check(user, stored); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	marked, err := u.tokenRepo.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !marked {
+		return nil, nil, domain.ErrInvalidToken
+	}
+	return user, stored, nil
+}
+
+// checkPasswordPolicy returns a *domain.ValidationError if password breaks the
+// password policy for user.
+func (u *userUseCase) checkPasswordPolicy(field, password string, user *domain.User) error {
+	if errs := u.passwordPolicy.Validate(field, password, user.Email, user.Name); len(errs) > 0 {
+		return &domain.ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// twoFactorChallengePurpose marks JWTs that only prove the password step of a
+// two-factor login. JWTAuth rejects any token carrying a purpose claim.
+const twoFactorChallengePurpose = "2fa_challenge"
+
+const recoveryCodeCount = 10
+
+// BeginTwoFactorEnrollment generates a new TOTP secret. It only becomes active
+// once confirmed with a valid code.
+func (u *userUseCase) BeginTwoFactorEnrollment(userID string) (string, string, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", domain.ErrUserNotFound
+	}
+	if user.TwoFactor.Enabled {
+		return "", "", errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := infrastructure.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	user.TwoFactor.PendingSecret = secret
+	if err := u.userRepo.Update(user); err != nil {
+		return "", "", err
+	}
+
+	issuer := infrastructure.EnvString("TOTP_ISSUER", "Cognivia")
+	return secret, infrastructure.TOTPURI(issuer, user.Email, secret), nil
+}
+
+// ConfirmTwoFactorEnrollment enables two-factor authentication and returns the
+// plaintext recovery codes. They are only shown once.
+func (u *userUseCase) ConfirmTwoFactorEnrollment(userID, code string) ([]string, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if user.TwoFactor.PendingSecret == "" {
+		return nil, errors.New("no two-factor enrollment in progress")
+	}
+
+	step, ok := infrastructure.ValidateTOTP(user.TwoFactor.PendingSecret, code, u.now())
+	if !ok {
+		return nil, domain.ErrInvalidOTP
+	}
+
+	codes, err := infrastructure.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = infrastructure.HashToken(c)
+	}
+
+	user.TwoFactor = domain.TwoFactorSettings{
+		Enabled:       true,
+		Secret:        user.TwoFactor.PendingSecret,
+		RecoveryCodes: hashes,
+		LastUsedStep:  step,
+	}
+	if err := u.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off. Both the password and
+// a current TOTP or recovery code are required.
+func (u *userUseCase) DisableTwoFactor(userID, password, code string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrUserNotFound
+	}
+	if !u.VerifyPassword(user.Password, password) {
+		return errors.New("invalid credentials")
+	}
+	if !user.TwoFactor.Enabled {
+		return nil
+	}
+	if !u.checkSecondFactor(user, code) {
+		return domain.ErrInvalidOTP
+	}
+
+	user.TwoFactor = domain.TwoFactorSettings{}
+	return u.userRepo.Update(user)
+}
+
+// GenerateTwoFactorChallenge issues a short-lived token proving that the user
+// passed the password step. It cannot be used to call protected routes.
+func (u *userUseCase) GenerateTwoFactorChallenge(user *domain.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":       user.ID.Hex(),
+		"purpose":       twoFactorChallengePurpose,
+		"token_version": user.TokenVersion,
+		"exp":           time.Now().Add(u.challengeTTL).Unix(),
+	})
+
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// CompleteTwoFactorLogin exchanges a challenge token and a TOTP or recovery
+// code for the user, who can then be issued a regular JWT.
+func (u *userUseCase) CompleteTwoFactorLogin(challengeToken, code string) (*domain.User, error) {
+	token, err := jwt.Parse(challengeToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, domain.ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != twoFactorChallengePurpose {
+		return nil, domain.ErrInvalidToken
+	}
+	userID, _ := claims["user_id"].(string)
+	version, _ := claims["token_version"].(float64)
+
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || int(version) != user.TokenVersion || !user.TwoFactor.Enabled {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Codes are short, so guesses count towards the account lockout too
+	if _, err := u.loginThrottle.Check(user.Email, ""); err != nil {
+		return nil, err
+	}
+	if !u.checkSecondFactor(user, code) {
+		if err := u.loginThrottle.RecordFailure(user.Email, ""); err != nil {
+			log.Printf("Error recording failed login: %v", err)
+		}
+		return nil, domain.ErrInvalidOTP
+	}
+	if err := u.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := u.loginThrottle.RecordSuccess(user.Email, ""); err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+	}
+
+	return user, nil
+}
+
+// checkSecondFactor accepts either a TOTP code that has not been used before
+// or an unused recovery code. The caller must persist the user afterwards.
+func (u *userUseCase) checkSecondFactor(user *domain.User, code string) bool {
+	code = strings.TrimSpace(code)
+
+	if step, ok := infrastructure.ValidateTOTP(user.TwoFactor.Secret, code, u.now()); ok {
+		if step <= user.TwoFactor.LastUsedStep {
+			return false
+		}
+		user.TwoFactor.LastUsedStep = step
+		return true
+	}
+
+	hash := infrastructure.HashToken(strings.ToLower(code))
+	if i := slices.Index(user.TwoFactor.RecoveryCodes, hash); i >= 0 {
+		user.TwoFactor.RecoveryCodes = slices.Delete(user.TwoFactor.RecoveryCodes, i, i+1)
+		return true
+	}
+	return false
+}
+
+func (u *userUseCase) ChangePassword(userID, currentPassword, newPassword string) (*domain.User, error) {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	if !u.VerifyPassword(user.Password, currentPassword) {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "current_password", Code: "incorrect", Message: "is incorrect",
+		}}}
+	}
+	if err := u.checkPasswordPolicy("new_password", newPassword, user); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := u.passwordService.PasswordHasher(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hashedPassword
+	user.TokenVersion++
+
+	if err := u.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := u.revokeCredentialTokens(user); err != nil {
+		return nil, err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nThe password of your Cognivia account was just changed, all other sessions were signed out and your API keys were revoked.\n\n"+
+			"If you did not do this, reset your password right away.\n",
+		user.Name,
+	)
+	if err := u.mailer.Send(user.Email, "Your Cognivia password was changed", body); err != nil {
+		log.Printf("Error sending password change notification: %v", err)
+	}
+
+	return user, nil
+}
+
+// RequestEmailChange emails a confirmation link to the new address. The email
+// only changes once that link is followed; the current address is told about
+// the request.
+func (u *userUseCase) RequestEmailChange(userID, currentPassword, newEmail string) error {
+	user, err := u.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrUserNotFound
+	}
+
+	if !u.VerifyPassword(user.Password, currentPassword) {
+		return &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "current_password", Code: "incorrect", Message: "is incorrect",
+		}}}
+	}
+	if err := u.checkEmailAvailable(user, newEmail); err != nil {
+		return err
+	}
+
+	token, err := u.issueToken(user, domain.TokenPurposeEmailChange, u.verifyTokenTTL, newEmail)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/confirm-email-change?token=%s", os.Getenv("APP_BASE_URL"), token)
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm that you want to use this address for your Cognivia account:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+		user.Name, link, u.verifyTokenTTL,
+	)
+	if err := u.mailer.Send(newEmail, "Confirm your new Cognivia email address", body); err != nil {
+		return err
+	}
+
+	notice := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to change the email address of your Cognivia account to %s. "+
+			"Nothing changes until the new address is confirmed.\n\n"+
+			"If this was not you, change your password right away.\n",
+		user.Name, newEmail,
+	)
+	if err := u.mailer.Send(user.Email, "Email change requested for your Cognivia account", notice); err != nil {
+		log.Printf("Error sending email change notification: %v", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the confirmed address and signs
+// out all sessions.
+func (u *userUseCase) ConfirmEmailChange(token string) error {
+	user, stored, err := u.consumeToken(token, domain.TokenPurposeEmailChange, func(user *domain.User, stored *domain.UserToken) error {
+		// The address may have been taken since the change was requested
+		return u.checkEmailAvailable(user, stored.NewEmail)
+	})
+	if err != nil {
+		return err
+	}
+	newEmail := stored.NewEmail
+
+	oldEmail := user.Email
+	user.Email = newEmail
+	user.EmailVerified = true
+	user.TokenVersion++
+
+	if err := u.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := u.revokeCredentialTokens(user); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nThe email address of your Cognivia account was changed to %s, all sessions were signed out and your API keys were revoked.\n\n"+
+			"If you did not do this, contact support right away.\n",
+		user.Name, newEmail,
+	)
+	if err := u.mailer.Send(oldEmail, "Your Cognivia email address was changed", body); err != nil {
+		log.Printf("Error sending email change notification: %v", err)
+	}
+
+	return nil
+}
+
+func (u *userUseCase) checkEmailAvailable(user *domain.User, email string) error {
+	if email == user.Email {
+		return &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "new_email", Code: "unchanged", Message: "is already your email address",
+		}}}
+	}
+
+	existing, err := u.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return &domain.ValidationError{Fields: []domain.FieldError{{
+			Field: "new_email", Code: "taken", Message: "is already used by another account",
+		}}}
+	}
+	return nil
+}
+
+// revokeCredentialTokens deletes every outstanding emailed token of the user
+// and revokes the user's API keys after a credential change, so keys minted
+// by someone who had taken over the account stop working. Callers bump
+// TokenVersion to revoke JWTs.
+func (u *userUseCase) revokeCredentialTokens(user *domain.User) error {
+	return revokeCredentials(u.tokenRepo, u.apiKeyRepo, user)
+}
+
+func revokeCredentials(tokenRepo domain.UserTokenRepository, apiKeyRepo domain.APIKeyRepository, user *domain.User) error {
+	for _, purpose := range []string{
+		domain.TokenPurposePasswordReset,
+		domain.TokenPurposeEmailChange,
+	} {
+		if err := tokenRepo.DeleteByUserID(user.ID, purpose); err != nil {
+			return err
+		}
+	}
+	return apiKeyRepo.RevokeAllByUserID(user.ID)
+}
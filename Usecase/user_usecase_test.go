@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+	mongodb "cognivia-api/Repositories"
+)
+
+// The secret of the RFC 6238 test vectors. Its code is 081804 from 1111111080
+// to 1111111109 (step 37037036) and 050471 in the 30 seconds after.
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// newTwoFactorFixture enrolls a user with testTOTPSecret using the code 081804
+// and returns the use case, the user and the recovery codes
+func newTwoFactorFixture(t *testing.T) (*userUseCase, *memoryUserRepository, *domain.User, []string, func(int64)) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	users := newMemoryUserRepository()
+	throttle := NewLoginThrottle(mongodb.NewInMemoryLoginAttemptRepository(), users, &recordingMailer{})
+	u := NewUserUseCase(users, nil, nil, &recordingMailer{}, throttle).(*userUseCase)
+	now := time.Unix(1111111100, 0)
+	u.now = func() time.Time { return now }
+
+	user := &domain.User{Email: "ada@example.com", TwoFactor: domain.TwoFactorSettings{PendingSecret: testTOTPSecret}}
+	users.Create(user)
+	codes, err := u.ConfirmTwoFactorEnrollment(user.ID.Hex(), "081804")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ = users.FindByID(user.ID.Hex())
+	return u, users, user, codes, func(unix int64) { now = time.Unix(unix, 0) }
+}
+
+// login completes a two-factor login with code
+func login(u *userUseCase, user *domain.User, code string) error {
+	challenge, err := u.GenerateTwoFactorChallenge(user)
+	if err != nil {
+		return err
+	}
+	_, err = u.CompleteTwoFactorLogin(challenge, code)
+	return err
+}
+
+func TestTwoFactorLoginRejectsReplayedCodes(t *testing.T) {
+	u, users, user, _, setClock := newTwoFactorFixture(t)
+	if !user.TwoFactor.Enabled || user.TwoFactor.Secret != testTOTPSecret || user.TwoFactor.LastUsedStep != 37037036 {
+		t.Fatalf("enrolled %+v", user.TwoFactor)
+	}
+
+	for _, tc := range []struct {
+		name string
+		unix int64
+		code string
+		ok   bool
+	}{
+		{"code used for enrollment", 1111111100, "081804", false},
+		{"code of the next step", 1111111100, "050471", true},
+		{"same code again", 1111111100, "050471", false},
+		{"same code in its own step", 1111111115, "050471", false},
+		{"older code within the skew", 1111111115, "081804", false},
+		{"wrong code", 1111111115, "123456", false},
+	} {
+		setClock(tc.unix)
+		err := login(u, user, tc.code)
+		if (err == nil) != tc.ok || (err != nil && !errors.Is(err, domain.ErrInvalidOTP)) {
+			t.Errorf("%s: got %v", tc.name, err)
+		}
+	}
+
+	stored, _ := users.FindByID(user.ID.Hex())
+	if stored.TwoFactor.LastUsedStep != 37037037 {
+		t.Errorf("last used step %d, want 37037037", stored.TwoFactor.LastUsedStep)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	u, users, user, codes, _ := newTwoFactorFixture(t)
+	if len(codes) != recoveryCodeCount || len(user.TwoFactor.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, stored %d", len(codes), len(user.TwoFactor.RecoveryCodes))
+	}
+	for _, hash := range user.TwoFactor.RecoveryCodes {
+		for _, code := range codes {
+			if strings.Contains(hash, code) {
+				t.Fatal("recovery codes are stored in plain text")
+			}
+		}
+	}
+
+	// Codes are accepted as typed from the printout
+	if err := login(u, user, " "+strings.ToUpper(codes[0])+" "); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := login(u, user, codes[0]); !errors.Is(err, domain.ErrInvalidOTP) {
+		t.Errorf("second use: got %v", err)
+	}
+	if err := login(u, user, codes[1]); err != nil {
+		t.Errorf("another code: %v", err)
+	}
+
+	stored, _ := users.FindByID(user.ID.Hex())
+	if len(stored.TwoFactor.RecoveryCodes) != recoveryCodeCount-2 {
+		t.Errorf("%d codes left, want %d", len(stored.TwoFactor.RecoveryCodes), recoveryCodeCount-2)
+	}
+}
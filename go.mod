@@ -40,9 +40,9 @@ require (
 	go.mongodb.org/mongo-driver v1.17.3
 	golang.org/x/arch v0.8.0 // indirect
 	golang.org/x/crypto v0.38.0
-	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/net v0.25.0
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.25.0 // indirect
+	golang.org/x/text v0.25.0
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,117 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// NewBlobStore picks a blob store from BLOB_STORE ("local" or "s3"). Files
+// are kept on the local disk by default so development needs no object
+// storage. An incomplete S3 configuration stops the server.
+func NewBlobStore() domain.BlobStore {
+	switch store := EnvString("BLOB_STORE", "local"); store {
+	case "s3":
+		s3Store, err := NewS3BlobStore(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          EnvString("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		}, &http.Client{Timeout: 5 * time.Minute})
+		if err != nil {
+			log.Fatal("Failed to set up the S3 blob store: ", err)
+		}
+		return s3Store
+	default:
+		if store != "local" {
+			log.Printf("Unknown BLOB_STORE %q, storing files on the local disk", store)
+		}
+		return NewLocalBlobStore(EnvString("BLOB_STORE_DIR", "data/blobs"))
+	}
+}
+
+// localBlobStore keeps each blob in a file under dir, at the path given by
+// its key
+type localBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) domain.BlobStore {
+	return &localBlobStore{dir: dir}
+}
+
+// path maps a key to a file below the store directory. Keys that would
+// escape it are rejected.
+func (s *localBlobStore) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.dir, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return p, nil
+}
+
+// Put writes to a temporary file first so readers never see a partial blob
+func (s *localBlobStore) Put(key string, content io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("blob %s: wrote %d bytes, expected %d", key, written, size)
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localBlobStore) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, domain.ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *localBlobStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// NewContentGenerator picks a generator from CONTENT_GENERATOR ("stub" or
+// "openai"). The stub is the default so development needs no model API.
+func NewContentGenerator() domain.ContentGenerator {
+	switch generator := EnvString("CONTENT_GENERATOR", "stub"); generator {
+	case "openai":
+		return NewOpenAIContentGenerator(OpenAIConfig{
+			BaseURL: EnvString("GENERATION_BASE_URL", "https://api.openai.com/v1"),
+			APIKey:  os.Getenv("GENERATION_API_KEY"),
+			Model:   EnvString("GENERATION_MODEL", "gpt-4o-mini"),
+		}, &http.Client{Timeout: EnvDuration("GENERATION_TIMEOUT", 3*time.Minute)})
+	default:
+		if generator != "stub" {
+			log.Printf("Unknown CONTENT_GENERATOR %q, using the stub generator", generator)
+		}
+		return NewStubContentGenerator()
+	}
+}
+
+// OpenAIConfig points at an endpoint that implements OpenAI's chat
+// completions API with structured output
+type OpenAIConfig struct {
+	// BaseURL is the API root, e.g. https://api.openai.com/v1
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+type openAIContentGenerator struct {
+	config     OpenAIConfig
+	httpClient *http.Client
+}
+
+func NewOpenAIContentGenerator(config OpenAIConfig, httpClient *http.Client) domain.ContentGenerator {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &openAIContentGenerator{config: config, httpClient: httpClient}
+}
+
+const snapnotesInstructions = `You write study notes for students. For every chapter of the text give
+a summary of a few sentences and the key points as short statements. Add
+flashcards for the important terms of a chapter, each with the term and a
+one-sentence definition; leave out chapters without such terms. Use the
+chapter titles of the text and write in the language of the text. Only use
+facts stated in the text.`
+
+const prepPilotInstructions = `You write exam preparation questions for students. For every chapter of
+the text write multiple-choice questions with four options, A to D, of which
+exactly one is correct. Give the letter of the correct option and an
+explanation that refers to the text. Use the chapter titles of the text and
+write in the language of the text. Only ask about facts stated in the text.`
+
+func (g *openAIContentGenerator) GenerateSnapnotes(input domain.GenerationInput) ([]byte, error) {
+	return g.complete(snapnotesInstructions, input, "snapnotes", SnapnotesSchema)
+}
+
+func (g *openAIContentGenerator) GeneratePrepPilot(input domain.GenerationInput) ([]byte, error) {
+	return g.complete(prepPilotInstructions, input, "prep_pilot", PrepPilotSchema)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string             `json:"model"`
+	Messages       []chatMessage      `json:"messages"`
+	ResponseFormat chatResponseFormat `json:"response_format"`
+}
+
+type chatResponseFormat struct {
+	Type       string         `json:"type"`
+	JSONSchema chatJSONSchema `json:"json_schema"`
+}
+
+type chatJSONSchema struct {
+	Name   string      `json:"name"`
+	Strict bool        `json:"strict"`
+	Schema *JSONSchema `json:"schema"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		FinishReason string `json:"finish_reason"`
+		Message      struct {
+			Content *string `json:"content"`
+			Refusal *string `json:"refusal"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// complete asks the model for a document matching schema. The endpoint is
+// asked to enforce the schema, but the caller still validates the result as
+// compatible endpoints may ignore it.
+func (g *openAIContentGenerator) complete(instructions string, input domain.GenerationInput, name string, schema *JSONSchema) ([]byte, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: g.config.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: instructions},
+			{Role: "user", Content: generationPrompt(input)},
+		},
+		ResponseFormat: chatResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: chatJSONSchema{Name: name, Strict: true, Schema: schema},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.config.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.config.APIKey)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("generation endpoint returned %s: %s", resp.Status, apiErrorMessage(resp.Body))
+	}
+
+	var completion chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("invalid response from the generation endpoint: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("the generation endpoint returned no choices")
+	}
+	choice := completion.Choices[0]
+	switch {
+	case choice.Message.Refusal != nil && *choice.Message.Refusal != "":
+		return nil, fmt.Errorf("the model refused: %s", *choice.Message.Refusal)
+	case choice.FinishReason == "length":
+		return nil, errors.New("the model ran out of output tokens")
+	case choice.Message.Content == nil:
+		return nil, errors.New("the model returned no content")
+	}
+	return []byte(*choice.Message.Content), nil
+}
+
+// generationPrompt lays out the text with its chapter titles as Markdown
+// headings
+func generationPrompt(input domain.GenerationInput) string {
+	var prompt strings.Builder
+	if input.Title != "" {
+		prompt.WriteString("# " + input.Title + "\n\n")
+	}
+	for _, chapter := range input.Chapters {
+		if chapter.Title != "" {
+			prompt.WriteString("## " + chapter.Title + "\n\n")
+		}
+		prompt.WriteString(chapter.Text + "\n\n")
+	}
+	return prompt.String()
+}
+
+// apiErrorMessage reads the message of an OpenAI-style error response,
+// falling back to the start of the body
+func apiErrorMessage(body io.Reader) string {
+	content, _ := io.ReadAll(io.LimitReader(body, 4096))
+	var apiError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(content, &apiError) == nil && apiError.Error.Message != "" {
+		return apiError.Error.Message
+	}
+	return strings.TrimSpace(string(content))
+}
@@ -0,0 +1,166 @@
+package infrastructure
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDOCXPartSize bounds a decompressed part of a DOCX archive
+const maxDOCXPartSize = 64 << 20
+
+// docxBlocks reads the paragraphs of a Word document. Paragraphs styled as
+// the title or as headings, directly or through their outline level, become
+// headings one level below the title.
+func docxBlocks(content []byte) ([]textBlock, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	styles := map[string]int{}
+	if part, err := openZipPart(archive, "word/styles.xml"); err == nil {
+		styles = docxHeadingStyles(part)
+		part.Close()
+	}
+
+	part, err := openZipPart(archive, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	var blocks []textBlock
+	var text strings.Builder
+	level, listItem := 0, false
+	decoder := xml.NewDecoder(io.LimitReader(part, maxDOCXPartSize))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "p":
+				text.Reset()
+				level, listItem = 0, false
+			case "pStyle":
+				if styleLevel, ok := styles[xmlAttr(token, "val")]; ok {
+					level = styleLevel
+				} else {
+					level = docxStyleNameLevel(xmlAttr(token, "val"))
+				}
+			case "outlineLvl":
+				if level == 0 {
+					level = docxOutlineLevel(xmlAttr(token, "val"))
+				}
+			case "numPr":
+				listItem = true
+			case "t":
+				var t string
+				if err := decoder.DecodeElement(&t, &token); err != nil {
+					return nil, err
+				}
+				text.WriteString(t)
+			case "tab":
+				text.WriteString(" ")
+			case "br", "cr":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			if token.Name.Local != "p" {
+				continue
+			}
+			paragraph := text.String()
+			if listItem && level == 0 {
+				paragraph = "- " + paragraph
+			}
+			blocks = append(blocks, textBlock{level: level, text: paragraph, listItem: listItem && level == 0})
+		}
+	}
+}
+
+// docxHeadingStyles maps the IDs of heading styles to their level. Style IDs
+// are localized, so styles are recognized by their built-in name or outline
+// level.
+func docxHeadingStyles(r io.Reader) map[string]int {
+	levels := map[string]int{}
+	decoder := xml.NewDecoder(io.LimitReader(r, maxDOCXPartSize))
+
+	styleID := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return levels
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		case "style":
+			styleID = xmlAttr(element, "styleId")
+		case "name":
+			if level := docxStyleNameLevel(xmlAttr(element, "val")); level > 0 && styleID != "" {
+				levels[styleID] = level
+			}
+		case "outlineLvl":
+			if _, named := levels[styleID]; !named && styleID != "" {
+				if level := docxOutlineLevel(xmlAttr(element, "val")); level > 0 {
+					levels[styleID] = level
+				}
+			}
+		}
+	}
+}
+
+var docxHeadingName = regexp.MustCompile(`(?i)^heading ?([1-9])$`)
+
+func docxStyleNameLevel(name string) int {
+	if strings.EqualFold(name, "title") {
+		return 1
+	}
+	if match := docxHeadingName.FindStringSubmatch(name); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		return min(n+1, 6)
+	}
+	return 0
+}
+
+// docxOutlineLevel converts a 0-based outline level, where 9 means body
+// text, to a heading level
+func docxOutlineLevel(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 8 {
+		return 0
+	}
+	return min(n+2, 6)
+}
+
+func openZipPart(archive *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, file := range archive.File {
+		if file.Name == name {
+			return file.Open()
+		}
+	}
+	return nil, errors.New("missing " + name)
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
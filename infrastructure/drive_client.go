@@ -0,0 +1,148 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// NewDriveClient reads files shared with anyone who has the link through the
+// Google Drive API at GOOGLE_DRIVE_BASE_URL, which can point at a fake server
+// in development
+func NewDriveClient() domain.DriveClient {
+	if os.Getenv("GOOGLE_DRIVE_ACCESS_TOKEN") != "" {
+		log.Println("GOOGLE_DRIVE_ACCESS_TOKEN is ignored, only files shared with anyone who has the link can be imported")
+	}
+	return NewGoogleDriveClient(DriveConfig{
+		BaseURL: EnvString("GOOGLE_DRIVE_BASE_URL", "https://www.googleapis.com/drive/v3"),
+		APIKey:  os.Getenv("GOOGLE_DRIVE_API_KEY"),
+	}, &http.Client{Timeout: EnvDuration("GOOGLE_DRIVE_TIMEOUT", 2*time.Minute)})
+}
+
+// DriveConfig points at an endpoint that implements the files API of Google
+// Drive v3
+type DriveConfig struct {
+	// BaseURL is the API root, e.g. https://www.googleapis.com/drive/v3
+	BaseURL string
+	// APIKey gives access to files shared with anyone who has the link.
+	// Requests carry no credentials of an account: any user could link a
+	// file that account can read and import it into their notebook.
+	APIKey string
+}
+
+// driveExports maps the Google Docs types to the format they are exported
+// in and its file extension
+var driveExports = map[string]struct{ mimeType, extension string }{
+	"application/vnd.google-apps.document":     {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx"},
+	"application/vnd.google-apps.presentation": {"application/pdf", ".pdf"},
+	"application/vnd.google-apps.spreadsheet":  {"application/pdf", ".pdf"},
+	"application/vnd.google-apps.drawing":      {"application/pdf", ".pdf"},
+}
+
+type googleDriveClient struct {
+	config     DriveConfig
+	httpClient *http.Client
+}
+
+func NewGoogleDriveClient(config DriveConfig, httpClient *http.Client) domain.DriveClient {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &googleDriveClient{config: config, httpClient: httpClient}
+}
+
+type driveFileResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	MimeType     string    `json:"mimeType"`
+	MD5Checksum  string    `json:"md5Checksum"`
+	Version      string    `json:"version"`
+	ModifiedTime time.Time `json:"modifiedTime"`
+}
+
+func (c *googleDriveClient) GetFile(fileID string) (*domain.DriveFile, error) {
+	query := url.Values{
+		"fields":            {"id,name,mimeType,md5Checksum,version,modifiedTime"},
+		"supportsAllDrives": {"true"},
+	}
+	resp, err := c.get("/files/"+url.PathEscape(fileID), query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var metadata driveFileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid response from google drive: %w", err)
+	}
+
+	// Uploaded files have a checksum of their content. Google Docs have
+	// none, their version also changes with comments and sharing.
+	file := &domain.DriveFile{
+		ID:         metadata.ID,
+		Name:       metadata.Name,
+		MimeType:   metadata.MimeType,
+		Revision:   metadata.MD5Checksum,
+		ModifiedAt: metadata.ModifiedTime,
+	}
+	if file.Revision == "" {
+		file.Revision = metadata.Version
+	}
+	if file.Revision == "" {
+		file.Revision = metadata.ModifiedTime.UTC().Format(time.RFC3339Nano)
+	}
+	if export, ok := driveExports[metadata.MimeType]; ok && !strings.EqualFold(path.Ext(file.Name), export.extension) {
+		file.Name += export.extension
+	}
+	return file, nil
+}
+
+func (c *googleDriveClient) Download(file *domain.DriveFile) (io.ReadCloser, error) {
+	if export, ok := driveExports[file.MimeType]; ok {
+		resp, err := c.get("/files/"+url.PathEscape(file.ID)+"/export", url.Values{"mimeType": {export.mimeType}})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+
+	resp, err := c.get("/files/"+url.PathEscape(file.ID), url.Values{"alt": {"media"}, "supportsAllDrives": {"true"}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get returns the response of a successful request, whose body the caller
+// closes
+func (c *googleDriveClient) get(resource string, query url.Values) (*http.Response, error) {
+	if c.config.APIKey != "" {
+		query.Set("key", c.config.APIKey)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.config.BaseURL+resource+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	// Drive answers 404 for files the caller may not see as well
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, domain.ErrDriveFileNotFound
+	}
+	return nil, fmt.Errorf("google drive returned %s: %s", resp.Status, apiErrorMessage(resp.Body))
+}
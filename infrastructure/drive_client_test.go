@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// newFakeDrive serves the files API for a few files and records the
+// requests it got
+func newFakeDrive(t *testing.T) (domain.DriveClient, *[]*http.Request) {
+	t.Helper()
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]map[string]any{
+		"pdf-file": {"id": "pdf-file", "name": "Lecture.pdf", "mimeType": "application/pdf", "md5Checksum": "5d41402abc4b2a76b9719d911017c592", "version": "7", "modifiedTime": modified},
+		"doc-file": {"id": "doc-file", "name": "Notes", "mimeType": "application/vnd.google-apps.document", "version": "12", "modifiedTime": modified},
+		"old-file": {"id": "old-file", "name": "Old.txt", "mimeType": "text/plain", "modifiedTime": modified},
+	}
+	requests := []*http.Request{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.URL.Query().Get("key") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"message":"The request is missing a valid API key."}}`))
+			return
+		}
+		id, export := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/drive/v3/files/"), "/export")
+		file, ok := files[id]
+		switch {
+		case !ok:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"message":"File not found."}}`))
+		case export:
+			w.Write([]byte("exported " + r.URL.Query().Get("mimeType")))
+		case r.URL.Query().Get("alt") == "media":
+			w.Write([]byte("content of " + id))
+		default:
+			json.NewEncoder(w).Encode(file)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return NewGoogleDriveClient(DriveConfig{BaseURL: server.URL + "/drive/v3/", APIKey: "test-key"}, server.Client()), &requests
+}
+
+func TestDriveGetFile(t *testing.T) {
+	client, requests := newFakeDrive(t)
+
+	for _, tc := range []struct {
+		id, name, revision string
+	}{
+		// Uploaded files are identified by their checksum
+		{"pdf-file", "Lecture.pdf", "5d41402abc4b2a76b9719d911017c592"},
+		// Google Docs have no checksum and get the extension of their export
+		{"doc-file", "Notes.docx", "12"},
+		{"old-file", "Old.txt", "2026-03-01T12:00:00Z"},
+	} {
+		file, err := client.GetFile(tc.id)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.id, err)
+		}
+		if file.ID != tc.id || file.Name != tc.name || file.Revision != tc.revision {
+			t.Errorf("%s: got %+v", tc.id, file)
+		}
+	}
+
+	for _, r := range *requests {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("a request carried account credentials")
+		}
+		if r.URL.Query().Get("supportsAllDrives") != "true" || !strings.Contains(r.URL.Query().Get("fields"), "md5Checksum") {
+			t.Errorf("unexpected metadata query %s", r.URL.RawQuery)
+		}
+	}
+}
+
+func TestDriveErrors(t *testing.T) {
+	client, _ := newFakeDrive(t)
+
+	if _, err := client.GetFile("missing"); !errors.Is(err, domain.ErrDriveFileNotFound) {
+		t.Errorf("missing file: got %v", err)
+	}
+	if _, err := client.Download(&domain.DriveFile{ID: "missing", MimeType: "application/pdf"}); !errors.Is(err, domain.ErrDriveFileNotFound) {
+		t.Errorf("downloading a missing file: got %v", err)
+	}
+
+	unauthorized := NewGoogleDriveClient(DriveConfig{BaseURL: client.(*googleDriveClient).config.BaseURL}, http.DefaultClient)
+	_, err := unauthorized.GetFile("pdf-file")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "missing a valid API key") {
+		t.Errorf("refused request: got %v", err)
+	}
+}
+
+func TestDriveDownload(t *testing.T) {
+	client, requests := newFakeDrive(t)
+
+	for _, tc := range []struct {
+		mimeType, content string
+	}{
+		{"application/pdf", "content of pdf-file"},
+		{"application/vnd.google-apps.document", "exported application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"application/vnd.google-apps.presentation", "exported application/pdf"},
+		{"application/vnd.google-apps.spreadsheet", "exported application/pdf"},
+	} {
+		id := "pdf-file"
+		if strings.HasPrefix(tc.mimeType, "application/vnd.google-apps.") {
+			id = "doc-file"
+		}
+		body, err := client.Download(&domain.DriveFile{ID: id, MimeType: tc.mimeType})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.mimeType, err)
+		}
+		content, _ := io.ReadAll(body)
+		body.Close()
+		if string(content) != tc.content {
+			t.Errorf("%s: got %q, want %q", tc.mimeType, content, tc.content)
+		}
+	}
+
+	if last := (*requests)[len(*requests)-1]; !strings.HasSuffix(last.URL.Path, "/files/doc-file/export") {
+		t.Errorf("Google Docs were not exported: %s", last.URL.Path)
+	}
+}
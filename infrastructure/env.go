@@ -0,0 +1,62 @@
+package infrastructure
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	domain "cognivia-api/Domain"
+)
+
+// EnvString returns the value of key, or def when it is unset.
+func EnvString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvDuration parses key as a time.Duration (e.g. "30m"), falling back to def
+// when it is unset or malformed.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid duration for %s: %v, using %s", key, err, def)
+		return def
+	}
+	return d
+}
+
+// EnvInt parses key as an integer, falling back to def when it is unset or
+// malformed.
+func EnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid integer for %s: %v, using %d", key, err, def)
+		return def
+	}
+	return n
+}
+
+// UnverifiedUserPolicy returns UNVERIFIED_USER_POLICY, defaulting to allowing
+// unverified users full access.
+func UnverifiedUserPolicy() string {
+	switch policy := os.Getenv("UNVERIFIED_USER_POLICY"); policy {
+	case domain.UnverifiedPolicyReadOnly, domain.UnverifiedPolicyDeny:
+		return policy
+	case "", domain.UnverifiedPolicyAllow:
+		return domain.UnverifiedPolicyAllow
+	default:
+		log.Printf("Unknown UNVERIFIED_USER_POLICY %q, allowing unverified users", policy)
+		return domain.UnverifiedPolicyAllow
+	}
+}
@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlBlocks reads headings, paragraphs, list items and preformatted text
+// from an HTML document. Scripts, styles and navigation are skipped.
+func htmlBlocks(content []byte) ([]textBlock, error) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	walker := &htmlWalker{}
+	walker.walk(doc)
+	walker.flush(false)
+	return walker.blocks, nil
+}
+
+type htmlWalker struct {
+	blocks []textBlock
+	text   strings.Builder
+}
+
+// htmlBlockElements end the paragraph before and after them
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Caption: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Hr: true, atom.Address: true, atom.Details: true, atom.Summary: true, atom.Form: true,
+	atom.Body: true,
+}
+
+// htmlSkippedElements hold no readable text
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true, atom.Nav: true,
+	atom.Button: true, atom.Select: true, atom.Textarea: true,
+}
+
+var htmlHeadingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch {
+		case htmlSkippedElements[n.DataAtom]:
+			return
+		case htmlHeadingLevels[n.DataAtom] > 0:
+			w.flush(false)
+			w.blocks = append(w.blocks, textBlock{level: htmlHeadingLevels[n.DataAtom], text: htmlText(n)})
+			return
+		case n.DataAtom == atom.Pre:
+			w.flush(false)
+			w.blocks = append(w.blocks, textBlock{text: htmlText(n), preformatted: true})
+			return
+		case n.DataAtom == atom.Li:
+			w.flush(false)
+			w.text.WriteString("- ")
+			w.walkChildren(n)
+			w.flush(true)
+			return
+		case n.DataAtom == atom.Br:
+			w.text.WriteString("\n")
+			return
+		case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+			w.text.WriteString(" ")
+			w.walkChildren(n)
+			w.text.WriteString(" ")
+			return
+		case n.DataAtom == atom.Img:
+			for _, attr := range n.Attr {
+				if attr.Key == "alt" && attr.Val != "" {
+					w.text.WriteString(" " + attr.Val + " ")
+				}
+			}
+			return
+		case htmlBlockElements[n.DataAtom]:
+			w.flush(false)
+			w.walkChildren(n)
+			w.flush(false)
+			return
+		}
+	}
+	w.walkChildren(n)
+}
+
+func (w *htmlWalker) walkChildren(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		w.walk(child)
+	}
+}
+
+func (w *htmlWalker) flush(listItem bool) {
+	text := w.text.String()
+	w.text.Reset()
+	if strings.TrimSpace(strings.TrimPrefix(text, "- ")) == "" {
+		return
+	}
+	w.blocks = append(w.blocks, textBlock{text: text, listItem: listItem})
+}
+
+// htmlText returns the text below n, skipping elements without readable text
+func htmlText(n *html.Node) string {
+	var text strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			text.WriteString(n.Data)
+		case n.Type == html.ElementNode && htmlSkippedElements[n.DataAtom]:
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			text.WriteString("\n")
+		default:
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				collect(child)
+			}
+		}
+	}
+	collect(n)
+	return text.String()
+}
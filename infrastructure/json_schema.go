@@ -0,0 +1,189 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JSONSchema is the subset of JSON Schema that generated content is checked
+// against. It marshals to a schema that OpenAI-compatible endpoints accept
+// for structured output in strict mode.
+type JSONSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+}
+
+// strictObject describes an object whose properties are all required and
+// that allows no others
+func strictObject(properties map[string]*JSONSchema) *JSONSchema {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	closed := false
+	return &JSONSchema{Type: "object", Properties: properties, Required: required, AdditionalProperties: &closed}
+}
+
+func arrayOf(items *JSONSchema, minItems int) *JSONSchema {
+	return &JSONSchema{Type: "array", Items: items, MinItems: &minItems}
+}
+
+// nonEmptyString rejects strings without any visible character
+var nonEmptyString = &JSONSchema{Type: "string", Pattern: `\S`}
+
+// SnapnotesSchema describes generated snapnotes
+var SnapnotesSchema = strictObject(map[string]*JSONSchema{
+	"title": nonEmptyString,
+	"summaryByChapter": arrayOf(strictObject(map[string]*JSONSchema{
+		"chapterTitle": nonEmptyString,
+		"summary":      nonEmptyString,
+		"keyPoints":    arrayOf(nonEmptyString, 1),
+	}), 1),
+	"flashcards": arrayOf(strictObject(map[string]*JSONSchema{
+		"chapterTitle": nonEmptyString,
+		"flashcards": arrayOf(strictObject(map[string]*JSONSchema{
+			"key term":   nonEmptyString,
+			"definition": nonEmptyString,
+		}), 1),
+	}), 0),
+})
+
+// PrepPilotSchema describes a generated prep pilot
+var PrepPilotSchema = strictObject(map[string]*JSONSchema{
+	"chapters": arrayOf(strictObject(map[string]*JSONSchema{
+		"chapterTitle": nonEmptyString,
+		"questions": arrayOf(strictObject(map[string]*JSONSchema{
+			"question": nonEmptyString,
+			"options": strictObject(map[string]*JSONSchema{
+				"A": nonEmptyString,
+				"B": nonEmptyString,
+				"C": nonEmptyString,
+				"D": nonEmptyString,
+			}),
+			"answer":      {Type: "string", Enum: []string{"A", "B", "C", "D"}},
+			"explanation": nonEmptyString,
+		}), 1),
+	}), 1),
+})
+
+// SchemaError lists every place where a document does not match its schema
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "does not match the schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that data is a single JSON value matching the schema
+func (s *JSONSchema) Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid JSON: unexpected data after the document")
+	}
+
+	var problems []string
+	s.check("", value, &problems)
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *JSONSchema) check(path string, value any, problems *[]string) {
+	fail := func(format string, args ...any) {
+		location := path
+		if location == "" {
+			location = "document"
+		}
+		*problems = append(*problems, location+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				fail("%q is required", name)
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("%q is not allowed", name)
+				}
+				continue
+			}
+			property.check(strings.TrimPrefix(path+"."+name, "."), object[name], problems)
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.MinItems != nil && len(array) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				s.Items.check(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			fail("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+		if s.Pattern != "" && !compiledPattern(s.Pattern).MatchString(str) {
+			fail("must match %s", s.Pattern)
+		}
+	default:
+		fail("unsupported schema type %q", s.Type)
+	}
+}
+
+var schemaPatterns sync.Map
+
+func compiledPattern(pattern string) *regexp.Regexp {
+	if re, ok := schemaPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	schemaPatterns.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,148 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	domain "cognivia-api/Domain"
+)
+
+var generationInput = domain.GenerationInput{
+	Title: "Biology",
+	Chapters: []domain.TextChapter{
+		{Title: "Cells", Text: "A cell is the smallest unit of life. Cells contain cytoplasm surrounded by a membrane. " +
+			"The nucleus is the organelle that holds the genetic material. Mitochondria produce energy for the cell.\n\n" +
+			"- Prokaryotes have no nucleus.\n- Eukaryotes keep their chromosomes inside a nucleus."},
+		{Text: "Photosynthesis is the process that turns sunlight into chemical energy. Chlorophyll absorbs light " +
+			"in the chloroplasts. Plants release oxygen as a byproduct of photosynthesis."},
+	},
+}
+
+func TestStubOutputMatchesSchemas(t *testing.T) {
+	generator := NewStubContentGenerator()
+
+	snapnotes, err := generator.GenerateSnapnotes(generationInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SnapnotesSchema.Validate(snapnotes); err != nil {
+		t.Errorf("snapnotes: %v\n%s", err, snapnotes)
+	}
+	again, _ := generator.GenerateSnapnotes(generationInput)
+	if !bytes.Equal(snapnotes, again) {
+		t.Error("the same text gave different snapnotes")
+	}
+
+	prepPilot, err := generator.GeneratePrepPilot(generationInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PrepPilotSchema.Validate(prepPilot); err != nil {
+		t.Errorf("prep pilot: %v\n%s", err, prepPilot)
+	}
+
+	var decoded generatedSnapnotes
+	json.Unmarshal(snapnotes, &decoded)
+	if decoded.Title != "Biology" || len(decoded.SummaryByChapter) != 2 || decoded.SummaryByChapter[1].ChapterTitle != "Part 2" {
+		t.Errorf("unexpected snapnotes %s", snapnotes)
+	}
+
+	empty := domain.GenerationInput{Chapters: []domain.TextChapter{{Title: "Empty", Text: " \n "}}}
+	if _, err := generator.GenerateSnapnotes(empty); err == nil {
+		t.Error("snapnotes of an empty text")
+	}
+	if _, err := generator.GeneratePrepPilot(empty); err == nil {
+		t.Error("prep pilot of an empty text")
+	}
+}
+
+const validPrepPilot = `{"chapters":[{"chapterTitle":"Cells","questions":[{"question":"What is the smallest unit of life?",` +
+	`"options":{"A":"The cell","B":"The atom","C":"The organ","D":"The tissue"},"answer":"A","explanation":"Cells are."}]}]}`
+
+func TestJSONSchemaValidate(t *testing.T) {
+	if err := PrepPilotSchema.Validate([]byte(validPrepPilot)); err != nil {
+		t.Fatalf("valid prep pilot: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, from, to string
+		problem        string
+	}{
+		{"missing required field", `,"explanation":"Cells are."`, ``, `chapters[0].questions[0]: "explanation" is required`},
+		{"extra property", `"answer":"A"`, `"answer":"A","difficulty":"easy"`, `chapters[0].questions[0]: "difficulty" is not allowed`},
+		{"bad enum", `"answer":"A"`, `"answer":"E"`, `chapters[0].questions[0].answer: must be one of A, B, C, D`},
+		{"lowercase enum", `"answer":"A"`, `"answer":"a"`, `answer: must be one of A, B, C, D`},
+		{"number instead of string", `"answer":"A"`, `"answer":1`, `chapters[0].questions[0].answer: must be a string`},
+		{"array instead of object", `{"A":"The cell","B":"The atom","C":"The organ","D":"The tissue"}`, `["The cell","The atom","The organ","The tissue"]`, `chapters[0].questions[0].options: must be an object`},
+		{"null", `"chapterTitle":"Cells"`, `"chapterTitle":null`, `chapters[0].chapterTitle: must be a string`},
+		{"blank string", `"B":"The atom"`, `"B":" \n "`, `chapters[0].questions[0].options.B: must match \S`},
+		{"empty array", `"questions":[{`, `"questions":[],"x":[{`, `chapters[0].questions: must have at least 1 items`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(validPrepPilot, tc.from) {
+				t.Fatalf("%q is not in the document", tc.from)
+			}
+			err := PrepPilotSchema.Validate([]byte(strings.Replace(validPrepPilot, tc.from, tc.to, 1)))
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.problem) {
+				t.Errorf("got %v, want %s", err, tc.problem)
+			}
+		})
+	}
+
+	for name, document := range map[string]string{
+		"array":         `[]`,
+		"string":        `"snapnotes"`,
+		"truncated":     validPrepPilot[:len(validPrepPilot)-3],
+		"trailing data": validPrepPilot + `{}`,
+		"empty":         ``,
+	} {
+		if err := PrepPilotSchema.Validate([]byte(document)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestJSONSchemaReportsEveryProblem(t *testing.T) {
+	err := SnapnotesSchema.Validate([]byte(`{"title":"","summaryByChapter":[{"chapterTitle":"Cells","summary":"s","keyPoints":[]}],"flashcards":[{"chapterTitle":"Cells","flashcards":[{"key term":"cell"}]}],"notes":1}`))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("got %v", err)
+	}
+	want := []string{
+		`flashcards[0].flashcards[0]: "definition" is required`,
+		`document: "notes" is not allowed`,
+		`summaryByChapter[0].keyPoints: must have at least 1 items`,
+		`title: must match \S`,
+	}
+	if strings.Join(schemaErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q", schemaErr.Problems)
+	}
+}
+
+// Strict structured output needs every property required and no others
+// allowed, at every level
+func TestSchemasAreStrict(t *testing.T) {
+	var walk func(path string, s *JSONSchema)
+	walk = func(path string, s *JSONSchema) {
+		switch s.Type {
+		case "object":
+			if s.AdditionalProperties == nil || *s.AdditionalProperties || len(s.Required) != len(s.Properties) {
+				t.Errorf("%s is not strict", path)
+			}
+			for name, property := range s.Properties {
+				walk(path+"."+name, property)
+			}
+		case "array":
+			walk(path+"[]", s.Items)
+		}
+	}
+	walk("snapnotes", SnapnotesSchema)
+	walk("prepPilot", PrepPilotSchema)
+}
@@ -3,45 +3,165 @@ package infrastructure
 import (
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"time"
+
+	domain "cognivia-api/Domain"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTAuth() gin.HandlerFunc {
+// Values of the "auth_method" context key
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// apiKeyTouchInterval limits how often last_used_at is written for a key
+const apiKeyTouchInterval = time.Minute
+
+// JWTAuth authenticates a request with either a JWT or a personal API key.
+// API keys are accepted as "Authorization: Bearer cgv_..." or in the
+// X-API-Key header. It sets "user_id", "auth_method" and, for API keys,
+// "scopes" on the context.
+func JWTAuth(userRepo domain.UserRepository, apiKeyRepo domain.APIKeyRepository) gin.HandlerFunc {
+	unverifiedPolicy := UnverifiedUserPolicy()
+
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
-		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid Authorization header"})
-			return
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" && strings.HasPrefix(header, "Bearer "+domain.APIKeyPrefix) {
+			apiKey = strings.TrimPrefix(header, "Bearer ")
 		}
 
-		tokenString := strings.TrimPrefix(header, "Bearer ")
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "JWT secret not set"})
-			return
+		var user *domain.User
+		if apiKey != "" {
+			key, err := apiKeyRepo.FindByHash(HashToken(apiKey))
+			if err != nil || key == nil || key.RevokedAt != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				return
+			}
+
+			user, err = userRepo.FindByID(key.UserID.Hex())
+			if err != nil || user == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				return
+			}
+
+			now := time.Now()
+			if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+				// Best effort, a failed write should not fail the request
+				_ = apiKeyRepo.TouchLastUsed(key.ID, now)
+			}
+
+			c.Set("auth_method", AuthMethodAPIKey)
+			c.Set("scopes", key.Scopes)
+		} else {
+			if header == "" || !strings.HasPrefix(header, "Bearer ") {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid Authorization header"})
+				return
+			}
+
+			tokenString := strings.TrimPrefix(header, "Bearer ")
+			secret := os.Getenv("JWT_SECRET")
+			if secret == "" {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "JWT secret not set"})
+				return
+			}
+
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, jwt.ErrSignatureInvalid
+				}
+				return []byte(secret), nil
+			})
+			if err != nil || !token.Valid {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok || claims["user_id"] == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+				return
+			}
+
+			// Purpose-bound tokens such as two-factor challenges are not sessions
+			if claims["purpose"] != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+
+			userID, ok := claims["user_id"].(string)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+				return
+			}
+
+			// Tokens issued before the last password reset carry an older version
+			user, err = userRepo.FindByID(userID)
+			if err != nil || user == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+			version, _ := claims["token_version"].(float64)
+			if int(version) != user.TokenVersion {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				return
+			}
+
+			c.Set("auth_method", AuthMethodJWT)
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		if !user.EmailVerified {
+			switch unverifiedPolicy {
+			case domain.UnverifiedPolicyDeny:
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": domain.ErrEmailNotVerified.Error()})
+				return
+			case domain.UnverifiedPolicyReadOnly:
+				if !isReadOnlyMethod(c.Request.Method) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Verify your email address to make changes"})
+					return
+				}
 			}
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		}
+
+		c.Set("user_id", user.ID.Hex())
+		c.Next()
+	}
+}
+
+// RequireScope rejects API key requests whose key lacks scope. JWT sessions
+// pass through unchanged.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") != AuthMethodAPIKey {
+			c.Next()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["user_id"] == nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		scopes, _ := c.Get("scopes")
+		if granted, ok := scopes.([]string); !ok || !slices.Contains(granted, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("user_id", claims["user_id"].(string))
+// RequireSession only lets JWT sessions through, e.g. for managing API keys.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") != AuthMethodJWT {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a user session"})
+			return
+		}
 		c.Next()
 	}
 }
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,217 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "cognivia-api/Domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type authUserRepository struct {
+	domain.UserRepository
+	users map[string]*domain.User
+}
+
+func (r *authUserRepository) FindByID(id string) (*domain.User, error) {
+	if user, ok := r.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+type authAPIKeyRepository struct {
+	domain.APIKeyRepository
+	keys    map[string]*domain.APIKey
+	touched []primitive.ObjectID
+}
+
+func (r *authAPIKeyRepository) FindByHash(keyHash string) (*domain.APIKey, error) {
+	return r.keys[keyHash], nil
+}
+
+func (r *authAPIKeyRepository) TouchLastUsed(id primitive.ObjectID, at time.Time) error {
+	r.touched = append(r.touched, id)
+	return nil
+}
+
+type authFixture struct {
+	router  *gin.Engine
+	user    *domain.User
+	apiKeys *authAPIKeyRepository
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+
+	user := &domain.User{ID: primitive.NewObjectID(), Email: "ada@example.com", EmailVerified: true, TokenVersion: 2}
+	f := &authFixture{
+		user:    user,
+		apiKeys: &authAPIKeyRepository{keys: map[string]*domain.APIKey{}},
+	}
+	auth := JWTAuth(&authUserRepository{users: map[string]*domain.User{user.ID.Hex(): user}}, f.apiKeys)
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "auth_method": c.GetString("auth_method")})
+	}
+
+	f.router = gin.New()
+	f.router.GET("/notebooks", auth, RequireScope(domain.ScopeNotebooksRead), ok)
+	f.router.POST("/notebooks", auth, RequireScope(domain.ScopeNotebooksWrite), ok)
+	f.router.POST("/api-keys", auth, RequireSession(), ok)
+	return f
+}
+
+// addKey stores an API key with scopes and returns its plaintext
+func (f *authFixture) addKey(name string, scopes ...string) (string, *domain.APIKey) {
+	plaintext := domain.APIKeyPrefix + name
+	key := &domain.APIKey{ID: primitive.NewObjectID(), UserID: f.user.ID, Name: name, Scopes: scopes}
+	f.apiKeys.keys[HashToken(plaintext)] = key
+	return plaintext, key
+}
+
+func (f *authFixture) session(claims jwt.MapClaims) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	return token
+}
+
+func (f *authFixture) do(method, path string, header ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		request.Header.Set(header[i], header[i+1])
+	}
+	response := httptest.NewRecorder()
+	f.router.ServeHTTP(response, request)
+	return response
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	f := newAuthFixture(t)
+	readOnly, _ := f.addKey("read-only", domain.ScopeNotebooksRead)
+	readWrite, _ := f.addKey("read-write", domain.ScopeNotebooksRead, domain.ScopeNotebooksWrite)
+	otherScopes, _ := f.addKey("test-results", domain.ScopeTestResultsRead, domain.ScopeTestResultsWrite)
+	noScopes, _ := f.addKey("no-scopes")
+
+	for _, tc := range []struct {
+		name, method, key string
+		want              int
+	}{
+		{"read with read scope", http.MethodGet, readOnly, http.StatusOK},
+		{"write with read scope", http.MethodPost, readOnly, http.StatusForbidden},
+		{"write with write scope", http.MethodPost, readWrite, http.StatusOK},
+		{"read with other scopes", http.MethodGet, otherScopes, http.StatusForbidden},
+		{"write with other scopes", http.MethodPost, otherScopes, http.StatusForbidden},
+		{"read without scopes", http.MethodGet, noScopes, http.StatusForbidden},
+		{"write without scopes", http.MethodPost, noScopes, http.StatusForbidden},
+	} {
+		// Both ways of sending a key are checked the same way
+		for _, header := range []string{"Authorization", "X-API-Key"} {
+			value := tc.key
+			if header == "Authorization" {
+				value = "Bearer " + tc.key
+			}
+			if response := f.do(tc.method, "/notebooks", header, value); response.Code != tc.want {
+				t.Errorf("%s in %s: got %d %s", tc.name, header, response.Code, response.Body)
+			}
+		}
+	}
+
+	// Sessions are not limited by scopes
+	session := f.session(jwt.MapClaims{"user_id": f.user.ID.Hex(), "token_version": 2})
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if response := f.do(method, "/notebooks", "Authorization", "Bearer "+session); response.Code != http.StatusOK {
+			t.Errorf("%s with a session: got %d", method, response.Code)
+		}
+	}
+}
+
+func TestSessionOnlyRoutesRejectAPIKeys(t *testing.T) {
+	f := newAuthFixture(t)
+	var allScopes []string
+	allScopes = append(allScopes, domain.APIKeyScopes...)
+	key, _ := f.addKey("everything", allScopes...)
+
+	if response := f.do(http.MethodPost, "/api-keys", "X-API-Key", key); response.Code != http.StatusForbidden {
+		t.Errorf("API key in X-API-Key: got %d", response.Code)
+	}
+	if response := f.do(http.MethodPost, "/api-keys", "Authorization", "Bearer "+key); response.Code != http.StatusForbidden {
+		t.Errorf("API key as bearer token: got %d", response.Code)
+	}
+	// A key and a session together are treated as the key
+	session := f.session(jwt.MapClaims{"user_id": f.user.ID.Hex(), "token_version": 2})
+	if response := f.do(http.MethodPost, "/api-keys", "X-API-Key", key, "Authorization", "Bearer "+session); response.Code != http.StatusForbidden {
+		t.Errorf("API key next to a session: got %d", response.Code)
+	}
+
+	if response := f.do(http.MethodPost, "/api-keys", "Authorization", "Bearer "+session); response.Code != http.StatusOK {
+		t.Errorf("session: got %d %s", response.Code, response.Body)
+	}
+}
+
+func TestJWTAuthRejectsBadCredentials(t *testing.T) {
+	f := newAuthFixture(t)
+	_, revoked := f.addKey("revoked", domain.ScopeNotebooksRead)
+	revokedAt := time.Now()
+	revoked.RevokedAt = &revokedAt
+	orphan, orphanKey := f.addKey("orphan", domain.ScopeNotebooksRead)
+	orphanKey.UserID = primitive.NewObjectID()
+
+	userID := f.user.ID.Hex()
+	for name, authorization := range map[string]string{
+		"no header":             "",
+		"basic auth":            "Basic YWRhOnNlY3JldA==",
+		"unknown key":           "Bearer " + domain.APIKeyPrefix + "unknown",
+		"revoked key":           "Bearer " + domain.APIKeyPrefix + "revoked",
+		"key of a deleted user": "Bearer " + orphan,
+		"malformed token":       "Bearer not.a.token",
+		"other secret": "Bearer " + func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": userID, "token_version": 2}).SignedString([]byte("other"))
+			return s
+		}(),
+		"old token version":     "Bearer " + f.session(jwt.MapClaims{"user_id": userID, "token_version": 1}),
+		"two-factor challenge":  "Bearer " + f.session(jwt.MapClaims{"user_id": userID, "token_version": 2, "purpose": "2fa_challenge"}),
+		"expired":               "Bearer " + f.session(jwt.MapClaims{"user_id": userID, "token_version": 2, "exp": time.Now().Add(-time.Minute).Unix()}),
+		"token without a user":  "Bearer " + f.session(jwt.MapClaims{"token_version": 2}),
+		"token of unknown user": "Bearer " + f.session(jwt.MapClaims{"user_id": primitive.NewObjectID().Hex(), "token_version": 0}),
+		"numeric user id":       "Bearer " + f.session(jwt.MapClaims{"user_id": 42, "token_version": 2}),
+	} {
+		header := []string{}
+		if authorization != "" {
+			header = []string{"Authorization", authorization}
+		}
+		if response := f.do(http.MethodGet, "/notebooks", header...); response.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d", name, response.Code)
+		}
+	}
+	if len(f.apiKeys.touched) != 0 {
+		t.Errorf("rejected keys were marked as used: %v", f.apiKeys.touched)
+	}
+}
+
+func TestAPIKeyUseIsRecorded(t *testing.T) {
+	f := newAuthFixture(t)
+	plaintext, key := f.addKey("script", domain.ScopeNotebooksRead)
+
+	response := f.do(http.MethodGet, "/notebooks", "X-API-Key", plaintext)
+	if response.Code != http.StatusOK || response.Body.String() != `{"auth_method":"api_key","user_id":"`+f.user.ID.Hex()+`"}` {
+		t.Fatalf("got %d %s", response.Code, response.Body)
+	}
+	if len(f.apiKeys.touched) != 1 || f.apiKeys.touched[0] != key.ID {
+		t.Errorf("touched %v", f.apiKeys.touched)
+	}
+
+	// Recently used keys are not written again
+	lastUsed := time.Now()
+	key.LastUsedAt = &lastUsed
+	f.do(http.MethodGet, "/notebooks", "X-API-Key", plaintext)
+	if len(f.apiKeys.touched) != 1 {
+		t.Errorf("touched %v", f.apiKeys.touched)
+	}
+}
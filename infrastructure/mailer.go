@@ -0,0 +1,112 @@
+package infrastructure
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer delivers plain-text emails.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer picks a mailer implementation from MAIL_DRIVER ("smtp" or "log").
+// The log driver is the default so local development never needs an SMTP server.
+func NewMailer() Mailer {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	default:
+		return NewLogMailer(os.Getenv("MAIL_LOG_DIR"))
+	}
+}
+
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	if port == "" {
+		port = "587"
+	}
+	return &smtpMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	msg := buildMessage(m.from, to, subject, body)
+	return smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from, []string{to}, msg)
+}
+
+// logMailer writes emails to the application log, or to one .eml file per
+// message when a directory is configured. Tests read the files back to pick up
+// tokens that would otherwise only be delivered by email.
+type logMailer struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewLogMailer(dir string) Mailer {
+	return &logMailer{dir: dir}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *logMailer) Send(to, subject, body string) error {
+	msg := buildMessage(os.Getenv("MAIL_FROM"), to, subject, body)
+	if m.dir == "" {
+		log.Printf("Email to %s:\n%s", to, msg)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(to, "_"))
+	return os.WriteFile(filepath.Join(m.dir, name), msg, 0o644)
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return []byte(b.String())
+}
@@ -0,0 +1,296 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+)
+
+// Character classes that PASSWORD_REQUIRED_CLASSES can list
+const (
+	CharClassLower  = "lower"
+	CharClassUpper  = "upper"
+	CharClassDigit  = "digit"
+	CharClassSymbol = "symbol"
+)
+
+// PasswordPolicy checks new passwords before they are hashed.
+type PasswordPolicy interface {
+	// Validate returns the rules password breaks, reported against field.
+	// email and name belong to the account the password is for.
+	Validate(field, password, email, name string) []domain.FieldError
+}
+
+// BreachedPasswordChecker reports whether a password appears in a known
+// breach corpus.
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+type passwordPolicy struct {
+	minLength       int
+	maxLength       int
+	requiredClasses []string
+	breachChecker   BreachedPasswordChecker
+}
+
+// NewPasswordPolicy reads the policy from PASSWORD_MIN_LENGTH,
+// PASSWORD_MAX_LENGTH, PASSWORD_REQUIRED_CLASSES and BREACHED_PASSWORDS_FILE.
+func NewPasswordPolicy() PasswordPolicy {
+	var classes []string
+	for _, class := range strings.Split(os.Getenv("PASSWORD_REQUIRED_CLASSES"), ",") {
+		class = strings.TrimSpace(class)
+		switch class {
+		case "":
+		case CharClassLower, CharClassUpper, CharClassDigit, CharClassSymbol:
+			classes = append(classes, class)
+		default:
+			log.Printf("Ignoring unknown password character class %q", class)
+		}
+	}
+
+	var checker BreachedPasswordChecker
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		checker = NewBreachedPasswordFile(path)
+	}
+
+	return NewPasswordPolicyWith(
+		EnvInt("PASSWORD_MIN_LENGTH", 8),
+		EnvInt("PASSWORD_MAX_LENGTH", 128),
+		classes,
+		checker,
+	)
+}
+
+func NewPasswordPolicyWith(minLength, maxLength int, requiredClasses []string, breachChecker BreachedPasswordChecker) PasswordPolicy {
+	return &passwordPolicy{
+		minLength:       minLength,
+		maxLength:       maxLength,
+		requiredClasses: requiredClasses,
+		breachChecker:   breachChecker,
+	}
+}
+
+func (p *passwordPolicy) Validate(field, password, email, name string) []domain.FieldError {
+	var errs []domain.FieldError
+	add := func(code, message string) {
+		errs = append(errs, domain.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		add("too_short", fmt.Sprintf("must be at least %d characters long", p.minLength))
+	}
+	if p.maxLength > 0 && length > p.maxLength {
+		add("too_long", fmt.Sprintf("must be at most %d characters long", p.maxLength))
+	}
+
+	for _, class := range p.requiredClasses {
+		if !containsClass(password, class) {
+			add("missing_"+class, "must contain at least one "+classDescription(class))
+		}
+	}
+
+	if matchesPersonalInfo(password, email, name) {
+		add("personal_info", "must not be based on your email address or name")
+	}
+
+	if p.breachChecker != nil && length > 0 {
+		breached, err := p.breachChecker.IsBreached(password)
+		if err != nil {
+			// Do not lock users out because the dataset is unreadable
+			log.Printf("Error checking breached passwords: %v", err)
+		} else if breached {
+			add("breached", "appears in a known data breach, please choose another password")
+		}
+	}
+
+	return errs
+}
+
+func containsClass(password, class string) bool {
+	for _, r := range password {
+		switch {
+		case class == CharClassLower && unicode.IsLower(r),
+			class == CharClassUpper && unicode.IsUpper(r),
+			class == CharClassDigit && unicode.IsDigit(r),
+			class == CharClassSymbol && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r):
+			return true
+		}
+	}
+	return false
+}
+
+func classDescription(class string) string {
+	switch class {
+	case CharClassLower:
+		return "lowercase letter"
+	case CharClassUpper:
+		return "uppercase letter"
+	case CharClassDigit:
+		return "digit"
+	default:
+		return "symbol"
+	}
+}
+
+// matchesPersonalInfo reports whether the password is, or is built around,
+// the email address, its local part or the user's name.
+func matchesPersonalInfo(password, email, name string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), ""))
+	}
+
+	pw := normalize(password)
+	if pw == "" {
+		return false
+	}
+
+	candidates := []string{normalize(email), normalize(name)}
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		candidates = append(candidates, normalize(email[:at]))
+	}
+	for _, part := range strings.Fields(name) {
+		candidates = append(candidates, normalize(part))
+	}
+
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		// Short fragments like "Al" would reject too many unrelated passwords
+		if pw == c || (utf8.RuneCountInString(c) >= 4 && strings.Contains(pw, c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// breachedPasswordFile looks passwords up in a local copy of a breach corpus
+// with one "<SHA-1 hex>:<count>" line per password, sorted by hash (the
+// "ordered by hash" download of Have I Been Pwned). Like the k-anonymity
+// range API, the lookup binary-searches to the first line sharing the
+// 5-character hash prefix and compares suffixes from there, so the file is
+// never loaded into memory.
+type breachedPasswordFile struct {
+	path string
+}
+
+func NewBreachedPasswordFile(path string) BreachedPasswordChecker {
+	return &breachedPasswordFile{path: path}
+}
+
+func (b *breachedPasswordFile) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// Narrow [lo, hi) until it is small enough to scan. Invariant: the line
+	// after lo sorts before our prefix (or lo is 0), the line after hi does not.
+	lo, hi := int64(0), info.Size()
+	for hi-lo > 4096 {
+		mid := lo + (hi-lo)/2
+		line, _, err := lineAfter(f, mid)
+		if err != nil {
+			return false, err
+		}
+		if line == "" || strings.ToUpper(firstN(line, 5)) >= prefix {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	start := int64(0)
+	if lo > 0 {
+		if _, start, err = lineAfter(f, lo); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		linePrefix := firstN(line, 5)
+		if linePrefix < prefix {
+			continue
+		}
+		if linePrefix > prefix {
+			return false, nil
+		}
+		lineHash, _, _ := strings.Cut(line, ":")
+		if lineHash[5:] == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// lineAfter returns the first complete line starting after offset (skipping
+// the line offset falls into, unless offset is 0) and the offset where that
+// line starts.
+func lineAfter(f *os.File, offset int64) (string, int64, error) {
+	buf := make([]byte, 256)
+	pos := offset
+	if offset > 0 {
+		// Skip to the end of the current line
+		for {
+			n, err := f.ReadAt(buf, pos)
+			if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+				pos += int64(i) + 1
+				break
+			}
+			pos += int64(n)
+			if err == io.EOF {
+				return "", pos, nil
+			}
+			if err != nil {
+				return "", pos, err
+			}
+		}
+	}
+
+	n, err := f.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
+		return "", pos, err
+	}
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(string(line)), pos, nil
+}
+
+func firstN(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
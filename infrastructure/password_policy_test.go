@@ -0,0 +1,189 @@
+package infrastructure
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	domain "cognivia-api/Domain"
+)
+
+func passwordHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachFile writes the hashes of passwords sorted like the Have I Been
+// Pwned download, each line ending in newline
+func writeBreachFile(t *testing.T, passwords []string, newline string) string {
+	t.Helper()
+	lines := make([]string, len(passwords))
+	for i, password := range passwords {
+		lines[i] = fmt.Sprintf("%s:%d", passwordHash(password), i+1)
+	}
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, newline)+newline), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedPasswordFile(t *testing.T) {
+	// Large enough for several rounds of binary search
+	var passwords []string
+	for i := range 3000 {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+	sorted := slices.Clone(passwords)
+	slices.SortFunc(sorted, func(a, b string) int { return strings.Compare(passwordHash(a), passwordHash(b)) })
+
+	for _, newline := range []string{"\n", "\r\n"} {
+		checker := NewBreachedPasswordFile(writeBreachFile(t, passwords, newline))
+		for _, password := range append([]string{sorted[0], sorted[1], sorted[len(sorted)-1], sorted[len(sorted)-2]}, passwords...) {
+			if breached, err := checker.IsBreached(password); !breached || err != nil {
+				t.Fatalf("%q with %q lines: got %v, %v", password, newline, breached, err)
+			}
+		}
+		for _, password := range []string{"password3000", "correct horse battery staple", "", "Password1"} {
+			if breached, err := checker.IsBreached(password); breached || err != nil {
+				t.Errorf("%q with %q lines: got %v, %v", password, newline, breached, err)
+			}
+		}
+	}
+}
+
+func TestBreachedPasswordFileEdgeCases(t *testing.T) {
+	for name, tc := range map[string]struct {
+		passwords []string
+		newline   string
+	}{
+		"single line":      {[]string{"password"}, "\n"},
+		"single CRLF line": {[]string{"password"}, "\r\n"},
+		"two lines":        {[]string{"password", "123456"}, "\n"},
+	} {
+		checker := NewBreachedPasswordFile(writeBreachFile(t, tc.passwords, tc.newline))
+		for _, password := range tc.passwords {
+			if breached, err := checker.IsBreached(password); !breached || err != nil {
+				t.Errorf("%s: %q got %v, %v", name, password, breached, err)
+			}
+		}
+		if breached, _ := checker.IsBreached("qwerty"); breached {
+			t.Errorf("%s: unlisted password reported", name)
+		}
+	}
+
+	dir := t.TempDir()
+	// Lower-case hashes, no final newline, a blank line and a hash sharing the
+	// prefix of "password" (5BAA6...) but not its suffix
+	content := "0000000000000000000000000000000000000000:1\n\n" +
+		"5BAA60000000000000000000000000000000000:2\n" +
+		strings.ToLower(passwordHash("password")) + ":3"
+	path := filepath.Join(dir, "mixed.txt")
+	os.WriteFile(path, []byte(content), 0o600)
+	checker := NewBreachedPasswordFile(path)
+	if breached, err := checker.IsBreached("password"); !breached || err != nil {
+		t.Errorf("lower-case last line: got %v, %v", breached, err)
+	}
+	if breached, err := checker.IsBreached("123456"); breached || err != nil {
+		t.Errorf("unlisted password: got %v, %v", breached, err)
+	}
+
+	empty := filepath.Join(dir, "empty.txt")
+	os.WriteFile(empty, nil, 0o600)
+	if breached, err := NewBreachedPasswordFile(empty).IsBreached("password"); breached || err != nil {
+		t.Errorf("empty file: got %v, %v", breached, err)
+	}
+
+	if _, err := NewBreachedPasswordFile(filepath.Join(dir, "missing.txt")).IsBreached("password"); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+type stubBreachChecker struct {
+	breached map[string]bool
+	err      error
+	checked  []string
+}
+
+func (c *stubBreachChecker) IsBreached(password string) (bool, error) {
+	c.checked = append(c.checked, password)
+	return c.breached[password], c.err
+}
+
+func violationCodes(errs []domain.FieldError) []string {
+	codes := []string{}
+	for _, e := range errs {
+		if e.Field != "password" {
+			return []string{"wrong field " + e.Field}
+		}
+		codes = append(codes, e.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	checker := &stubBreachChecker{breached: map[string]bool{"Tr0ub4dor&3": true}}
+	policy := NewPasswordPolicyWith(8, 16, []string{CharClassLower, CharClassUpper, CharClassDigit, CharClassSymbol}, checker)
+
+	for _, tc := range []struct {
+		password string
+		want     []string
+	}{
+		{"c0rrect-Horse", []string{}},
+		{"Sh0rt!", []string{"too_short"}},
+		// Length counts characters, not bytes
+		{"Ünïcödé-Pässw0r", []string{}},
+		{"Much-T00-Long-Password", []string{"too_long"}},
+		{"lowercase-only", []string{"missing_upper", "missing_digit"}},
+		{"ALLCAPS123", []string{"missing_lower", "missing_symbol"}},
+		{"Spaces 0nly ok", []string{"missing_symbol"}},
+		{"Tr0ub4dor&3", []string{"breached"}},
+		{"Ada.Lovelace1!", []string{"personal_info"}},
+		{"X-lovelace-9", []string{"personal_info"}},
+		// "ada" is too short to count as a fragment of the name
+		{"ada@Example.com", []string{"missing_digit"}},
+		{"Ada-Lovel4ce", []string{}},
+		{"", []string{"too_short", "missing_lower", "missing_upper", "missing_digit", "missing_symbol"}},
+	} {
+		got := violationCodes(policy.Validate("password", tc.password, "ada.lovelace1!@example.com", "Ada Lovelace"))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%q: got %q, want %q", tc.password, got, tc.want)
+		}
+	}
+	if slices.Contains(checker.checked, "") {
+		t.Error("looked up the empty password")
+	}
+
+	// An unreadable corpus does not block everyone
+	checker.err = errors.New("disk error")
+	if got := violationCodes(policy.Validate("password", "Tr0ub4dor&3", "", "")); len(got) != 0 {
+		t.Errorf("with a failing checker: got %q", got)
+	}
+}
+
+func TestMatchesPersonalInfo(t *testing.T) {
+	for _, tc := range []struct {
+		password, email, name string
+		want                  bool
+	}{
+		{"grace@navy.mil", "Grace@Navy.mil", "", true},
+		{"Grace Hopper", "", "grace hopper", true},
+		{"hopper2024", "", "Grace Hopper", true},
+		{"Al-is-my-pal", "", "Al Gore", false},
+		{"gore", "", "Al Gore", true},
+		{"GracefulDegradation", "gr@navy.mil", "", false},
+		{"anything", "", "", false},
+	} {
+		if got := matchesPersonalInfo(tc.password, tc.email, tc.name); got != tc.want {
+			t.Errorf("%q for %q, %q: got %v", tc.password, tc.email, tc.name, got)
+		}
+	}
+}
@@ -1,34 +1,203 @@
 package infrastructure
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type PasswordService interface {
 	PasswordHasher(password string) (string, error)
+	// PasswordComparator reports whether password matches hash. The hash
+	// format (bcrypt or argon2id) is detected from the hash itself.
 	PasswordComparator(hash, password string) bool
+	// NeedsRehash reports whether hash was produced by another algorithm or
+	// with other parameters than new hashes are.
+	NeedsRehash(hash string) bool
 }
 
-type passwordService struct{}
+// Argon2Params are the tunable argon2id parameters.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params target roughly the cost of bcrypt's default while being
+// memory-hard. Measured per hash on a single core with BenchmarkArgon2Hash
+// and BenchmarkBcryptHash:
+//
+//	m=19MiB  t=2 p=1    17ms
+//	m=46MiB  t=1 p=1    25ms
+//	m=64MiB  t=2 p=2    62ms   <- default
+//	m=64MiB  t=3 p=2    91ms
+//	m=128MiB t=2 p=2   140ms
+//	bcrypt cost 10      46ms
+//
+// 64 MiB also matches the second recommended option of RFC 9106. Lower
+// ARGON2_MEMORY_KIB on small instances; every concurrent login holds that
+// much memory while hashing.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  2,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
 
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+type passwordService struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+// NewPasswordService hashes new passwords with PASSWORD_HASH_ALGORITHM
+// (argon2id by default) and verifies both bcrypt and argon2id hashes, so
+// existing bcrypt hashes keep working and are upgraded on the next login.
 func NewPasswordService() PasswordService {
-	return &passwordService{}
+	params := DefaultArgon2Params
+	params.Memory = uint32(EnvInt("ARGON2_MEMORY_KIB", int(params.Memory)))
+	params.Iterations = uint32(EnvInt("ARGON2_ITERATIONS", int(params.Iterations)))
+	params.Parallelism = uint8(EnvInt("ARGON2_PARALLELISM", int(params.Parallelism)))
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations == 0 || params.Parallelism == 0 {
+		log.Printf("Invalid argon2 parameters %+v, using defaults", params)
+		params = DefaultArgon2Params
+	}
+
+	algorithm := EnvString("PASSWORD_HASH_ALGORITHM", AlgorithmArgon2id)
+	if algorithm != AlgorithmArgon2id && algorithm != AlgorithmBcrypt {
+		log.Printf("Unknown PASSWORD_HASH_ALGORITHM %q, using %s", algorithm, AlgorithmArgon2id)
+		algorithm = AlgorithmArgon2id
+	}
+
+	return NewPasswordServiceWith(algorithm, EnvInt("BCRYPT_COST", bcrypt.DefaultCost), params)
+}
+
+func NewPasswordServiceWith(algorithm string, bcryptCost int, params Argon2Params) PasswordService {
+	return &passwordService{
+		algorithm:  algorithm,
+		bcryptCost: bcryptCost,
+		argon2:     params,
+	}
 }
 
 func (s *passwordService) PasswordHasher(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if s.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	return hashArgon2id(password, s.argon2)
+}
+
+func (s *passwordService) PasswordComparator(hash, password string) bool {
+	switch {
+	case isBcryptHash(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			log.Printf("Password comparison error: %v", err)
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1
+	default:
+		return false
+	}
+}
+
+func (s *passwordService) NeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		if s.algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != s.bcryptCost
+	}
+
+	if s.algorithm != AlgorithmArgon2id {
+		return true
+	}
+	params, salt, key, err := decodeArgon2id(hash)
 	if err != nil {
+		return true
+	}
+	return params.Memory != s.argon2.Memory ||
+		params.Iterations != s.argon2.Iterations ||
+		params.Parallelism != s.argon2.Parallelism ||
+		uint32(len(salt)) != s.argon2.SaltLength ||
+		uint32(len(key)) != s.argon2.KeyLength
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// hashArgon2id encodes the hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=2,p=2$<salt>$<key>
+func hashArgon2id(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(hash), nil
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-func (s *passwordService) PasswordComparator(hash, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
 
-	log.Printf("Password comparison error: %v", err)
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, err
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
 
-	return err != nil
+	return params, salt, key, nil
 }
@@ -0,0 +1,136 @@
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep the tests fast; the benchmarks use the defaults
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	service := NewPasswordServiceWith(AlgorithmArgon2id, bcrypt.MinCost, testArgon2Params)
+
+	hash, err := service.PasswordHasher("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if !service.PasswordComparator(hash, "correct horse") {
+		t.Error("the password does not match its own hash")
+	}
+	if service.PasswordComparator(hash, "correct horse ") {
+		t.Error("another password matches the hash")
+	}
+	if service.NeedsRehash(hash) {
+		t.Error("a hash made with the current parameters needs a rehash")
+	}
+
+	again, _ := service.PasswordHasher("correct horse")
+	if again == hash {
+		t.Error("two hashes of one password share a salt")
+	}
+}
+
+func TestLegacyBcryptHashes(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewPasswordServiceWith(AlgorithmArgon2id, bcrypt.MinCost, testArgon2Params)
+
+	if !service.PasswordComparator(string(legacy), "correct horse") {
+		t.Error("a bcrypt hash no longer verifies")
+	}
+	if service.PasswordComparator(string(legacy), "wrong") {
+		t.Error("a wrong password matches a bcrypt hash")
+	}
+	if !service.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash is not upgraded to argon2id")
+	}
+
+	bcryptService := NewPasswordServiceWith(AlgorithmBcrypt, bcrypt.MinCost, testArgon2Params)
+	if bcryptService.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash of the configured cost needs a rehash")
+	}
+	if !NewPasswordServiceWith(AlgorithmBcrypt, bcrypt.MinCost+1, testArgon2Params).NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash of another cost does not need a rehash")
+	}
+}
+
+func TestNeedsRehashOnChangedArgon2Params(t *testing.T) {
+	hash, err := hashArgon2id("correct horse", testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testArgon2Params
+	stronger.Iterations++
+	if !NewPasswordServiceWith(AlgorithmArgon2id, bcrypt.MinCost, stronger).NeedsRehash(hash) {
+		t.Error("a hash with fewer iterations does not need a rehash")
+	}
+	if !NewPasswordServiceWith(AlgorithmBcrypt, bcrypt.MinCost, testArgon2Params).NeedsRehash(hash) {
+		t.Error("an argon2id hash is not rehashed when bcrypt is configured")
+	}
+}
+
+func TestMalformedHashesDoNotMatch(t *testing.T) {
+	service := NewPasswordServiceWith(AlgorithmArgon2id, bcrypt.MinCost, testArgon2Params)
+	hash, _ := service.PasswordHasher("correct horse")
+	parts := strings.Split(hash, "$")
+
+	for name, malformed := range map[string]string{
+		"empty":           "",
+		"plain text":      "correct horse",
+		"argon2i":         strings.Replace(hash, "$argon2id$", "$argon2i$", 1),
+		"other version":   strings.Replace(hash, "$v=19$", "$v=16$", 1),
+		"missing key":     strings.Join(parts[:5], "$"),
+		"invalid salt":    strings.Join([]string{"", parts[1], parts[2], parts[3], "!!", parts[5]}, "$"),
+		"invalid params":  strings.Join([]string{"", parts[1], parts[2], "m=x", parts[4], parts[5]}, "$"),
+		"truncated crypt": "$2a$04$abc",
+	} {
+		if service.PasswordComparator(malformed, "correct horse") {
+			t.Errorf("%s: malformed hash matched", name)
+		}
+		if !service.NeedsRehash(malformed) {
+			t.Errorf("%s: malformed hash does not need a rehash", name)
+		}
+	}
+}
+
+// BenchmarkArgon2Hash measures the cost of one login for the parameters
+// listed on DefaultArgon2Params, e.g.
+//
+//	go test -run '^$' -bench Hash -cpu 1 ./infrastructure
+func BenchmarkArgon2Hash(b *testing.B) {
+	for _, params := range []Argon2Params{
+		{Memory: 19 * 1024, Iterations: 2, Parallelism: 1},
+		{Memory: 46 * 1024, Iterations: 1, Parallelism: 1},
+		{Memory: 64 * 1024, Iterations: 2, Parallelism: 2},
+		{Memory: 64 * 1024, Iterations: 3, Parallelism: 2},
+		{Memory: 128 * 1024, Iterations: 2, Parallelism: 2},
+	} {
+		params.SaltLength, params.KeyLength = DefaultArgon2Params.SaltLength, DefaultArgon2Params.KeyLength
+		name := fmt.Sprintf("m=%dMiB/t=%d/p=%d", params.Memory/1024, params.Iterations, params.Parallelism)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := hashArgon2id("correct horse", params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBcryptHash(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.DefaultCost); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,1685 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Limits for one PDF. Streams can be referenced any number of times and
+// forms can show each other, so a small file can expand without end.
+const (
+	// maxPDFStreamSize bounds a decoded PDF stream
+	maxPDFStreamSize = 64 << 20
+	// maxPDFDecodedSize bounds all decoded streams of a document together
+	maxPDFDecodedSize = 256 << 20
+	// maxPDFOperations bounds the content stream operators interpreted for
+	// a document, including those of the forms its pages show
+	maxPDFOperations = 20_000_000
+)
+
+var (
+	// errMalformedPDF is returned for files without a PDF header or without
+	// a single page, e.g. because they were cut off before it
+	errMalformedPDF  = errors.New("malformed PDF")
+	errPDFTooLarge   = errors.New("the PDF's content is too large to extract")
+	errPDFTooComplex = errors.New("the PDF's pages are too complex to extract")
+	errPDFTimeout    = errors.New("reading the PDF took too long")
+)
+
+// pdfBlocks reads the text layer of a PDF, giving up at a non-zero deadline.
+// PDFs have no document structure to speak of, so lines are rebuilt from
+// glyph positions, headings are told apart by their font size or weight and
+// paragraphs by the space between lines. Running headers, footers and page
+// numbers are dropped.
+func pdfBlocks(content []byte, deadline time.Time) (blocks []textBlock, err error) {
+	defer func() {
+		if recover() != nil {
+			blocks, err = nil, errMalformedPDF
+		}
+	}()
+
+	doc, err := parsePDF(content)
+	if err != nil {
+		return nil, err
+	}
+	doc.deadline = deadline
+	return doc.blocks()
+}
+
+func (d *pdfDocument) blocks() ([]textBlock, error) {
+	docPages := d.pages()
+	if len(docPages) == 0 {
+		return nil, errMalformedPDF
+	}
+	var pages [][]*pdfLine
+	for i, page := range docPages {
+		glyphs := d.pageGlyphs(page)
+		if d.err != nil {
+			return nil, d.err
+		}
+		pages = append(pages, pdfPageLines(glyphs, i))
+	}
+	return pdfLinesToBlocks(pages), nil
+}
+
+// PDF objects. Numbers are float64, booleans bool and null nil.
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfString  string
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+)
+
+type pdfRef struct {
+	num, gen int
+}
+
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// refs reads "n g R" as a reference, which content streams and CMaps
+	// never contain
+	refs bool
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// readObject returns the next object, or a pdfKeyword for operators and for
+// the closing ">>" and "]"
+func (l *pdfLexer) readObject() (any, error) {
+	return l.readNested(0)
+}
+
+func (l *pdfLexer) readNested(depth int) (any, error) {
+	if depth > 64 {
+		return nil, errMalformedPDF
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch c {
+	case '/':
+		l.pos++
+		return pdfName(l.readName()), nil
+	case '(':
+		l.pos++
+		return l.readLiteralString(), nil
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			dict := pdfDict{}
+			for {
+				key, err := l.readNested(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				if key == pdfKeyword(">>") {
+					return dict, nil
+				}
+				value, err := l.readNested(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				if value == pdfKeyword(">>") {
+					return dict, nil
+				}
+				if name, ok := key.(pdfName); ok {
+					dict[name] = value
+				}
+			}
+		}
+		l.pos++
+		return l.readHexString(), nil
+	case '[':
+		l.pos++
+		array := pdfArray{}
+		for {
+			value, err := l.readNested(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if value == pdfKeyword("]") {
+				return array, nil
+			}
+			array = append(array, value)
+		}
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return pdfKeyword(">"), nil
+	case ']', ')', '{', '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	token := l.readRegular()
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if strings.IndexByte("+-.0123456789", token[0]) >= 0 {
+		if number, err := strconv.ParseFloat(token, 64); err == nil {
+			if l.refs && isDigits(token) {
+				if ref, ok := l.readRefTail(token); ok {
+					return ref, nil
+				}
+			}
+			return number, nil
+		}
+	}
+	return pdfKeyword(token), nil
+}
+
+func (l *pdfLexer) readRegular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// readRefTail completes "num gen R" after num has been read, leaving the
+// position alone when no reference follows
+func (l *pdfLexer) readRefTail(num string) (pdfRef, bool) {
+	start := l.pos
+	l.skipSpace()
+	gen := l.readRegular()
+	l.skipSpace()
+	if isDigits(gen) && l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+		(l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+		l.pos++
+		n, _ := strconv.Atoi(num)
+		g, _ := strconv.Atoi(gen)
+		return pdfRef{num: n, gen: g}, true
+	}
+	l.pos = start
+	return pdfRef{}, false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *pdfLexer) readName() string {
+	var name []byte
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) && isHexDigit(l.data[l.pos+1]) && isHexDigit(l.data[l.pos+2]) {
+			decoded, _ := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3]))
+			name = append(name, decoded...)
+			l.pos += 3
+			continue
+		}
+		name = append(name, c)
+		l.pos++
+	}
+	return string(name)
+}
+
+func (l *pdfLexer) readLiteralString() pdfString {
+	var s []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(s)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return pdfString(s)
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A backslash before a line break continues the string
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return pdfString(s)
+}
+
+func (l *pdfLexer) readHexString() pdfString {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; isHexDigit(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	hex.Decode(s, digits)
+	return pdfString(s)
+}
+
+// readStream reads the data of a stream whose dictionary has just been read
+func (l *pdfLexer) readStream(dict pdfDict) *pdfStream {
+	l.pos += len("stream")
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	// Length is trusted when it is a direct number that ends at endstream
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(l.data)-start) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = end
+			return &pdfStream{dict: dict, raw: l.data[start:end]}
+		}
+	}
+
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		l.pos = len(l.data)
+		return &pdfStream{dict: dict, raw: l.data[start:]}
+	}
+	l.pos = start + end
+	raw := bytes.TrimSuffix(l.data[start:start+end], []byte("\n"))
+	return &pdfStream{dict: dict, raw: bytes.TrimSuffix(raw, []byte("\r"))}
+}
+
+// skipInlineImage skips the dictionary and binary data of an inline image
+// after its BI operator
+func (l *pdfLexer) skipInlineImage() {
+	for {
+		object, err := l.readObject()
+		if err != nil {
+			return
+		}
+		if object == pdfKeyword("ID") {
+			break
+		}
+	}
+	l.pos++
+	for ; l.pos+2 <= len(l.data); l.pos++ {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isPDFSpace(l.data[l.pos-1]) &&
+			(l.pos+2 == len(l.data) || isPDFSpace(l.data[l.pos+2]) || isPDFDelimiter(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+type pdfDocument struct {
+	objects  map[int]any
+	trailer  pdfDict
+	fonts    map[pdfRef]*pdfFont
+	fallback *pdfFont
+	// decoded holds the data of decoded streams, so a stream referenced
+	// many times is decoded once
+	decoded map[*pdfStream][]byte
+	// decodeBudget and operations are what is left of maxPDFDecodedSize and
+	// maxPDFOperations
+	decodeBudget int
+	operations   int
+	deadline     time.Time
+	// err is set once a limit is hit and stops the extraction
+	err error
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+\d+[\x00\t\n\f\r ]+obj\b`)
+
+// parsePDF reads every object by scanning the file instead of following the
+// cross-reference table, which is more forgiving of damaged files. Later
+// definitions of an object win, as with incremental updates.
+func parsePDF(data []byte) (*pdfDocument, error) {
+	// Readers accept up to 1 KB of junk before the header
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, errMalformedPDF
+	}
+	doc := &pdfDocument{
+		objects:      map[int]any{},
+		trailer:      pdfDict{},
+		fonts:        map[pdfRef]*pdfFont{},
+		decoded:      map[*pdfStream][]byte{},
+		decodeBudget: maxPDFDecodedSize,
+		operations:   maxPDFOperations,
+	}
+
+	consumed := 0
+	for _, match := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		if match[0] < consumed {
+			continue
+		}
+		num, err := strconv.Atoi(string(data[match[2]:match[3]]))
+		if err != nil {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: match[1], refs: true}
+		object, err := l.readObject()
+		if err != nil {
+			continue
+		}
+		if dict, ok := object.(pdfDict); ok {
+			l.skipSpace()
+			if bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+				object = l.readStream(dict)
+			}
+		}
+		doc.objects[num] = object
+		consumed = l.pos
+	}
+	if len(doc.objects) == 0 {
+		return nil, errMalformedPDF
+	}
+
+	for i := 0; ; {
+		at := bytes.Index(data[i:], []byte("trailer"))
+		if at < 0 {
+			break
+		}
+		i += at + len("trailer")
+		l := &pdfLexer{data: data, pos: i, refs: true}
+		if trailer, err := l.readObject(); err == nil {
+			if dict, ok := trailer.(pdfDict); ok {
+				maps.Copy(doc.trailer, dict)
+			}
+		}
+	}
+
+	var objectStreams []*pdfStream
+	for _, object := range doc.objects {
+		stream, ok := object.(*pdfStream)
+		if !ok {
+			continue
+		}
+		switch stream.dict["Type"] {
+		case pdfName("XRef"):
+			// Cross-reference streams replace the trailer
+			for _, key := range []pdfName{"Root", "Encrypt"} {
+				if _, ok := doc.trailer[key]; !ok && stream.dict[key] != nil {
+					doc.trailer[key] = stream.dict[key]
+				}
+			}
+		case pdfName("ObjStm"):
+			objectStreams = append(objectStreams, stream)
+		}
+	}
+	if doc.trailer["Encrypt"] != nil {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+	for _, stream := range objectStreams {
+		doc.loadObjectStream(stream)
+	}
+	if doc.err != nil {
+		return nil, doc.err
+	}
+
+	doc.fallback = doc.loadFont(nil)
+	return doc, nil
+}
+
+// loadObjectStream adds the objects compressed into an object stream
+func (d *pdfDocument) loadObjectStream(stream *pdfStream) {
+	data, err := d.streamData(stream)
+	if err != nil {
+		return
+	}
+	count, _ := d.number(stream.dict["N"])
+	first, _ := d.number(stream.dict["First"])
+
+	header := &pdfLexer{data: data}
+	for range int(count) {
+		num, err := header.readObject()
+		if err != nil {
+			return
+		}
+		offset, err := header.readObject()
+		if err != nil {
+			return
+		}
+		n, ok1 := num.(float64)
+		at, ok2 := offset.(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+		pos := int(first) + int(at)
+		if _, exists := d.objects[int(n)]; exists || pos < 0 || pos >= len(data) {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: pos, refs: true}
+		if object, err := l.readObject(); err == nil {
+			d.objects[int(n)] = object
+		}
+	}
+}
+
+func (d *pdfDocument) resolve(object any) any {
+	for range 16 {
+		ref, ok := object.(pdfRef)
+		if !ok {
+			return object
+		}
+		object = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(object any) pdfDict {
+	switch object := d.resolve(object).(type) {
+	case pdfDict:
+		return object
+	case *pdfStream:
+		return object.dict
+	}
+	return nil
+}
+
+func (d *pdfDocument) array(object any) pdfArray {
+	array, _ := d.resolve(object).(pdfArray)
+	return array
+}
+
+func (d *pdfDocument) number(object any) (float64, bool) {
+	number, ok := d.resolve(object).(float64)
+	return number, ok
+}
+
+func (d *pdfDocument) name(object any) pdfName {
+	name, _ := d.resolve(object).(pdfName)
+	return name
+}
+
+func (d *pdfDocument) matrix(object any) (pdfMatrix, bool) {
+	array := d.array(object)
+	if len(array) != 6 {
+		return pdfMatrix{}, false
+	}
+	var m pdfMatrix
+	for i := range m {
+		m[i], _ = d.number(array[i])
+	}
+	return m, true
+}
+
+// streamData decodes a stream. LZW and the image filters are not supported,
+// as text is not stored with them in practice. Decoded data counts against
+// the document's decode budget.
+func (d *pdfDocument) streamData(stream *pdfStream) ([]byte, error) {
+	if data, ok := d.decoded[stream]; ok {
+		return data, nil
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var filters pdfArray
+	switch filter := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = pdfArray{filter}
+	case pdfArray:
+		filters = filter
+	}
+
+	data := stream.raw
+	for _, filter := range filters {
+		var err error
+		switch d.name(filter) {
+		case "FlateDecode", "Fl":
+			// One byte over the budget is enough to tell it is exceeded
+			data, err = inflatePDF(data, min(maxPDFStreamSize, d.decodeBudget+1))
+		case "ASCIIHexDecode", "AHx":
+			data = []byte((&pdfLexer{data: data}).readHexString())
+		case "ASCII85Decode", "A85":
+			data, err = ascii85DecodePDF(data)
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %v", d.name(filter))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(filters) > 0 {
+		if len(data) > d.decodeBudget {
+			d.err = errPDFTooLarge
+			return nil, d.err
+		}
+		d.decodeBudget -= len(data)
+	}
+	d.decoded[stream] = data
+	return data, nil
+}
+
+// inflatePDF decompresses a Flate stream, cut off after limit bytes
+func inflatePDF(data []byte, limit int) ([]byte, error) {
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		defer zr.Close()
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	// Truncated or corrupt streams usually still hold most of their text
+	return out, nil
+}
+
+func ascii85DecodePDF(data []byte) ([]byte, error) {
+	if end := bytes.Index(data, []byte("~>")); end >= 0 {
+		data = data[:end]
+	}
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	out := make([]byte, 4*len(data)+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree, passing inherited resources down to the pages
+func (d *pdfDocument) pages() []pdfPage {
+	root := d.dict(d.trailer["Root"])
+	if root == nil {
+		for _, object := range d.objects {
+			if dict := d.dict(object); dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+
+	var pages []pdfPage
+	visited := map[pdfRef]bool{}
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if own := d.dict(dict["Resources"]); own != nil {
+			resources = own
+		}
+		if kids := d.array(dict["Kids"]); kids != nil && dict["Type"] != pdfName("Page") {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		pages = append(pages, pdfPage{dict: dict, resources: resources})
+	}
+	if root != nil {
+		walk(root["Pages"], nil, 0)
+	}
+
+	if len(pages) == 0 {
+		// A broken page tree still leaves the pages in object order
+		nums := slices.Sorted(maps.Keys(d.objects))
+		for _, num := range nums {
+			if dict := d.dict(d.objects[num]); dict["Type"] == pdfName("Page") {
+				pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+			}
+		}
+	}
+	return pages
+}
+
+// pageContent returns the content streams of a page. They are not joined, as
+// a page may list one stream any number of times.
+func (d *pdfDocument) pageContent(page pdfPage) [][]byte {
+	var streams pdfArray
+	switch contents := d.resolve(page.dict["Contents"]).(type) {
+	case *pdfStream:
+		streams = pdfArray{contents}
+	case pdfArray:
+		streams = contents
+	}
+
+	var content [][]byte
+	for _, object := range streams {
+		stream, ok := d.resolve(object).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.streamData(stream)
+		if err != nil {
+			continue
+		}
+		content = append(content, data)
+	}
+	return content
+}
+
+type pdfFont struct {
+	composite bool
+	// codespace decides how many bytes each character code of a composite
+	// font takes
+	codespace []pdfCodeRange
+	toUnicode map[string]string
+	ranges    []pdfUnicodeRange
+	// encoding maps the codes of simple fonts without a ToUnicode entry
+	encoding *[256]rune
+	// widths are in text space units, so 1 is the font size
+	widths       map[int]float64
+	defaultWidth float64
+	bold         bool
+}
+
+type pdfCodeRange struct {
+	lo, hi []byte
+}
+
+type pdfUnicodeRange struct {
+	lo, hi []byte
+	// start is the UTF-16 text of lo, incremented for the codes after it,
+	// unless the text of every code is listed in texts
+	start []uint16
+	texts []string
+}
+
+func (d *pdfDocument) font(object any) *pdfFont {
+	ref, isRef := object.(pdfRef)
+	if font, ok := d.fonts[ref]; isRef && ok {
+		return font
+	}
+	dict := d.dict(object)
+	if dict == nil {
+		return d.fallback
+	}
+	font := d.loadFont(dict)
+	if isRef {
+		d.fonts[ref] = font
+	}
+	return font
+}
+
+func (d *pdfDocument) loadFont(dict pdfDict) *pdfFont {
+	font := &pdfFont{toUnicode: map[string]string{}, widths: map[int]float64{}, defaultWidth: 0.5}
+	descriptor := d.dict(dict["FontDescriptor"])
+
+	if d.name(dict["Subtype"]) == "Type0" {
+		font.composite = true
+		var descendant pdfDict
+		if descendants := d.array(dict["DescendantFonts"]); len(descendants) > 0 {
+			descendant = d.dict(descendants[0])
+		}
+		descriptor = d.dict(descendant["FontDescriptor"])
+		font.defaultWidth = 1
+		if width, ok := d.number(descendant["DW"]); ok {
+			font.defaultWidth = width / 1000
+		}
+		font.loadCIDWidths(d, d.array(descendant["W"]))
+	} else {
+		font.encoding = d.simpleEncoding(dict)
+		// Type 3 glyphs are measured in their own glyph space
+		scale := 0.001
+		if matrix, ok := d.matrix(dict["FontMatrix"]); ok && d.name(dict["Subtype"]) == "Type3" {
+			scale = matrix[0]
+		}
+		first, _ := d.number(dict["FirstChar"])
+		for i, width := range d.array(dict["Widths"]) {
+			if width, ok := d.number(width); ok {
+				font.widths[int(first)+i] = width * scale
+			}
+		}
+		if missing, ok := d.number(descriptor["MissingWidth"]); ok && missing > 0 {
+			font.defaultWidth = missing * scale
+		}
+	}
+
+	if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.streamData(stream); err == nil {
+			font.loadToUnicode(data)
+		}
+	}
+
+	// Subset fonts carry a six letter prefix such as "ABCDEF+"
+	name := string(d.name(dict["BaseFont"]))
+	if plus := strings.IndexByte(name, '+'); plus == 6 {
+		name = name[plus+1:]
+	}
+	name = strings.ToLower(name)
+	weight, _ := d.number(descriptor["FontWeight"])
+	font.bold = weight >= 600 || strings.Contains(name, "bold") || strings.Contains(name, "black") ||
+		strings.Contains(name, "heavy") || strings.HasPrefix(name, "cmbx") || strings.HasPrefix(name, "sfbx")
+	return font
+}
+
+// loadCIDWidths reads the W array of a CID font, which mixes
+// "first [w1 w2 ...]" and "first last w" entries
+func (f *pdfFont) loadCIDWidths(d *pdfDocument, w pdfArray) {
+	for i := 0; i < len(w); {
+		first, ok := d.number(w[i])
+		if !ok {
+			return
+		}
+		if i+1 < len(w) {
+			if list := d.array(w[i+1]); list != nil {
+				for j, width := range list {
+					if width, ok := d.number(width); ok {
+						f.widths[int(first)+j] = width / 1000
+					}
+				}
+				i += 2
+				continue
+			}
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, ok1 := d.number(w[i+1])
+		width, ok2 := d.number(w[i+2])
+		if !ok1 || !ok2 {
+			return
+		}
+		for cid := int(first); cid <= int(last) && cid-int(first) < 1<<16; cid++ {
+			f.widths[cid] = width / 1000
+		}
+		i += 3
+	}
+}
+
+// simpleEncoding builds the code to character table of a simple font from
+// its base encoding and the glyph names of its Differences array. The
+// standard encoding is close enough to WinAnsi for text.
+func (d *pdfDocument) simpleEncoding(dict pdfDict) *[256]rune {
+	encoding := d.resolve(dict["Encoding"])
+	base, _ := encoding.(pdfName)
+	differences := d.dict(encoding)
+	if differences != nil {
+		base = d.name(differences["BaseEncoding"])
+	}
+
+	table := charmap.Windows1252
+	if base == "MacRomanEncoding" {
+		table = charmap.Macintosh
+	}
+	var runes [256]rune
+	for i := range runes {
+		runes[i] = table.DecodeByte(byte(i))
+	}
+
+	code := 0
+	for _, item := range d.array(differences["Differences"]) {
+		switch item := d.resolve(item).(type) {
+		case float64:
+			code = int(item)
+		case pdfName:
+			if code >= 0 && code < len(runes) {
+				if r := glyphRune(string(item)); r != 0 {
+					runes[code] = r
+				}
+			}
+			code++
+		}
+	}
+	return &runes
+}
+
+func (f *pdfFont) loadToUnicode(data []byte) {
+	l := &pdfLexer{data: data}
+	var operands []any
+	for {
+		object, err := l.readObject()
+		if err != nil {
+			return
+		}
+		keyword, ok := object.(pdfKeyword)
+		if !ok {
+			operands = append(operands, object)
+			continue
+		}
+
+		switch keyword {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+					f.codespace = append(f.codespace, pdfCodeRange{lo: []byte(lo), hi: []byte(hi)})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				code, ok1 := operands[i].(pdfString)
+				text, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					f.toUnicode[string(code)] = utf16Text([]byte(text))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				r := pdfUnicodeRange{lo: []byte(lo), hi: []byte(hi)}
+				switch target := operands[i+2].(type) {
+				case pdfString:
+					r.start = utf16Units([]byte(target))
+				case pdfArray:
+					for _, text := range target {
+						s, _ := text.(pdfString)
+						r.texts = append(r.texts, utf16Text([]byte(s)))
+					}
+				default:
+					continue
+				}
+				f.ranges = append(f.ranges, r)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// utf16Units reads big-endian UTF-16. Some producers write single bytes,
+// which are taken as Latin-1.
+func utf16Units(b []byte) []uint16 {
+	var units []uint16
+	if len(b)%2 == 1 {
+		for _, c := range b {
+			units = append(units, uint16(c))
+		}
+		return units
+	}
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+func utf16Text(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+func codeValue(code []byte) int {
+	value := 0
+	for _, c := range code {
+		value = value<<8 | int(c)
+	}
+	return value
+}
+
+// codes splits a shown string into character codes
+func (f *pdfFont) codes(s []byte) [][]byte {
+	var codes [][]byte
+	for len(s) > 0 {
+		n := f.codeLength(s)
+		codes = append(codes, s[:n])
+		s = s[n:]
+	}
+	return codes
+}
+
+func (f *pdfFont) codeLength(s []byte) int {
+	if !f.composite {
+		return 1
+	}
+	if len(f.codespace) == 0 {
+		return min(2, len(s))
+	}
+	shortest := 4
+	for _, r := range f.codespace {
+		shortest = min(shortest, len(r.lo))
+	}
+	for n := 1; n <= 4 && n <= len(s); n++ {
+		for _, r := range f.codespace {
+			if len(r.lo) == n && inCodeRange(s[:n], r) {
+				return n
+			}
+		}
+	}
+	return min(shortest, len(s))
+}
+
+func inCodeRange(code []byte, r pdfCodeRange) bool {
+	for i, c := range code {
+		if c < r.lo[i] || c > r.hi[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *pdfFont) text(code []byte) string {
+	if text, ok := f.toUnicode[string(code)]; ok {
+		return text
+	}
+	value := codeValue(code)
+	for _, r := range f.ranges {
+		if len(r.lo) != len(code) || value < codeValue(r.lo) || value > codeValue(r.hi) {
+			continue
+		}
+		offset := value - codeValue(r.lo)
+		if r.texts != nil {
+			if offset < len(r.texts) {
+				return r.texts[offset]
+			}
+			continue
+		}
+		if len(r.start) == 0 {
+			continue
+		}
+		units := slices.Clone(r.start)
+		units[len(units)-1] += uint16(offset)
+		return string(utf16.Decode(units))
+	}
+	// Without a ToUnicode map the codes of composite fonts are glyph IDs
+	if f.encoding != nil && len(code) == 1 {
+		return string(f.encoding[code[0]])
+	}
+	return ""
+}
+
+func (f *pdfFont) width(code []byte) float64 {
+	if width, ok := f.widths[codeValue(code)]; ok {
+		return width
+	}
+	return f.defaultWidth
+}
+
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "quoteright": '’', "quoteleft": '‘',
+	"parenleft": '(', "parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-',
+	"period": '.', "slash": '/', "zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9', "colon": ':', "semicolon": ';',
+	"less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@', "bracketleft": '[',
+	"backslash": '\\', "bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`',
+	"braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~', "bullet": '•',
+	"endash": '–', "emdash": '—', "quotedblleft": '“', "quotedblright": '”',
+	"quotesinglbase": '‚', "quotedblbase": '„', "ellipsis": '…', "dagger": '†',
+	"daggerdbl": '‡', "fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ',
+	"ffl": 'ﬄ', "germandbls": 'ß', "ae": 'æ', "AE": 'Æ', "oe": 'œ', "OE": 'Œ', "oslash": 'ø',
+	"Oslash": 'Ø', "dotlessi": 'ı', "minus": '−', "multiply": '×', "divide": '÷',
+	"degree": '°', "copyright": '©', "registered": '®', "trademark": '™', "section": '§',
+	"paragraph": '¶', "periodcentered": '·', "guillemotleft": '«', "guillemotright": '»',
+	"guilsinglleft": '‹', "guilsinglright": '›', "exclamdown": '¡', "questiondown": '¿',
+	"sterling": '£', "yen": '¥', "Euro": '€', "cent": '¢', "plusminus": '±', "mu": 'µ',
+	"nbspace": '\u00a0', "acute": '´', "dieresis": '¨', "cedilla": '¸', "macron": '¯',
+}
+
+var glyphAccents = map[string]rune{
+	"acute": '\u0301', "grave": '\u0300', "circumflex": '\u0302', "tilde": '\u0303',
+	"dieresis": '\u0308', "ring": '\u030a', "cedilla": '\u0327', "caron": '\u030c',
+}
+
+// glyphRune maps a glyph name from the Adobe Glyph List conventions to its
+// character, or returns 0
+func glyphRune(name string) rune {
+	// Variants such as "a.sc" share the character of their base glyph
+	name, _, _ = strings.Cut(name, ".")
+	if r, ok := glyphNames[name]; ok {
+		return r
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return r
+	}
+	if hexDigits, ok := strings.CutPrefix(name, "uni"); ok && len(hexDigits) == 4 {
+		if v, err := strconv.ParseUint(hexDigits, 16, 32); err == nil {
+			return rune(v)
+		}
+	}
+	if hexDigits, ok := strings.CutPrefix(name, "u"); ok && len(hexDigits) >= 4 && len(hexDigits) <= 6 {
+		if v, err := strconv.ParseUint(hexDigits, 16, 32); err == nil && v <= unicode.MaxRune {
+			return rune(v)
+		}
+	}
+	for accent, mark := range glyphAccents {
+		if base, ok := strings.CutSuffix(name, accent); ok && utf8.RuneCountInString(base) == 1 {
+			composed := norm.NFC.String(base + string(mark))
+			if r, size := utf8.DecodeRuneInString(composed); size == len(composed) {
+				return r
+			}
+		}
+	}
+	return 0
+}
+
+// pdfMatrix is the affine transformation [a b c d e f]
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+type pdfGraphicsState struct {
+	ctm       pdfMatrix
+	font      *pdfFont
+	size      float64
+	charSpace float64
+	wordSpace float64
+	scale     float64
+	leading   float64
+	rise      float64
+}
+
+// pdfGlyph is a shown character in device space. end is where the next
+// character would start.
+type pdfGlyph struct {
+	text      string
+	x, y, end float64
+	size      float64
+	bold      bool
+}
+
+type pdfInterpreter struct {
+	doc     *pdfDocument
+	glyphs  []pdfGlyph
+	state   pdfGraphicsState
+	saved   []pdfGraphicsState
+	tm, tlm pdfMatrix
+}
+
+// pageGlyphs runs the text operators of a page's content
+func (d *pdfDocument) pageGlyphs(page pdfPage) []pdfGlyph {
+	in := &pdfInterpreter{doc: d, state: pdfGraphicsState{ctm: pdfIdentity, font: d.fallback, scale: 1}}
+	in.run(d.pageContent(page), page.resources, 0)
+	return in.glyphs
+}
+
+// run interprets content streams one after the other. Streams are split
+// between tokens, so operands carry over to the next stream.
+func (in *pdfInterpreter) run(content [][]byte, resources pdfDict, depth int) {
+	var operands []any
+	for _, data := range content {
+		l := &pdfLexer{data: data}
+		for {
+			object, err := l.readObject()
+			if err != nil {
+				break
+			}
+			if operator, ok := object.(pdfKeyword); ok {
+				if !in.doc.spend() {
+					return
+				}
+				in.execute(string(operator), operands, resources, depth, l)
+				operands = operands[:0]
+				continue
+			}
+			operands = append(operands, object)
+		}
+	}
+}
+
+// spend counts an operator against the document's limits and reports
+// whether interpretation may go on
+func (d *pdfDocument) spend() bool {
+	if d.err != nil {
+		return false
+	}
+	d.operations--
+	if d.operations < 0 {
+		d.err = errPDFTooComplex
+		return false
+	}
+	// Reading the clock for every operator would slow down large pages
+	if d.operations%4096 == 0 && !d.deadline.IsZero() && time.Now().After(d.deadline) {
+		d.err = errPDFTimeout
+		return false
+	}
+	return true
+}
+
+func (in *pdfInterpreter) execute(operator string, operands []any, resources pdfDict, depth int, l *pdfLexer) {
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if n, ok := operands[i].(float64); ok {
+				return n
+			}
+		}
+		return 0
+	}
+	matrix := func() pdfMatrix {
+		return pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+	}
+	last := func() pdfString {
+		if len(operands) == 0 {
+			return ""
+		}
+		s, _ := operands[len(operands)-1].(pdfString)
+		return s
+	}
+	state := &in.state
+
+	switch operator {
+	case "q":
+		if len(in.saved) < 1024 {
+			in.saved = append(in.saved, in.state)
+		}
+	case "Q":
+		if n := len(in.saved); n > 0 {
+			in.state = in.saved[n-1]
+			in.saved = in.saved[:n-1]
+		}
+	case "cm":
+		if len(operands) == 6 {
+			state.ctm = matrix().multiply(state.ctm)
+		}
+	case "BT":
+		in.tm, in.tlm = pdfIdentity, pdfIdentity
+	case "Tf":
+		if len(operands) == 2 {
+			name, _ := operands[0].(pdfName)
+			state.font = in.doc.font(in.doc.dict(resources["Font"])[name])
+			state.size = number(1)
+		}
+	case "Tc":
+		state.charSpace = number(0)
+	case "Tw":
+		state.wordSpace = number(0)
+	case "Tz":
+		state.scale = number(0) / 100
+	case "TL":
+		state.leading = number(0)
+	case "Ts":
+		state.rise = number(0)
+	case "Td":
+		in.moveLine(number(0), number(1))
+	case "TD":
+		state.leading = -number(1)
+		in.moveLine(number(0), number(1))
+	case "Tm":
+		if len(operands) == 6 {
+			in.tm = matrix()
+			in.tlm = in.tm
+		}
+	case "T*":
+		in.moveLine(0, -state.leading)
+	case "Tj":
+		in.show(last())
+	case "'":
+		in.moveLine(0, -state.leading)
+		in.show(last())
+	case "\"":
+		state.wordSpace, state.charSpace = number(0), number(1)
+		in.moveLine(0, -state.leading)
+		in.show(last())
+	case "TJ":
+		if len(operands) == 0 {
+			return
+		}
+		items, _ := operands[0].(pdfArray)
+		for _, item := range items {
+			switch item := item.(type) {
+			case pdfString:
+				in.show(item)
+			case float64:
+				in.advance(-item / 1000 * state.size * state.scale)
+			}
+		}
+	case "Do":
+		if depth >= 8 || len(operands) != 1 {
+			return
+		}
+		name, _ := operands[0].(pdfName)
+		form, ok := in.doc.resolve(in.doc.dict(resources["XObject"])[name]).(*pdfStream)
+		if !ok || form.dict["Subtype"] != pdfName("Form") {
+			return
+		}
+		data, err := in.doc.streamData(form)
+		if err != nil {
+			return
+		}
+		formResources := in.doc.dict(form.dict["Resources"])
+		if formResources == nil {
+			formResources = resources
+		}
+		saved, tm, tlm := in.state, in.tm, in.tlm
+		if m, ok := in.doc.matrix(form.dict["Matrix"]); ok {
+			in.state.ctm = m.multiply(in.state.ctm)
+		}
+		in.run([][]byte{data}, formResources, depth+1)
+		in.state, in.tm, in.tlm = saved, tm, tlm
+	case "BI":
+		l.skipInlineImage()
+	}
+}
+
+func (in *pdfInterpreter) moveLine(tx, ty float64) {
+	in.tlm = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(in.tlm)
+	in.tm = in.tlm
+}
+
+func (in *pdfInterpreter) advance(tx float64) {
+	in.tm = pdfMatrix{1, 0, 0, 1, tx, 0}.multiply(in.tm)
+}
+
+func (in *pdfInterpreter) show(s pdfString) {
+	state := &in.state
+	font := state.font
+	for _, code := range font.codes([]byte(s)) {
+		textSpace := pdfMatrix{state.size * state.scale, 0, 0, state.size, 0, state.rise}
+		start := textSpace.multiply(in.tm).multiply(state.ctm)
+
+		advance := font.width(code)*state.size + state.charSpace
+		if len(code) == 1 && code[0] == ' ' {
+			advance += state.wordSpace
+		}
+		in.advance(advance * state.scale)
+
+		text := font.text(code)
+		if text == "" {
+			continue
+		}
+		end := textSpace.multiply(in.tm).multiply(state.ctm)
+		in.glyphs = append(in.glyphs, pdfGlyph{
+			text: text,
+			x:    start[4],
+			y:    start[5],
+			end:  end[4],
+			size: math.Hypot(start[2], start[3]),
+			bold: font.bold,
+		})
+	}
+}
+
+type pdfLine struct {
+	text string
+	x, y float64
+	// size is the average font size of the line's characters
+	size float64
+	bold bool
+	page int
+}
+
+// pdfPageLines joins glyphs on the same baseline into lines, adding spaces
+// at gaps wider than a fifth of the font size
+func pdfPageLines(glyphs []pdfGlyph, page int) []*pdfLine {
+	var lines []*pdfLine
+	var current *pdfLine
+	var text strings.Builder
+	var last pdfGlyph
+	var shown []pdfGlyph
+	sizes, chars, bold := 0.0, 0.0, true
+	finish := func() {
+		if current == nil {
+			return
+		}
+		current.text = strings.TrimSpace(text.String())
+		if chars > 0 {
+			current.size = sizes / chars
+		}
+		current.bold = bold && chars > 0
+		if current.text != "" {
+			lines = append(lines, current)
+		}
+		current = nil
+		text.Reset()
+		shown = shown[:0]
+		sizes, chars, bold = 0, 0, true
+	}
+	// Fake bold and some generators show the same text twice at almost the
+	// same spot
+	overprinted := func(glyph pdfGlyph) bool {
+		for _, other := range shown {
+			if glyph.text == other.text && math.Abs(glyph.x-other.x) < 0.1*glyph.size && math.Abs(glyph.y-other.y) < 0.1*glyph.size {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, glyph := range glyphs {
+		space := strings.TrimSpace(glyph.text) == ""
+		if current != nil {
+			if overprinted(glyph) {
+				continue
+			}
+			size := max(glyph.size, last.size)
+			if math.Abs(glyph.y-current.y) > 0.5*size || glyph.x < last.end-size {
+				finish()
+			} else if glyph.x-last.end > 0.2*min(glyph.size, last.size) && !space && !strings.HasSuffix(text.String(), " ") {
+				text.WriteByte(' ')
+			}
+		}
+		if current == nil {
+			if space {
+				continue
+			}
+			current = &pdfLine{x: glyph.x, y: glyph.y, page: page}
+		}
+		text.WriteString(glyph.text)
+		if !space {
+			n := float64(utf8.RuneCountInString(glyph.text))
+			sizes += glyph.size * n
+			chars += n
+			bold = bold && glyph.bold
+		}
+		shown = append(shown, glyph)
+		last = glyph
+	}
+	finish()
+	return lines
+}
+
+var (
+	pdfDigits     = regexp.MustCompile(`\d+`)
+	pdfPageNumber = regexp.MustCompile(`^(page )?#( ?(of|/) ?#)?$|^- ?# ?-$`)
+)
+
+// removePageFurniture drops running headers and footers, which repeat near
+// the top or bottom of most pages with only their numbers changing, and
+// lone page numbers
+func removePageFurniture(pages [][]*pdfLine) {
+	key := func(line *pdfLine) string {
+		return strings.ToLower(pdfDigits.ReplaceAllString(strings.Join(strings.Fields(line.text), " "), "#"))
+	}
+	// edges ranks lines from the top of the page, leaving out the middle
+	edges := func(lines []*pdfLine) map[*pdfLine]int {
+		sorted := slices.Clone(lines)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].y > sorted[j].y })
+		ranks := map[*pdfLine]int{}
+		for i, line := range sorted {
+			if i < 2 || i >= len(sorted)-2 {
+				ranks[line] = i
+			}
+		}
+		return ranks
+	}
+
+	counts := map[string]int{}
+	for _, lines := range pages {
+		seen := map[string]bool{}
+		for line := range edges(lines) {
+			if k := key(line); !seen[k] {
+				seen[k] = true
+				counts[k]++
+			}
+		}
+	}
+
+	for p, lines := range pages {
+		ranks := edges(lines)
+		kept := lines[:0]
+		for _, line := range lines {
+			rank, edge := ranks[line]
+			k := key(line)
+			repeated := len(pages) >= 3 && counts[k]*2 > len(pages)
+			number := (rank == 0 || rank == len(lines)-1) && pdfPageNumber.MatchString(k)
+			if edge && (repeated || number) {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		pages[p] = kept
+	}
+}
+
+// pdfLinesToBlocks groups lines into headings and paragraphs. The body size
+// is the font size most characters are set in. Short lines set larger are
+// headings ranked by size, and short bold lines at body size are the lowest
+// headings unless the body itself is bold.
+func pdfLinesToBlocks(pages [][]*pdfLine) []textBlock {
+	removePageFurniture(pages)
+	var lines []*pdfLine
+	for _, page := range pages {
+		lines = append(lines, page...)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	roundSize := func(size float64) float64 { return math.Round(size*2) / 2 }
+	charsBySize := map[float64]int{}
+	boldChars, allChars := 0, 0
+	for _, line := range lines {
+		n := utf8.RuneCountInString(line.text)
+		charsBySize[roundSize(line.size)] += n
+		allChars += n
+		if line.bold {
+			boldChars += n
+		}
+	}
+	body, most := 0.0, -1
+	for size, n := range charsBySize {
+		if n > most || (n == most && size < body) {
+			body, most = size, n
+		}
+	}
+
+	var headingSizes []float64
+	for _, line := range lines {
+		size := roundSize(line.size)
+		if size >= body*1.15 && pdfHeadingText(line.text) && !slices.Contains(headingSizes, size) {
+			headingSizes = append(headingSizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(headingSizes)))
+	boldHeadings := boldChars*2 < allChars
+	headingLevel := func(line *pdfLine) int {
+		if !pdfHeadingText(line.text) {
+			return 0
+		}
+		size := roundSize(line.size)
+		if i := slices.Index(headingSizes, size); i >= 0 {
+			return min(i+1, 5)
+		}
+		if boldHeadings && line.bold && utf8.RuneCountInString(line.text) <= 80 && size >= body*0.95 {
+			return min(len(headingSizes)+1, 6)
+		}
+		return 0
+	}
+
+	gap := medianLineGap(lines, body)
+	var blocks []textBlock
+	var previous *pdfLine
+	for _, line := range lines {
+		level := headingLevel(line)
+		item, isItem := pdfListItem(line.text)
+		var open *textBlock
+		if n := len(blocks); n > 0 {
+			open = &blocks[n-1]
+		}
+
+		switch {
+		case level > 0:
+			// Long headings wrap onto several lines
+			if open != nil && open.level == level && previous.page == line.page &&
+				previous.y-line.y > 0 && previous.y-line.y < 2*line.size {
+				open.text += " " + line.text
+			} else {
+				blocks = append(blocks, textBlock{level: level, text: line.text})
+			}
+		case isItem:
+			blocks = append(blocks, textBlock{text: "- " + item, listItem: true})
+		case open != nil && open.level == 0 && pdfContinues(previous, line, gap):
+			open.text = joinPDFLines(open.text, line.text)
+		default:
+			blocks = append(blocks, textBlock{text: line.text})
+		}
+		previous = line
+	}
+	return blocks
+}
+
+func pdfHeadingText(text string) bool {
+	return utf8.RuneCountInString(text) <= 150 && strings.IndexFunc(text, unicode.IsLetter) >= 0
+}
+
+// medianLineGap is the usual distance between the baselines of body text
+// lines, from which larger gaps are read as paragraph breaks
+func medianLineGap(lines []*pdfLine, body float64) float64 {
+	var gaps []float64
+	for i := 1; i < len(lines); i++ {
+		previous, line := lines[i-1], lines[i]
+		gap := previous.y - line.y
+		if previous.page == line.page && gap > 0 && gap < 3*line.size && math.Abs(line.size-body) < 0.15*body {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 1.2 * body
+	}
+	sort.Float64s(gaps)
+	return gaps[len(gaps)/2]
+}
+
+// pdfContinues reports whether line continues the paragraph of previous.
+// Paragraphs run on across pages and columns unless a sentence ended there.
+func pdfContinues(previous, line *pdfLine, gap float64) bool {
+	ended := strings.ContainsAny(previous.text[len(previous.text)-1:], ".!?:\"") ||
+		strings.HasSuffix(previous.text, "”")
+	if previous.page != line.page {
+		return !ended
+	}
+	if math.Abs(previous.size-line.size) > 0.15*max(previous.size, line.size) {
+		return false
+	}
+	distance := previous.y - line.y
+	if distance <= 0 {
+		return !ended
+	}
+	return distance <= 1.4*gap
+}
+
+// joinPDFLines undoes hyphenation at the end of a line before joining it
+// with the next one
+func joinPDFLines(text, next string) string {
+	if trimmed, ok := strings.CutSuffix(text, "\u00ad"); ok {
+		return trimmed + next
+	}
+	if trimmed, ok := strings.CutSuffix(text, "-"); ok {
+		before, _ := utf8.DecodeLastRuneInString(trimmed)
+		after, _ := utf8.DecodeRuneInString(next)
+		if unicode.IsLetter(before) && unicode.IsLower(after) {
+			return trimmed + next
+		}
+	}
+	return text + " " + next
+}
+
+var pdfBullets = "•◦▪‣●○■□∙"
+
+// pdfListItem returns the text of a line that starts with a bullet
+func pdfListItem(text string) (string, bool) {
+	r, size := utf8.DecodeRuneInString(text)
+	if !strings.ContainsRune(pdfBullets, r) {
+		return "", false
+	}
+	item := strings.TrimSpace(text[size:])
+	return item, item != ""
+}
@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// RunPeriodically runs task every interval in a background goroutine until
+// the returned stop function is called. Errors are logged and do not stop
+// the schedule.
+func RunPeriodically(name string, interval time.Duration, task func() error) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := task(); err != nil {
+					log.Printf("Error running %s: %v", name, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// StartWorkers runs count workers in background goroutines. Each calls next
+// with its own worker ID for as long as next reports that it did some work,
+// then waits interval before asking again. The returned stop function waits
+// for the work in progress to finish.
+func StartWorkers(name string, count int, interval time.Duration, next func(workerID string) (bool, error)) (stop func()) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := range count {
+		workerID := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				worked, err := next(workerID)
+				if err != nil {
+					log.Printf("Error running %s: %v", name, err)
+				}
+				if worked {
+					select {
+					case <-done:
+						return
+					default:
+						continue
+					}
+				}
+				select {
+				case <-time.After(interval):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,357 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+)
+
+// stubContentGenerator writes study material from the source text itself,
+// without a language model: summaries and key points quote the text,
+// flashcards come from sentences that define a term and questions ask for a
+// word blanked out of a sentence. The same text always gives the same
+// content, which makes it suitable for tests and local development.
+type stubContentGenerator struct{}
+
+func NewStubContentGenerator() domain.ContentGenerator {
+	return stubContentGenerator{}
+}
+
+// generatedSnapnotes and generatedPrepPilot are the documents described by
+// SnapnotesSchema and PrepPilotSchema
+type generatedSnapnotes struct {
+	Title            string                     `json:"title"`
+	SummaryByChapter []domain.ChapterSummary    `json:"summaryByChapter"`
+	Flashcards       []domain.ChapterFlashcards `json:"flashcards"`
+}
+
+type generatedPrepPilot struct {
+	Chapters []domain.Chapter `json:"chapters"`
+}
+
+const (
+	stubSummaryWords      = 60
+	stubKeyPoints         = 5
+	stubFlashcards        = 5
+	stubQuestions         = 5
+	stubMaxStatementRunes = 300
+)
+
+func (stubContentGenerator) GenerateSnapnotes(input domain.GenerationInput) ([]byte, error) {
+	chapters := stubChapters(input)
+	if len(chapters) == 0 {
+		return nil, errors.New("the text has no sentences to summarize")
+	}
+
+	snapnotes := generatedSnapnotes{
+		Title:            strings.TrimSpace(input.Title),
+		SummaryByChapter: []domain.ChapterSummary{},
+		Flashcards:       []domain.ChapterFlashcards{},
+	}
+	if snapnotes.Title == "" {
+		snapnotes.Title = chapters[0].title
+	}
+	for _, chapter := range chapters {
+		snapnotes.SummaryByChapter = append(snapnotes.SummaryByChapter, domain.ChapterSummary{
+			ChapterTitle: chapter.title,
+			Summary:      stubSummary(chapter.sentences),
+			KeyPoints:    stubKeyPointsOf(chapter),
+		})
+		if flashcards := stubFlashcardsOf(chapter.sentences); len(flashcards) > 0 {
+			snapnotes.Flashcards = append(snapnotes.Flashcards, domain.ChapterFlashcards{
+				ChapterTitle: chapter.title,
+				Flashcards:   flashcards,
+			})
+		}
+	}
+	return json.Marshal(snapnotes)
+}
+
+func (stubContentGenerator) GeneratePrepPilot(input domain.GenerationInput) ([]byte, error) {
+	chapters := stubChapters(input)
+	words := stubLongWords(chapters)
+
+	prepPilot := generatedPrepPilot{Chapters: []domain.Chapter{}}
+	asked := 0
+	for _, chapter := range chapters {
+		var questions []domain.Question
+		for _, sentence := range spread(stubQuestionSentences(chapter.sentences), stubQuestions) {
+			question, ok := stubQuestion(sentence, words, "ABCD"[asked%4:asked%4+1])
+			if !ok {
+				continue
+			}
+			questions = append(questions, question)
+			asked++
+		}
+		if len(questions) > 0 {
+			prepPilot.Chapters = append(prepPilot.Chapters, domain.Chapter{ChapterTitle: chapter.title, Questions: questions})
+		}
+	}
+	if len(prepPilot.Chapters) == 0 {
+		return nil, errors.New("the text is too short to write questions about")
+	}
+	return json.Marshal(prepPilot)
+}
+
+type stubChapter struct {
+	title     string
+	sentences []string
+	// listItems are the sentences that were list items in the text
+	listItems []string
+}
+
+// stubChapters splits every chapter into sentences, naming untitled chapters
+// by their position
+func stubChapters(input domain.GenerationInput) []stubChapter {
+	var chapters []stubChapter
+	for i, chapter := range input.Chapters {
+		parsed := stubChapter{title: strings.TrimSpace(chapter.Title)}
+		if parsed.title == "" {
+			parsed.title = fmt.Sprintf("Part %d", i+1)
+		}
+		for _, line := range strings.Split(chapter.Text, "\n") {
+			line = strings.TrimSpace(line)
+			item, isItem := strings.CutPrefix(line, "- ")
+			for _, sentence := range splitSentences(item) {
+				parsed.sentences = append(parsed.sentences, sentence)
+				if isItem {
+					parsed.listItems = append(parsed.listItems, sentence)
+				}
+			}
+		}
+		if len(parsed.sentences) > 0 {
+			chapters = append(chapters, parsed)
+		}
+	}
+	return chapters
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]["')\]]*\s+`)
+
+// splitSentences breaks text after sentence punctuation that is followed by
+// a capital letter or a digit
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, end := range sentenceEnd.FindAllStringIndex(text, -1) {
+		next, _ := utf8.DecodeRuneInString(text[end[1]:])
+		if !unicode.IsUpper(next) && !unicode.IsDigit(next) {
+			continue
+		}
+		if sentence := strings.TrimSpace(text[start:end[1]]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end[1]
+	}
+	if sentence := strings.TrimSpace(text[start:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// stubSummary takes the opening sentences of a chapter
+func stubSummary(sentences []string) string {
+	var summary []string
+	words := 0
+	for _, sentence := range sentences {
+		if words >= stubSummaryWords {
+			break
+		}
+		summary = append(summary, sentence)
+		words += len(strings.Fields(sentence))
+	}
+	return shorten(strings.Join(summary, " "), 3*stubMaxStatementRunes)
+}
+
+// stubKeyPointsOf prefers the chapter's list items and otherwise picks
+// sentences from all over the chapter
+func stubKeyPointsOf(chapter stubChapter) []string {
+	candidates := statements(chapter.listItems)
+	if len(candidates) == 0 {
+		candidates = statements(chapter.sentences)
+	}
+	if len(candidates) == 0 {
+		candidates = chapter.sentences
+	}
+	points := []string{}
+	for _, sentence := range spread(candidates, stubKeyPoints) {
+		points = append(points, shorten(sentence, stubMaxStatementRunes))
+	}
+	return points
+}
+
+var definition = regexp.MustCompile(`^(?:An? |The )?([\p{L}\d][\p{L}\d\-' ]{0,60}?),? (?:is|are|refers to|means|describes|denotes) (.{10,})$`)
+
+// notTerms are words that do not start a useful flashcard term
+var notTerms = map[string]bool{
+	"it": true, "this": true, "that": true, "these": true, "those": true, "there": true,
+	"they": true, "he": true, "she": true, "we": true, "you": true, "i": true, "what": true,
+	"which": true, "who": true, "here": true, "one": true, "each": true, "all": true,
+	"if": true, "but": true, "and": true, "or": true, "so": true, "when": true, "such": true,
+}
+
+// statements are the sentences of a few words or more that read as prose
+func statements(sentences []string) []string {
+	var kept []string
+	for _, sentence := range sentences {
+		if len(strings.Fields(sentence)) >= 3 && prose(sentence) {
+			kept = append(kept, sentence)
+		}
+	}
+	return kept
+}
+
+// stubFlashcardsOf turns sentences of the form "X is Y" into flashcards
+func stubFlashcardsOf(sentences []string) []domain.Flashcard {
+	var flashcards []domain.Flashcard
+	seen := map[string]bool{}
+	for _, sentence := range sentences {
+		if !prose(sentence) {
+			continue
+		}
+		match := definition.FindStringSubmatch(sentence)
+		if match == nil {
+			continue
+		}
+		term := strings.TrimSpace(match[1])
+		words := strings.Fields(term)
+		key := strings.ToLower(term)
+		if len(words) == 0 || len(words) > 4 || notTerms[strings.ToLower(words[0])] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		flashcards = append(flashcards, domain.Flashcard{
+			KeyTerm:    term,
+			Definition: shorten(capitalize(strings.TrimRight(match[2], ".!? ")), stubMaxStatementRunes),
+		})
+		if len(flashcards) == stubFlashcards {
+			break
+		}
+	}
+	return flashcards
+}
+
+var wordPattern = regexp.MustCompile(`\p{L}+`)
+
+// stubLongWords lists the long words of the text in order of their first
+// appearance. They are the answers and wrong options of questions.
+func stubLongWords(chapters []stubChapter) []string {
+	var words []string
+	seen := map[string]bool{}
+	for _, chapter := range chapters {
+		for _, sentence := range chapter.sentences {
+			for _, word := range wordPattern.FindAllString(sentence, -1) {
+				key := strings.ToLower(word)
+				if utf8.RuneCountInString(word) >= 7 && !seen[key] {
+					seen[key] = true
+					words = append(words, key)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// stubQuestionSentences are the sentences long enough to ask about
+func stubQuestionSentences(sentences []string) []string {
+	var candidates []string
+	for _, sentence := range sentences {
+		if words := len(strings.Fields(sentence)); words >= 6 && words <= 40 && prose(sentence) {
+			candidates = append(candidates, sentence)
+		}
+	}
+	return candidates
+}
+
+// stubQuestion blanks out the longest word of a sentence and offers it
+// among the long words of the text closest to it in length
+func stubQuestion(sentence string, words []string, answer string) (domain.Question, bool) {
+	var blank []int
+	inSentence := map[string]bool{}
+	for _, loc := range wordPattern.FindAllStringIndex(sentence, -1) {
+		word := sentence[loc[0]:loc[1]]
+		inSentence[strings.ToLower(word)] = true
+		if utf8.RuneCountInString(word) >= 7 && (blank == nil || utf8.RuneCountInString(word) > blank[1]-blank[0]) {
+			blank = loc
+		}
+	}
+	if blank == nil {
+		return domain.Question{}, false
+	}
+	correct := strings.ToLower(sentence[blank[0]:blank[1]])
+
+	var distractors []string
+	for length := 0; length <= 20 && len(distractors) < 3; length++ {
+		for _, word := range words {
+			diff := utf8.RuneCountInString(word) - utf8.RuneCountInString(correct)
+			if (diff == length || diff == -length) && !inSentence[word] && len(distractors) < 3 {
+				distractors = append(distractors, word)
+			}
+		}
+	}
+	if len(distractors) < 3 {
+		return domain.Question{}, false
+	}
+
+	options := map[string]string{}
+	for _, letter := range []string{"A", "B", "C", "D"} {
+		if letter == answer {
+			options[letter] = correct
+		} else {
+			options[letter], distractors = distractors[0], distractors[1:]
+		}
+	}
+	return domain.Question{
+		Question:    "Fill in the blank: " + sentence[:blank[0]] + "_____" + sentence[blank[1]:],
+		Options:     domain.QuestionOption{A: options["A"], B: options["B"], C: options["C"], D: options["D"]},
+		Answer:      answer,
+		Explanation: `The text says: "` + shorten(sentence, stubMaxStatementRunes) + `"`,
+	}, true
+}
+
+// prose tells sentences from code, tables of contents and other text that
+// is mostly symbols
+func prose(sentence string) bool {
+	letters := 0
+	for _, r := range sentence {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			letters++
+		}
+	}
+	return letters*10 >= utf8.RuneCountInString(sentence)*9
+}
+
+// spread picks up to n items spaced evenly over items, keeping their order
+func spread(items []string, n int) []string {
+	if len(items) <= n {
+		return items
+	}
+	picked := make([]string, n)
+	for i := range picked {
+		picked[i] = items[i*len(items)/n]
+	}
+	return picked
+}
+
+// shorten cuts s at a word boundary to at most limit runes
+func shorten(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	cut := string([]rune(s)[:limit])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:") + "..."
+}
+
+func capitalize(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(first)) + s[size:]
+}
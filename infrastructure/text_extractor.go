@@ -0,0 +1,300 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	domain "cognivia-api/Domain"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// errNoText is returned for files that contain no extractable text, such as
+// scanned PDFs without a text layer
+var errNoText = errors.New("the file contains no extractable text")
+
+type textExtractor struct{}
+
+// NewTextExtractor returns an extractor for PDF, DOCX, HTML, Markdown and
+// plain text files
+func NewTextExtractor() domain.TextExtractor {
+	return textExtractor{}
+}
+
+// Extract gives up at deadline on PDFs, the only files whose cost is not
+// bound by their size
+func (textExtractor) Extract(contentType string, content []byte, deadline time.Time) ([]domain.TextChapter, error) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+
+	var blocks []textBlock
+	var err error
+	switch strings.TrimSpace(mediaType) {
+	case "application/pdf":
+		blocks, err = pdfBlocks(content, deadline)
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		blocks, err = docxBlocks(content)
+	case "text/html":
+		blocks, err = htmlBlocks(content)
+	case "text/markdown":
+		blocks = markdownBlocks(string(content))
+	case "text/plain":
+		blocks = plainTextBlocks(string(content))
+	default:
+		return nil, fmt.Errorf("no text extraction for %s files", mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chapters := chaptersFromBlocks(blocks)
+	if len(chapters) == 0 {
+		return nil, errNoText
+	}
+	return chapters, nil
+}
+
+// textBlock is a heading or a paragraph of a document in reading order
+type textBlock struct {
+	// level is 1 to 6 for headings and 0 for body text
+	level int
+	text  string
+	// preformatted blocks keep their line breaks and spacing
+	preformatted bool
+	// listItem blocks are kept on consecutive lines instead of separate
+	// paragraphs
+	listItem bool
+}
+
+// chaptersFromBlocks starts a new chapter at every heading of the level that
+// splits the document, the highest level used more than once or else the
+// highest level used at all, and at the rarer headings above it such as a
+// document title. Lower headings stay in the chapter text. Chapters without
+// text are dropped.
+func chaptersFromBlocks(blocks []textBlock) []domain.TextChapter {
+	counts := map[int]int{}
+	for i := range blocks {
+		if blocks[i].preformatted {
+			blocks[i].text = normalizePreformatted(blocks[i].text)
+		} else {
+			blocks[i].text = normalizeParagraph(blocks[i].text)
+		}
+		if blocks[i].level > 0 && blocks[i].text != "" {
+			counts[blocks[i].level]++
+		}
+	}
+	splitLevel := 0
+	for level := 6; level >= 1; level-- {
+		if counts[level] > 0 {
+			splitLevel = level
+		}
+	}
+	for level := 1; level <= 6; level++ {
+		if counts[level] > 1 {
+			splitLevel = level
+			break
+		}
+	}
+
+	var chapters []domain.TextChapter
+	var current *domain.TextChapter
+	var text strings.Builder
+	previousItem := false
+	flush := func() {
+		if current != nil && text.Len() > 0 {
+			current.Text = text.String()
+			chapters = append(chapters, *current)
+		}
+		text.Reset()
+		previousItem = false
+	}
+
+	for _, block := range blocks {
+		if block.text == "" {
+			continue
+		}
+		if block.level > 0 && block.level <= splitLevel {
+			flush()
+			current = &domain.TextChapter{Title: block.text}
+			continue
+		}
+		if current == nil {
+			current = &domain.TextChapter{}
+		}
+		if text.Len() > 0 {
+			if block.listItem && previousItem {
+				text.WriteString("\n")
+			} else {
+				text.WriteString("\n\n")
+			}
+		}
+		text.WriteString(block.text)
+		previousItem = block.listItem
+	}
+	flush()
+	return chapters
+}
+
+// normalizeParagraph applies NFKC, which also splits ligatures such as "ﬁ",
+// drops invisible characters and collapses all whitespace to single spaces
+func normalizeParagraph(s string) string {
+	return strings.Join(strings.Fields(cleanCharacters(s)), " ")
+}
+
+// normalizePreformatted is normalizeParagraph for code and other text whose
+// line breaks and indentation matter
+func normalizePreformatted(s string) string {
+	lines := strings.Split(cleanCharacters(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func cleanCharacters(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = norm.NFKC.String(s)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case r == '\u00ad', r == '\ufeff', r == '\ufffd', r >= '\u200b' && r <= '\u200f':
+			// Soft hyphens, byte order marks, replacement characters and
+			// zero-width characters
+			return -1
+		case unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// plainTextBlocks treats blank lines as paragraph breaks
+func plainTextBlocks(content string) []textBlock {
+	var blocks []textBlock
+	for _, paragraph := range regexp.MustCompile(`\n[ \t]*\n`).Split(strings.ReplaceAll(content, "\r\n", "\n"), -1) {
+		blocks = append(blocks, textBlock{text: paragraph})
+	}
+	return blocks
+}
+
+var (
+	markdownATXHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	markdownSetextLine  = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	markdownFence       = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	markdownListItem    = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d+[.)])[ \t]+(.*)$`)
+	markdownRule        = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	markdownReference   = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s+\S+`)
+	markdownImage       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink        = regexp.MustCompile(`\[([^\]]+)\](?:\([^)]*\)|\[[^\]]*\])`)
+	markdownCode        = regexp.MustCompile("`+([^`]+)`+")
+	markdownEmphasis    = regexp.MustCompile(`(\*{1,3}|\b_{1,3})([^*_\n]+?)(\*{1,3}|_{1,3}\b)`)
+	markdownStrike      = regexp.MustCompile(`~~([^~]+)~~`)
+	markdownHTMLTag     = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	markdownAutolink    = regexp.MustCompile(`<((?:https?|mailto):[^>]+)>`)
+	markdownTableBorder = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-{3,}:?[ \t]*(\|[ \t]*:?-{3,}:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// markdownBlocks reads the block structure of CommonMark closely enough for
+// text extraction: ATX and setext headings, paragraphs, lists, block quotes,
+// fenced code and tables. Inline markup is reduced to its text.
+func markdownBlocks(content string) []textBlock {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	// YAML front matter
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if end := strings.TrimSpace(lines[i]); end == "---" || end == "..." {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	var blocks []textBlock
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, textBlock{text: markdownInline(strings.Join(paragraph, " "))})
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// Block quotes are read as their content
+		for {
+			trimmed := strings.TrimLeft(line, " ")
+			if !strings.HasPrefix(trimmed, ">") {
+				break
+			}
+			line = strings.TrimPrefix(strings.TrimPrefix(trimmed, ">"), " ")
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case markdownFence.MatchString(line):
+			flush()
+			fence := markdownFence.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimLeft(lines[i], " "), fence); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, textBlock{text: strings.Join(code, "\n"), preformatted: true})
+		case markdownATXHeading.MatchString(line):
+			flush()
+			match := markdownATXHeading.FindStringSubmatch(line)
+			blocks = append(blocks, textBlock{level: len(match[1]), text: markdownInline(match[2])})
+		case len(paragraph) > 0 && markdownSetextLine.MatchString(line):
+			level := 1
+			if strings.Contains(line, "-") {
+				level = 2
+			}
+			blocks = append(blocks, textBlock{level: level, text: markdownInline(strings.Join(paragraph, " "))})
+			paragraph = nil
+		case markdownRule.MatchString(line), markdownReference.MatchString(line), markdownTableBorder.MatchString(line):
+			flush()
+		case markdownListItem.MatchString(line):
+			flush()
+			item := markdownListItem.FindStringSubmatch(line)[1]
+			blocks = append(blocks, textBlock{text: "- " + markdownInline(item), listItem: true})
+		case strings.HasPrefix(strings.TrimSpace(line), "|"):
+			flush()
+			cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+			for j, cell := range cells {
+				cells[j] = strings.TrimSpace(markdownInline(cell))
+			}
+			blocks = append(blocks, textBlock{text: strings.Join(cells, " | "), listItem: true})
+		default:
+			// Continuation lines of a list item belong to it
+			if len(paragraph) == 0 && len(blocks) > 0 && blocks[len(blocks)-1].listItem &&
+				(strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && i > 0 && strings.TrimSpace(lines[i-1]) != "" {
+				blocks[len(blocks)-1].text += " " + markdownInline(line)
+				continue
+			}
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+	flush()
+	return blocks
+}
+
+func markdownInline(s string) string {
+	s = markdownImage.ReplaceAllString(s, "$1")
+	s = markdownLink.ReplaceAllString(s, "$1")
+	s = markdownAutolink.ReplaceAllString(s, "$1")
+	s = markdownCode.ReplaceAllString(s, "$1")
+	s = markdownHTMLTag.ReplaceAllString(s, "")
+	s = markdownStrike.ReplaceAllString(s, "$1")
+	// Nested emphasis needs more than one pass
+	for range 3 {
+		s = markdownEmphasis.ReplaceAllString(s, "$2")
+	}
+	return strings.NewReplacer(`\*`, "*", `\_`, "_", `\#`, "#", `\[`, "[", `\]`, "]", `\\`, `\`, "\\`", "`").Replace(s)
+}
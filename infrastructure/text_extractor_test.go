@@ -0,0 +1,557 @@
+package infrastructure
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	domain "cognivia-api/Domain"
+)
+
+// pdfTextLine is a line of text placed on a test PDF page
+type pdfTextLine struct {
+	bold bool
+	size float64
+	x, y float64
+	text string
+}
+
+// buildPDF writes a PDF with a page per entry of pages, set in Helvetica.
+// Content streams are compressed when compress is set.
+func buildPDF(pages [][]pdfTextLine, compress bool) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	object := func(num int, body string) {
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+
+	kids := []string{}
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object(4, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range pages {
+		var content strings.Builder
+		for _, line := range lines {
+			font := "F1"
+			if line.bold {
+				font = "F2"
+			}
+			text := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line.text)
+			fmt.Fprintf(&content, "BT /%s %g Tf %g %g Td (%s) Tj ET\n", font, line.size, line.x, line.y, text)
+		}
+		stream, filter := []byte(content.String()), ""
+		if compress {
+			var compressed bytes.Buffer
+			w := zlib.NewWriter(&compressed)
+			w.Write(stream)
+			w.Close()
+			stream, filter = compressed.Bytes(), " /Filter /FlateDecode"
+		}
+
+		object(5+2*i, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", 6+2*i))
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d%s >>\nstream\n", 6+2*i, len(stream), filter)
+		out.Write(stream)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+	out.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return out.Bytes()
+}
+
+// lecturePDF is three pages of lecture notes with a running header, page
+// numbers in the footer, a title, a heading per page, wrapped and
+// hyphenated paragraphs and a bulleted list
+func lecturePDF(compress bool) []byte {
+	furniture := func(page int) []pdfTextLine {
+		return []pdfTextLine{
+			{size: 9, x: 72, y: 760, text: "BIO 101 - Lecture notes, spring 2026"},
+			{size: 9, x: 280, y: 40, text: fmt.Sprintf("Page %d of 3", page)},
+		}
+	}
+	return buildPDF([][]pdfTextLine{
+		append(furniture(1),
+			pdfTextLine{bold: true, size: 22, x: 72, y: 700, text: "Introduction to Biology"},
+			pdfTextLine{bold: true, size: 16, x: 72, y: 660, text: "Cells"},
+			pdfTextLine{size: 11, x: 72, y: 630, text: "Cells are the basic unit of life. Every living"},
+			pdfTextLine{size: 11, x: 72, y: 616, text: "thing is made of one or more cells, and the mito-"},
+			pdfTextLine{size: 11, x: 72, y: 602, text: "chondria in them turn food into energy that"},
+			pdfTextLine{size: 11, x: 72, y: 588, text: "the cell can use."},
+			pdfTextLine{size: 11, x: 72, y: 560, text: "A second paragraph starts after a wider"},
+			pdfTextLine{size: 11, x: 72, y: 546, text: "gap."},
+		),
+		append(furniture(2),
+			pdfTextLine{bold: true, size: 16, x: 72, y: 700, text: "Membranes"},
+			pdfTextLine{size: 11, x: 72, y: 670, text: "The membrane separates the cell from its"},
+			pdfTextLine{size: 11, x: 72, y: 656, text: "surroundings."},
+			pdfTextLine{size: 11, x: 72, y: 626, text: "\x95 lipids"},
+			pdfTextLine{size: 11, x: 72, y: 612, text: "\x95 proteins"},
+		),
+		append(furniture(3),
+			pdfTextLine{bold: true, size: 16, x: 72, y: 700, text: "Energy"},
+			pdfTextLine{size: 11, x: 72, y: 670, text: "Cells turn glucose into ATP, which"},
+			pdfTextLine{size: 11, x: 72, y: 656, text: "stores energy for later."},
+		),
+	}, compress)
+}
+
+var lectureChapters = []domain.TextChapter{
+	{Title: "Cells", Text: "Cells are the basic unit of life. Every living thing is made of one or more cells, and the mitochondria " +
+		"in them turn food into energy that the cell can use.\n\nA second paragraph starts after a wider gap."},
+	{Title: "Membranes", Text: "The membrane separates the cell from its surroundings.\n\n- lipids\n- proteins"},
+	{Title: "Energy", Text: "Cells turn glucose into ATP, which stores energy for later."},
+}
+
+// buildDOCX zips a Word document from its document and styles parts and a
+// page header, which extraction leaves out
+func buildDOCX(document, styles string) []byte {
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"word/document.xml":   document,
+		"word/styles.xml":     styles,
+		"word/header1.xml":    `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>CONFIDENTIAL</w:t></w:r></w:p></w:hdr>`,
+		"word/footer1.xml":    `<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>Page 1</w:t></w:r></w:p></w:ftr>`,
+	} {
+		if content == "" {
+			continue
+		}
+		w, _ := archive.Create(name)
+		w.Write([]byte(content))
+	}
+	archive.Close()
+	return out.Bytes()
+}
+
+const docxNamespace = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+
+// docxParagraph is a paragraph with the given paragraph properties
+func docxParagraph(properties, text string) string {
+	return `<w:p><w:pPr>` + properties + `</w:pPr><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`
+}
+
+// geneticsDOCX uses localized style IDs, so headings are only recognized
+// by their style names
+var geneticsDOCX = buildDOCX(
+	`<w:document `+docxNamespace+`><w:body>`+
+		docxParagraph(`<w:pStyle w:val="Titel"/>`, "Genetics")+
+		docxParagraph(`<w:pStyle w:val="berschrift1"/>`, "DNA")+
+		docxParagraph(``, "DNA stores the genetic information.")+
+		docxParagraph(`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`, "adenine")+
+		docxParagraph(`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`, "thymine")+
+		docxParagraph(`<w:pStyle w:val="berschrift2"/>`, "Replication")+
+		`<w:p><w:r><w:t>Copies are made</w:t><w:tab/><w:t>before division.</w:t><w:br/><w:t>Errors are rare.</w:t></w:r></w:p>`+
+		docxParagraph(`<w:outlineLvl w:val="0"/>`, "RNA")+
+		docxParagraph(``, "RNA carries the message.")+
+		`<w:sectPr><w:headerReference w:type="default" w:id="rId1"/></w:sectPr>`+
+		`</w:body></w:document>`,
+	`<w:styles `+docxNamespace+`>`+
+		`<w:style w:type="paragraph" w:styleId="Titel"><w:name w:val="Title"/></w:style>`+
+		`<w:style w:type="paragraph" w:styleId="berschrift1"><w:name w:val="heading 1"/></w:style>`+
+		`<w:style w:type="paragraph" w:styleId="berschrift2"><w:name w:val="heading 2"/></w:style>`+
+		`</w:styles>`,
+)
+
+var geneticsChapters = []domain.TextChapter{
+	{Title: "DNA", Text: "DNA stores the genetic information.\n\n- adenine\n- thymine\n\n" +
+		"Replication\n\nCopies are made before division. Errors are rare."},
+	{Title: "RNA", Text: "RNA carries the message."},
+}
+
+const ecologyHTML = `<!DOCTYPE html>
+<html>
+<head><title>Ecology</title><style>h2 { color: green; }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/courses">Courses</a></nav>
+<h1>Ecology</h1>
+<h2>Ecosystems</h2>
+<p>An ecosystem is a community of organisms
+   and their environment.</p>
+<script>trackPageView();</script>
+<ul><li>producers</li><li>consumers</li></ul>
+<h2>Food webs</h2>
+<p>Energy flows from <em>producers</em> to consumers.<br>Most is lost as heat.</p>
+<pre>sun -> grass
+    -> rabbit</pre>
+<table><tr><td>Level</td><td>Energy</td></tr></table>
+<img src="web.png" alt="A food web">
+</body>
+</html>`
+
+var ecologyChapters = []domain.TextChapter{
+	{Title: "Ecosystems", Text: "An ecosystem is a community of organisms and their environment.\n\n- producers\n- consumers"},
+	{Title: "Food webs", Text: "Energy flows from producers to consumers. Most is lost as heat.\n\n" +
+		"sun -> grass\n    -> rabbit\n\nLevel Energy\n\nA food web"},
+}
+
+const chemistryMarkdown = `---
+title: Chemistry
+---
+Chemistry
+=========
+
+Atoms
+-----
+
+Atoms are made of **protons**, _neutrons_ and [electrons](https://example.com).
+
+* nucleus
+* shell
+  continued
+
+Bonds
+-----
+
+| Bond | Strength |
+| ---- | -------- |
+| covalent | strong |
+
+` + "```" + `
+H2 + O2 -> H2O
+` + "```" + `
+
+### Details
+> Ionic bonds form between ions.
+`
+
+var chemistryChapters = []domain.TextChapter{
+	{Title: "Atoms", Text: "Atoms are made of protons, neutrons and electrons.\n\n- nucleus\n- shell continued"},
+	{Title: "Bonds", Text: "Bond | Strength\ncovalent | strong\n\nH2 + O2 -> H2O\n\nDetails\n\nIonic bonds form between ions."},
+}
+
+func TestExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name, contentType string
+		content           []byte
+		want              []domain.TextChapter
+	}{
+		{"pdf", "application/pdf", lecturePDF(false), lectureChapters},
+		{"compressed pdf", "application/pdf", lecturePDF(true), lectureChapters},
+		{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", geneticsDOCX, geneticsChapters},
+		{"html", "text/html; charset=utf-8", []byte(ecologyHTML), ecologyChapters},
+		{"markdown", "text/markdown", []byte(chemistryMarkdown), chemistryChapters},
+		{"plain text", "text/plain", []byte("First paragraph\nstill first.\n\n  \nSecond."), []domain.TextChapter{
+			{Text: "First paragraph still first.\n\nSecond."},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewTextExtractor().Extract(tc.contentType, tc.content, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractFailures(t *testing.T) {
+	extractor := NewTextExtractor()
+
+	if _, err := extractor.Extract("image/png", []byte("\x89PNG"), time.Time{}); err == nil || !strings.Contains(err.Error(), "image/png") {
+		t.Errorf("unsupported type: got %v", err)
+	}
+	// A scanned page has no text layer
+	scanned := buildPDF([][]pdfTextLine{{}}, false)
+	if _, err := extractor.Extract("application/pdf", scanned, time.Time{}); !errors.Is(err, errNoText) {
+		t.Errorf("scanned PDF: got %v", err)
+	}
+	if _, err := extractor.Extract("text/markdown", []byte("# Title\n\n---\n"), time.Time{}); !errors.Is(err, errNoText) {
+		t.Errorf("empty markdown: got %v", err)
+	}
+	if _, err := extractor.Extract("application/vnd.openxmlformats-officedocument.wordprocessingml.document", []byte("PK not a zip"), time.Time{}); err == nil {
+		t.Error("broken DOCX accepted")
+	}
+	if _, err := extractor.Extract("application/vnd.openxmlformats-officedocument.wordprocessingml.document", buildDOCX("", ""), time.Time{}); err == nil {
+		t.Error("DOCX without a document accepted")
+	}
+}
+
+func TestMalformedPDF(t *testing.T) {
+	pdf := lecturePDF(false)
+	firstPage := bytes.Index(pdf, []byte("5 0 obj"))
+	lastStream := bytes.LastIndex(pdf, []byte("stores energy"))
+
+	for name, content := range map[string][]byte{
+		"empty":                  nil,
+		"not a pdf":              []byte("<html><body>Cells</body></html>"),
+		"header only":            []byte("%PDF-1.7\n"),
+		"cut before first page":  pdf[:firstPage],
+		"cut inside page object": pdf[:firstPage+20],
+		"garbage objects":        []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 9 0 R >>\nendobj\n2 0 obj\n[[[[\n"),
+		"deep nesting":           []byte("%PDF-1.4\n1 0 obj\n" + strings.Repeat("[", 100000) + "\nendobj\n"),
+	} {
+		if _, err := pdfBlocks(content, time.Time{}); !errors.Is(err, errMalformedPDF) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	// Text before the cut is kept
+	blocks, err := pdfBlocks(pdf[:lastStream], time.Time{})
+	if err != nil {
+		t.Fatalf("cut inside the last page: %v", err)
+	}
+	if last := blocks[len(blocks)-1]; last.text != "Cells turn glucose into ATP, which" {
+		t.Errorf("cut inside the last page: ends with %q", last.text)
+	}
+
+	encrypted := append(bytes.Clone(pdf), []byte("trailer\n<< /Encrypt 1 0 R >>\n")...)
+	if _, err := pdfBlocks(encrypted, time.Time{}); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("encrypted: got %v", err)
+	}
+}
+
+// objectsPDF writes a PDF whose objects are numbered from 1 in order. A
+// stream follows its dictionary after a NUL byte.
+func objectsPDF(objects ...string) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n")
+	for i, object := range objects {
+		if dict, stream, ok := strings.Cut(object, "\x00"); ok {
+			end := strings.LastIndex(dict, ">>")
+			object = fmt.Sprintf("%s/Length %d >>\nstream\n%s\nendstream", dict[:end], len(stream), stream)
+		}
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	out.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return out.Bytes()
+}
+
+func deflate(data string) string {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write([]byte(data))
+	w.Close()
+	return compressed.String()
+}
+
+// selfAmplifyingPDF has a form showing itself 16 times, which nested 8
+// levels deep makes 16^8 forms
+func selfAmplifyingPDF() []byte {
+	return objectsPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /X 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /XObject /Subtype /Form /Resources << /XObject << /X 4 0 R >> /Font << /F1 6 0 R >> >> >>\x00"+
+			"BT /F1 12 Tf 72 700 Td (Again) Tj ET "+strings.Repeat("/X Do ", 16),
+		"<< >>\x00/X Do",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+}
+
+func TestPDFOperationLimit(t *testing.T) {
+	doc, err := parsePDF(selfAmplifyingPDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.operations = 100000
+	if _, err := doc.blocks(); !errors.Is(err, errPDFTooComplex) {
+		t.Errorf("got %v", err)
+	}
+	if doc.operations > 0 {
+		t.Errorf("stopped with %d operations left", doc.operations)
+	}
+
+	// Ahead of the operation limit, the deadline stops it
+	start := time.Now()
+	if _, err := pdfBlocks(selfAmplifyingPDF(), start.Add(50*time.Millisecond)); !errors.Is(err, errPDFTimeout) {
+		t.Errorf("got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stopped after %v", elapsed)
+	}
+}
+
+func TestPDFDecodeBudget(t *testing.T) {
+	// A megabyte of spaces compresses to about a kilobyte
+	padding, text := strings.Repeat(" ", 1<<20), "BT /F1 12 Tf 72 700 Td (Cells) Tj ET"
+	page := deflate(padding + text)
+	repeated := objectsPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents ["+strings.Repeat("4 0 R ", 100)+"] >>",
+		"<< /Filter /FlateDecode >>\x00"+page,
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+
+	// A stream listed many times is decoded once
+	doc, err := parsePDF(repeated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := doc.blocks()
+	if err != nil || len(blocks) == 0 || !strings.HasPrefix(blocks[0].text, "Cells") {
+		t.Fatalf("got %v, %v", blocks, err)
+	}
+	if used := maxPDFDecodedSize - doc.decodeBudget; used != len(padding)+len(text) {
+		t.Errorf("decoded %d bytes", used)
+	}
+
+	// Streams decoding to more than the budget stop the extraction
+	distinct := objectsPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [4 0 R 5 0 R 6 0 R] >>",
+		"<< /Filter /FlateDecode >>\x00"+page,
+		"<< /Filter /FlateDecode >>\x00"+deflate(padding),
+		"<< /Filter /FlateDecode >>\x00"+deflate(padding+" "),
+	)
+	doc, err = parsePDF(distinct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.decodeBudget = 2 << 20
+	if _, err := doc.blocks(); !errors.Is(err, errPDFTooLarge) {
+		t.Errorf("got %v", err)
+	}
+	if doc.decodeBudget < 0 {
+		t.Errorf("budget overdrawn to %d", doc.decodeBudget)
+	}
+}
+
+func TestPDFHeadings(t *testing.T) {
+	line := func(bold bool, size, y float64, text string) pdfTextLine {
+		return pdfTextLine{bold: bold, size: size, x: 72, y: y, text: text}
+	}
+	for _, tc := range []struct {
+		name  string
+		lines []pdfTextLine
+		want  []textBlock
+	}{
+		{
+			"larger headings ranked by size",
+			[]pdfTextLine{line(false, 20, 700, "Part"), line(false, 14, 670, "Section"), line(false, 11, 640, "Body text of the section.")},
+			[]textBlock{{level: 1, text: "Part"}, {level: 2, text: "Section"}, {text: "Body text of the section."}},
+		},
+		{
+			"bold lines at body size",
+			[]pdfTextLine{line(true, 11, 700, "Summary"), line(false, 11, 680, "Body text of the summary.")},
+			[]textBlock{{level: 1, text: "Summary"}, {text: "Body text of the summary."}},
+		},
+		{
+			"bold body has no bold headings",
+			[]pdfTextLine{line(true, 11, 700, "Summary"), line(true, 11, 680, "Body text set in bold.")},
+			[]textBlock{{text: "Summary Body text set in bold."}},
+		},
+		{
+			"wrapped heading",
+			[]pdfTextLine{line(false, 18, 700, "A heading long"), line(false, 18, 680, "enough to wrap"), line(false, 11, 650, "Body text below the long heading.")},
+			[]textBlock{{level: 1, text: "A heading long enough to wrap"}, {text: "Body text below the long heading."}},
+		},
+		{
+			"figures are no headings",
+			[]pdfTextLine{
+				line(false, 11, 700, "Body text before the figure."), line(false, 11, 686, "More body text."),
+				line(false, 18, 660, "3.14"),
+				line(false, 11, 630, "Body text after the figure."), line(false, 11, 616, "The end."),
+			},
+			[]textBlock{{text: "Body text before the figure. More body text."}, {text: "3.14"}, {text: "Body text after the figure. The end."}},
+		},
+		{
+			"lone page numbers",
+			[]pdfTextLine{line(false, 11, 700, "Body text of the page."), line(false, 11, 40, "7")},
+			[]textBlock{{text: "Body text of the page."}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := pdfBlocks(buildPDF([][]pdfTextLine{tc.lines}, false), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChaptersFromBlocks(t *testing.T) {
+	heading := func(level int, text string) textBlock { return textBlock{level: level, text: text} }
+	body := func(text string) textBlock { return textBlock{text: text} }
+
+	for _, tc := range []struct {
+		name   string
+		blocks []textBlock
+		want   []domain.TextChapter
+	}{
+		{
+			"splits at the highest level used more than once",
+			[]textBlock{heading(1, "Book"), heading(2, "One"), body("a"), heading(3, "Detail"), body("b"), heading(2, "Two"), body("c")},
+			[]domain.TextChapter{{Title: "One", Text: "a\n\nDetail\n\nb"}, {Title: "Two", Text: "c"}},
+		},
+		{
+			"rarer headings above the split level start chapters",
+			[]textBlock{heading(1, "Preface"), body("p"), heading(2, "One"), body("a"), heading(2, "Two"), body("b")},
+			[]domain.TextChapter{{Title: "Preface", Text: "p"}, {Title: "One", Text: "a"}, {Title: "Two", Text: "b"}},
+		},
+		{
+			"a single heading",
+			[]textBlock{body("intro"), heading(3, "Only"), body("a")},
+			[]domain.TextChapter{{Text: "intro"}, {Title: "Only", Text: "a"}},
+		},
+		{
+			"no headings",
+			[]textBlock{body("a"), body("b")},
+			[]domain.TextChapter{{Text: "a\n\nb"}},
+		},
+		{
+			"empty chapters and blocks are dropped",
+			[]textBlock{heading(2, "Empty"), heading(2, "  "), body(" \u200b "), heading(2, "Full"), body("a")},
+			[]domain.TextChapter{{Title: "Full", Text: "a"}},
+		},
+		{
+			"list items stay on consecutive lines",
+			[]textBlock{body("List:"), {text: "- a", listItem: true}, {text: "- b", listItem: true}, body("after")},
+			[]domain.TextChapter{{Text: "List:\n\n- a\n- b\n\nafter"}},
+		},
+		{
+			"normalized text",
+			[]textBlock{heading(1, "\ufb01rst\tsteps"), body("co\u00adoperate  \n with\ufeff \uff21\uff22\uff23"), {text: "  code\n\tindented  \n", preformatted: true}},
+			[]domain.TextChapter{{Title: "first steps", Text: "cooperate with ABC\n\n  code\n indented"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := chaptersFromBlocks(tc.blocks); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func FuzzPDFBlocks(f *testing.F) {
+	pdf := lecturePDF(false)
+	f.Add(pdf)
+	f.Add(lecturePDF(true))
+	f.Add(pdf[:len(pdf)/2])
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Page /Contents 2 0 R >>\nendobj\n2 0 obj\n<< /Length 99 >>\nstream\nBT (x) Tj ET"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 >>\nstream\n2 0 << /Type /Page >>\nendstream\nendobj\n"))
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		blocks, err := pdfBlocks(content, time.Now().Add(time.Second))
+		if err != nil {
+			if !errors.Is(err, errMalformedPDF) && !errors.Is(err, errPDFTooLarge) && !errors.Is(err, errPDFTooComplex) &&
+				!errors.Is(err, errPDFTimeout) && !strings.Contains(err.Error(), "encrypted") {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+		for _, block := range blocks {
+			if !utf8.ValidString(block.text) || block.level < 0 || block.level > 6 {
+				t.Fatalf("invalid block %+v", block)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package infrastructure
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+
+	// The RFC lists 8 digit codes, 6 digit codes are their last digits
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		if got := totpCode(key, tc.unix/totpPeriod); got != tc.code {
+			t.Errorf("at %d: got %s, want %s", tc.unix, got, tc.code)
+		}
+		if step, ok := ValidateTOTP(rfc6238Secret, tc.code, time.Unix(tc.unix, 0)); !ok || step != tc.unix/totpPeriod {
+			t.Errorf("at %d: %s validated as step %d, %v", tc.unix, tc.code, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// 081804 is the code of step 37037036 (1111111080 to 1111111109)
+	const code = "081804"
+	const step = 37037036
+
+	for _, tc := range []struct {
+		name string
+		unix int64
+		ok   bool
+	}{
+		{"first second of its step", 1111111080, true},
+		{"last second of its step", 1111111109, true},
+		{"one step late", 1111111111, true},
+		{"end of the next step", 1111111139, true},
+		{"two steps late", 1111111140, false},
+		{"one step early", 1111111079, true},
+		{"start of the step before", 1111111050, true},
+		{"two steps early", 1111111049, false},
+	} {
+		got, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(tc.unix, 0))
+		if ok != tc.ok || (ok && got != step) {
+			t.Errorf("%s: got step %d, %v", tc.name, got, ok)
+		}
+	}
+
+	at := time.Unix(1111111100, 0)
+	if _, ok := ValidateTOTP(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq ", code, at); !ok {
+		t.Error("lower-case secret with spaces rejected")
+	}
+	for name, tc := range map[string]struct{ secret, code string }{
+		"wrong code":       {rfc6238Secret, "081805"},
+		"eight digits":     {rfc6238Secret, "07081804"},
+		"five digits":      {rfc6238Secret, "81804"},
+		"empty code":       {rfc6238Secret, ""},
+		"other secret":     {"JBSWY3DPEHPK3PXP", code},
+		"malformed secret": {"not base32!", code},
+		"empty secret":     {"", code},
+	} {
+		if _, ok := ValidateTOTP(tc.secret, tc.code, at); ok {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Cognivia", "ada@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := uri.Query()
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Cognivia:ada@example.com" ||
+		query.Get("secret") != rfc6238Secret || query.Get("issuer") != "Cognivia" ||
+		query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("got %s", uri)
+	}
+}
+
+func TestGenerateTOTPSecretAndRecoveryCodes(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := totpEncoding.DecodeString(secret); err != nil || len(key) != 20 {
+		t.Errorf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[a-hjkmnp-z2-9]{5}-[a-hjkmnp-z2-9]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) || seen[code] {
+			t.Errorf("bad or repeated code %q", code)
+		}
+		seen[code] = true
+	}
+	if len(codes) != 10 {
+		t.Errorf("got %d codes", len(codes))
+	}
+}